@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
@@ -62,7 +64,52 @@ func cliTest(testName string) func(t *testing.T) {
 		objNamespace := "my-obj-namespace"
 		objName := "my-obj-name"
 
-		resolvedYAML, err := utils.ProcessTemplate(inputBytes, kcPath, clusterName, hubNS, objNamespace, objName)
+		var objLabels, objAnnotations map[string]string
+
+		if strings.Contains(testName, "object-labels") {
+			objLabels = map[string]string{"env": "prod", "app.kubernetes.io/part-of": "payments"}
+			objAnnotations = map[string]string{"owner": "platform@example.com"}
+		}
+
+		var objTemplateIndex *int
+
+		if strings.Contains(testName, "object-template-index") {
+			index := 1
+			objTemplateIndex = &index
+		}
+
+		defaultsPath := "testdata/test_" + testName + "/defaults.yaml"
+		if _, err := os.Stat(defaultsPath); err != nil {
+			defaultsPath = ""
+		}
+
+		valuesPath := "testdata/test_" + testName + "/values.yaml"
+		if _, err := os.Stat(valuesPath); err != nil {
+			valuesPath = ""
+		}
+
+		var outputTrailingNewline *bool
+
+		if strings.Contains(testName, "no-trailing-newline") {
+			trailingNewline := false
+			outputTrailingNewline = &trailingNewline
+		}
+
+		resolvedYAML, err := utils.ProcessTemplateWithOptions(inputBytes, utils.ProcessTemplateOptions{
+			HubKubeConfigPath:             kcPath,
+			ManagedKubeConfigPath:         kubeconfigPath,
+			ClusterName:                   clusterName,
+			HubNamespace:                  hubNS,
+			ObjectNamespace:               objNamespace,
+			ObjectName:                    objName,
+			ObjectLabels:                  objLabels,
+			ObjectAnnotations:             objAnnotations,
+			ResolveAllPolicyTemplateKinds: strings.Contains(testName, "all-kinds"),
+			ObjectTemplateIndex:           objTemplateIndex,
+			DefaultsPath:                  defaultsPath,
+			ValuesPath:                    valuesPath,
+			OutputTrailingNewline:         outputTrailingNewline,
+		})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -91,3 +138,57 @@ func cliTest(testName string) func(t *testing.T) {
 		}
 	}
 }
+
+func TestLintCLI(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args           []string
+		expectedOutput string
+		expectedErr    error
+	}{
+		"text": {
+			args: []string{"lint", "testdata/lint/input.yaml"},
+			expectedOutput: "16:68: [error] GTUL003 (strayClosingDelimiter): the }} delimiter does not have a " +
+				"matching {{ delimiter\n",
+			expectedErr: utils.ErrBlockingViolations,
+		},
+		"json": {
+			args:           []string{"lint", "--format", "json", "testdata/lint/input.yaml"},
+			expectedOutput: `"ruleName": "strayClosingDelimiter"`,
+			expectedErr:    utils.ErrBlockingViolations,
+		},
+		"sarif_custom_delimiters": {
+			args: []string{
+				"lint", "--format", "sarif", "--start-delim", "{{hub", "--stop-delim", "hub}}",
+				"testdata/lint/input.yaml",
+			},
+			expectedOutput: `"results": []`,
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResolver := utils.TemplateResolver{}
+			cmd := tmplResolver.GetCmd()
+
+			output := bytes.Buffer{}
+			cmd.SetOut(&output)
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs(test.args)
+
+			err := cmd.Execute()
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v, got: %v", test.expectedErr, err)
+			}
+
+			if !strings.Contains(output.String(), test.expectedOutput) {
+				t.Fatalf("expected the output to contain %q, got:\n%s", test.expectedOutput, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/stolostron/go-template-utils/v6/pkg/templates"
+)
+
+const (
+	hubStartDelim = "{{hub"
+	hubStopDelim  = "hub}}"
+)
+
+// AnalyzePolicyTemplates reports what resolving the templates in the input YAML requires without resolving them. The
+// returned hubFunctions and managedFunctions are the sorted unique names of the functions called in the hub and
+// managed cluster templates, and needsHubKubeconfig is true if there are any hub templates. For a Policy, only the
+// objectDefinition of each policy-templates entry is scanned. Any other YAML document, such as a ConfigurationPolicy,
+// is scanned as a whole. If the input contains multiple YAML documents separated by "---", the results are combined.
+func AnalyzePolicyTemplates(policy []byte) (
+	hubFunctions, managedFunctions []string, needsHubKubeconfig bool, err error,
+) {
+	return analyzePolicyTemplates(policy, nil)
+}
+
+// analyzePolicyTemplates is AnalyzePolicyTemplates with the scan limited to the parts of the input that
+// ProcessTemplateWithOptions resolves with the input options when they are set. In that case, the documents passed
+// through unchanged in a multiple document input are skipped, and so are the objectDefinitions in a Policy that are not
+// a ConfigurationPolicy or an OperatorPolicy unless opts.ResolveAllPolicyTemplateKinds is set. If
+// opts.ObjectTemplateIndex is set, only that entry of the object-templates of a ConfigurationPolicy is scanned.
+func analyzePolicyTemplates(policy []byte, opts *ProcessTemplateOptions) (
+	hubFunctions, managedFunctions []string, needsHubKubeconfig bool, err error,
+) {
+	hubFunctions = []string{}
+	managedFunctions = []string{}
+	documents := []map[string]interface{}{}
+	decoder := yaml.NewDecoder(bytes.NewReader(policy))
+
+	for {
+		var document map[string]interface{}
+
+		err := decoder.Decode(&document)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, nil, false, fmt.Errorf("failed to parse input to YAML: %w", err)
+		}
+
+		if document != nil {
+			documents = append(documents, document)
+		}
+	}
+
+	for i, document := range documents {
+		if opts != nil && len(documents) > 1 && !isSupportedDocument(unstructured.Unstructured{Object: document}) {
+			continue
+		}
+
+		scanned := []interface{}{document}
+
+		if kind, _ := document["kind"].(string); kind == "Policy" {
+			scanned, err = policyObjectDefinitions(document, opts)
+			if err != nil {
+				return nil, nil, false, fmt.Errorf("%w (in the document at index %d)", err, i)
+			}
+		}
+
+		for _, value := range scanned {
+			if opts != nil && opts.ObjectTemplateIndex != nil {
+				value = selectObjectTemplate(value, *opts.ObjectTemplateIndex)
+			}
+
+			for _, str := range collectStrings(value, nil) {
+				hubScan := templates.ScanTemplates([]byte(str), hubStartDelim, hubStopDelim)
+
+				// Remove the hub templates so that their start delimiters aren't considered managed cluster templates
+				for _, action := range hubScan.Actions {
+					needsHubKubeconfig = true
+					str = strings.Replace(str, action.Text, "", 1)
+				}
+
+				managedScan := templates.ScanTemplates([]byte(str), "", "")
+
+				hubFunctions = appendUnique(hubFunctions, hubScan.Functions)
+				managedFunctions = appendUnique(managedFunctions, managedScan.Functions)
+			}
+		}
+	}
+
+	slices.Sort(hubFunctions)
+	slices.Sort(managedFunctions)
+
+	return hubFunctions, managedFunctions, needsHubKubeconfig, nil
+}
+
+// policyObjectDefinitions returns the objectDefinition of each policy-templates entry in the input Policy. If opts is
+// set, only the objectDefinitions that ProcessTemplateWithOptions resolves with the options are returned.
+func policyObjectDefinitions(policy map[string]interface{}, opts *ProcessTemplateOptions) ([]interface{}, error) {
+	spec, _ := policy["spec"].(map[string]interface{})
+
+	policyTemplates, ok := spec["policy-templates"].([]interface{})
+	if !ok {
+		if _, set := spec["policy-templates"]; set {
+			return nil, fmt.Errorf("invalid policy-templates array was provided")
+		}
+
+		return []interface{}{}, nil
+	}
+
+	objectDefinitions := make([]interface{}, 0, len(policyTemplates))
+
+	for i, policyTemplate := range policyTemplates {
+		policyTemplateMap, ok := policyTemplate.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid policy-templates entry was provided (in policy-templates at index %d)", i)
+		}
+
+		if opts != nil && !opts.ResolveAllPolicyTemplateKinds {
+			objectDefinition, _ := policyTemplateMap["objectDefinition"].(map[string]interface{})
+
+			if !isResolvedPolicyTemplateKind(unstructured.Unstructured{Object: objectDefinition}) {
+				continue
+			}
+		}
+
+		objectDefinitions = append(objectDefinitions, policyTemplateMap["objectDefinition"])
+	}
+
+	return objectDefinitions, nil
+}
+
+// selectObjectTemplate returns a copy of the input ConfigurationPolicy with only the object-templates entry at the
+// index, matching what processObjectTemplates resolves. Other values and an out of range index are returned as is.
+func selectObjectTemplate(value interface{}, index int) interface{} {
+	objectDefinition, _ := value.(map[string]interface{})
+	if kind, _ := objectDefinition["kind"].(string); kind != "ConfigurationPolicy" {
+		return value
+	}
+
+	spec, _ := objectDefinition["spec"].(map[string]interface{})
+
+	objTemplates, _ := spec["object-templates"].([]interface{})
+	if index < 0 || index >= len(objTemplates) {
+		return value
+	}
+
+	selectedSpec := maps.Clone(spec)
+	selectedSpec["object-templates"] = objTemplates[index : index+1]
+
+	selected := maps.Clone(objectDefinition)
+	selected["spec"] = selectedSpec
+
+	return selected
+}
+
+// collectStrings appends the map keys and string values nested in the input value to strs and returns the result.
+func collectStrings(value interface{}, strs []string) []string {
+	switch typedValue := value.(type) {
+	case string:
+		strs = append(strs, typedValue)
+	case map[string]interface{}:
+		for key, nestedValue := range typedValue {
+			strs = append(strs, key)
+			strs = collectStrings(nestedValue, strs)
+		}
+	case []interface{}:
+		for _, nestedValue := range typedValue {
+			strs = collectStrings(nestedValue, strs)
+		}
+	}
+
+	return strs
+}
+
+// appendUnique appends the values in src that are not already in dst and returns the result.
+func appendUnique(dst []string, src []string) []string {
+	for _, value := range src {
+		if !slices.Contains(dst, value) {
+			dst = append(dst, value)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyzePolicyTemplates(t *testing.T) {
+	t.Parallel()
+
+	policy := `
+apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: '{{ notScanned }}'
+spec:
+  policy-templates:
+    - objectDefinition:
+        apiVersion: policy.open-cluster-management.io/v1
+        kind: ConfigurationPolicy
+        spec:
+          object-templates:
+            - objectDefinition:
+                data:
+                  hub: '{{hub fromConfigMap "policies" "cm" "key" | lower hub}}'
+                  managed: '{{ fromSecret "default" "s" "k" }}'
+                  both: '{{hub .ManagedClusterName hub}}-{{ lookup "v1" "Pod" "" "" }}'
+`
+
+	testcases := map[string]struct {
+		input            string
+		expectedHub      []string
+		expectedManaged  []string
+		expectedNeedsHub bool
+		expectedErr      bool
+	}{
+		"policy": {
+			policy, []string{"fromConfigMap", "lower"}, []string{"fromSecret", "lookup"}, true, false,
+		},
+		"hub_without_functions": {
+			"kind: ConfigurationPolicy\nspec:\n  name: '{{hub .ManagedClusterName hub}}'\n",
+			[]string{}, []string{}, true, false,
+		},
+		"multiple_documents": {
+			"kind: ConfigurationPolicy\nspec: '{{ toInt 1 }}'\n---\nkind: OperatorPolicy\nspec: '{{ b64enc \"a\" }}'\n",
+			[]string{}, []string{"b64enc", "toInt"}, false, false,
+		},
+		"no_templates":             {"kind: Policy\nspec:\n  disabled: false\n", []string{}, []string{}, false, false},
+		"invalid_yaml":             {"kind: [", nil, nil, false, true},
+		"invalid_policy_templates": {"kind: Policy\nspec:\n  policy-templates: 3\n", nil, nil, false, true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			hubFunctions, managedFunctions, needsHub, err := AnalyzePolicyTemplates([]byte(test.input))
+			if test.expectedErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !slices.Equal(hubFunctions, test.expectedHub) {
+				t.Fatalf("expected hub functions %v, got %v", test.expectedHub, hubFunctions)
+			}
+
+			if !slices.Equal(managedFunctions, test.expectedManaged) {
+				t.Fatalf("expected managed functions %v, got %v", test.expectedManaged, managedFunctions)
+			}
+
+			if needsHub != test.expectedNeedsHub {
+				t.Fatalf("expected needsHubKubeconfig to be %v, got %v", test.expectedNeedsHub, needsHub)
+			}
+		})
+	}
+}
+
+func TestAnalyzePolicyTemplatesResolvedParts(t *testing.T) {
+	t.Parallel()
+
+	policy := `
+apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+spec:
+  policy-templates:
+    - objectDefinition:
+        apiVersion: policy.open-cluster-management.io/v1
+        kind: ConfigurationPolicy
+        spec:
+          object-templates:
+            - objectDefinition:
+                data:
+                  hub: '{{hub fromConfigMap "policies" "cm" "key" hub}}'
+            - objectDefinition:
+                data:
+                  managed: '{{ fromSecret "default" "s" "k" }}'
+    - objectDefinition:
+        apiVersion: constraints.gatekeeper.sh/v1beta1
+        kind: K8sRequiredLabels
+        spec:
+          message: '{{hub lookup "v1" "Namespace" "" "" hub}}'
+`
+	passThrough := "kind: ConfigurationPolicy\nspec: '{{ toInt 1 }}'\n---\n" +
+		"kind: PlacementBinding\nplacementRef:\n  name: '{{hub .ManagedClusterName hub}}'\n"
+	index := 1
+
+	testcases := map[string]struct {
+		input            string
+		opts             ProcessTemplateOptions
+		expectedHub      []string
+		expectedManaged  []string
+		expectedNeedsHub bool
+	}{
+		"policy": {
+			policy, ProcessTemplateOptions{}, []string{"fromConfigMap"}, []string{"fromSecret"}, true,
+		},
+		"policy_all_kinds": {
+			policy, ProcessTemplateOptions{ResolveAllPolicyTemplateKinds: true},
+			[]string{"fromConfigMap", "lookup"}, []string{"fromSecret"}, true,
+		},
+		"policy_object_template_index": {
+			policy, ProcessTemplateOptions{ObjectTemplateIndex: &index}, []string{}, []string{"fromSecret"}, false,
+		},
+		"pass_through_document": {
+			passThrough, ProcessTemplateOptions{}, []string{}, []string{"toInt"}, false,
+		},
+		"single_document": {
+			"kind: PlacementBinding\nplacementRef:\n  name: '{{hub .ManagedClusterName hub}}'\n",
+			ProcessTemplateOptions{}, []string{}, []string{}, true,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			hubFunctions, managedFunctions, needsHub, err := analyzePolicyTemplates([]byte(test.input), &test.opts)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !slices.Equal(hubFunctions, test.expectedHub) {
+				t.Fatalf("expected hub functions %v, got %v", test.expectedHub, hubFunctions)
+			}
+
+			if !slices.Equal(managedFunctions, test.expectedManaged) {
+				t.Fatalf("expected managed functions %v, got %v", test.expectedManaged, managedFunctions)
+			}
+
+			if needsHub != test.expectedNeedsHub {
+				t.Fatalf("expected needsHubKubeconfig to be %v, got %v", test.expectedNeedsHub, needsHub)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// documentDefaults are the default values from a defaults file that are deep merged under each resolved document.
+type documentDefaults struct {
+	// all are the defaults from the document without a kind, which apply to every resolved document.
+	all map[string]interface{}
+	// byKind are the defaults from the documents with a kind, which only apply to resolved documents of that kind.
+	byKind map[string]map[string]interface{}
+}
+
+// loadDefaults parses the defaults file at the input path. Each YAML document in the file without a kind applies to
+// every resolved document and each YAML document with a kind applies to only resolved documents of that kind. Only
+// one document without a kind and one document per kind are allowed. A nil value is returned if the path is empty.
+func loadDefaults(path string) (*documentDefaults, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	defaultsBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the defaults file: %w", err)
+	}
+
+	defaults := &documentDefaults{byKind: map[string]map[string]interface{}{}}
+	decoder := yaml.NewDecoder(bytes.NewReader(defaultsBytes))
+
+	for i := 0; ; i++ {
+		var document map[string]interface{}
+
+		err := decoder.Decode(&document)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to parse the defaults file to YAML: %w", err)
+		}
+
+		if document == nil {
+			continue
+		}
+
+		// Convert the document to JSON compatible values to match the resolved documents
+		documentJSON, err := json.Marshal(document)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON in the defaults file (in the document at index %d): %w", i, err)
+		}
+
+		document = map[string]interface{}{}
+
+		if err := json.Unmarshal(documentJSON, &document); err != nil {
+			return nil, fmt.Errorf("invalid JSON in the defaults file (in the document at index %d): %w", i, err)
+		}
+
+		kind, _ := document["kind"].(string)
+
+		if kind == "" {
+			if defaults.all != nil {
+				return nil, fmt.Errorf(
+					"the defaults file has multiple documents without a kind (in the document at index %d)", i,
+				)
+			}
+
+			defaults.all = document
+
+			continue
+		}
+
+		if _, ok := defaults.byKind[kind]; ok {
+			return nil, fmt.Errorf(
+				"the defaults file has multiple documents with the kind %s (in the document at index %d)", kind, i,
+			)
+		}
+
+		defaults.byKind[kind] = document
+	}
+
+	return defaults, nil
+}
+
+// apply deep merges the defaults under the input resolved document, so the values in the document take precedence.
+// The defaults for the kind of the document take precedence over the defaults for every document.
+func (d *documentDefaults) apply(document map[string]interface{}) {
+	if d == nil {
+		return
+	}
+
+	kind, _ := document["kind"].(string)
+
+	if kindDefaults, ok := d.byKind[kind]; ok && kind != "" {
+		mergeUnder(document, kindDefaults)
+	}
+
+	mergeUnder(document, d.all)
+}
+
+// mergeUnder sets the values in src that are not set in dst. Nested maps are merged recursively, but any other value
+// in dst, including a list, takes precedence over the value in src as a whole.
+func mergeUnder(dst map[string]interface{}, src map[string]interface{}) {
+	for key, srcValue := range src {
+		dstValue, ok := dst[key]
+		if !ok {
+			dst[key] = runtime.DeepCopyJSONValue(srcValue)
+
+			continue
+		}
+
+		dstMap, dstIsMap := dstValue.(map[string]interface{})
+		srcMap, srcIsMap := srcValue.(map[string]interface{})
+
+		if dstIsMap && srcIsMap {
+			mergeUnder(dstMap, srcMap)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/stolostron/go-template-utils/v6/pkg/templates"
+)
+
+// explanation collects the sources of the resolved values for ProcessTemplateOptions.Explain. A nil explanation
+// doesn't collect anything.
+type explanation struct {
+	// paths are the field paths in the resolved document in the order they were added
+	paths []string
+	// sources maps the field paths in the resolved document to the descriptions of the objects their values came from
+	sources map[string][]string
+}
+
+// add adds the provenance of the template result to the explanation. The provenance paths are relative to the input
+// path in the resolved document and the objects are described with the source label when it is hubSource. An object
+// that data keys were read from, such as with "fromSecret", is described once per key with the key name.
+func (e *explanation) add(source string, path string, result templates.TemplateResult) {
+	if e == nil {
+		return
+	}
+
+	if e.sources == nil {
+		e.sources = map[string][]string{}
+	}
+
+	provenancePaths := make([]string, 0, len(result.Provenance))
+	for provenancePath := range result.Provenance {
+		provenancePaths = append(provenancePaths, provenancePath)
+	}
+
+	slices.Sort(provenancePaths)
+
+	for _, provenancePath := range provenancePaths {
+		fullPath := joinFieldPath(path, provenancePath)
+
+		if _, ok := e.sources[fullPath]; !ok {
+			e.paths = append(e.paths, fullPath)
+		}
+
+		for _, objID := range result.Provenance[provenancePath] {
+			description := "from "
+			if source == hubSource {
+				description += "hub "
+			}
+
+			description += objID.Kind
+
+			switch {
+			case objID.Name != "" && objID.Namespace != "":
+				description += " " + objID.Namespace + "/" + objID.Name
+			case objID.Name != "":
+				description += " " + objID.Name
+			default:
+				description += " list"
+
+				if objID.Namespace != "" {
+					description += " in namespace " + objID.Namespace
+				}
+
+				if objID.Selector != "" {
+					description += " matching " + objID.Selector
+				}
+			}
+
+			descriptions := []string{}
+
+			for _, provenanceKey := range result.ProvenanceKeys[provenancePath] {
+				if provenanceKey.Object == objID {
+					descriptions = append(descriptions, description+" key "+provenanceKey.Key)
+				}
+			}
+
+			if len(descriptions) == 0 {
+				descriptions = append(descriptions, description)
+			}
+
+			for _, keyDescription := range descriptions {
+				if !slices.Contains(e.sources[fullPath], keyDescription) {
+					e.sources[fullPath] = append(e.sources[fullPath], keyDescription)
+				}
+			}
+		}
+	}
+}
+
+// annotate returns the resolved YAML with a comment above each field that has a source in the explanation. If a field
+// path is not in the resolved YAML, such as when a template resolved to a YAML string, the comment is added to the
+// closest parent field that is. The comments only describe the objects, so sensitive values such as from a Secret are
+// never included. If the explanation is nil or empty, the input is returned as is.
+func (e *explanation) annotate(resolvedYAML []byte) ([]byte, error) {
+	if e == nil || len(e.paths) == 0 {
+		return resolvedYAML, nil
+	}
+
+	var document yaml.Node
+
+	if err := yaml.Unmarshal(resolvedYAML, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse the resolved YAML to explain it: %w", err)
+	}
+
+	if len(document.Content) == 0 {
+		return resolvedYAML, nil
+	}
+
+	for _, path := range e.paths {
+		node := findExplainedNode(document.Content[0], path)
+		if node == nil {
+			node = &document
+		}
+
+		// Several paths may fall back to the same parent field
+		for _, comment := range e.sources[path] {
+			if !slices.Contains(strings.Split(node.HeadComment, "\n"), comment) {
+				node.HeadComment = strings.TrimPrefix(node.HeadComment+"\n"+comment, "\n")
+			}
+		}
+	}
+
+	var b bytes.Buffer
+
+	yamlEncoder := yaml.NewEncoder(&b)
+	yamlEncoder.SetIndent(2)
+
+	if err := yamlEncoder.Encode(&document); err != nil {
+		return nil, fmt.Errorf("failed to generate the explained YAML: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// findExplainedNode returns the node to add the comment for the field path to, which is the key node of a map value
+// and the item node of a sequence item or its first key. If the field path is not fully in the YAML, the node of the
+// closest parent field is returned. Nil is returned if no field in the path is in the YAML. Since map keys in the path
+// are joined with dots and may contain dots themselves, the longest matching key is used.
+func findExplainedNode(root *yaml.Node, path string) *yaml.Node {
+	var target *yaml.Node
+
+	current := root
+	rest := path
+
+	for rest != "" {
+		switch current.Kind {
+		case yaml.MappingNode:
+			match := -1
+
+			for i := 0; i+1 < len(current.Content); i += 2 {
+				key := current.Content[i].Value
+
+				if !strings.HasPrefix(rest, key) || (len(rest) != len(key) && rest[len(key)] != '.' &&
+					rest[len(key)] != '[') {
+					continue
+				}
+
+				if match == -1 || len(key) > len(current.Content[match].Value) {
+					match = i
+				}
+			}
+
+			if match == -1 {
+				return target
+			}
+
+			// The comment of the first key of a sequence item is added above the item instead of after the dash
+			if match != 0 || target != current {
+				target = current.Content[match]
+			}
+
+			rest = strings.TrimPrefix(rest[len(current.Content[match].Value):], ".")
+			current = current.Content[match+1]
+		case yaml.SequenceNode:
+			end := strings.Index(rest, "]")
+			if !strings.HasPrefix(rest, "[") || end == -1 {
+				return target
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 || index >= len(current.Content) {
+				return target
+			}
+
+			target = current.Content[index]
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+			current = target
+		default:
+			return target
+		}
+	}
+
+	return target
+}
+
+// joinFieldPath joins the field paths in the format of TemplateResult.Provenance.
+func joinFieldPath(path string, subPath string) string {
+	switch {
+	case path == "":
+		return subPath
+	case subPath == "":
+		return path
+	case strings.HasPrefix(subPath, "["):
+		return path + subPath
+	default:
+		return path + "." + subPath
+	}
+}
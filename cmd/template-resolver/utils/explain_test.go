@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+
+	"github.com/stolostron/go-template-utils/v6/pkg/templates"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	objects, err := templates.ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cool-car
+  namespace: default
+data:
+  model: Shelby Mustang
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: cool-secret
+  namespace: default
+data:
+  password: c3VwZXJzZWNyZXQ=
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotResolver, err := templates.NewResolverFromSnapshot(objects, templates.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := unstructured.Unstructured{}
+
+	err = yaml.Unmarshal([]byte(`
+apiVersion: policy.open-cluster-management.io/v1
+kind: ConfigurationPolicy
+metadata:
+  name: explain
+spec:
+  remediationAction: inform
+  object-templates:
+    - complianceType: musthave
+      objectDefinition:
+        apiVersion: v1
+        kind: Secret
+        metadata:
+          name: copied-secret
+          namespace: default
+          labels:
+            app.kubernetes.io/model: '{{ fromConfigMap "default" "cool-car" "model" | replace " " "-" }}'
+        data:
+          password: '{{ fromSecret "default" "cool-secret" "password" }}'
+          static: c3RhdGlj
+    - complianceType: musthave
+      objectDefinition:
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: car-count
+          namespace: default
+        data:
+          count: '{{ (lookup "v1" "ConfigMap" "default" "" "app=cars").items | len }}'
+`), &policy.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	explain := &explanation{}
+	resolver := reportingResolver{
+		TemplateResolver: snapshotResolver, source: managedSource, explanation: explain,
+	}
+
+	err = processConfigPolicyTemplate(&policy, resolver, managedTemplateCtx{}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A hub template source is labeled as such
+	explain.add(hubSource, "metadata", templates.TemplateResult{
+		Provenance: map[string][]client.ObjectIdentifier{
+			"name": {{Version: "v1", Kind: "ConfigMap", Namespace: "policies", Name: "names"}},
+		},
+	})
+
+	resolvedYAML, err := objectToYAML(policy.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	explainedYAML, err := explain.annotate(resolvedYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `apiVersion: policy.open-cluster-management.io/v1
+kind: ConfigurationPolicy
+metadata:
+  # from hub ConfigMap policies/names
+  name: explain
+spec:
+  object-templates:
+    - complianceType: musthave
+      objectDefinition:
+        apiVersion: v1
+        data:
+          # from Secret default/cool-secret key password
+          password: c3VwZXJzZWNyZXQ=
+          static: c3RhdGlj
+        kind: Secret
+        metadata:
+          labels:
+            # from ConfigMap default/cool-car key model
+            app.kubernetes.io/model: Shelby-Mustang
+          name: copied-secret
+          namespace: default
+    - complianceType: musthave
+      objectDefinition:
+        apiVersion: v1
+        data:
+          # from ConfigMap list in namespace default matching app=cars
+          count: "0"
+        kind: ConfigMap
+        metadata:
+          name: car-count
+          namespace: default
+  remediationAction: inform
+`
+
+	if string(explainedYAML) != expected {
+		t.Fatalf("expected :\n%s\n, got :\n%s", expected, string(explainedYAML))
+	}
+
+	if strings.Contains(string(explainedYAML), "supersecret") {
+		t.Fatal("expected the explanation to not include the decoded Secret value")
+	}
+}
+
+func TestExplainFallback(t *testing.T) {
+	t.Parallel()
+
+	explain := &explanation{}
+	explain.add(managedSource, "patches[0].patch", templates.TemplateResult{
+		Provenance: map[string][]client.ObjectIdentifier{
+			"spec.replicas":        {{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "app-config"}},
+			"spec.template.image":  {{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "app-config"}},
+			"":                     {{Group: "cluster.open-cluster-management.io", Kind: "ClusterClaim", Name: "env"}},
+			"spec.does-not-exist":  {{Version: "v1", Kind: "Namespace", Name: "app"}},
+			"spec.replicas[0].bad": {{Version: "v1", Kind: "Namespace", Name: "app"}},
+		},
+	})
+	explain.add(managedSource, "", templates.TemplateResult{
+		Provenance: map[string][]client.ObjectIdentifier{
+			"": {{Version: "v1", Kind: "Node", Name: "node1"}},
+		},
+	})
+
+	explainedYAML, err := explain.annotate([]byte("patches:\n  - patch: |\n      spec:\n        replicas: 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# from Node node1
+
+patches:
+  # from ClusterClaim env
+  # from Namespace app
+  # from ConfigMap app/app-config
+  - patch: |
+      spec:
+        replicas: 3
+`
+
+	if string(explainedYAML) != expected {
+		t.Fatalf("expected :\n%s\n, got :\n%s", expected, string(explainedYAML))
+	}
+}
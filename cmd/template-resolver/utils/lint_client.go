@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stolostron/go-template-utils/v6/pkg/lint"
+)
+
+// ErrBlockingViolations is returned by the lint command when error level violations are found.
+var ErrBlockingViolations = errors.New("blocking lint violations were found")
+
+var lintFormats = []string{"text", "json", "sarif"}
+
+// Struct representing the template-resolver lint command
+type TemplateLinter struct {
+	format               string
+	startDelim           string
+	stopDelim            string
+	deprecatedDelimiters map[string]string
+}
+
+func (l *TemplateLinter) GetCmd() *cobra.Command {
+	lintCmd := &cobra.Command{
+		Use: `lint [flags] [file|-]
+
+  The file positional argument is the path to a policy YAML manifest. If file
+  is a dash ('-') or absent, lint reads from the standard input. The exit code
+  is 1 if any error level violations are found.`,
+		Short:        "Lint the templates in a policy",
+		Long:         "Lint the templates in a policy",
+		Args:         cobra.MaximumNArgs(1),
+		RunE:         l.lintTemplates,
+		SilenceUsage: true,
+	}
+
+	lintCmd.Flags().StringVar(
+		&l.format,
+		"format",
+		"text",
+		"the output format of the violations (one of text, json, or sarif)",
+	)
+	lintCmd.Flags().StringVar(
+		&l.startDelim,
+		"start-delim",
+		"",
+		"the start delimiter of template actions (defaults to \"{{\")",
+	)
+	lintCmd.Flags().StringVar(
+		&l.stopDelim,
+		"stop-delim",
+		"",
+		"the stop delimiter of template actions (defaults to \"}}\")",
+	)
+	lintCmd.Flags().StringToStringVar(
+		&l.deprecatedDelimiters,
+		"deprecated-delimiter",
+		nil,
+		"a deprecated start delimiter to report usages of in the format of deprecated=replacement, where the "+
+			"replacement can be empty (can be repeated)",
+	)
+
+	return lintCmd
+}
+
+func (l *TemplateLinter) lintTemplates(cmd *cobra.Command, args []string) error {
+	if !slices.Contains(lintFormats, l.format) {
+		return fmt.Errorf("the format must be one of text, json, or sarif but got: %s", l.format)
+	}
+
+	yamlFile, err := inputFileFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	yamlBytes, err := HandleFile(yamlFile)
+	if err != nil {
+		return fmt.Errorf("error handling YAML file input: %w", err)
+	}
+
+	violations := lint.LintWithConfig(string(yamlBytes), lint.LintConfig{
+		StartDelim:           l.startDelim,
+		StopDelim:            l.stopDelim,
+		DeprecatedDelimiters: l.deprecatedDelimiters,
+	})
+
+	var output string
+
+	switch l.format {
+	case "json":
+		output, err = lint.OutputJSONViolations(violations)
+	case "sarif":
+		uri := yamlFile
+		if uri == "-" {
+			uri = ""
+		}
+
+		output, err = lint.OutputSARIFViolations(violations, uri)
+	default:
+		output = lint.OutputStringViolations(violations)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), output)
+
+	if lint.HasBlockingViolations(violations) {
+		return ErrBlockingViolations
+	}
+
+	return nil
+}
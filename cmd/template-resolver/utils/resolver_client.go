@@ -9,11 +9,23 @@ import (
 
 // Struct representing the template-resolver command
 type TemplateResolver struct {
-	hubKubeConfigPath string
-	clusterName       string
-	hubNamespace      string
-	objNamespace      string
-	objName           string
+	hubKubeConfigPath     string
+	managedKubeConfigPath string
+	clusterName           string
+	hubNamespace          string
+	objNamespace          string
+	objName               string
+	objLabels             map[string]string
+	objAnnotations        map[string]string
+	resolveAllKinds       bool
+	allowEnvFunction      bool
+	objTemplateIndex      int
+	rejectSecretInCM      bool
+	defaultsPath          string
+	resourcesReportPath   string
+	valuesPath            string
+	trailingNewline       bool
+	explain               bool
 }
 
 func (t *TemplateResolver) GetCmd() *cobra.Command {
@@ -37,6 +49,13 @@ func (t *TemplateResolver) GetCmd() *cobra.Command {
 		"",
 		"the input kubeconfig to also resolve hub templates",
 	)
+	templateResolverCmd.Flags().StringVar(
+		&t.managedKubeConfigPath,
+		"managed-kubeconfig",
+		"",
+		"the input kubeconfig to resolve managed cluster templates instead of the default kubeconfig loading rules "+
+			"(i.e. the KUBECONFIG environment variable)",
+	)
 	templateResolverCmd.Flags().StringVar(
 		&t.clusterName,
 		"cluster-name",
@@ -62,29 +81,103 @@ func (t *TemplateResolver) GetCmd() *cobra.Command {
 		"the object namespace to use for the .ObjectName template variable "+
 			"when policy uses namespaceSelector or objectSelector",
 	)
+	templateResolverCmd.Flags().StringToStringVar(
+		&t.objLabels,
+		"object-labels",
+		nil,
+		"the object labels in the format of key=value to use for the .ObjectLabels template variable "+
+			"when policy uses namespaceSelector or objectSelector (can be repeated)",
+	)
+	templateResolverCmd.Flags().StringToStringVar(
+		&t.objAnnotations,
+		"object-annotations",
+		nil,
+		"the object annotations in the format of key=value to use for the .ObjectAnnotations template variable "+
+			"when policy uses namespaceSelector or objectSelector (can be repeated)",
+	)
+
+	templateResolverCmd.Flags().BoolVar(
+		&t.resolveAllKinds,
+		"resolve-all-policy-template-kinds",
+		false,
+		"resolve the managed templates in every policy-templates entry of a Policy regardless of its kind "+
+			"instead of only ConfigurationPolicy and OperatorPolicy",
+	)
+
+	templateResolverCmd.Flags().BoolVar(
+		&t.allowEnvFunction,
+		"allow-env-function",
+		false,
+		"enable the env template function, which returns the value of an environment variable of the CLI process",
+	)
+
+	templateResolverCmd.Flags().IntVar(
+		&t.objTemplateIndex,
+		"object-template-index",
+		0,
+		"resolve and output only the object-templates entry at this index of a ConfigurationPolicy, which is useful "+
+			"for debugging a single template",
+	)
+
+	templateResolverCmd.Flags().BoolVar(
+		&t.rejectSecretInCM,
+		"reject-secret-in-configmap",
+		false,
+		"fail if a managed cluster template that references sensitive data, such as with fromSecret, resolves to a "+
+			"ConfigMap",
+	)
+
+	templateResolverCmd.Flags().StringVar(
+		&t.defaultsPath,
+		"defaults",
+		"",
+		"the path to a YAML file of default values to deep merge under each resolved document, where a YAML document "+
+			"in the file with a kind only applies to documents of that kind",
+	)
+
+	templateResolverCmd.Flags().StringVar(
+		&t.resourcesReportPath,
+		"resources-report",
+		"",
+		"the path to write a JSON report to of the resources referenced by the hub and managed cluster templates, "+
+			"including whether each resource was found",
+	)
+
+	templateResolverCmd.Flags().StringVar(
+		&t.valuesPath,
+		"values",
+		"",
+		"the path to a YAML file of values, similar to a Helm values file, to use for the .Values template variable "+
+			"in managed cluster templates",
+	)
+
+	templateResolverCmd.Flags().BoolVar(
+		&t.trailingNewline,
+		"trailing-newline",
+		true,
+		"end the output with a newline, which can be disabled with --trailing-newline=false for repositories that "+
+			"don't allow a final newline",
+	)
+
+	templateResolverCmd.Flags().BoolVar(
+		&t.explain,
+		"explain",
+		false,
+		"add a comment above each resolved value with the objects referenced by the templates that output it, "+
+			"without including the values of the objects",
+	)
+
+	linter := TemplateLinter{}
+	templateResolverCmd.AddCommand(linter.GetCmd())
 
 	return templateResolverCmd
 }
 
 func (t *TemplateResolver) resolveTemplates(cmd *cobra.Command, args []string) error {
 	// Validate YAML input as positional arg
-	yamlFile := ""
-
-	// Detect whether stdin is provided when no arguments are provided
-	if len(args) == 0 {
-		stdinInfo, err := os.Stdin.Stat()
-		if err != nil {
-			return fmt.Errorf("error reading stdin: %w", err)
-		}
-
-		if (stdinInfo.Mode() & os.ModeCharDevice) != 0 {
-			return fmt.Errorf("failed to read from stdin: input is not a pipe")
-		}
-	}
-
-	// Set YAML path if a positional argument is provided ("-" is read as stdin)
-	if len(args) == 1 {
-		yamlFile = args[0]
+	yamlFile, err := inputFileFromArgs(args)
+	if err != nil {
+		return err
 	}
 
 	// Validate flag args
@@ -95,13 +188,44 @@ func (t *TemplateResolver) resolveTemplates(cmd *cobra.Command, args []string) e
 		)
 	}
 
+	if t.managedKubeConfigPath != "" {
+		if _, err := os.Stat(t.managedKubeConfigPath); err != nil {
+			return fmt.Errorf("failed to read the managed kubeconfig: %w", err)
+		}
+	}
+
 	yamlBytes, err := HandleFile(yamlFile)
 	if err != nil {
 		return fmt.Errorf("error handling YAML file input: %w", err)
 	}
 
-	resolvedYAML, err := ProcessTemplate(yamlBytes, t.hubKubeConfigPath,
-		t.clusterName, t.hubNamespace, t.objNamespace, t.objName)
+	opts := ProcessTemplateOptions{
+		HubKubeConfigPath:             t.hubKubeConfigPath,
+		ManagedKubeConfigPath:         t.managedKubeConfigPath,
+		ClusterName:                   t.clusterName,
+		HubNamespace:                  t.hubNamespace,
+		ObjectNamespace:               t.objNamespace,
+		ObjectName:                    t.objName,
+		ObjectLabels:                  t.objLabels,
+		ObjectAnnotations:             t.objAnnotations,
+		ResolveAllPolicyTemplateKinds: t.resolveAllKinds,
+		AllowEnvFunction:              t.allowEnvFunction,
+		RejectSecretInConfigMap:       t.rejectSecretInCM,
+		DefaultsPath:                  t.defaultsPath,
+		ResourcesReportPath:           t.resourcesReportPath,
+		ValuesPath:                    t.valuesPath,
+		Explain:                       t.explain,
+	}
+
+	if cmd.Flags().Changed("object-template-index") {
+		opts.ObjectTemplateIndex = &t.objTemplateIndex
+	}
+
+	if cmd.Flags().Changed("trailing-newline") {
+		opts.OutputTrailingNewline = &t.trailingNewline
+	}
+
+	resolvedYAML, err := ProcessTemplateWithOptions(yamlBytes, opts)
 	if err != nil {
 		cmd.Printf("error processing templates: %s\n", err.Error())
 
@@ -114,6 +238,27 @@ func (t *TemplateResolver) resolveTemplates(cmd *cobra.Command, args []string) e
 	return nil
 }
 
+// inputFileFromArgs returns the YAML file path from the positional arguments. An empty string or a dash ('-') means
+// that the input is read from stdin, in which case an error is returned if stdin is not a pipe.
+func inputFileFromArgs(args []string) (string, error) {
+	// Detect whether stdin is provided when no arguments are provided
+	if len(args) == 0 {
+		stdinInfo, err := os.Stdin.Stat()
+		if err != nil {
+			return "", fmt.Errorf("error reading stdin: %w", err)
+		}
+
+		if (stdinInfo.Mode() & os.ModeCharDevice) != 0 {
+			return "", fmt.Errorf("failed to read from stdin: input is not a pipe")
+		}
+
+		return "", nil
+	}
+
+	// Set YAML path if a positional argument is provided ("-" is read as stdin)
+	return args[0], nil
+}
+
 // Execute runs the `template-resolver` command.
 func Execute() error {
 	tmplResolverCmd := TemplateResolver{}
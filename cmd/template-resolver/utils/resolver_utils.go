@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -25,6 +26,13 @@ type hubTemplateCtx struct {
 	PolicyMetadata       map[string]interface{}
 }
 
+// managedTemplateCtx is the context of the managed cluster templates, which has the values from the values file under
+// .Values in addition to the TemplateContext fields.
+type managedTemplateCtx struct {
+	templates.TemplateContext
+	Values map[string]interface{}
+}
+
 type hubTemplateOptions struct {
 	config templates.Config
 	opts   templates.ResolveOptions
@@ -67,22 +75,281 @@ func HandleFile(yamlFile string) ([]byte, error) {
 	return yamlBytes, nil
 }
 
+// ProcessTemplateOptions is the configuration for ProcessTemplateWithOptions.
+//
+// - HubKubeConfigPath is the path to the kubeconfig of the hub to resolve hub templates with. If this is not set, hub
+// templates are not resolved.
+//
+// - ManagedKubeConfigPath is the path to the kubeconfig of the managed cluster to resolve managed cluster templates
+// with. If this is not set, the default kubeconfig loading rules are used.
+//
+// - ClusterName is the name of the managed cluster to use for the .ManagedClusterName hub template variable.
+//
+// - HubNamespace is the namespace on the hub to restrict namespaced lookups to when resolving hub templates.
+//
+// - ObjectNamespace and ObjectName are the values to use for the .ObjectNamespace and .ObjectName template variables.
+//
+// - ObjectLabels and ObjectAnnotations are the values to use for the .ObjectLabels and .ObjectAnnotations template
+// variables, which are the labels and annotations of the object matched by a namespaceSelector or objectSelector.
+//
+// - ResolveAllPolicyTemplateKinds resolves the managed templates in the objectDefinition of every policy-templates
+// entry of a Policy regardless of its kind. By default, only ConfigurationPolicy and OperatorPolicy objectDefinitions
+// are resolved.
+//
+// - AllowEnvFunction enables the "env" template function in both hub and managed cluster templates. See
+// templates.Config.AllowEnvFunction for the security implications.
+//
+// - ObjectTemplateIndex, if set, restricts the object-templates of a ConfigurationPolicy to only the entry at this
+// index, and only that entry has its managed templates resolved. This is useful for debugging a single template in a
+// large ConfigurationPolicy. An error is returned if the index is out of range or if the ConfigurationPolicy uses
+// object-templates-raw.
+//
+// - RejectSecretInConfigMap returns an error if a managed cluster template that references sensitive data, such as
+// with fromSecret, resolves to a ConfigMap. Each object-templates entry is checked separately, but object-templates-raw
+// is checked as a whole. See templates.ResolveOptions.RejectSecretInConfigMap.
+//
+// - DefaultsPath, if set, is the path to a YAML file of default values that are deep merged under each document after
+// its templates are resolved, so the values in the resolved document take precedence. A YAML document in the file
+// without a kind applies to every document, and a YAML document with a kind only applies to documents of that kind
+// and takes precedence over the defaults without a kind. Nested maps are merged, but lists are not, so a list in the
+// resolved document replaces the list in the defaults. The defaults are not resolved as templates.
+//
+// - ResourcesReportPath, if set, is the path to write a JSON report to of the resources referenced by the hub and
+// managed cluster templates, such as for auditing the required RBAC. Each entry has the source ("hub" or "managed"),
+// the apiVersion, kind, namespace, and name of the resource, and whether it was found. A list query has no name and
+// instead has the labelSelector of the query, if any, and is always considered found. The report is only written if
+// the templates are resolved successfully. See templates.ResolveOptions.TrackReferences.
+//
+// - ValuesPath, if set, is the path to a YAML file of values, similar to a Helm values file, that are available to the
+// managed cluster templates under the .Values template variable, such as "{{ .Values.image.tag }}". The values are
+// not available to hub templates. Since the values are only under .Values, they can't override the .ObjectNamespace,
+// .ObjectName, .ObjectLabels, and .ObjectAnnotations template variables, which are always set from their options.
+//
+// - OutputTrailingNewline controls whether the returned YAML ends with a newline. If this is nil, it defaults to true.
+// See templates.Config.OutputTrailingNewline.
+//
+// - Explain adds a comment above each resolved field of a Policy, ConfigurationPolicy, OperatorPolicy,
+// object-templates-raw, or patches document with the objects referenced by the templates that output it, such as
+// "# from Secret testns/testsecret key secretkey1". The data key is included for the "fromSecret" and "fromConfigMap"
+// family of template functions. Objects referenced by hub templates are prefixed with "hub". The comments only name
+// the objects and keys and never include their values, so sensitive data is not exposed by them. See
+// templates.ResolveOptions.TrackProvenance.
+type ProcessTemplateOptions struct {
+	HubKubeConfigPath             string
+	ManagedKubeConfigPath         string
+	ClusterName                   string
+	HubNamespace                  string
+	ObjectNamespace               string
+	ObjectName                    string
+	ObjectLabels                  map[string]string
+	ObjectAnnotations             map[string]string
+	ResolveAllPolicyTemplateKinds bool
+	AllowEnvFunction              bool
+	ObjectTemplateIndex           *int
+	RejectSecretInConfigMap       bool
+	DefaultsPath                  string
+	ResourcesReportPath           string
+	ValuesPath                    string
+	OutputTrailingNewline         *bool
+	Explain                       bool
+}
+
 // ProcessTemplate takes a YAML byte array input, unmarshals it to a Policy, ConfigPolicy,
 // or object-templates-raw, processes the templates, and marshals it back to YAML,
 // returning the resulting byte array. Validation is performed along the way, returning
 // an error if any failures are found. It uses the `hubKubeConfigPath`, `hubNS` and `clusterName`
-// to establish a dynamic client with the hub to resolve any hub templates it finds.
+// to establish a dynamic client with the hub to resolve any hub templates it finds. The managed
+// cluster uses the default kubeconfig loading rules. See ProcessTemplateWithOptions for additional
+// options, such as ManagedKubeConfigPath.
 func ProcessTemplate(yamlBytes []byte, hubKubeConfigPath, clusterName, hubNS,
 	objNamespace, objName string,
 ) ([]byte, error) {
-	policy := unstructured.Unstructured{}
+	return ProcessTemplateWithOptions(yamlBytes, ProcessTemplateOptions{
+		HubKubeConfigPath: hubKubeConfigPath,
+		ClusterName:       clusterName,
+		HubNamespace:      hubNS,
+		ObjectNamespace:   objNamespace,
+		ObjectName:        objName,
+	})
+}
+
+// ProcessTemplateWithOptions is the same as ProcessTemplate but accepts a ProcessTemplateOptions struct for the
+// configuration. If the input contains multiple YAML documents separated by "---", each supported document is
+// processed and documents of other kinds, such as a PlacementBinding, are passed through unchanged. If
+// HubKubeConfigPath is not set and the parts of the input that are resolved have hub templates, an error listing the
+// hub template functions is returned before resolving anything.
+func ProcessTemplateWithOptions(yamlBytes []byte, opts ProcessTemplateOptions) ([]byte, error) {
+	// Fail upfront rather than partway through resolving the input. Invalid YAML is reported when processing it.
+	if opts.HubKubeConfigPath == "" {
+		hubFunctions, _, needsHubKubeconfig, err := analyzePolicyTemplates(yamlBytes, &opts)
+		if err == nil && needsHubKubeconfig {
+			msg := "the input has hub templates, so the hub-kubeconfig and cluster-name arguments are required"
+
+			if len(hubFunctions) != 0 {
+				msg += fmt.Sprintf(" (hub template functions: %s)", strings.Join(hubFunctions, ", "))
+			}
+
+			return nil, errors.New(msg)
+		}
+	}
 
-	err := yaml.Unmarshal(yamlBytes, &policy.Object)
+	defaults, err := loadDefaults(opts.DefaultsPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse input to YAML: %w", err)
+		return nil, err
+	}
+
+	values, err := loadValues(opts.ValuesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *resourcesReport
+
+	if opts.ResourcesReportPath != "" {
+		report = &resourcesReport{}
+	}
+
+	documents := []map[string]interface{}{}
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlBytes))
+
+	for {
+		var document map[string]interface{}
+
+		err := decoder.Decode(&document)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to parse input to YAML: %w", err)
+		}
+
+		// Skip empty documents such as from a leading separator
+		if document == nil {
+			continue
+		}
+
+		documents = append(documents, document)
+	}
+
+	// A single document must be a supported type
+	if len(documents) < 2 {
+		policy := unstructured.Unstructured{}
+
+		if len(documents) == 1 {
+			policy.Object = documents[0]
+		}
+
+		resolvedYAML, err := processDocument(policy, opts, defaults, values, report)
+		if err != nil {
+			return nil, err
+		}
+
+		return setOutputTrailingNewline(resolvedYAML, opts), writeResourcesReport(report, opts.ResourcesReportPath)
+	}
+
+	resolvedDocuments := make([][]byte, 0, len(documents))
+
+	for i, document := range documents {
+		policy := unstructured.Unstructured{Object: document}
+
+		var (
+			resolvedYAML []byte
+			err          error
+		)
+
+		if isSupportedDocument(policy) {
+			resolvedYAML, err = processDocument(policy, opts, defaults, values, report)
+		} else {
+			defaults.apply(policy.Object)
+			resolvedYAML, err = objectToYAML(policy.Object)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w (in the document at index %d)", err, i)
+		}
+
+		resolvedDocuments = append(resolvedDocuments, resolvedYAML)
+	}
+
+	err = writeResourcesReport(report, opts.ResourcesReportPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return setOutputTrailingNewline(bytes.Join(resolvedDocuments, []byte("---\n")), opts), nil
+}
+
+// setOutputTrailingNewline removes the final newline from the resolved YAML if opts.OutputTrailingNewline is false.
+func setOutputTrailingNewline(resolvedYAML []byte, opts ProcessTemplateOptions) []byte {
+	if opts.OutputTrailingNewline == nil {
+		return resolvedYAML
+	}
+
+	return templates.SetTrailingNewline(resolvedYAML, *opts.OutputTrailingNewline)
+}
+
+// writeResourcesReport writes the report to the input path if the path is set.
+func writeResourcesReport(report *resourcesReport, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	return report.write(path)
+}
+
+// isSupportedDocument returns true if the input is a type that ProcessTemplate resolves templates in.
+func isSupportedDocument(policy unstructured.Unstructured) bool {
+	switch policy.GetKind() {
+	case "Policy", "ConfigurationPolicy", "OperatorPolicy":
+		return true
+	}
+
+	if _, ok := policy.Object["object-templates-raw"]; ok {
+		return true
+	}
+
+	_, ok := policy.Object["patches"]
+
+	return ok
+}
+
+// isResolvedPolicyTemplateKind returns true if the input objectDefinition of a Policy is a kind that
+// processPolicyTemplate resolves templates in without ResolveAllPolicyTemplateKinds, which are ConfigurationPolicy and
+// OperatorPolicy.
+func isResolvedPolicyTemplateKind(objectDefinition unstructured.Unstructured) bool {
+	gvk := objectDefinition.GroupVersionKind()
+	if gvk.Group != "policy.open-cluster-management.io" {
+		return false
+	}
+
+	return (gvk.Version == "v1" && gvk.Kind == "ConfigurationPolicy") ||
+		(gvk.Version == "v1beta1" && gvk.Kind == "OperatorPolicy")
+}
+
+// processDocument processes the templates in a single Policy, ConfigurationPolicy, OperatorPolicy,
+// object-templates-raw, or patches document, merges the defaults under it, and returns the resulting YAML. The
+// resources referenced by the templates are added to the report if it is not nil, and the values are available to the
+// managed cluster templates under .Values.
+func processDocument(
+	policy unstructured.Unstructured,
+	opts ProcessTemplateOptions,
+	defaults *documentDefaults,
+	values map[string]interface{},
+	report *resourcesReport,
+) ([]byte, error) {
+	hubKubeConfigPath := opts.HubKubeConfigPath
+	clusterName := opts.ClusterName
+	hubNS := opts.HubNamespace
+
+	var explain *explanation
+
+	if opts.Explain {
+		explain = &explanation{}
 	}
 
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.ManagedKubeConfigPath
 	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
 
 	kubeConfig, err := clientConfig.ClientConfig()
@@ -93,14 +360,13 @@ func ProcessTemplate(yamlBytes []byte, hubKubeConfigPath, clusterName, hubNS,
 	hubTemplateOpts := &hubTemplateOptions{
 		config: templates.Config{
 			AdditionalIndentation: 8,
+			AllowEnvFunction:      opts.AllowEnvFunction,
 			DisabledFunctions:     []string{},
-			StartDelim:            "{{hub",
-			StopDelim:             "hub}}",
+			StartDelim:            hubStartDelim,
+			StopDelim:             hubStopDelim,
 		},
 	}
 
-	var hubResolver *templates.TemplateResolver
-
 	if hubKubeConfigPath != "" {
 		customSA, _, _ := unstructured.NestedString(policy.Object, "spec", "hubTemplateOptions", "serviceAccountName")
 
@@ -175,12 +441,18 @@ func ProcessTemplate(yamlBytes []byte, hubKubeConfigPath, clusterName, hubNS,
 			}
 		}
 
-		hubResolver, err = templates.NewResolver(hubKubeConfig, hubTemplateOpts.config)
+		hubResolver, err := templates.NewResolver(hubKubeConfig, hubTemplateOpts.config)
 		if err != nil {
 			return nil, fmt.Errorf("failed to instantiate the hub template resolver: %w", err)
 		}
 
-		hubResolvedObject, err := resolveHubTemplates(policy.Object, hubResolver, hubTemplateOpts)
+		hubResolvedObject, err := resolveHubTemplates(
+			policy.Object,
+			reportingResolver{
+				TemplateResolver: hubResolver, source: hubSource, report: report, explanation: explain,
+			},
+			hubTemplateOpts,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -188,37 +460,71 @@ func ProcessTemplate(yamlBytes []byte, hubKubeConfigPath, clusterName, hubNS,
 		policy.Object = hubResolvedObject
 	}
 
-	resolver, err := templates.NewResolver(kubeConfig, templates.Config{})
+	managedResolver, err := templates.NewResolver(
+		kubeConfig, templates.Config{AllowEnvFunction: opts.AllowEnvFunction},
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to instantiate the template resolver: %w", err)
 	}
 
-	tempCtx := templates.TemplateContext{
-		ObjectNamespace: objNamespace,
-		ObjectName:      objName,
+	resolver := reportingResolver{
+		TemplateResolver: managedResolver, source: managedSource, report: report, explanation: explain,
+	}
+
+	tempCtx := managedTemplateCtx{
+		TemplateContext: templates.TemplateContext{
+			ObjectNamespace:   opts.ObjectNamespace,
+			ObjectName:        opts.ObjectName,
+			ObjectLabels:      opts.ObjectLabels,
+			ObjectAnnotations: opts.ObjectAnnotations,
+		},
+		Values: values,
 	}
 
 	switch policy.GetKind() {
 	case "Policy":
-		err = processPolicyTemplate(&policy, resolver, tempCtx)
+		err = processPolicyTemplate(
+			&policy, resolver, tempCtx, opts.ResolveAllPolicyTemplateKinds, opts.ObjectTemplateIndex,
+			opts.RejectSecretInConfigMap,
+		)
 	case "ConfigurationPolicy":
-		err = processConfigPolicyTemplate(&policy, resolver, tempCtx)
+		err = processConfigPolicyTemplate(
+			&policy, resolver, tempCtx, opts.ObjectTemplateIndex, opts.RejectSecretInConfigMap,
+		)
 	case "OperatorPolicy":
 		_, err = processOperatorPolicyTemplates(policy.Object, resolver, tempCtx)
 	default:
-		if _, ok := policy.Object["object-templates-raw"]; !ok {
+		if _, ok := policy.Object["object-templates-raw"]; ok {
+			err = processObjTemplatesRaw(&policy, resolver, tempCtx, opts.RejectSecretInConfigMap)
+
+			break
+		}
+
+		if _, ok := policy.Object["patches"]; !ok {
 			return nil, fmt.Errorf("invalid YAML. Supported types: Policy, " +
-				"ConfigurationPolicy, OperatorPolicy, object-templates-raw")
+				"ConfigurationPolicy, OperatorPolicy, object-templates-raw, patches")
 		}
 
-		err = processObjTemplatesRaw(&policy, resolver, tempCtx)
+		err = processPatches(&policy, resolver, tempCtx)
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
+	defaults.apply(policy.Object)
+
+	resolvedYAML, err := objectToYAML(policy.Object)
 	if err != nil {
 		return nil, err
 	}
 
-	resolvedJSON, err := json.Marshal(policy.Object)
+	return explain.annotate(resolvedYAML)
+}
+
+// objectToYAML marshals the input object to YAML in the output format of ProcessTemplate.
+func objectToYAML(object map[string]interface{}) ([]byte, error) {
+	resolvedJSON, err := json.Marshal(object)
 	if err != nil {
 		return nil, fmt.Errorf("invalid JSON resulted after resolving templates: %w", err)
 	}
@@ -232,11 +538,16 @@ func ProcessTemplate(yamlBytes []byte, hubKubeConfigPath, clusterName, hubNS,
 }
 
 // ProcessPolicyTemplate takes the unmarshalled Policy YAML as input and resolves
-// all valid ConfigurationPolicy templates specified in the policy-templates field
+// all valid ConfigurationPolicy templates specified in the policy-templates field.
+// If resolveAllKinds is true, the objectDefinitions of other kinds are also resolved. See processObjectTemplates for
+// objTemplateIndex and rejectSecretInConfigMap.
 func processPolicyTemplate(
 	policy *unstructured.Unstructured,
-	resolver *templates.TemplateResolver,
-	tempCtx templates.TemplateContext,
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
+	resolveAllKinds bool,
+	objTemplateIndex *int,
+	rejectSecretInConfigMap bool,
 ) error {
 	policyTemplates, _, err := unstructured.NestedSlice(policy.Object, "spec", "policy-templates")
 	if err != nil {
@@ -255,26 +566,47 @@ func processPolicyTemplate(
 		}
 
 		templateObj := unstructured.Unstructured{Object: objectDefinition}
-
-		switch templateObj.GetAPIVersion() {
-		case "policy.open-cluster-management.io/v1":
-			if templateObj.GetKind() != "ConfigurationPolicy" {
-				continue
-			}
-
-			objectDefinition, err = processObjectTemplates(objectDefinition, resolver, tempCtx)
+		gvk := templateObj.GroupVersionKind()
+		templateResolver := resolver.at(fmt.Sprintf("spec.policy-templates[%d].objectDefinition", i))
+
+		switch {
+		case gvk.Group == "policy.open-cluster-management.io" && gvk.Version == "v1" &&
+			gvk.Kind == "ConfigurationPolicy":
+			objectDefinition, err = processObjectTemplates(
+				objectDefinition, templateResolver, tempCtx, objTemplateIndex, rejectSecretInConfigMap,
+			)
 			if err != nil {
 				return fmt.Errorf("%w (in policy-templates at index %d)", err, i)
 			}
-		case "policy.open-cluster-management.io/v1beta1":
-			if templateObj.GetKind() != "OperatorPolicy" {
-				continue
+		case gvk.Group == "policy.open-cluster-management.io" && gvk.Version == "v1beta1" &&
+			gvk.Kind == "OperatorPolicy":
+			objectDefinition, err = processOperatorPolicyTemplates(objectDefinition, templateResolver, tempCtx)
+			if err != nil {
+				return fmt.Errorf("%w (in policy-templates at index %d)", err, i)
 			}
-
-			objectDefinition, err = processOperatorPolicyTemplates(objectDefinition, resolver, tempCtx)
+		case resolveAllKinds:
+			var resolved interface{}
+
+			resolved, err = resolveManagedTemplate(
+				objectDefinition,
+				"objectDefinition",
+				templateResolver,
+				templates.ResolveOptions{
+					AllowNestedContextValues: true, RejectSecretInConfigMap: rejectSecretInConfigMap,
+				},
+				tempCtx,
+			)
 			if err != nil {
 				return fmt.Errorf("%w (in policy-templates at index %d)", err, i)
 			}
+
+			objectDefinition, ok = resolved.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf(
+					"the objectDefinition in policy-templates at index %d was not an object after resolving templates",
+					i,
+				)
+			}
 		default:
 			continue
 		}
@@ -298,13 +630,17 @@ func processPolicyTemplate(
 }
 
 // ProcessConfigPolicyTemplate takes the unmarshalled ConfigPolicy YAML as input
-// and resolves its templates
+// and resolves its templates. See processObjectTemplates for objTemplateIndex and rejectSecretInConfigMap.
 func processConfigPolicyTemplate(
 	policy *unstructured.Unstructured,
-	resolver *templates.TemplateResolver,
-	tempCtx templates.TemplateContext,
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
+	objTemplateIndex *int,
+	rejectSecretInConfigMap bool,
 ) error {
-	resolvedPolicy, err := processObjectTemplates(policy.Object, resolver, tempCtx)
+	resolvedPolicy, err := processObjectTemplates(
+		policy.Object, resolver, tempCtx, objTemplateIndex, rejectSecretInConfigMap,
+	)
 	if err != nil {
 		return err
 	}
@@ -314,110 +650,215 @@ func processConfigPolicyTemplate(
 	return nil
 }
 
-// processObjTemplatesRaw takes a YAML string representation and resolves the object's managed templates
+// processObjTemplatesRaw takes a YAML string representation and resolves the object's managed templates. If
+// rejectSecretInConfigMap is true, an error is returned if sensitive data is used and any of the resolved
+// objectDefinitions is a ConfigMap.
 func processObjTemplatesRaw(
 	raw *unstructured.Unstructured,
-	resolver *templates.TemplateResolver,
-	tempCtx templates.TemplateContext,
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
+	rejectSecretInConfigMap bool,
 ) error {
-	resolveOptions := templates.ResolveOptions{InputIsYAML: true}
-
 	oTRaw, _, _ := unstructured.NestedString(raw.Object, "object-templates-raw")
 	if oTRaw == "" {
 		return fmt.Errorf("invalid object-templates-raw after resolving hub templates")
 	}
 
-	if bytes.Contains([]byte(oTRaw), []byte("{{hub")) {
-		return fmt.Errorf("unresolved hub template in YAML input. Use the hub-kubeconfig argument")
+	// The object-templates-raw is resolved as the spec of a ConfigurationPolicy
+	resolved, err := resolveObjectTemplates(
+		map[string]interface{}{"spec": raw.Object}, resolver, tempCtx, nil, rejectSecretInConfigMap,
+	)
+	if err != nil {
+		return err
 	}
 
-	tmplResult, err := resolver.ResolveTemplate([]byte(oTRaw), tempCtx, &resolveOptions)
+	raw.Object = resolved["spec"].(map[string]interface{})
+
+	return nil
+}
+
+// resolveObjectTemplates resolves the managed templates in the object-templates or object-templates-raw of the
+// ConfigurationPolicy objectDefinition with TemplateResolver.ResolveObjectTemplatesWithOptions. Each resolved entry is
+// reported at its field path relative to the path of the resolver, and an error is returned if any hub templates
+// remain. See processObjectTemplates for objTemplateIndex and rejectSecretInConfigMap.
+func resolveObjectTemplates(
+	objectDefinition map[string]interface{},
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
+	objTemplateIndex *int,
+	rejectSecretInConfigMap bool,
+) (map[string]interface{}, error) {
+	resolveOptions := templates.ResolveOptions{
+		AllowNestedContextValues: true,
+		RejectSecretInConfigMap:  rejectSecretInConfigMap,
+	}
+
+	resolved, err := resolver.ResolveObjectTemplatesWithOptions(
+		objectDefinition,
+		&resolveOptions,
+		templates.ObjectTemplatesOptions{
+			Context: tempCtx,
+			Index:   objTemplateIndex,
+			ResolveTemplate: func(
+				fieldPath string, tmplRaw []byte, tmplCtx interface{}, options *templates.ResolveOptions,
+			) (templates.TemplateResult, error) {
+				if bytes.Contains(tmplRaw, []byte("{{hub")) {
+					return templates.TemplateResult{}, errors.New(
+						"unresolved hub template in YAML input. Use the hub-kubeconfig argument",
+					)
+				}
+
+				return resolver.at(fieldPath).ResolveTemplate(tmplRaw, tmplCtx, options)
+			},
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("failed to process the templates: %w", err)
+		return nil, fmt.Errorf("failed to process the templates: %w", err)
 	}
 
-	var resolved interface{}
+	return resolved, nil
+}
 
-	err = json.Unmarshal(tmplResult.ResolvedJSON, &resolved)
+// processPatches resolves the managed templates in each entry of a Kustomize-style patches list. A patch specified
+// as a string in the patch field is resolved as YAML and remains a string, and a patch specified as an object is
+// resolved in place. Entries that only reference a patch file with the path field are left unchanged.
+func processPatches(
+	patchesObj *unstructured.Unstructured,
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
+) error {
+	patches, _, err := unstructured.NestedSlice(patchesObj.Object, "patches")
 	if err != nil {
-		return fmt.Errorf("failed to process the templates: %w", err)
+		return fmt.Errorf("invalid patches array was provided: %w", err)
 	}
 
-	var objectTemplates []interface{}
+	for i, patch := range patches {
+		patchEntry, ok := patch.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid patches entry at index %d was provided", i)
+		}
 
-	switch v := resolved.(type) {
-	case []interface{}:
-		objectTemplates = v
-	case nil:
-		objectTemplates = []interface{}{}
-	default:
-		return fmt.Errorf("object-templates-raw was not an array after templates were resolved")
-	}
+		switch patchValue := patchEntry["patch"].(type) {
+		case string:
+			if strings.Contains(patchValue, "{{hub") {
+				return fmt.Errorf("unresolved hub template in YAML input. Use the hub-kubeconfig argument")
+			}
 
-	unstructured.RemoveNestedField(raw.Object, "object-templates-raw")
+			resolveOptions := templates.ResolveOptions{AllowNestedContextValues: true, InputIsYAML: true}
 
-	err = unstructured.SetNestedSlice(raw.Object, objectTemplates, "object-templates")
+			tmplResult, err := resolver.at(fmt.Sprintf("patches[%d].patch", i)).ResolveTemplate(
+				[]byte(patchValue), tempCtx, &resolveOptions,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to process the templates: %w (in patches at index %d)", err, i)
+			}
+
+			resolvedYAML, err := templates.JSONToYAML(tmplResult.ResolvedJSON)
+			if err != nil {
+				return fmt.Errorf("failed to convert the processed patch at index %d back to YAML: %w", i, err)
+			}
+
+			patchEntry["patch"] = string(resolvedYAML)
+		case map[string]interface{}, []interface{}:
+			resolved, err := resolveManagedTemplate(
+				patchValue,
+				"patch",
+				resolver.at(fmt.Sprintf("patches[%d].patch", i)),
+				templates.ResolveOptions{AllowNestedContextValues: true},
+				tempCtx,
+			)
+			if err != nil {
+				return fmt.Errorf("%w (in patches at index %d)", err, i)
+			}
+
+			patchEntry["patch"] = resolved
+		}
+	}
+
+	err = unstructured.SetNestedSlice(patchesObj.Object, patches, "patches")
 	if err != nil {
-		return fmt.Errorf("failed to process the object-templates-raw: %w", err)
+		return fmt.Errorf("invalid patches after resolving templates: %w", err)
 	}
 
 	return nil
 }
 
-// processObjectTemplates takes any nested object and resolves its managed templates
+// configPolicySpecTemplateFields are the ConfigurationPolicy spec fields other than the object-templates that may
+// contain managed templates.
+var configPolicySpecTemplateFields = []string{"customMessage"}
+
+// processObjectTemplates takes any nested object and resolves its managed templates. If objTemplateIndex is set, the
+// object-templates are filtered to only the entry at that index before resolving. If rejectSecretInConfigMap is true,
+// an error is returned if an object-templates entry that uses sensitive data resolves to a ConfigMap. The templates in
+// configPolicySpecTemplateFields are also resolved when the fields are set.
 func processObjectTemplates(
 	objectDefinition map[string]interface{},
-	resolver *templates.TemplateResolver,
-	tempCtx templates.TemplateContext,
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
+	objTemplateIndex *int,
+	rejectSecretInConfigMap bool,
 ) (map[string]interface{}, error) {
-	_, oTRawFound, _ := unstructured.NestedString(objectDefinition, "spec", "object-templates-raw")
-	if oTRawFound {
-		policy := unstructured.Unstructured{Object: objectDefinition["spec"].(map[string]interface{})}
-
-		err := processObjTemplatesRaw(&policy, resolver, tempCtx)
-		if err != nil {
-			return nil, err
-		}
-
-		objectDefinition["spec"] = policy.Object
-
-		return objectDefinition, nil
+	err := processSpecTemplateFields(objectDefinition, resolver, tempCtx)
+	if err != nil {
+		return nil, err
 	}
 
-	objTemplates, _, err := unstructured.NestedSlice(objectDefinition, "spec", "object-templates")
-	if err != nil {
-		return nil, fmt.Errorf("invalid object-templates array in Configuration Policy: %w", err)
+	oTRaw, oTRawFound, _ := unstructured.NestedString(objectDefinition, "spec", "object-templates-raw")
+	if oTRawFound && oTRaw == "" {
+		return nil, fmt.Errorf("invalid object-templates-raw after resolving hub templates")
 	}
 
-	resolvedTemplates := make([]interface{}, len(objTemplates))
-	resolveOptions := templates.ResolveOptions{InputIsYAML: false}
+	return resolveObjectTemplates(
+		objectDefinition, resolver.at("spec"), tempCtx, objTemplateIndex, rejectSecretInConfigMap,
+	)
+}
 
-	for i, objTemplate := range objTemplates {
-		fieldName := fmt.Sprintf("object-templates[%v]", i)
+// processSpecTemplateFields resolves the managed templates in each of the configPolicySpecTemplateFields that is set in
+// the spec of the ConfigurationPolicy.
+func processSpecTemplateFields(
+	objectDefinition map[string]interface{},
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
+) error {
+	resolveOptions := templates.ResolveOptions{
+		AllowNestedContextValues: true,
+		InputIsYAML:              false,
+	}
 
-		resolved, err := resolveManagedTemplate(objTemplate, fieldName, resolver, resolveOptions, tempCtx)
+	for _, fieldName := range configPolicySpecTemplateFields {
+		field, found, err := unstructured.NestedFieldNoCopy(objectDefinition, "spec", fieldName)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("invalid %s: %w", fieldName, err)
 		}
 
-		resolvedTemplates[i] = resolved
-	}
+		if !found {
+			continue
+		}
 
-	err = unstructured.SetNestedSlice(objectDefinition, resolvedTemplates, "spec", "object-templates")
-	if err != nil {
-		return nil, fmt.Errorf("failed to process the templates: %w", err)
+		resolved, err := resolveManagedTemplate(
+			field, fieldName, resolver.at("spec."+fieldName), resolveOptions, tempCtx,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = unstructured.SetNestedField(objectDefinition, resolved, "spec", fieldName)
+		if err != nil {
+			return fmt.Errorf("invalid %s after resolving templates: %w", fieldName, err)
+		}
 	}
 
-	return objectDefinition, nil
+	return nil
 }
 
 func processOperatorPolicyTemplates(
 	operatorPolicy map[string]interface{},
-	resolver *templates.TemplateResolver,
-	tempCtx templates.TemplateContext,
+	resolver reportingResolver,
+	tempCtx managedTemplateCtx,
 ) (map[string]interface{}, error) {
 	resolveOptions := templates.ResolveOptions{
-		InputIsYAML: false,
+		AllowNestedContextValues: true,
+		InputIsYAML:              false,
 	}
 
 	opGroup, found, err := unstructured.NestedMap(operatorPolicy, "spec", "operatorGroup")
@@ -426,7 +867,9 @@ func processOperatorPolicyTemplates(
 	}
 
 	if found {
-		resolved, err := resolveManagedTemplate(opGroup, "operatorGroup", resolver, resolveOptions, tempCtx)
+		resolved, err := resolveManagedTemplate(
+			opGroup, "operatorGroup", resolver.at("spec.operatorGroup"), resolveOptions, tempCtx,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -443,7 +886,9 @@ func processOperatorPolicyTemplates(
 	}
 
 	if found {
-		resolved, err := resolveManagedTemplate(sub, "subscription", resolver, resolveOptions, tempCtx)
+		resolved, err := resolveManagedTemplate(
+			sub, "subscription", resolver.at("spec.subscription"), resolveOptions, tempCtx,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -462,7 +907,9 @@ func processOperatorPolicyTemplates(
 	}
 
 	if found {
-		resolved, err := resolveManagedTemplate(versions, "versions", resolver, resolveOptions, tempCtx)
+		resolved, err := resolveManagedTemplate(
+			versions, "versions", resolver.at("spec.versions"), resolveOptions, tempCtx,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -489,7 +936,7 @@ func processOperatorPolicyTemplates(
 // resolveHubTemplates takes a hub templateResolver and any nested object and resolves its hub templates
 func resolveHubTemplates(
 	objectDefinition map[string]interface{},
-	hubResolver *templates.TemplateResolver,
+	hubResolver reportingResolver,
 	hubTemplateOpts *hubTemplateOptions,
 ) (map[string]interface{}, error) {
 	objectDefinitionJSON, err := json.Marshal(objectDefinition)
@@ -521,9 +968,9 @@ func resolveHubTemplates(
 func resolveManagedTemplate(
 	field interface{},
 	fieldName string,
-	resolver *templates.TemplateResolver,
+	resolver reportingResolver,
 	resolveOptions templates.ResolveOptions,
-	tempCtx templates.TemplateContext,
+	tempCtx managedTemplateCtx,
 ) (interface{}, error) {
 	rawData, err := json.Marshal(field)
 	if err != nil {
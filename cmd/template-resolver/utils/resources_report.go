@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/stolostron/go-template-utils/v6/pkg/templates"
+)
+
+const (
+	hubSource     = "hub"
+	managedSource = "managed"
+)
+
+// referencedResource is an entry in the resources report written to ProcessTemplateOptions.ResourcesReportPath.
+type referencedResource struct {
+	// Source is "hub" if a hub template referenced the resource and "managed" if a managed cluster template did.
+	Source     string `json:"source"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	// Name is empty for a list query, in which case LabelSelector is the label selector of the query, if any.
+	Name          string `json:"name,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Found is false if the named object was not found. A list query is always considered found.
+	Found bool `json:"found"`
+}
+
+// resourcesReport collects the resources referenced by the hub and managed cluster templates. A nil report doesn't
+// collect anything.
+type resourcesReport struct {
+	resources []referencedResource
+}
+
+// add adds the objects referenced in the template result to the report under the source label if they aren't already
+// present. An object that was not found in any template result is reported as not found.
+func (r *resourcesReport) add(source string, result templates.TemplateResult) {
+	if r == nil {
+		return
+	}
+
+	for _, objID := range result.ReferencedObjects {
+		found := !slices.Contains(result.NotFoundObjects, objID)
+		resource := referencedResource{
+			Source:        source,
+			APIVersion:    schema.GroupVersion{Group: objID.Group, Version: objID.Version}.String(),
+			Kind:          objID.Kind,
+			Namespace:     objID.Namespace,
+			Name:          objID.Name,
+			LabelSelector: objID.Selector,
+		}
+
+		// Compare the entries regardless of whether the resource was found
+		i := slices.IndexFunc(r.resources, func(existing referencedResource) bool {
+			existing.Found = resource.Found
+
+			return existing == resource
+		})
+		if i != -1 {
+			r.resources[i].Found = r.resources[i].Found && found
+
+			continue
+		}
+
+		resource.Found = found
+		r.resources = append(r.resources, resource)
+	}
+}
+
+// write writes the report as a JSON list sorted by the source and then the resource identifiers to the input path.
+func (r *resourcesReport) write(path string) error {
+	resources := slices.Clone(r.resources)
+	if resources == nil {
+		resources = []referencedResource{}
+	}
+
+	slices.SortFunc(resources, func(a, b referencedResource) int {
+		return cmp.Or(
+			cmp.Compare(a.Source, b.Source),
+			cmp.Compare(a.APIVersion, b.APIVersion),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.LabelSelector, b.LabelSelector),
+		)
+	})
+
+	reportJSON, err := json.MarshalIndent(resources, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to generate the resources report: %w", err)
+	}
+
+	err = os.WriteFile(path, append(reportJSON, '\n'), 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write the resources report: %w", err)
+	}
+
+	return nil
+}
+
+// reportingResolver is a TemplateResolver that adds the resources referenced by the resolved templates to the report
+// under the source label. If the explanation is set, the sources of the resolved values are also added to it, where
+// path is the field path in the resolved document of the templates being resolved. If the report and explanation are
+// nil, it behaves the same as the TemplateResolver.
+type reportingResolver struct {
+	*templates.TemplateResolver
+	source      string
+	report      *resourcesReport
+	explanation *explanation
+	path        string
+}
+
+// at returns a copy of the reportingResolver for resolving the templates at the field path relative to the current
+// path.
+func (r reportingResolver) at(path string) reportingResolver {
+	r.path = joinFieldPath(r.path, path)
+
+	return r
+}
+
+// ResolveTemplate calls TemplateResolver.ResolveTemplate with ResolveOptions.TrackReferences set to true if the report
+// is set and ResolveOptions.TrackProvenance set to true if the explanation is set, and adds the referenced resources
+// to the report and the sources of the resolved values to the explanation.
+func (r reportingResolver) ResolveTemplate(
+	tmplRaw []byte, context interface{}, options *templates.ResolveOptions,
+) (templates.TemplateResult, error) {
+	if r.report == nil && r.explanation == nil {
+		return r.TemplateResolver.ResolveTemplate(tmplRaw, context, options)
+	}
+
+	reportOptions := templates.ResolveOptions{}
+	if options != nil {
+		reportOptions = *options
+	}
+
+	if r.report != nil {
+		reportOptions.TrackReferences = true
+	}
+
+	if r.explanation != nil {
+		reportOptions.TrackProvenance = true
+	}
+
+	result, err := r.TemplateResolver.ResolveTemplate(tmplRaw, context, &reportOptions)
+	r.report.add(r.source, result)
+
+	if err == nil {
+		r.explanation.add(r.source, r.path, result)
+	}
+
+	return result, err
+}
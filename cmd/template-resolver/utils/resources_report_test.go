@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stolostron/go-template-utils/v6/pkg/templates"
+)
+
+func TestResourcesReport(t *testing.T) {
+	t.Parallel()
+
+	objects, err := templates.ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cool-car
+  namespace: default
+data:
+  model: Shelby Mustang
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotResolver, err := templates.NewResolverFromSnapshot(objects, templates.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := &resourcesReport{}
+	hubResolver := reportingResolver{TemplateResolver: snapshotResolver, source: hubSource, report: report}
+	resolver := reportingResolver{TemplateResolver: snapshotResolver, source: managedSource, report: report}
+
+	_, err = hubResolver.ResolveTemplate(
+		[]byte(`{"data": "{{ fromConfigMap \"default\" \"cool-car\" \"model\" }}"}`), nil, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tmpl := range []string{
+		`{"data": "{{ fromConfigMap \"default\" \"cool-car\" \"model\" }}"}`,
+		`{"data": "{{ (lookup \"v1\" \"ConfigMap\" \"default\" \"does-not-exist\") | len }}"}`,
+		`{"data": "{{ (lookup \"v1\" \"ConfigMap\" \"default\" \"\" \"app=cars\").items | len }}"}`,
+		`{"data": "{{ fromConfigMap \"default\" \"cool-car\" \"model\" }}"}`,
+	} {
+		_, err = resolver.ResolveTemplate([]byte(tmpl), nil, &templates.ResolveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	if err := report.write(reportPath); err != nil {
+		t.Fatal(err)
+	}
+
+	reportJSON, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resources []referencedResource
+
+	if err := json.Unmarshal(reportJSON, &resources); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []referencedResource{
+		{Source: "hub", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cool-car", Found: true},
+		{
+			Source: "managed", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", LabelSelector: "app=cars",
+			Found: true,
+		},
+		{Source: "managed", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cool-car", Found: true},
+		{Source: "managed", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "does-not-exist"},
+	}
+
+	if !reflect.DeepEqual(resources, expected) {
+		t.Fatalf("expected the report %v, got %v", expected, resources)
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadValues parses the values file at the input path, which must be a single YAML document that is a map or empty,
+// similar to a Helm values file. The values are converted to JSON compatible values so that they have the same types
+// regardless of the YAML formatting. If the path is empty, nil is returned.
+func loadValues(path string) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	valuesBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the values file: %w", err)
+	}
+
+	var values map[string]interface{}
+
+	if err := yaml.Unmarshal(valuesBytes, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse the values file to YAML: %w", err)
+	}
+
+	valuesJSON, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON in the values file: %w", err)
+	}
+
+	values = map[string]interface{}{}
+
+	if err := json.Unmarshal(valuesJSON, &values); err != nil {
+		return nil, fmt.Errorf("invalid JSON in the values file: %w", err)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,586 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultStartDelim = "{{"
+	defaultStopDelim  = "}}"
+)
+
+// Level is the severity of a lint violation.
+type Level string
+
+const (
+	LevelError   Level = "error"
+	LevelWarning Level = "warning"
+	LevelInfo    Level = "info"
+)
+
+// Violation is a single issue found by a lint rule. Line and Column are 1-based.
+type Violation struct {
+	RuleID   string `json:"ruleId"`
+	RuleName string `json:"ruleName"`
+	Level    Level  `json:"level"`
+	Message  string `json:"message"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%d:%d: [%s] %s (%s): %s", v.Line, v.Column, v.Level, v.RuleID, v.RuleName, v.Message)
+}
+
+// Rule is a lint check that can be run against a template string.
+type Rule struct {
+	// ID is the stable identifier of the rule (e.g. GTUL001).
+	ID string
+	// Name is the human readable name of the rule (e.g. mismatchedDelimiters).
+	Name string
+	// Description explains what the rule detects.
+	Description string
+	// Level is the default level of the violations reported by the rule.
+	Level Level
+	check func(templateStr string, cfg LintConfig) []Violation
+}
+
+// LintConfig is a struct containing configuration for LintWithConfig.
+//
+// - EnabledRules is a list of rule IDs to run. If this is not set, all rules are run.
+//
+// - DisabledRules is a list of rule IDs not to run. This takes precedence over EnabledRules.
+//
+// - LevelOverrides is a map of rule IDs to the level the rule's violations should be reported at instead of the
+// rule's default level.
+//
+// - StartDelim customizes the start delimiter used to distinguish a template action. This defaults to "{{".
+//
+// - StopDelim customizes the stop delimiter used to distinguish a template action. This defaults to "}}".
+//
+// - DeprecatedDelimiters is a map of start delimiters that are deprecated, such as "{{hub" during a migration to a
+// new delimiter style, to their replacements, which can be empty. Each usage is reported by the deprecatedDelimiter
+// rule.
+type LintConfig struct {
+	EnabledRules         []string
+	DisabledRules        []string
+	LevelOverrides       map[string]Level
+	StartDelim           string
+	StopDelim            string
+	DeprecatedDelimiters map[string]string
+}
+
+// delimiters returns the start and stop delimiters from the LintConfig, using the defaults for the unset ones.
+func (cfg LintConfig) delimiters() (string, string) {
+	startDelim := cfg.StartDelim
+	if startDelim == "" {
+		startDelim = defaultStartDelim
+	}
+
+	stopDelim := cfg.StopDelim
+	if stopDelim == "" {
+		stopDelim = defaultStopDelim
+	}
+
+	return startDelim, stopDelim
+}
+
+var rules = []Rule{
+	{
+		ID:          "GTUL001",
+		Name:        "mismatchedDelimiters",
+		Description: "A template start delimiter does not have a matching stop delimiter.",
+		Level:       LevelError,
+		check:       checkMismatchedDelimiters,
+	},
+	{
+		ID:          "GTUL003",
+		Name:        "strayClosingDelimiter",
+		Description: "A template stop delimiter does not have a matching start delimiter.",
+		Level:       LevelError,
+		check:       checkStrayClosingDelimiter,
+	},
+	{
+		ID:   "GTUL004",
+		Name: "clusterScopedNeedsAllowlist",
+		Description: "A hub template looks up a cluster-scoped kind, which requires a ClusterScopedAllowList entry " +
+			"when lookups are restricted to a namespace.",
+		Level: LevelInfo,
+		check: checkClusterScopedNeedsAllowlist,
+	},
+	{
+		ID:   "GTUL005",
+		Name: "tabIndentation",
+		Description: "A line is indented with a tab, which YAML does not allow except in the content of block " +
+			"scalars.",
+		Level: LevelWarning,
+		check: checkTabIndentation,
+	},
+	{
+		ID:   "GTUL006",
+		Name: "unbalancedControlStructure",
+		Description: "An if, range, with, define, or block action does not have a matching end action, or an end " +
+			"or else action does not have a matching opening action.",
+		Level: LevelError,
+		check: checkUnbalancedControlStructure,
+	},
+	{
+		ID:          "GTUL007",
+		Name:        "deprecatedDelimiter",
+		Description: "A start delimiter configured as deprecated in LintConfig.DeprecatedDelimiters is used.",
+		Level:       LevelWarning,
+		check:       checkDeprecatedDelimiter,
+	},
+}
+
+const (
+	hubStartDelim = "{{hub"
+	hubStopDelim  = "hub}}"
+)
+
+// controlKeywords are the keywords that start a control structure that must be closed with an end action.
+var controlKeywords = []string{"block", "define", "if", "range", "with"}
+
+// clusterScopedKinds are the commonly looked up cluster-scoped kinds.
+var clusterScopedKinds = []string{
+	"APIService", "ClusterClaim", "ClusterRole", "ClusterRoleBinding", "ClusterVersion", "CustomResourceDefinition",
+	"Infrastructure", "ManagedCluster", "ManagedClusterSet", "MutatingWebhookConfiguration", "Namespace", "Node",
+	"PersistentVolume", "PriorityClass", "StorageClass", "ValidatingWebhookConfiguration",
+}
+
+var (
+	// lookupKindRegex matches a lookup call and captures the kind argument.
+	lookupKindRegex = regexp.MustCompile(`\blookup\s+"[^"]*"\s+"([^"]*)"`)
+	// clusterScopedFuncRegex matches the template functions that always look up cluster-scoped kinds.
+	clusterScopedFuncRegex = regexp.MustCompile(
+		`\b(fromClusterClaim|fromManagedClusterInfo|getNodesWithExactRoles|hasNodesWithExactRoles|ingressDomain|` +
+			`matchingNamespaces)\b`,
+	)
+	clusterScopedFuncKinds = map[string]string{
+		"fromClusterClaim":       "ClusterClaim",
+		"fromManagedClusterInfo": "ClusterClaim",
+		"getNodesWithExactRoles": "Node",
+		"hasNodesWithExactRoles": "Node",
+		"ingressDomain":          "Ingress",
+		"matchingNamespaces":     "Namespace",
+	}
+)
+
+// Rules returns a copy of all the available lint rules.
+func Rules() []Rule {
+	return slices.Clone(rules)
+}
+
+// Lint runs all the lint rules at their default levels against the input template string and returns the violations
+// sorted by position.
+func Lint(templateStr string) []Violation {
+	return LintWithConfig(templateStr, LintConfig{})
+}
+
+// LintWithConfig runs the lint rules selected by the input LintConfig against the input template string and returns
+// the violations sorted by position.
+func LintWithConfig(templateStr string, cfg LintConfig) []Violation {
+	violations := []Violation{}
+
+	for _, rule := range rules {
+		if len(cfg.EnabledRules) != 0 && !slices.Contains(cfg.EnabledRules, rule.ID) {
+			continue
+		}
+
+		if slices.Contains(cfg.DisabledRules, rule.ID) {
+			continue
+		}
+
+		level := rule.Level
+		if override, ok := cfg.LevelOverrides[rule.ID]; ok {
+			level = override
+		}
+
+		for _, violation := range rule.check(templateStr, cfg) {
+			violation.RuleID = rule.ID
+			violation.RuleName = rule.Name
+			violation.Level = level
+
+			violations = append(violations, violation)
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Line != violations[j].Line {
+			return violations[i].Line < violations[j].Line
+		}
+
+		return violations[i].Column < violations[j].Column
+	})
+
+	return violations
+}
+
+// OutputStringViolations returns the violations formatted one per line.
+func OutputStringViolations(violations []Violation) string {
+	var output strings.Builder
+
+	for _, violation := range violations {
+		output.WriteString(violation.String())
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
+
+// OutputJSONViolations returns the violations formatted as an indented JSON array.
+func OutputJSONViolations(violations []Violation) (string, error) {
+	if violations == nil {
+		violations = []Violation{}
+	}
+
+	output, err := json.MarshalIndent(violations, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to format the violations as JSON: %w", err)
+	}
+
+	return string(output) + "\n", nil
+}
+
+// HasBlockingViolations returns true if any of the violations are at the error level.
+func HasBlockingViolations(violations []Violation) bool {
+	return slices.ContainsFunc(violations, func(v Violation) bool { return v.Level == LevelError })
+}
+
+// position converts a byte offset in the input string to a 1-based line and column.
+func position(input string, offset int) (int, int) {
+	before := input[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := len([]rune(before[strings.LastIndex(before, "\n")+1:])) + 1
+
+	return line, column
+}
+
+// checkMismatchedDelimiters reports start delimiters that are not closed by a stop delimiter before the next start
+// delimiter or the end of the input.
+func checkMismatchedDelimiters(templateStr string, cfg LintConfig) []Violation {
+	startDelim, stopDelim := cfg.delimiters()
+	violations := []Violation{}
+	offset := 0
+
+	for {
+		start := strings.Index(templateStr[offset:], startDelim)
+		if start == -1 {
+			break
+		}
+
+		start += offset
+		afterStart := start + len(startDelim)
+
+		stop := strings.Index(templateStr[afterStart:], stopDelim)
+		nextStart := strings.Index(templateStr[afterStart:], startDelim)
+
+		if stop == -1 || (nextStart != -1 && nextStart < stop) {
+			line, column := position(templateStr, start)
+
+			violations = append(violations, Violation{
+				Message: fmt.Sprintf("the %s delimiter is not closed by a %s delimiter", startDelim,
+					stopDelim),
+				Line:   line,
+				Column: column,
+			})
+
+			offset = afterStart
+
+			continue
+		}
+
+		offset = afterStart + stop + len(stopDelim)
+	}
+
+	return violations
+}
+
+// checkTabIndentation reports lines with a tab in the indentation. These otherwise cause confusing YAML parsing errors
+// when the template is resolved.
+func checkTabIndentation(templateStr string, _ LintConfig) []Violation {
+	violations := []Violation{}
+
+	for i, line := range strings.Split(templateStr, "\n") {
+		content := strings.TrimLeft(line, " \t")
+		// Whitespace only lines have no content to misparse
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		tab := strings.IndexByte(line[:len(line)-len(content)], '\t')
+		if tab == -1 {
+			continue
+		}
+
+		violations = append(violations, Violation{
+			Message: "the line is indented with a tab instead of spaces",
+			Line:    i + 1,
+			Column:  tab + 1,
+		})
+	}
+
+	return violations
+}
+
+// checkStrayClosingDelimiter reports stop delimiters that remain after pairing each start delimiter with the next stop
+// delimiter, such as the extra "}}" in "{{ if .Value }}a{{ end }} }}". These would otherwise be output literally.
+func checkStrayClosingDelimiter(templateStr string, cfg LintConfig) []Violation {
+	startDelim, stopDelim := cfg.delimiters()
+	violations := []Violation{}
+	offset := 0
+	open := false
+
+	for {
+		start := strings.Index(templateStr[offset:], startDelim)
+		stop := strings.Index(templateStr[offset:], stopDelim)
+
+		if stop == -1 {
+			break
+		}
+
+		if start != -1 && start < stop {
+			open = true
+			offset += start + len(startDelim)
+
+			continue
+		}
+
+		stop += offset
+
+		if !open {
+			line, column := position(templateStr, stop)
+
+			violations = append(violations, Violation{
+				Message: fmt.Sprintf("the %s delimiter does not have a matching %s delimiter", stopDelim,
+					startDelim),
+				Line:   line,
+				Column: column,
+			})
+		}
+
+		open = false
+		offset = stop + len(stopDelim)
+	}
+
+	return violations
+}
+
+// checkUnbalancedControlStructure reports control structure actions, such as "if" and "range", that are not closed by
+// an "end" action, and "end" or "else" actions that are not inside a control structure. These otherwise cause opaque
+// parse errors when the template is resolved.
+func checkUnbalancedControlStructure(templateStr string, cfg LintConfig) []Violation {
+	startDelim, stopDelim := cfg.delimiters()
+	violations := []Violation{}
+
+	type opener struct {
+		keyword string
+		offset  int
+	}
+
+	openers := []opener{}
+	offset := 0
+
+	for {
+		start := strings.Index(templateStr[offset:], startDelim)
+		if start == -1 {
+			break
+		}
+
+		start += offset
+		afterStart := start + len(startDelim)
+
+		// Unclosed actions are reported by mismatchedDelimiters
+		stop := strings.Index(templateStr[afterStart:], stopDelim)
+		if stop == -1 {
+			break
+		}
+
+		offset = afterStart + stop + len(stopDelim)
+
+		inner := strings.TrimSpace(templateStr[afterStart : afterStart+stop])
+		inner = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(inner, "-"), "-"))
+
+		// Comments don't have keywords
+		if strings.HasPrefix(inner, "/*") {
+			continue
+		}
+
+		keyword := strings.FieldsFunc(inner, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '('
+		})
+		if len(keyword) == 0 {
+			continue
+		}
+
+		switch {
+		case slices.Contains(controlKeywords, keyword[0]):
+			openers = append(openers, opener{keyword: keyword[0], offset: start})
+		case keyword[0] == "else":
+			if len(openers) == 0 || !slices.Contains([]string{"if", "range", "with"}, openers[len(openers)-1].keyword) {
+				line, column := position(templateStr, start)
+
+				violations = append(violations, Violation{
+					Message: "the else action is not inside an if, range, or with action",
+					Line:    line,
+					Column:  column,
+				})
+			}
+		case keyword[0] == "end":
+			if len(openers) == 0 {
+				line, column := position(templateStr, start)
+
+				violations = append(violations, Violation{
+					Message: "the end action does not have a matching if, range, with, define, or block action",
+					Line:    line,
+					Column:  column,
+				})
+
+				continue
+			}
+
+			openers = openers[:len(openers)-1]
+		}
+	}
+
+	for _, unclosed := range openers {
+		line, column := position(templateStr, unclosed.offset)
+
+		violations = append(violations, Violation{
+			Message: fmt.Sprintf("the %s action is not closed by an end action", unclosed.keyword),
+			Line:    line,
+			Column:  column,
+		})
+	}
+
+	return violations
+}
+
+// checkDeprecatedDelimiter reports the usages of the start delimiters in LintConfig.DeprecatedDelimiters. A usage is
+// not reported if a longer start delimiter that is not deprecated, such as the configured "{{hub" when "{{" is
+// deprecated, starts at the same position.
+func checkDeprecatedDelimiter(templateStr string, cfg LintConfig) []Violation {
+	startDelim, _ := cfg.delimiters()
+	violations := []Violation{}
+
+	deprecatedDelims := make([]string, 0, len(cfg.DeprecatedDelimiters))
+
+	for deprecatedDelim := range cfg.DeprecatedDelimiters {
+		if deprecatedDelim != "" {
+			deprecatedDelims = append(deprecatedDelims, deprecatedDelim)
+		}
+	}
+
+	// Check the longest delimiters first so that a usage is only reported for the most specific delimiter
+	sort.Slice(deprecatedDelims, func(i, j int) bool {
+		if len(deprecatedDelims[i]) != len(deprecatedDelims[j]) {
+			return len(deprecatedDelims[i]) > len(deprecatedDelims[j])
+		}
+
+		return deprecatedDelims[i] < deprecatedDelims[j]
+	})
+
+	reported := map[int]bool{}
+
+	for _, deprecatedDelim := range deprecatedDelims {
+		offset := 0
+
+		for {
+			start := strings.Index(templateStr[offset:], deprecatedDelim)
+			if start == -1 {
+				break
+			}
+
+			start += offset
+			offset = start + len(deprecatedDelim)
+
+			if reported[start] {
+				continue
+			}
+
+			if len(startDelim) > len(deprecatedDelim) && strings.HasPrefix(templateStr[start:], startDelim) {
+				continue
+			}
+
+			reported[start] = true
+			line, column := position(templateStr, start)
+
+			message := fmt.Sprintf("the %s delimiter is deprecated", deprecatedDelim)
+			if replacement := cfg.DeprecatedDelimiters[deprecatedDelim]; replacement != "" {
+				message += fmt.Sprintf(", use the %s delimiter instead", replacement)
+			}
+
+			violations = append(violations, Violation{Message: message, Line: line, Column: column})
+		}
+	}
+
+	return violations
+}
+
+// checkClusterScopedNeedsAllowlist reports lookups of cluster-scoped kinds in hub templates. Since hub templates are
+// restricted to the policy namespace, these fail unless the object is on the ClusterScopedAllowList, which the linter
+// doesn't have access to.
+func checkClusterScopedNeedsAllowlist(templateStr string, _ LintConfig) []Violation {
+	violations := []Violation{}
+	offset := 0
+
+	for {
+		start := strings.Index(templateStr[offset:], hubStartDelim)
+		if start == -1 {
+			break
+		}
+
+		start += offset
+		end := len(templateStr)
+
+		stop := strings.Index(templateStr[start:], hubStopDelim)
+		if stop != -1 {
+			end = start + stop
+		}
+
+		action := templateStr[start:end]
+
+		for _, match := range lookupKindRegex.FindAllStringSubmatchIndex(action, -1) {
+			kind := action[match[2]:match[3]]
+			if !slices.Contains(clusterScopedKinds, kind) {
+				continue
+			}
+
+			violations = append(violations, clusterScopedViolation(templateStr, start+match[0], kind))
+		}
+
+		for _, match := range clusterScopedFuncRegex.FindAllStringSubmatchIndex(action, -1) {
+			kind := clusterScopedFuncKinds[action[match[2]:match[3]]]
+
+			violations = append(violations, clusterScopedViolation(templateStr, start+match[0], kind))
+		}
+
+		if stop == -1 {
+			break
+		}
+
+		offset = end + len(hubStopDelim)
+	}
+
+	return violations
+}
+
+func clusterScopedViolation(templateStr string, offset int, kind string) Violation {
+	line, column := position(templateStr, offset)
+
+	return Violation{
+		Message: fmt.Sprintf(
+			"the lookup of the cluster-scoped %s kind in a hub template may require a ClusterScopedAllowList entry",
+			kind,
+		),
+		Line:   line,
+		Column: column,
+	}
+}
@@ -0,0 +1,366 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	unclosedMsg = "the {{ delimiter is not closed by a }} delimiter"
+	strayMsg    = "the }} delimiter does not have a matching {{ delimiter"
+	tabMsg      = "the line is indented with a tab instead of spaces"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input    string
+		expected []Violation
+	}{
+		"no_violations": {
+			input:    "data: '{{ .ClusterName }}'\nother: '{{hub .ManagedClusterName hub}}'\n",
+			expected: []Violation{},
+		},
+		"mismatched_delimiters_unclosed": {
+			input: "data: '{{ .ClusterName '\n",
+			expected: []Violation{
+				{"GTUL001", "mismatchedDelimiters", LevelError, unclosedMsg, 1, 8},
+			},
+		},
+		"mismatched_delimiters_nested_open": {
+			input: "first: '{{ .ClusterName'\nsecond: '{{ .ClusterName }}'\n",
+			expected: []Violation{
+				{"GTUL001", "mismatchedDelimiters", LevelError, unclosedMsg, 1, 9},
+			},
+		},
+		"stray_closing_delimiter": {
+			input: "data: '{{ if .ClusterName }}a{{ else }}b{{ end }} }}'\nother: '}}{{hub .Name hub}}'\n",
+			expected: []Violation{
+				{"GTUL003", "strayClosingDelimiter", LevelError, strayMsg, 1, 51},
+				{"GTUL003", "strayClosingDelimiter", LevelError, strayMsg, 2, 9},
+			},
+		},
+		"stray_closing_delimiter_after_unclosed": {
+			input: "data: '{{ .ClusterName'\nother: '{{ .ClusterName }}'\n",
+			expected: []Violation{
+				{"GTUL001", "mismatchedDelimiters", LevelError, unclosedMsg, 1, 8},
+			},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			violations := Lint(test.input)
+
+			if !reflect.DeepEqual(violations, test.expected) {
+				t.Fatalf("expected violations: %v, got: %v", test.expected, violations)
+			}
+		})
+	}
+}
+
+func TestLintWithConfig(t *testing.T) {
+	t.Parallel()
+
+	input := "data: '{{ .ClusterName '\n\tother: value\n"
+
+	testcases := map[string]struct {
+		cfg      LintConfig
+		expected []Violation
+	}{
+		"all_rules": {
+			cfg: LintConfig{},
+			expected: []Violation{
+				{"GTUL001", "mismatchedDelimiters", LevelError, unclosedMsg, 1, 8},
+				{"GTUL005", "tabIndentation", LevelWarning, tabMsg, 2, 1},
+			},
+		},
+		"enabled_rules": {
+			cfg: LintConfig{EnabledRules: []string{"GTUL005"}},
+			expected: []Violation{
+				{"GTUL005", "tabIndentation", LevelWarning, tabMsg, 2, 1},
+			},
+		},
+		"disabled_rules": {
+			cfg: LintConfig{DisabledRules: []string{"GTUL005"}},
+			expected: []Violation{
+				{"GTUL001", "mismatchedDelimiters", LevelError, unclosedMsg, 1, 8},
+			},
+		},
+		"disabled_overrides_enabled": {
+			cfg:      LintConfig{EnabledRules: []string{"GTUL005"}, DisabledRules: []string{"GTUL005"}},
+			expected: []Violation{},
+		},
+		"level_overrides": {
+			cfg: LintConfig{LevelOverrides: map[string]Level{"GTUL001": LevelWarning, "GTUL005": LevelInfo}},
+			expected: []Violation{
+				{"GTUL001", "mismatchedDelimiters", LevelWarning, unclosedMsg, 1, 8},
+				{"GTUL005", "tabIndentation", LevelInfo, tabMsg, 2, 1},
+			},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			violations := LintWithConfig(input, test.cfg)
+
+			if !reflect.DeepEqual(violations, test.expected) {
+				t.Fatalf("expected violations: %v, got: %v", test.expected, violations)
+			}
+		})
+	}
+}
+
+func TestLintCustomDelimiters(t *testing.T) {
+	t.Parallel()
+
+	input := "data: '{{hub .ManagedClusterName hub}} hub}}'\nother: '{{hub .ManagedClusterName'\nmanaged: '{{ x }}'\n"
+	expected := []Violation{
+		{"GTUL003", "strayClosingDelimiter", LevelError,
+			"the hub}} delimiter does not have a matching {{hub delimiter", 1, 40},
+		{"GTUL001", "mismatchedDelimiters", LevelError,
+			"the {{hub delimiter is not closed by a hub}} delimiter", 2, 9},
+	}
+
+	violations := LintWithConfig(input, LintConfig{StartDelim: "{{hub", StopDelim: "hub}}"})
+
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("expected violations: %v, got: %v", expected, violations)
+	}
+}
+
+func TestLintClusterScopedNeedsAllowlist(t *testing.T) {
+	t.Parallel()
+
+	input := "a: '{{hub (lookup \"v1\" \"Namespace\" \"\" \"default\").metadata.name hub}}'\n" +
+		"b: '{{hub (lookup \"v1\" \"ConfigMap\" \"ns\" \"cm\").data.key hub}}'\n" +
+		"c: '{{hub fromClusterClaim \"env\" hub}}'\n" +
+		"d: '{{ (lookup \"v1\" \"Node\" \"\" \"node1\").metadata.name }}'\n"
+	msg := "the lookup of the cluster-scoped %s kind in a hub template may require a ClusterScopedAllowList entry"
+	expected := []Violation{
+		{"GTUL004", "clusterScopedNeedsAllowlist", LevelInfo, fmt.Sprintf(msg, "Namespace"), 1, 12},
+		{"GTUL004", "clusterScopedNeedsAllowlist", LevelInfo, fmt.Sprintf(msg, "ClusterClaim"), 3, 11},
+	}
+
+	violations := LintWithConfig(input, LintConfig{EnabledRules: []string{"GTUL004"}})
+
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("expected violations: %v, got: %v", expected, violations)
+	}
+}
+
+func TestLintTabIndentation(t *testing.T) {
+	t.Parallel()
+
+	input := "data:\n\tkey: value\n  \tother: '{{ .Value }}'\n  fine: \"a\\tb\"\n\t\n"
+	expected := []Violation{
+		{"GTUL005", "tabIndentation", LevelWarning, tabMsg, 2, 1},
+		{"GTUL005", "tabIndentation", LevelWarning, tabMsg, 3, 3},
+	}
+
+	violations := LintWithConfig(input, LintConfig{EnabledRules: []string{"GTUL005"}})
+
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("expected violations: %v, got: %v", expected, violations)
+	}
+}
+
+func TestLintUnbalancedControlStructure(t *testing.T) {
+	t.Parallel()
+
+	input := "a: '{{ if .A }}x{{ else if .B }}y{{ else }}z{{ end }}'\n" +
+		"b: '{{ end }}'\n" +
+		"c: '{{- else -}}'\n" +
+		"d: '{{/* if */}}{{ define \"t\" }}{{ end }}'\n" +
+		"e: |\n  {{- range $i, $v := .Items }}\n  {{- with $v }}{{ . }}{{ end }}\n" +
+		"f: '{{ with .C }}{{if(eq 1 1)}}'\n"
+	expected := []Violation{
+		{"GTUL006", "unbalancedControlStructure", LevelError,
+			"the end action does not have a matching if, range, with, define, or block action", 2, 5},
+		{"GTUL006", "unbalancedControlStructure", LevelError,
+			"the else action is not inside an if, range, or with action", 3, 5},
+		{"GTUL006", "unbalancedControlStructure", LevelError, "the range action is not closed by an end action", 6, 3},
+		{"GTUL006", "unbalancedControlStructure", LevelError, "the with action is not closed by an end action", 8, 5},
+		{"GTUL006", "unbalancedControlStructure", LevelError, "the if action is not closed by an end action", 8, 18},
+	}
+
+	violations := LintWithConfig(input, LintConfig{EnabledRules: []string{"GTUL006"}})
+
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("expected violations: %v, got: %v", expected, violations)
+	}
+}
+
+func TestLintDeprecatedDelimiter(t *testing.T) {
+	t.Parallel()
+
+	input := "a: '{{hub .ManagedClusterName hub}}'\n" +
+		"b: '{{ .ObjectName }}{{hub fromConfigMap \"ns\" \"cm\" \"key\" hub}}'\n" +
+		"c: '{{% .ManagedClusterName %}}'\n" +
+		"d: '[[ .Legacy ]]'\n"
+	expected := []Violation{
+		{"GTUL007", "deprecatedDelimiter", LevelWarning,
+			"the {{hub delimiter is deprecated, use the {{% delimiter instead", 1, 5},
+		{"GTUL007", "deprecatedDelimiter", LevelWarning,
+			"the {{hub delimiter is deprecated, use the {{% delimiter instead", 2, 22},
+		{"GTUL007", "deprecatedDelimiter", LevelWarning, "the [[ delimiter is deprecated", 4, 5},
+	}
+
+	violations := LintWithConfig(input, LintConfig{
+		EnabledRules:         []string{"GTUL007"},
+		DeprecatedDelimiters: map[string]string{"{{hub": "{{%", "[[": ""},
+	})
+
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("expected violations: %v, got: %v", expected, violations)
+	}
+
+	// A deprecated delimiter that is a prefix of the configured start delimiter is only reported for its own usages
+	violations = LintWithConfig(input, LintConfig{
+		EnabledRules:         []string{"GTUL007"},
+		StartDelim:           "{{%",
+		StopDelim:            "%}}",
+		DeprecatedDelimiters: map[string]string{"{{": "{{%"},
+	})
+
+	msg := "the {{ delimiter is deprecated, use the {{% delimiter instead"
+	expected = []Violation{
+		{"GTUL007", "deprecatedDelimiter", LevelWarning, msg, 1, 5},
+		{"GTUL007", "deprecatedDelimiter", LevelWarning, msg, 2, 5},
+		{"GTUL007", "deprecatedDelimiter", LevelWarning, msg, 2, 22},
+	}
+
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatalf("expected violations: %v, got: %v", expected, violations)
+	}
+
+	if violations := LintWithConfig(input, LintConfig{EnabledRules: []string{"GTUL007"}}); len(violations) != 0 {
+		t.Fatalf("expected no violations without deprecated delimiters, got: %v", violations)
+	}
+}
+
+func TestHasBlockingViolations(t *testing.T) {
+	t.Parallel()
+
+	if HasBlockingViolations(Lint("data:\n\tkey: value\n")) {
+		t.Fatal("expected no blocking violations for a warning")
+	}
+
+	if !HasBlockingViolations(Lint("data: '{{ .ClusterName '\n")) {
+		t.Fatal("expected blocking violations for an error")
+	}
+}
+
+func TestOutputStringViolations(t *testing.T) {
+	t.Parallel()
+
+	output := OutputStringViolations(Lint("data: '{{ .ClusterName '\n\tother: value\n"))
+	expected := "1:8: [error] GTUL001 (mismatchedDelimiters): the {{ delimiter is not closed by a }} delimiter\n" +
+		"2:1: [warning] GTUL005 (tabIndentation): the line is indented with a tab instead of spaces\n"
+
+	if output != expected {
+		t.Fatalf("expected output: %q, got: %q", expected, output)
+	}
+}
+
+func TestOutputJSONViolations(t *testing.T) {
+	t.Parallel()
+
+	output, err := OutputJSONViolations(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output != "[]\n" {
+		t.Fatalf("expected an empty JSON array, got: %q", output)
+	}
+
+	violations := Lint("data: '{{ .ClusterName '\n\tother: value\n")
+
+	output, err = OutputJSONViolations(violations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := []Violation{}
+
+	err = json.Unmarshal([]byte(output), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, violations) {
+		t.Fatalf("expected violations: %v, got: %v", violations, parsed)
+	}
+
+	if !strings.Contains(output, `"ruleId": "GTUL001"`) {
+		t.Fatalf("expected the output to contain the rule ID, got: %s", output)
+	}
+}
+
+func TestOutputSARIFViolations(t *testing.T) {
+	t.Parallel()
+
+	cfg := LintConfig{LevelOverrides: map[string]Level{"GTUL005": LevelInfo}}
+
+	violations := LintWithConfig("data: '{{ .ClusterName '\n\tother: value\n", cfg)
+
+	output, err := OutputSARIFViolations(violations, "policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := sarifLog{}
+
+	err = json.Unmarshal([]byte(output), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Version != "2.1.0" || len(parsed.Runs) != 1 {
+		t.Fatalf("expected a SARIF 2.1.0 log with one run, got: %s", output)
+	}
+
+	if len(parsed.Runs[0].Tool.Driver.Rules) != len(Rules()) {
+		t.Fatalf("expected %d rules, got: %d", len(Rules()), len(parsed.Runs[0].Tool.Driver.Rules))
+	}
+
+	expected := []sarifResult{
+		{
+			RuleID:  "GTUL001",
+			Level:   "error",
+			Message: sarifMessage{Text: unclosedMsg},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: "policy.yaml"},
+				Region:           sarifRegion{StartLine: 1, StartColumn: 8},
+			}}},
+		},
+		{
+			RuleID:  "GTUL005",
+			Level:   "note",
+			Message: sarifMessage{Text: tabMsg},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: "policy.yaml"},
+				Region:           sarifRegion{StartLine: 2, StartColumn: 1},
+			}}},
+		},
+	}
+
+	if !reflect.DeepEqual(parsed.Runs[0].Results, expected) {
+		t.Fatalf("expected results: %v, got: %v", expected, parsed.Runs[0].Results)
+	}
+}
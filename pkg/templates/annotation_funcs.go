@@ -0,0 +1,45 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+)
+
+// annotationTruncationMarker is appended to the values that annotationSafe truncates.
+const annotationTruncationMarker = "..."
+
+// annotationSafe returns the input string with control characters and invalid UTF-8 removed so that it can be used as
+// an annotation value. If the result is longer than maxLen bytes, it's truncated on a character boundary and
+// annotationTruncationMarker is appended so that the returned value is at most maxLen bytes. An error is returned if
+// maxLen is too small to fit the marker or exceeds the total size limit of an object's annotations.
+func annotationSafe(value string, maxLen int) (string, error) {
+	if maxLen < len(annotationTruncationMarker) || maxLen > apivalidation.TotalAnnotationSizeLimitB {
+		return "", fmt.Errorf("%w: the maximum length must be between %d and %d, got %d",
+			ErrInvalidInput, len(annotationTruncationMarker), apivalidation.TotalAnnotationSizeLimitB, maxLen)
+	}
+
+	sanitized := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+
+		return r
+	}, strings.ToValidUTF8(value, ""))
+
+	if len(sanitized) <= maxLen {
+		return sanitized, nil
+	}
+
+	end := maxLen - len(annotationTruncationMarker)
+	for end > 0 && !utf8.RuneStart(sanitized[end]) {
+		end--
+	}
+
+	return sanitized[:end] + annotationTruncationMarker, nil
+}
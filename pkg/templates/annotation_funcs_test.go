@@ -0,0 +1,53 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+	"unicode/utf8"
+
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+)
+
+func TestAnnotationSafe(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		input       string
+		maxLen      int
+		result      string
+		expectedErr error
+	}{
+		{"plain value", 63, "plain value", nil},
+		{"line1\nline2\ttab\x00\x7f", 63, "line1line2tab", nil},
+		{"bell\u0007 and \u0085next line", 63, "bell and next line", nil},
+		{"invalid \xff\xfeutf-8", 63, "invalid utf-8", nil},
+		{"0123456789", 10, "0123456789", nil},
+		{"0123456789a", 10, "0123456...", nil},
+		// "é" is 2 bytes and "日本" is 6 bytes, so they can't be split
+		{"ééééé", 8, "éé...", nil},
+		{"日本語", 8, "日...", nil},
+		{"日本語", 9, "日本語", nil},
+		{"\x01日\x02本\x03語\x04", 9, "日本語", nil},
+		{"日本語", 3, "...", nil},
+		{"", 3, "", nil},
+		{"value", 2, "", ErrInvalidInput},
+		{"value", apivalidation.TotalAnnotationSizeLimitB + 1, "", ErrInvalidInput},
+	}
+
+	for _, test := range testcases {
+		val, err := annotationSafe(test.input, test.maxLen)
+		if !errors.Is(err, test.expectedErr) {
+			t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+		}
+
+		if val != test.result {
+			t.Fatalf("expected : %q , got : %q", test.result, val)
+		}
+
+		if err == nil && (len(val) > test.maxLen || !utf8.ValidString(val)) {
+			t.Fatalf("expected %q to be valid UTF-8 of at most %d bytes", val, test.maxLen)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+)
+
+// checkArgumentCounts returns an error wrapping ErrWrongArgumentCount for the first call in the parsed template, or
+// the templates defined in it, to a function in the function map with the wrong number of arguments for
+// Config.StrictArgumentCounts. The error has the position of the call. This is the same check that text/template does
+// when executing a call, but it applies to every call, including the ones in branches that aren't executed.
+func checkArgumentCounts(tmpl *template.Template, funcMap template.FuncMap) error {
+	for _, definedTmpl := range tmpl.Templates() {
+		if definedTmpl.Tree == nil || definedTmpl.Root == nil {
+			continue
+		}
+
+		checker := argumentCountChecker{tree: definedTmpl.Tree, funcMap: funcMap}
+
+		if err := checker.checkNode(definedTmpl.Root); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// argumentCountChecker walks the nodes of a parsed template tree for checkArgumentCounts.
+type argumentCountChecker struct {
+	tree    *parse.Tree
+	funcMap template.FuncMap
+}
+
+func (c argumentCountChecker) checkNode(node parse.Node) error {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return nil
+		}
+
+		for _, child := range typedNode.Nodes {
+			if err := c.checkNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return c.checkPipe(typedNode.Pipe)
+	case *parse.IfNode:
+		return c.checkBranch(&typedNode.BranchNode)
+	case *parse.RangeNode:
+		return c.checkBranch(&typedNode.BranchNode)
+	case *parse.WithNode:
+		return c.checkBranch(&typedNode.BranchNode)
+	case *parse.TemplateNode:
+		return c.checkPipe(typedNode.Pipe)
+	}
+
+	return nil
+}
+
+func (c argumentCountChecker) checkBranch(branch *parse.BranchNode) error {
+	if err := c.checkPipe(branch.Pipe); err != nil {
+		return err
+	}
+
+	if err := c.checkNode(branch.List); err != nil {
+		return err
+	}
+
+	return c.checkNode(branch.ElseList)
+}
+
+// checkPipe checks the commands of the pipeline. The output of each command is passed as the last argument of the next
+// command.
+func (c argumentCountChecker) checkPipe(pipe *parse.PipeNode) error {
+	if pipe == nil {
+		return nil
+	}
+
+	for i, cmd := range pipe.Cmds {
+		if len(cmd.Args) == 0 {
+			continue
+		}
+
+		if identifier, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+			argCount := len(cmd.Args) - 1
+			if i > 0 {
+				argCount++
+			}
+
+			if err := c.checkCall(identifier, argCount); err != nil {
+				return err
+			}
+		} else if err := c.checkArg(cmd.Args[0]); err != nil {
+			return err
+		}
+
+		for _, arg := range cmd.Args[1:] {
+			if err := c.checkArg(arg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkArg checks an argument of a command. A function used as an argument is called without arguments.
+func (c argumentCountChecker) checkArg(arg parse.Node) error {
+	switch typedArg := arg.(type) {
+	case *parse.IdentifierNode:
+		return c.checkCall(typedArg, 0)
+	case *parse.PipeNode:
+		return c.checkPipe(typedArg)
+	case *parse.ChainNode:
+		return c.checkArg(typedArg.Node)
+	}
+
+	return nil
+}
+
+// checkCall returns an error if the function is in the function map and doesn't accept the number of arguments.
+// Functions that aren't in the function map, such as the text/template built-in functions, are not checked.
+func (c argumentCountChecker) checkCall(identifier *parse.IdentifierNode, argCount int) error {
+	function, ok := c.funcMap[identifier.Ident]
+	if !ok {
+		return nil
+	}
+
+	funcType := reflect.TypeOf(function)
+	if funcType == nil || funcType.Kind() != reflect.Func {
+		return nil
+	}
+
+	expected := funcType.NumIn()
+	location, _ := c.tree.ErrorContext(identifier)
+
+	if funcType.IsVariadic() {
+		if argCount < expected-1 {
+			return fmt.Errorf(
+				"%w: %s: %s expects at least %d arguments, got %d",
+				ErrWrongArgumentCount, location, identifier.Ident, expected-1, argCount,
+			)
+		}
+
+		return nil
+	}
+
+	if argCount != expected {
+		return fmt.Errorf(
+			"%w: %s: %s expects %d arguments, got %d",
+			ErrWrongArgumentCount, location, identifier.Ident, expected, argCount,
+		)
+	}
+
+	return nil
+}
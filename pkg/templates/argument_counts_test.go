@@ -0,0 +1,119 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveTemplateStrictArgumentCounts(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	strictResolver, err := NewResolverFromSnapshot(objects, Config{StrictArgumentCounts: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl     string
+		jsonNative    bool
+		expectedErr   string
+		expectedJSON  string
+		nonStrictJSON string
+	}{
+		"valid": {
+			inputTmpl: "replicas: '{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}'\n" +
+				"name: '{{ (lookup \"v1\" \"ConfigMap\" \"app\" \"app-config\").metadata.name }}'\n" +
+				"count: '{{ len (lookup \"v1\" \"ConfigMap\" \"app\" \"\" \"env=prod\").items }}'\n" +
+				"encoded: '{{ \"value\" | base64enc }}'\n",
+			expectedJSON: `{"count":"1","encoded":"dmFsdWU=","name":"app-config","replicas":"3"}`,
+		},
+		"unexecuted_branch": {
+			inputTmpl: "password: '{{ if false }}{{ fromSecret \"app\" \"app-secret\" }}{{ end }}'\n",
+			expectedErr: "the template function was called with the wrong number of arguments: tmpl:1:28: " +
+				"fromSecret expects 3 arguments, got 2",
+			nonStrictJSON: `{"password":""}`,
+		},
+		"variadic_missing_required": {
+			inputTmpl:   "name: '{{ with false }}{{ lookup \"v1\" \"ConfigMap\" \"app\" }}{{ end }}'\n",
+			expectedErr: "lookup expects at least 4 arguments, got 3",
+		},
+		"piped_argument": {
+			inputTmpl:   "encoded: '{{ if false }}{{ \"value\" | base64enc \"extra\" }}{{ end }}'\n",
+			expectedErr: "base64enc expects 1 arguments, got 2",
+		},
+		"function_as_argument": {
+			inputTmpl:   "value: '{{ if false }}{{ printf \"%s\" fromSecret }}{{ end }}'\n",
+			expectedErr: "fromSecret expects 3 arguments, got 0",
+		},
+		"nested_pipeline": {
+			inputTmpl: "value: '{{ range $i := list }}{{ (fromConfigMap \"app\" \"app-config\") | lower }}" +
+				"{{ end }}'\n",
+			expectedErr: "fromConfigMap expects 3 arguments, got 2",
+		},
+		"defined_template": {
+			inputTmpl: "{{ define \"unused\" }}{{ fromConfigMap \"app\" }}{{ end }}" +
+				"replicas: '{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}'\n",
+			expectedErr: "fromConfigMap expects 3 arguments, got 1",
+		},
+		"json_native": {
+			inputTmpl:   `{"password":"{{ if false }}{{ fromSecret \"app\" \"app-secret\" }}{{ end }}"}`,
+			jsonNative:  true,
+			expectedErr: "fromSecret expects 3 arguments, got 2",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{InputIsYAML: !test.jsonNative, JSONNative: test.jsonNative}
+
+			result, err := strictResolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if test.expectedErr != "" {
+				if !errors.Is(err, ErrWrongArgumentCount) {
+					t.Fatalf("expected an ErrWrongArgumentCount error, got : %v", err)
+				}
+
+				if !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected : %s , got : %s", test.expectedErr, err.Error())
+				}
+			} else {
+				if err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				if string(result.ResolvedJSON) != test.expectedJSON {
+					t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+				}
+			}
+
+			if test.nonStrictJSON == "" {
+				return
+			}
+
+			result, err = resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.nonStrictJSON {
+				t.Fatalf("expected : %s , got : %s", test.nonStrictJSON, string(result.ResolvedJSON))
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+)
+
+// parseCIDR parses the input CIDR and returns the network prefix with any host bits cleared.
+func parseCIDR(cidr string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: the CIDR %q is invalid: %w", ErrInvalidInput, cidr, err)
+	}
+
+	return prefix.Masked(), nil
+}
+
+// addToAddr returns the input address offset by the input number.
+func addToAddr(addr netip.Addr, offset *big.Int) netip.Addr {
+	addrInt := new(big.Int).SetBytes(addr.AsSlice())
+	addrInt.Add(addrInt, offset)
+
+	result, _ := netip.AddrFromSlice(addrInt.FillBytes(make([]byte, addr.BitLen()/8)))
+
+	return result
+}
+
+// cidrHost returns the IP address of the host number within the input CIDR, similar to the Terraform "cidrhost"
+// function. The host number 0 is the network address, so 1 is the first usable host such as a gateway. A negative
+// host number counts back from the end of the range, so -1 is the last address.
+func cidrHost(cidr string, hostNum int) (string, error) {
+	prefix, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	maxHosts := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+
+	offset := big.NewInt(int64(hostNum))
+	if hostNum < 0 {
+		offset.Add(offset, maxHosts)
+	}
+
+	if offset.Sign() < 0 || offset.Cmp(maxHosts) >= 0 {
+		return "", fmt.Errorf(
+			"%w: the host number %d is out of range for the CIDR %s, which has %s addresses",
+			ErrInvalidInput, hostNum, prefix, maxHosts,
+		)
+	}
+
+	return addToAddr(prefix.Addr(), offset).String(), nil
+}
+
+// cidrSubnet returns the subnet of the input CIDR with the prefix length extended by newBits and the network number
+// netNum, similar to the Terraform "cidrsubnet" function. For example, cidrSubnet "10.0.0.0/16" 8 2 is "10.0.2.0/24".
+func cidrSubnet(cidr string, newBits int, netNum int) (string, error) {
+	prefix, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	newPrefixLen := prefix.Bits() + newBits
+	if newBits < 0 || newPrefixLen > prefix.Addr().BitLen() {
+		return "", fmt.Errorf(
+			"%w: the CIDR %s cannot be extended by %d bits", ErrInvalidInput, prefix, newBits,
+		)
+	}
+
+	maxNetNum := new(big.Int).Lsh(big.NewInt(1), uint(newBits))
+
+	if netNum < 0 || big.NewInt(int64(netNum)).Cmp(maxNetNum) >= 0 {
+		return "", fmt.Errorf(
+			"%w: the network number %d is out of range since %d new bits allows %s subnets",
+			ErrInvalidInput, netNum, newBits, maxNetNum,
+		)
+	}
+
+	offset := new(big.Int).Lsh(big.NewInt(int64(netNum)), uint(prefix.Addr().BitLen()-newPrefixLen))
+
+	return netip.PrefixFrom(addToAddr(prefix.Addr(), offset), newPrefixLen).String(), nil
+}
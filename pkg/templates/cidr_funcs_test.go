@@ -0,0 +1,103 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCIDRHost(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		cidr        string
+		hostNum     int
+		expected    string
+		expectedErr bool
+	}{
+		"ipv4_gateway":      {"10.0.0.0/24", 1, "10.0.0.1", false},
+		"ipv4_network":      {"10.0.0.0/24", 0, "10.0.0.0", false},
+		"ipv4_last":         {"10.0.0.0/24", -1, "10.0.0.255", false},
+		"ipv4_host_bits":    {"10.0.3.7/16", 258, "10.0.1.2", false},
+		"ipv4_out_of_range": {"10.0.0.0/24", 256, "", true},
+		"ipv4_negative":     {"10.0.0.0/30", -5, "", true},
+		"ipv6":              {"fd00:1::/64", 10, "fd00:1::a", false},
+		"ipv6_last":         {"fd00:1::/64", -1, "fd00:1::ffff:ffff:ffff:ffff", false},
+		"ipv6_out_of_range": {"fd00:1::/126", 4, "", true},
+		"invalid":           {"10.0.0.0", 1, "", true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := cidrHost(test.cidr, test.hostNum)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestCIDRSubnet(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		cidr        string
+		newBits     int
+		netNum      int
+		expected    string
+		expectedErr bool
+	}{
+		"ipv4":               {"10.0.0.0/16", 8, 2, "10.0.2.0/24", false},
+		"ipv4_first":         {"10.0.0.0/16", 4, 0, "10.0.0.0/20", false},
+		"ipv4_last":          {"10.0.0.0/16", 4, 15, "10.0.240.0/20", false},
+		"ipv4_no_new_bits":   {"10.1.2.3/16", 0, 0, "10.1.0.0/16", false},
+		"ipv4_too_many_bits": {"10.0.0.0/16", 17, 0, "", true},
+		"ipv4_out_of_range":  {"10.0.0.0/16", 4, 16, "", true},
+		"negative_net_num":   {"10.0.0.0/16", 4, -1, "", true},
+		"ipv6":               {"fd00:1::/56", 8, 10, "fd00:1:0:a::/64", false},
+		"ipv6_out_of_range":  {"fd00:1::/56", 8, 256, "", true},
+		"invalid":            {"not-a-cidr", 8, 0, "", true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := cidrSubnet(test.cidr, test.newBits, test.netNum)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
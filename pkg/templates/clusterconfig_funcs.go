@@ -6,23 +6,34 @@ package templates
 import (
 	"errors"
 	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-const clusterClaimAPIVersion string = "cluster.open-cluster-management.io/v1alpha1"
+const (
+	clusterClaimAPIVersion    string = "cluster.open-cluster-management.io/v1alpha1"
+	openshiftConfigAPIVersion string = "config.openshift.io/v1"
+)
 
-func (t *TemplateResolver) fromClusterClaimHelper(options *ResolveOptions) func(string) (string, error) {
+func (t *TemplateResolver) fromClusterClaimHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string) (string, error) {
 	return func(claimName string) (string, error) {
-		return t.fromClusterClaim(options, claimName)
+		return t.fromClusterClaim(options, templateResult, claimName)
 	}
 }
 
 // retrieve the Spec value for the given clusterclaim.
-func (t *TemplateResolver) fromClusterClaim(options *ResolveOptions, claimName string) (string, error) {
+func (t *TemplateResolver) fromClusterClaim(
+	options *ResolveOptions, templateResult *TemplateResult, claimName string,
+) (string, error) {
 	if claimName == "" {
 		return "", errors.New("a claim name must be provided")
 	}
 
-	clusterClaim, err := t.getOrList(options, nil, clusterClaimAPIVersion, "ClusterClaim", "", claimName)
+	clusterClaim, err := t.getOrList(options, templateResult, clusterClaimAPIVersion, "ClusterClaim", "", claimName)
 	if err != nil {
 		return "", err
 	}
@@ -40,3 +51,120 @@ func (t *TemplateResolver) fromClusterClaim(options *ResolveOptions, claimName s
 
 	return value, nil
 }
+
+// managedClusterInfoClaims maps the fields of fromManagedClusterInfo to the ClusterClaims that the klusterlet creates
+// on the managed cluster.
+var managedClusterInfoClaims = map[string]string{
+	"id":          "id.k8s.io",
+	"kubeVersion": "kubeversion.open-cluster-management.io",
+	"name":        "name",
+	"platform":    "platform.open-cluster-management.io",
+	"product":     "product.open-cluster-management.io",
+	"region":      "region.open-cluster-management.io",
+}
+
+func (t *TemplateResolver) fromManagedClusterInfoHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string) (string, error) {
+	return func(field string) (string, error) {
+		return t.fromManagedClusterInfo(options, templateResult, field)
+	}
+}
+
+// fromManagedClusterInfo returns the identity information of the managed cluster from the ClusterClaims on it, which
+// the klusterlet keeps in sync and also reports to the hub in the ManagedCluster status. The well-known fields in
+// managedClusterInfoClaims, such as "id" and "platform", are mapped to their ClusterClaims and any other field is the
+// name of a custom ClusterClaim. An empty string is returned if the ClusterClaim or the ClusterClaim API doesn't
+// exist, such as when the cluster is not managed.
+func (t *TemplateResolver) fromManagedClusterInfo(
+	options *ResolveOptions, templateResult *TemplateResult, field string,
+) (string, error) {
+	if field == "" {
+		return "", errors.New("a field must be provided")
+	}
+
+	claimName := field
+	if wellKnownClaim, ok := managedClusterInfoClaims[field]; ok {
+		claimName = wellKnownClaim
+	}
+
+	value, err := t.fromClusterClaim(options, templateResult, claimName)
+	if err != nil {
+		if errors.Is(err, ErrMissingAPIResource) || apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return value, nil
+}
+
+func (t *TemplateResolver) ingressDomainHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func() (string, error) {
+	return func() (string, error) {
+		return t.ingressDomain(options, templateResult)
+	}
+}
+
+// ingressDomain returns the spec.domain value of the OpenShift Ingress configuration named cluster, which is the
+// domain of the cluster's applications. An empty string is returned if the cluster is not OpenShift.
+func (t *TemplateResolver) ingressDomain(options *ResolveOptions, templateResult *TemplateResult) (string, error) {
+	ingress, err := t.getOrList(options, templateResult, openshiftConfigAPIVersion, "Ingress", "", "cluster")
+	if err != nil {
+		if errors.Is(err, ErrMissingAPIResource) || apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	domain, _, _ := unstructured.NestedString(ingress, "spec", "domain")
+
+	return domain, nil
+}
+
+func (t *TemplateResolver) infrastructureHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string) (string, error) {
+	return func(field string) (string, error) {
+		return t.infrastructure(options, templateResult, field)
+	}
+}
+
+// infrastructure returns the value of the field at the dot separated path (e.g. "status.platformStatus.aws.region")
+// in the OpenShift Infrastructure configuration named cluster, which has the platform, region, and API URLs of the
+// cluster. An empty string is returned if the field doesn't exist or the cluster is not OpenShift, and an error is
+// returned if the field is not a string, number, or boolean.
+func (t *TemplateResolver) infrastructure(
+	options *ResolveOptions, templateResult *TemplateResult, field string,
+) (string, error) {
+	if field == "" {
+		return "", errors.New("a field must be provided")
+	}
+
+	infra, err := t.getOrList(options, templateResult, openshiftConfigAPIVersion, "Infrastructure", "", "cluster")
+	if err != nil {
+		if errors.Is(err, ErrMissingAPIResource) || apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	value, found, err := unstructured.NestedFieldNoCopy(infra, strings.Split(field, ".")...)
+	if err != nil || !found || value == nil {
+		return "", nil
+	}
+
+	switch value.(type) {
+	case string, bool, int64, float64:
+		return fmt.Sprint(value), nil
+	default:
+		return "", fmt.Errorf(
+			"%w: the %s field of the Infrastructure cluster is not a string, number, or boolean",
+			ErrInvalidInput, field,
+		)
+	}
+}
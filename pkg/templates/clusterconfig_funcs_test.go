@@ -2,7 +2,10 @@
 
 package templates
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestFromClusterClaimInvalidInput(t *testing.T) {
 	resolver, err := NewResolver(k8sConfig, Config{})
@@ -10,7 +13,7 @@ func TestFromClusterClaimInvalidInput(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 
-	rv, err := resolver.fromClusterClaim(nil, "")
+	rv, err := resolver.fromClusterClaim(nil, nil, "")
 	if err == nil || err.Error() != "a claim name must be provided" {
 		t.Fatalf("Expected an error for the missing claim name but got %v", err)
 	}
@@ -26,7 +29,7 @@ func TestFromClusterClaimNotFound(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 
-	rv, err := resolver.fromClusterClaim(&ResolveOptions{}, "something-nonexistent")
+	rv, err := resolver.fromClusterClaim(&ResolveOptions{}, nil, "something-nonexistent")
 
 	expectedMsg := `clusterclaims.cluster.open-cluster-management.io "something-nonexistent" not found`
 	if err == nil || err.Error() != expectedMsg {
@@ -37,3 +40,234 @@ func TestFromClusterClaimNotFound(t *testing.T) {
 		t.Fatalf("Expected no return value due to the error but got %v", rv)
 	}
 }
+
+func TestIngressDomain(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: config.openshift.io/v1
+kind: Ingress
+metadata:
+  name: cluster
+spec:
+  domain: apps.example.com
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	openshiftResolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	kubernetesResolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	allowList := []ClusterScopedObjectIdentifier{{Group: "config.openshift.io", Kind: "Ingress", Name: "cluster"}}
+
+	testcases := map[string]struct {
+		resolver       *TemplateResolver
+		options        *ResolveOptions
+		expectedResult string
+		expectedErr    error
+	}{
+		"openshift":     {openshiftResolver, &ResolveOptions{}, "apps.example.com", nil},
+		"not_openshift": {kubernetesResolver, &ResolveOptions{}, "", nil},
+		"allowlisted": {
+			openshiftResolver,
+			&ResolveOptions{LookupNamespace: "policies", ClusterScopedAllowList: allowList},
+			"apps.example.com",
+			nil,
+		},
+		"restricted": {
+			openshiftResolver,
+			&ResolveOptions{LookupNamespace: "policies"},
+			"",
+			ClusterScopedLookupRestrictedError{"Ingress", "cluster"},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			domain, err := test.resolver.ingressDomain(test.options, &TemplateResult{})
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if domain != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, domain)
+			}
+		})
+	}
+}
+
+func TestInfrastructure(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: config.openshift.io/v1
+kind: Infrastructure
+metadata:
+  name: cluster
+spec:
+  platformSpec:
+    type: AWS
+status:
+  apiServerURL: https://api.example.com:6443
+  controlPlaneTopology: HighlyAvailable
+  platformStatus:
+    type: AWS
+    aws:
+      region: us-east-1
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	openshiftResolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	kubernetesResolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	allowList := []ClusterScopedObjectIdentifier{
+		{Group: "config.openshift.io", Kind: "Infrastructure", Name: "cluster"},
+	}
+
+	testcases := map[string]struct {
+		resolver       *TemplateResolver
+		options        *ResolveOptions
+		field          string
+		expectedResult string
+		expectedErr    error
+	}{
+		"region": {openshiftResolver, &ResolveOptions{}, "status.platformStatus.aws.region", "us-east-1", nil},
+		"api_url": {
+			openshiftResolver, &ResolveOptions{}, "status.apiServerURL", "https://api.example.com:6443", nil,
+		},
+		"missing_field": {openshiftResolver, &ResolveOptions{}, "status.platformStatus.gcp.region", "", nil},
+		"not_openshift": {kubernetesResolver, &ResolveOptions{}, "status.platformStatus.aws.region", "", nil},
+		"not_a_scalar":  {openshiftResolver, &ResolveOptions{}, "status.platformStatus", "", ErrInvalidInput},
+		"allowlisted": {
+			openshiftResolver,
+			&ResolveOptions{LookupNamespace: "policies", ClusterScopedAllowList: allowList},
+			"status.platformStatus.type",
+			"AWS",
+			nil,
+		},
+		"restricted": {
+			openshiftResolver,
+			&ResolveOptions{LookupNamespace: "policies"},
+			"status.platformStatus.type",
+			"",
+			ClusterScopedLookupRestrictedError{"Infrastructure", "cluster"},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := test.resolver.infrastructure(test.options, &TemplateResult{}, test.field)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if value != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, value)
+			}
+		})
+	}
+
+	_, err = openshiftResolver.infrastructure(&ResolveOptions{}, &TemplateResult{}, "")
+	if err == nil || err.Error() != "a field must be provided" {
+		t.Fatalf("Expected an error for the missing field but got %v", err)
+	}
+}
+
+func TestFromManagedClusterInfo(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: cluster.open-cluster-management.io/v1alpha1
+kind: ClusterClaim
+metadata:
+  name: id.k8s.io
+spec:
+  value: 0e2a7f3c-5c4d-4f4e-9a49-1b6f1f9d3c2a
+---
+apiVersion: cluster.open-cluster-management.io/v1alpha1
+kind: ClusterClaim
+metadata:
+  name: platform.open-cluster-management.io
+spec:
+  value: AWS
+---
+apiVersion: cluster.open-cluster-management.io/v1alpha1
+kind: ClusterClaim
+metadata:
+  name: env
+spec:
+  value: dev
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	managedResolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	unmanagedResolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		resolver       *TemplateResolver
+		field          string
+		expectedResult string
+	}{
+		"id":           {managedResolver, "id", "0e2a7f3c-5c4d-4f4e-9a49-1b6f1f9d3c2a"},
+		"platform":     {managedResolver, "platform", "AWS"},
+		"custom_claim": {managedResolver, "env", "dev"},
+		"missing":      {managedResolver, "region", ""},
+		"unmanaged":    {unmanagedResolver, "id", ""},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := test.resolver.fromManagedClusterInfo(&ResolveOptions{}, &TemplateResult{}, test.field)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if value != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, value)
+			}
+		})
+	}
+
+	_, err = managedResolver.fromManagedClusterInfo(&ResolveOptions{}, &TemplateResult{}, "")
+	if err == nil || err.Error() != "a field must be provided" {
+		t.Fatalf("Expected an error for the missing field but got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"sync"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// resolveSnapshot is the point-in-time view of the cache for ResolveOptions.ConsistentSnapshot. It starts with the
+// objects watched by the watcher when the resolve starts, and the objects and list queries first read during the
+// resolve are added so that later reads of them return the same result.
+type resolveSnapshot struct {
+	lock sync.Mutex
+	// objects are keyed by the identifier of each object without a selector. A nil value is a not found result.
+	objects map[client.ObjectIdentifier]*unstructured.Unstructured
+	// lists are keyed by the identifier of the list query.
+	lists map[client.ObjectIdentifier][]unstructured.Unstructured
+}
+
+// newResolveSnapshot returns a resolveSnapshot with a copy of the objects watched by the watcher in the cache.
+func (t *TemplateResolver) newResolveSnapshot(watcher client.ObjectIdentifier) (*resolveSnapshot, error) {
+	watched, err := t.dynamicWatcher.ListWatchedFromCache(watcher)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &resolveSnapshot{
+		objects: make(map[client.ObjectIdentifier]*unstructured.Unstructured, len(watched)),
+		lists:   map[client.ObjectIdentifier][]unstructured.Unstructured{},
+	}
+
+	for i := range watched {
+		snapshot.objects[snapshotObjectID(&watched[i])] = &watched[i]
+	}
+
+	return snapshot, nil
+}
+
+// snapshotObjectID returns the identifier of the object used as a key in resolveSnapshot.objects.
+func snapshotObjectID(obj *unstructured.Unstructured) client.ObjectIdentifier {
+	gvk := obj.GroupVersionKind()
+
+	return client.ObjectIdentifier{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// get returns a copy of the object in the snapshot with the identifier. If it's not in the snapshot, the input result
+// from the cache, which may be nil if the object was not found, is added to the snapshot and a copy of it is returned.
+func (s *resolveSnapshot) get(
+	objID client.ObjectIdentifier, result *unstructured.Unstructured,
+) *unstructured.Unstructured {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if snapshotObj, ok := s.objects[objID]; ok {
+		result = snapshotObj
+	} else {
+		s.objects[objID] = result
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return result.DeepCopy()
+}
+
+// list returns a copy of the list query result in the snapshot with the identifier. If it's not in the snapshot, the
+// input result from the cache is added to the snapshot with the objects already in the snapshot replaced by their
+// snapshot version, or removed if they were not found, and a copy of it is returned.
+func (s *resolveSnapshot) list(
+	listID client.ObjectIdentifier, result []unstructured.Unstructured,
+) []unstructured.Unstructured {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snapshotItems, ok := s.lists[listID]
+	if !ok {
+		snapshotItems = make([]unstructured.Unstructured, 0, len(result))
+
+		for i := range result {
+			objID := snapshotObjectID(&result[i])
+
+			snapshotObj, ok := s.objects[objID]
+			if !ok {
+				snapshotObj = result[i].DeepCopy()
+				s.objects[objID] = snapshotObj
+			}
+
+			if snapshotObj != nil {
+				snapshotItems = append(snapshotItems, *snapshotObj)
+			}
+		}
+
+		s.lists[listID] = snapshotItems
+	}
+
+	items := make([]unstructured.Unstructured, len(snapshotItems))
+	for i := range snapshotItems {
+		items[i] = *snapshotItems[i].DeepCopy()
+	}
+
+	return items
+}
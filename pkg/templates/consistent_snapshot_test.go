@@ -0,0 +1,106 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newSnapshotConfigMap(name string, value string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "app"},
+		"data":       map[string]interface{}{"value": value},
+	}}
+}
+
+func snapshotConfigMapValue(obj *unstructured.Unstructured) string {
+	if obj == nil {
+		return "<nil>"
+	}
+
+	value, _, _ := unstructured.NestedString(obj.Object, "data", "value")
+
+	return value
+}
+
+func TestResolveSnapshot(t *testing.T) {
+	t.Parallel()
+
+	watched := newSnapshotConfigMap("watched", "start")
+
+	snapshot := &resolveSnapshot{
+		objects: map[client.ObjectIdentifier]*unstructured.Unstructured{snapshotObjectID(watched): watched},
+		lists:   map[client.ObjectIdentifier][]unstructured.Unstructured{},
+	}
+
+	watchedID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "watched"}
+	otherID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "other"}
+	missingID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "missing"}
+	listID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app"}
+
+	// The object watched when the resolve started is returned even though the cache has a newer version
+	result := snapshot.get(watchedID, newSnapshotConfigMap("watched", "updated"))
+	if snapshotConfigMapValue(result) != "start" {
+		t.Fatalf("expected : start , got : %s", snapshotConfigMapValue(result))
+	}
+
+	// Modifying the returned object must not modify the snapshot
+	_ = unstructured.SetNestedField(result.Object, "modified", "data", "value")
+
+	result = snapshot.get(watchedID, newSnapshotConfigMap("watched", "updated"))
+	if snapshotConfigMapValue(result) != "start" {
+		t.Fatalf("expected : start , got : %s", snapshotConfigMapValue(result))
+	}
+
+	// An object first read during the resolve keeps the version of the first read
+	result = snapshot.get(otherID, newSnapshotConfigMap("other", "first"))
+	if snapshotConfigMapValue(result) != "first" {
+		t.Fatalf("expected : first , got : %s", snapshotConfigMapValue(result))
+	}
+
+	result = snapshot.get(otherID, newSnapshotConfigMap("other", "second"))
+	if snapshotConfigMapValue(result) != "first" {
+		t.Fatalf("expected : first , got : %s", snapshotConfigMapValue(result))
+	}
+
+	// A not found result stays not found
+	if result := snapshot.get(missingID, nil); result != nil {
+		t.Fatalf("expected a nil result, got : %v", result.Object)
+	}
+
+	// The list uses the snapshot versions of the objects and excludes the object that was not found
+	items := snapshot.list(listID, []unstructured.Unstructured{
+		*newSnapshotConfigMap("watched", "updated"),
+		*newSnapshotConfigMap("other", "second"),
+		*newSnapshotConfigMap("missing", "created"),
+		*newSnapshotConfigMap("new", "listed"),
+	})
+
+	values := []string{}
+	for i := range items {
+		values = append(values, items[i].GetName()+"="+snapshotConfigMapValue(&items[i]))
+	}
+
+	expected := "[watched=start other=first new=listed]"
+	if got := fmt.Sprint(values); got != expected {
+		t.Fatalf("expected : %s , got : %s", expected, got)
+	}
+
+	// The list query result is kept for the rest of the resolve
+	items = snapshot.list(listID, []unstructured.Unstructured{})
+	if len(items) != 3 {
+		t.Fatalf("expected the list to have 3 items, got : %d", len(items))
+	}
+
+	// An object first read through the list keeps the listed version
+	result = snapshot.get(client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "new"}, nil)
+	if snapshotConfigMapValue(result) != "listed" {
+		t.Fatalf("expected : listed , got : %s", snapshotConfigMapValue(result))
+	}
+}
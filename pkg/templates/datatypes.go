@@ -0,0 +1,428 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// explicitDataTypeFunc is the internal template function appended to actions that end with toInt or toBool when
+	// Config.ExplicitDataTypes is set.
+	explicitDataTypeFunc = "_explicitDataType"
+	// explicitDataTypePrefix is the start of the placeholder output by explicitDataType.
+	explicitDataTypePrefix = "$gtu_explicit_"
+	// structuredValueFunc is the internal template function appended to actions that are a whole YAML value when
+	// Config.StructuredValues is set.
+	structuredValueFunc = "_structuredValue"
+	// structuredValuePrefix is the start of the placeholder output by structuredValue.
+	structuredValuePrefix = "$gtu_structured:"
+)
+
+var (
+	// explicitDataTypePipe matches a template action pipeline that ends with toInt or toBool.
+	explicitDataTypePipe = regexp.MustCompile(`\|\s*(toInt|toBool)$`)
+	// explicitDataTypeAssignment matches a template action that assigns a variable and so has no output.
+	explicitDataTypeAssignment = regexp.MustCompile(`^\$\w*\s*:?=`)
+	// explicitDataTypePlaceholder matches the placeholders output by explicitDataType.
+	explicitDataTypePlaceholder = regexp.MustCompile(`\$gtu_explicit_(int|bool):([^$]*)\$`)
+	// structuredValuePlaceholder matches the placeholders output by structuredValue.
+	structuredValuePlaceholder = regexp.MustCompile(`\$gtu_structured:([A-Za-z0-9+/]*=*)\$`)
+)
+
+// explicitDataTypeKeywords are the keywords that start template actions whose pipeline is not output.
+var explicitDataTypeKeywords = []string{"block", "define", "else", "if", "range", "template", "with"}
+
+// processForExplicitDataTypes appends the explicitDataType template function to the template actions whose pipeline
+// ends with toInt or toBool so that the type of the value can be set explicitly in the resolved template rather than
+// relying on YAML inference.
+// ex-1 key: '{{ "6" | toInt }}' .. is replaced with key: '{{ "6" | toInt | _explicitDataType "int" }}'
+func (t *TemplateResolver) processForExplicitDataTypes(str string) string {
+	d1 := regexp.QuoteMeta(t.config.StartDelim)
+	d2 := regexp.QuoteMeta(t.config.StopDelim)
+	re := regexp.MustCompile(`(` + d1 + `)([^\n]*?)(` + d2 + `)`)
+
+	return re.ReplaceAllStringFunc(str, func(action string) string {
+		inner := strings.TrimSuffix(strings.TrimPrefix(action, t.config.StartDelim), t.config.StopDelim)
+		pipeline := strings.TrimRight(inner, " \t")
+		suffix := inner[len(pipeline):]
+
+		// Preserve the trim marker (e.g. " -}}") after the appended function
+		if strings.HasSuffix(pipeline, " -") || strings.HasSuffix(pipeline, "\t-") {
+			suffix = pipeline[len(pipeline)-2:] + suffix
+			pipeline = strings.TrimRight(pipeline[:len(pipeline)-2], " \t")
+		}
+
+		match := explicitDataTypePipe.FindStringSubmatch(pipeline)
+		if match == nil {
+			return action
+		}
+
+		start := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(pipeline, " \t"), "-"), " \t")
+		firstWord, _, _ := strings.Cut(start, " ")
+
+		if slices.Contains(explicitDataTypeKeywords, firstWord) || explicitDataTypeAssignment.MatchString(start) {
+			return action
+		}
+
+		dataType := "int"
+		if match[1] == "toBool" {
+			dataType = "bool"
+		}
+
+		return fmt.Sprintf(
+			"%s%s | %s %q%s%s",
+			t.config.StartDelim, pipeline, explicitDataTypeFunc, dataType, suffix, t.config.StopDelim,
+		)
+	})
+}
+
+// explicitDataType returns a placeholder of the value with its data type which is replaced with the typed value by
+// applyExplicitDataTypes after the template is resolved.
+func explicitDataType(dataType string, value interface{}) string {
+	return fmt.Sprintf("%s%s:%v$", explicitDataTypePrefix, dataType, value)
+}
+
+// applyExplicitDataTypes replaces the placeholders from explicitDataType in the resolved YAML. If a string value is
+// just a placeholder, it is replaced with an integer or boolean value. Otherwise, the placeholders are replaced with
+// the value as a string. Comments in the input are retained.
+func applyExplicitDataTypes(y []byte) ([]byte, error) {
+	if !bytes.Contains(y, []byte(explicitDataTypePrefix)) {
+		return y, nil
+	}
+
+	var node yaml.Node
+
+	err := yaml.Unmarshal(y, &node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	applyExplicitDataTypesToNode(&node, false)
+
+	var b bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&b)
+	yamlEncoder.SetIndent(yamlIndentation)
+
+	err = yamlEncoder.Encode(&node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return b.Bytes(), nil
+}
+
+// applyExplicitDataTypesToNode recursively replaces the placeholders from explicitDataType in the YAML node tree.
+// Mapping keys always remain strings.
+func applyExplicitDataTypesToNode(node *yaml.Node, isKey bool) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			applyExplicitDataTypesToNode(child, false)
+		}
+	case yaml.MappingNode:
+		for i, child := range node.Content {
+			applyExplicitDataTypesToNode(child, i%2 == 0)
+		}
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!str" || !strings.Contains(node.Value, explicitDataTypePrefix) {
+			return
+		}
+
+		match := explicitDataTypePlaceholder.FindStringSubmatch(node.Value)
+		if !isKey && match != nil && match[0] == node.Value {
+			switch match[1] {
+			case "int":
+				if _, err := strconv.ParseInt(match[2], 10, 64); err == nil {
+					node.Value = match[2]
+					node.Tag = "!!int"
+					node.Style = 0
+
+					return
+				}
+			case "bool":
+				if _, err := strconv.ParseBool(match[2]); err == nil {
+					node.Value = match[2]
+					node.Tag = "!!bool"
+					node.Style = 0
+
+					return
+				}
+			}
+		}
+
+		node.Value = explicitDataTypePlaceholder.ReplaceAllString(node.Value, "$2")
+	}
+}
+
+// numericContextFieldName matches the map keys in the context that can be referenced with a field path.
+var numericContextFieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// isNumericKind returns true if the input kind is an integer or floating point number.
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// numericContextFields returns the field paths (e.g. "Cluster.ID") of the numeric values in the input context used by
+// ResolveOptions.AutoTypeNumericContext. The fields of embedded structs are also returned with the promoted field path.
+func numericContextFields(value reflect.Value, path string) []string {
+	childPath := func(name string) string {
+		if path == "" {
+			return name
+		}
+
+		return path + "." + name
+	}
+
+	paths := []string{}
+
+	switch value.Kind() {
+	case reflect.Interface:
+		if !value.IsNil() {
+			paths = append(paths, numericContextFields(value.Elem(), path)...)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			if field.Anonymous {
+				paths = append(paths, numericContextFields(value.Field(i), path)...)
+			}
+
+			paths = append(paths, numericContextFields(value.Field(i), childPath(field.Name))...)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			if !numericContextFieldName.MatchString(key.String()) {
+				continue
+			}
+
+			paths = append(paths, numericContextFields(value.MapIndex(key), childPath(key.String()))...)
+		}
+	default:
+		if path != "" && isNumericKind(value.Kind()) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// processForNumericContext removes the quotes enclosing a template that only outputs a numeric context field, such as
+// '{{ .ClusterID }}', so that the value is a number in the resolved YAML. This is used when
+// ResolveOptions.AutoTypeNumericContext is set and complements processForDataTypes, which removes the quotes enclosing
+// templates that use functions such as toInt.
+// ex-1 key: '{{ .ClusterID }}' .. is replaced with key: {{ .ClusterID }}
+// ex-2 - '{{ .Port }}' .. is replaced with - {{ .Port }}
+// ex-3 key: '{{- .ClusterID -}}' .. is replaced with key: {{ .ClusterID }}
+func (t *TemplateResolver) processForNumericContext(str string, fieldPaths []string) string {
+	if len(fieldPaths) == 0 {
+		return str
+	}
+
+	quotedPaths := make([]string, 0, len(fieldPaths))
+
+	for _, fieldPath := range fieldPaths {
+		quotedPaths = append(quotedPaths, regexp.QuoteMeta(fieldPath))
+	}
+
+	d1 := regexp.QuoteMeta(t.config.StartDelim)
+	d2 := regexp.QuoteMeta(t.config.StopDelim)
+	re := regexp.MustCompile(
+		`(?m)(:[ \t]+|^[ \t]*-[ \t]+)['"](` + d1 + `-?\s*\$?\.(?:` + strings.Join(quotedPaths, "|") + `)\s*-?` +
+			d2 + `)['"]([ \t]*(?:#.*)?)$`,
+	)
+
+	return re.ReplaceAllStringFunc(str, func(match string) string {
+		submatch := re.FindStringSubmatch(match)
+
+		return submatch[1] + t.trimChompMarkers(submatch[2]) + submatch[3]
+	})
+}
+
+// processForStructuredValues appends the structuredValue template function to the template actions that are the whole
+// quoted YAML value of a mapping key or a list item, so that a map or list output by the action can be set as a nested
+// YAML value in the resolved template rather than as a string. Actions that don't output their pipeline, such as "if"
+// and variable assignments, and actions whose pipeline ends with toInt or toBool are not changed.
+// ex-1 key: '{{ .Labels }}' .. is replaced with key: '{{ .Labels | _structuredValue }}'
+// ex-2 - '{{ list "a" "b" }}' .. is replaced with - '{{ list "a" "b" | _structuredValue }}'
+func (t *TemplateResolver) processForStructuredValues(str string) string {
+	d1 := regexp.QuoteMeta(t.config.StartDelim)
+	d2 := regexp.QuoteMeta(t.config.StopDelim)
+	re := regexp.MustCompile(`(?m)(:[ \t]+|^[ \t]*-[ \t]+)(['"])(` + d1 + `[^\n]*` + d2 + `)(['"][ \t]*(?:#.*)?)$`)
+
+	return re.ReplaceAllStringFunc(str, func(match string) string {
+		submatch := re.FindStringSubmatch(match)
+		inner := strings.TrimSuffix(strings.TrimPrefix(submatch[3], t.config.StartDelim), t.config.StopDelim)
+
+		// Skip values with multiple template actions
+		if strings.Contains(inner, t.config.StartDelim) || strings.Contains(inner, t.config.StopDelim) {
+			return match
+		}
+
+		pipeline := strings.TrimRight(inner, " \t")
+		suffix := inner[len(pipeline):]
+
+		// Preserve the trim marker (e.g. " -}}") after the appended function
+		if strings.HasSuffix(pipeline, " -") || strings.HasSuffix(pipeline, "\t-") {
+			suffix = pipeline[len(pipeline)-2:] + suffix
+			pipeline = strings.TrimRight(pipeline[:len(pipeline)-2], " \t")
+		}
+
+		start := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(pipeline, " \t"), "-"), " \t")
+		firstWord, _, _ := strings.Cut(start, " ")
+
+		if start == "" || strings.HasPrefix(start, "/*") || slices.Contains(explicitDataTypeKeywords, firstWord) ||
+			explicitDataTypeAssignment.MatchString(start) || explicitDataTypePipe.MatchString(pipeline) {
+			return match
+		}
+
+		return fmt.Sprintf(
+			"%s%s%s%s | %s%s%s%s",
+			submatch[1], submatch[2], t.config.StartDelim, pipeline, structuredValueFunc, suffix, t.config.StopDelim,
+			submatch[4],
+		)
+	})
+}
+
+// structuredValue returns a placeholder of the JSON of the value if it is a map or a list, which is replaced with the
+// nested YAML value by applyStructuredValues after the template is resolved. Other values are returned as is.
+func structuredValue(value interface{}) (interface{}, error) {
+	if value == nil {
+		return value, nil
+	}
+
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Map, reflect.Array, reflect.Slice:
+		if _, ok := value.([]byte); ok {
+			return value, nil
+		}
+
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the value to JSON to set it as a structured value: %w", err)
+		}
+
+		return structuredValuePrefix + base64.StdEncoding.EncodeToString(valueJSON) + "$", nil
+	default:
+		return value, nil
+	}
+}
+
+// applyStructuredValues replaces the placeholders from structuredValue in the resolved YAML. If a string value is just
+// a placeholder, it is replaced with the nested map or list. Otherwise, the placeholders are replaced with the JSON of
+// the value as a string. Comments in the input are retained.
+func applyStructuredValues(y []byte) ([]byte, error) {
+	if !bytes.Contains(y, []byte(structuredValuePrefix)) {
+		return y, nil
+	}
+
+	var node yaml.Node
+
+	err := yaml.Unmarshal(y, &node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	err = applyStructuredValuesToNode(&node, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&b)
+	yamlEncoder.SetIndent(yamlIndentation)
+
+	err = yamlEncoder.Encode(&node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return b.Bytes(), nil
+}
+
+// applyStructuredValuesToNode recursively replaces the placeholders from structuredValue in the YAML node tree.
+// Mapping keys always remain strings.
+func applyStructuredValuesToNode(node *yaml.Node, isKey bool) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := applyStructuredValuesToNode(child, false); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i, child := range node.Content {
+			if err := applyStructuredValuesToNode(child, i%2 == 0); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!str" || !strings.Contains(node.Value, structuredValuePrefix) {
+			return nil
+		}
+
+		var decodeErr error
+
+		decode := func(placeholder string) []byte {
+			encoded := structuredValuePlaceholder.FindStringSubmatch(placeholder)[1]
+
+			valueJSON, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil && decodeErr == nil {
+				decodeErr = fmt.Errorf("failed to decode the structured value placeholder: %w", err)
+			}
+
+			return valueJSON
+		}
+
+		match := structuredValuePlaceholder.FindString(node.Value)
+		if !isKey && match == node.Value {
+			var value interface{}
+
+			if err := json.Unmarshal(decode(match), &value); err != nil {
+				return fmt.Errorf("failed to parse the structured value: %w", err)
+			}
+
+			var valueNode yaml.Node
+
+			if err := valueNode.Encode(value); err != nil {
+				return fmt.Errorf("failed to convert the structured value to YAML: %w", err)
+			}
+
+			valueNode.HeadComment = node.HeadComment
+			valueNode.LineComment = node.LineComment
+			valueNode.FootComment = node.FootComment
+			*node = valueNode
+
+			return decodeErr
+		}
+
+		node.Value = structuredValuePlaceholder.ReplaceAllStringFunc(node.Value, func(placeholder string) string {
+			return string(decode(placeholder))
+		})
+
+		return decodeErr
+	}
+
+	return nil
+}
@@ -0,0 +1,387 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProcessForExplicitDataTypes(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{config: Config{StartDelim: "{{", StopDelim: "}}"}}
+	hubResolver := TemplateResolver{config: Config{StartDelim: "{{hub", StopDelim: "hub}}"}}
+
+	testcases := map[string]struct {
+		resolver TemplateResolver
+		input    string
+		expected string
+	}{
+		"toInt": {
+			resolver: resolver,
+			input:    `key: '{{ "6" | toInt }}'`,
+			expected: `key: '{{ "6" | toInt | _explicitDataType "int" }}'`,
+		},
+		"toBool_trim_markers": {
+			resolver: resolver,
+			input:    `key: '{{- "true" | toBool -}}'`,
+			expected: `key: '{{- "true" | toBool | _explicitDataType "bool" -}}'`,
+		},
+		"hub": {
+			resolver: hubResolver,
+			input:    `key: '{{hub "6" | toInt hub}}' other: '{{ "6" | toInt }}'`,
+			expected: `key: '{{hub "6" | toInt | _explicitDataType "int" hub}}' other: '{{ "6" | toInt }}'`,
+		},
+		"not_last": {
+			resolver: resolver,
+			input:    `key: '{{ "6" | toInt | add 1 }}'`,
+			expected: `key: '{{ "6" | toInt | add 1 }}'`,
+		},
+		"keywords": {
+			resolver: resolver,
+			input:    `key: '{{ if "true" | toBool }}yes{{ end }}{{- with "6" | toInt }}{{ . }}{{ end }}'`,
+			expected: `key: '{{ if "true" | toBool }}yes{{ end }}{{- with "6" | toInt }}{{ . }}{{ end }}'`,
+		},
+		"assignment": {
+			resolver: resolver,
+			input:    `key: '{{ $replicas := "6" | toInt }}{{ $replicas = "7" | toInt }}'`,
+			expected: `key: '{{ $replicas := "6" | toInt }}{{ $replicas = "7" | toInt }}'`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val := test.resolver.processForExplicitDataTypes(test.input)
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateExplicitDataTypes(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{ExplicitDataTypes: true, PreserveComments: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl    string
+		expectedJSON string
+		expectedYAML string
+	}{
+		"large_int": {
+			inputTmpl:    `replicas: '{{ "9007199254740993" | toInt }}'`,
+			expectedJSON: `{"replicas":9007199254740993}`,
+			expectedYAML: "replicas: 9007199254740993\n",
+		},
+		"double_quoted": {
+			inputTmpl:    "enabled: \"{{ `true` | toBool }}\"\n",
+			expectedJSON: `{"enabled":true}`,
+			expectedYAML: "enabled: true\n",
+		},
+		"block_scalar": {
+			inputTmpl:    "replicas: |\n  '{{ \"3\" | toInt }}'\n",
+			expectedJSON: `{"replicas":3}`,
+			expectedYAML: "replicas: 3\n",
+		},
+		"part_of_string": {
+			inputTmpl:    "name: 'app-{{ \"6\" | toInt }}'\nplain: 1{{ \"2\" | toInt }}\n",
+			expectedJSON: `{"name":"app-6","plain":"12"}`,
+			expectedYAML: "name: 'app-6'\nplain: \"12\"\n",
+		},
+		"not_last_in_pipeline": {
+			inputTmpl:    `replicas: '{{ "6" | toInt | add 1 }}'`,
+			expectedJSON: `{"replicas":7}`,
+			expectedYAML: "replicas: 7\n",
+		},
+		"comments": {
+			inputTmpl:    "# The replicas\nreplicas: '{{ \"3\" | toInt }}' # inline\n",
+			expectedJSON: `{"replicas":3}`,
+			expectedYAML: "# The replicas\nreplicas: 3 # inline\n",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, tmplResult.ResolvedJSON)
+			}
+
+			if string(tmplResult.ResolvedYAML) != test.expectedYAML {
+				t.Fatalf("expected : %q , got : %q", test.expectedYAML, tmplResult.ResolvedYAML)
+			}
+		})
+	}
+}
+
+func TestProcessForNumericContext(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{config: Config{StartDelim: "{{", StopDelim: "}}"}}
+	fieldPaths := []string{"ClusterID", "Cluster.Port"}
+
+	testcases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"single_quoted": {
+			input:    `key: '{{ .ClusterID }}'`,
+			expected: `key: {{ .ClusterID }}`,
+		},
+		"double_quoted_nested": {
+			input:    `key: "{{- $.Cluster.Port -}}"`,
+			expected: `key: {{ $.Cluster.Port }}`,
+		},
+		"list_item": {
+			input:    "ports:\n  - '{{ .Cluster.Port }}'\n",
+			expected: "ports:\n  - {{ .Cluster.Port }}\n",
+		},
+		"comment": {
+			input:    `key: '{{ .ClusterID }}' # the ID`,
+			expected: `key: {{ .ClusterID }} # the ID`,
+		},
+		"not_numeric": {
+			input:    `key: '{{ .ClusterName }}'`,
+			expected: `key: '{{ .ClusterName }}'`,
+		},
+		"field_prefix": {
+			input:    `key: '{{ .ClusterIDs }}'`,
+			expected: `key: '{{ .ClusterIDs }}'`,
+		},
+		"part_of_string": {
+			input:    `key: 'id-{{ .ClusterID }}'`,
+			expected: `key: 'id-{{ .ClusterID }}'`,
+		},
+		"pipeline": {
+			input:    `key: '{{ .ClusterID | printf "id-%d" }}'`,
+			expected: `key: '{{ .ClusterID | printf "id-%d" }}'`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val := resolver.processForNumericContext(test.input, fieldPaths)
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateAutoTypeNumericContext(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	type cluster struct {
+		Port   int
+		Weight float64
+	}
+
+	ctx := struct {
+		ClusterID   int64
+		ClusterName string
+		Cluster     cluster
+		Labels      map[string]interface{}
+	}{
+		ClusterID:   12,
+		ClusterName: "cluster1",
+		Cluster:     cluster{Port: 8443, Weight: 0.5},
+		Labels:      map[string]interface{}{"zone": "a", "rack": 3},
+	}
+
+	testcases := map[string]struct {
+		inputTmpl    string
+		options      ResolveOptions
+		expectedJSON string
+		expectedErr  error
+	}{
+		"numeric_fields": {
+			inputTmpl: "id: '{{ .ClusterID }}'\nport: \"{{ .Cluster.Port }}\"\nweight: '{{ .Cluster.Weight }}'\n" +
+				"rack: '{{ .Labels.rack }}'\nname: '{{ .ClusterName }}'\n",
+			options:      ResolveOptions{AutoTypeNumericContext: true},
+			expectedJSON: `{"id":12,"name":"cluster1","port":8443,"rack":3,"weight":0.5}`,
+		},
+		"pipeline_requires_toInt": {
+			inputTmpl:    "id: '{{ .ClusterID | printf \"%d\" }}'\ntyped: '{{ .ClusterID | printf \"%d\" | toInt }}'\n",
+			options:      ResolveOptions{AutoTypeNumericContext: true},
+			expectedJSON: `{"id":"12","typed":12}`,
+		},
+		"disabled": {
+			inputTmpl:   "id: '{{ .ClusterID }}'\n",
+			expectedErr: ErrInvalidContextType,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			test.options.InputIsYAML = true
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), ctx, &test.options)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected err: %v, got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestProcessForStructuredValues(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{config: Config{StartDelim: "{{", StopDelim: "}}"}}
+
+	testcases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"single_quoted": {
+			input:    `key: '{{ configMapData "ns" "name" }}'`,
+			expected: `key: '{{ configMapData "ns" "name" | _structuredValue }}'`,
+		},
+		"chomped": {
+			input:    `key: "{{- list 1 2 -}}"`,
+			expected: `key: "{{- list 1 2 | _structuredValue -}}"`,
+		},
+		"list_item_comment": {
+			input:    "items:\n  - '{{ .Labels }}' # the labels\n",
+			expected: "items:\n  - '{{ .Labels | _structuredValue }}' # the labels\n",
+		},
+		"part_of_string": {
+			input:    `key: 'data-{{ .Labels }}'`,
+			expected: `key: 'data-{{ .Labels }}'`,
+		},
+		"multiple_actions": {
+			input:    `key: '{{ .A }}{{ .B }}'`,
+			expected: `key: '{{ .A }}{{ .B }}'`,
+		},
+		"control_structure": {
+			input:    `key: '{{ if .A }}'`,
+			expected: `key: '{{ if .A }}'`,
+		},
+		"assignment": {
+			input:    `key: '{{ $data := .Labels }}'`,
+			expected: `key: '{{ $data := .Labels }}'`,
+		},
+		"explicit_data_type": {
+			input:    `key: "{{ .Port | toInt }}"`,
+			expected: `key: "{{ .Port | toInt }}"`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val := resolver.processForStructuredValues(test.input)
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateStructuredValues(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: app
+data:
+  b: "2"
+  a: "it's"
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{StructuredValues: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl    string
+		expectedJSON string
+	}{
+		"map": {
+			inputTmpl:    `data: '{{ configMapData "app" "settings" }}'`,
+			expectedJSON: `{"data":{"a":"it's","b":"2"}}`,
+		},
+		"list": {
+			inputTmpl:    "items:\n  - '{{ list 1 \"two\" (dict \"three\" 3) }}'\n",
+			expectedJSON: `{"items":[[1,"two",{"three":3}]]}`,
+		},
+		"string": {
+			inputTmpl:    `name: '{{ "a" }}'`,
+			expectedJSON: `{"name":"a"}`,
+		},
+		"part_of_string": {
+			inputTmpl:    `name: 'items-{{ list 1 2 }}'`,
+			expectedJSON: `{"name":"items-[1 2]"}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var invalidDNS1123Chars = regexp.MustCompile("[^a-z0-9-]+")
+
+// dns1123 converts the input string to a valid DNS-1123 label by lowercasing it, replacing invalid characters with
+// dashes, trimming non-alphanumeric characters from the ends, and truncating it to 63 characters. An error is returned
+// if no valid label can be produced.
+func dns1123(value string) (string, error) {
+	label := strings.ToLower(value)
+	label = invalidDNS1123Chars.ReplaceAllString(label, "-")
+	label = strings.Trim(label, "-")
+
+	if len(label) > validation.DNS1123LabelMaxLength {
+		label = strings.TrimRight(label[:validation.DNS1123LabelMaxLength], "-")
+	}
+
+	if errs := validation.IsDNS1123Label(label); len(errs) != 0 {
+		return "", fmt.Errorf("%w: %s cannot be converted to a DNS-1123 label: %s",
+			ErrInvalidInput, value, strings.Join(errs, "; "))
+	}
+
+	return label, nil
+}
+
+// isDNS1123 returns true if the input string is a valid DNS-1123 label.
+func isDNS1123(value string) bool {
+	return len(validation.IsDNS1123Label(value)) == 0
+}
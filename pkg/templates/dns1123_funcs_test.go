@@ -0,0 +1,66 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDNS1123(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		input       string
+		result      string
+		expectedErr error
+	}{
+		{"my-app", "my-app", nil},
+		{"My_App.v2", "my-app-v2", nil},
+		{"--Cluster  Name!--", "cluster-name", nil},
+		{strings.Repeat("a", 62) + "-b", strings.Repeat("a", 62), nil},
+		{strings.Repeat("ab", 40), strings.Repeat("ab", 31) + "a", nil},
+		{"_!_", "", ErrInvalidInput},
+		{"", "", ErrInvalidInput},
+	}
+
+	for _, test := range testcases {
+		val, err := dns1123(test.input)
+		if !errors.Is(err, test.expectedErr) {
+			t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+		}
+
+		if val != test.result {
+			t.Fatalf("expected : %v , got : %v", test.result, val)
+		}
+
+		if err == nil && !isDNS1123(val) {
+			t.Fatalf("expected %s to be a valid DNS-1123 label", val)
+		}
+	}
+}
+
+func TestIsDNS1123(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		input  string
+		result bool
+	}{
+		{"my-app", true},
+		{"my-app-2", true},
+		{"My-App", false},
+		{"my_app", false},
+		{"-my-app", false},
+		{strings.Repeat("a", 64), false},
+		{"", false},
+	}
+
+	for _, test := range testcases {
+		val := isDNS1123(test.input)
+		if val != test.result {
+			t.Fatalf("expected : %v , got : %v", test.result, val)
+		}
+	}
+}
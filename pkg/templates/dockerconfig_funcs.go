@@ -0,0 +1,86 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+func (t *TemplateResolver) dockerRegistriesHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string, string) ([]interface{}, error) {
+	return func(namespace string, name string) ([]interface{}, error) {
+		return t.dockerRegistries(options, templateResult, namespace, name)
+	}
+}
+
+// dockerRegistries returns the sorted list of the registry hostnames configured in the .dockerconfigjson key of the
+// pull Secret. Since the credentials are not returned, reading the Secret doesn't set TemplateResult.HasSensitiveData.
+func (t *TemplateResolver) dockerRegistries(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string,
+) ([]interface{}, error) {
+	klog.V(2).Infof("dockerRegistries for namespace: %v, name: %v", namespace, name)
+
+	if name == "" || (options.LookupNamespace == "" && namespace == "") {
+		return nil, fmt.Errorf("%w: namespace and name must be specified", ErrInvalidInput)
+	}
+
+	hadSensitiveData := templateResult.HasSensitiveData
+
+	secret, err := t.getOrList(options, templateResult, "v1", "Secret", namespace, name)
+
+	templateResult.HasSensitiveData = hadSensitiveData
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the secret %s from %s: %w", name, namespace, err)
+	}
+
+	encodedConfig, _, _ := unstructured.NestedString(secret, "data", corev1.DockerConfigJsonKey)
+	if encodedConfig == "" {
+		return nil, fmt.Errorf(
+			"%w: the secret %s from %s does not have the %s key", ErrInvalidInput, name, namespace,
+			corev1.DockerConfigJsonKey,
+		)
+	}
+
+	decodedConfig, err := base64.StdEncoding.DecodeString(encodedConfig)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: the %s key of the secret %s from %s is not valid base64", ErrInvalidInput,
+			corev1.DockerConfigJsonKey, name, namespace,
+		)
+	}
+
+	// Only the registry hostnames are needed, so the credentials in the auths entries are not parsed
+	var dockerConfig struct {
+		Auths map[string]json.RawMessage `json:"auths"`
+	}
+
+	if err := json.Unmarshal(decodedConfig, &dockerConfig); err != nil {
+		return nil, fmt.Errorf(
+			"%w: the %s key of the secret %s from %s is not a valid Docker config", ErrInvalidInput,
+			corev1.DockerConfigJsonKey, name, namespace,
+		)
+	}
+
+	registries := make([]string, 0, len(dockerConfig.Auths))
+	for registry := range dockerConfig.Auths {
+		registries = append(registries, registry)
+	}
+
+	sort.Strings(registries)
+
+	registryList := make([]interface{}, 0, len(registries))
+	for _, registry := range registries {
+		registryList = append(registryList, registry)
+	}
+
+	return registryList, nil
+}
@@ -0,0 +1,176 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const dockerConfigBundle = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: pull-secret
+  namespace: app
+type: kubernetes.io/dockerconfigjson
+data:
+  # {"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"},"quay.io":{}}}
+  .dockerconfigjson: eyJhdXRocyI6eyJyZWdpc3RyeS5leGFtcGxlLmNvbSI6eyJhdXRoIjoiZFhObGNqcHdZWE56In0sInF1YXkuaW8iOnt9fX0=
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: invalid-pull-secret
+  namespace: app
+type: kubernetes.io/dockerconfigjson
+data:
+  .dockerconfigjson: bm90IGpzb24=
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+  namespace: app
+data:
+  password: cGFzc3dvcmQ=
+`
+
+func TestDockerRegistries(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(dockerConfigBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		options        *ResolveOptions
+		namespace      string
+		name           string
+		expectedResult []interface{}
+		expectedErr    error
+	}{
+		"registries": {
+			options:        &ResolveOptions{},
+			namespace:      "app",
+			name:           "pull-secret",
+			expectedResult: []interface{}{"quay.io", "registry.example.com"},
+		},
+		"lookup_namespace": {
+			options:        &ResolveOptions{LookupNamespace: "app"},
+			name:           "pull-secret",
+			expectedResult: []interface{}{"quay.io", "registry.example.com"},
+		},
+		"restricted_namespace": {
+			options:     &ResolveOptions{LookupNamespace: "other"},
+			namespace:   "app",
+			name:        "pull-secret",
+			expectedErr: ErrRestrictedNamespace,
+		},
+		"invalid_config": {
+			options:     &ResolveOptions{},
+			namespace:   "app",
+			name:        "invalid-pull-secret",
+			expectedErr: ErrInvalidInput,
+		},
+		"no_config_key": {
+			options:     &ResolveOptions{},
+			namespace:   "app",
+			name:        "app-secret",
+			expectedErr: ErrInvalidInput,
+		},
+		"no_name": {
+			options:     &ResolveOptions{},
+			namespace:   "app",
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			templateResult := &TemplateResult{}
+
+			result, err := resolver.dockerRegistries(test.options, templateResult, test.namespace, test.name)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if templateResult.HasSensitiveData {
+				t.Fatalf("expected HasSensitiveData to be false")
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedResult) {
+				t.Fatalf("expected : %v , got : %v", test.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateDockerRegistries(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(dockerConfigBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		tmpl                  string
+		expectedJSON          string
+		expectedSensitiveData bool
+	}{
+		"registries": {
+			tmpl:         `registries: '{{ dockerRegistries "app" "pull-secret" | join "," }}'`,
+			expectedJSON: `{"registries":"quay.io,registry.example.com"}`,
+		},
+		"with_fromSecret": {
+			tmpl: `password: '{{ fromSecret "app" "app-secret" "password" }}'` + "\n" +
+				`registries: '{{ dockerRegistries "app" "pull-secret" | join "," }}'`,
+			expectedJSON:          `{"password":"cGFzc3dvcmQ=","registries":"quay.io,registry.example.com"}`,
+			expectedSensitiveData: true,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(test.tmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+			}
+
+			if result.HasSensitiveData != test.expectedSensitiveData {
+				t.Fatalf(
+					"expected HasSensitiveData : %v , got : %v", test.expectedSensitiveData, result.HasSensitiveData,
+				)
+			}
+		})
+	}
+}
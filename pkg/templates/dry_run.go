@@ -0,0 +1,108 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// apiTemplateFunctions are the template functions that query the API server. They are replaced with placeholder
+// functions when ResolveOptions.DryRunUnresolvable is set.
+var apiTemplateFunctions = []string{
+	"canLookup",
+	"configMapData",
+	"containerResource",
+	"copyConfigMapData",
+	"copySecretData",
+	"countResources",
+	"distinctField",
+	"dockerRegistries",
+	"fromClusterClaim",
+	"fromConfigMap",
+	"fromConfigMapFirst",
+	"fromLatestConfigMap",
+	"fromManagedClusterInfo",
+	"fromRemoteSecret",
+	"fromSealedSecret",
+	"fromSecret",
+	"fromSecretFirst",
+	"getNodesWithExactRoles",
+	"hasNodesWithExactRoles",
+	"infrastructure",
+	"ingressDomain",
+	"isWorkloadReady",
+	"lastApplied",
+	"lookup",
+	"lookupNameGlob",
+	"matchingNamespaces",
+	"readyReplicas",
+	"secretData",
+}
+
+// dryRunArgReplacer replaces the characters in the placeholder arguments that could make the placeholder invalid YAML
+// when it's output in a quoted or unquoted YAML value.
+var dryRunArgReplacer = strings.NewReplacer(
+	`'`, "_", `"`, "_", `\`, "_", ":", "_", "#", "_", "\n", "_", "\r", "_", "\t", "_",
+)
+
+// setDryRunPlaceholders replaces the API template functions in the function map with placeholder functions for
+// ResolveOptions.DryRunUnresolvable. Functions that aren't in the function map, such as disabled functions, are not
+// added.
+func setDryRunPlaceholders(funcMap template.FuncMap) {
+	for _, funcName := range apiTemplateFunctions {
+		if function, ok := funcMap[funcName]; ok {
+			funcMap[funcName] = dryRunPlaceholderFunc(funcName, function)
+		}
+	}
+}
+
+// dryRunPlaceholderFunc returns a function with the same arguments as the input function that returns a placeholder
+// of the function call in the format of "<funcName arg1 arg2>" instead of querying the API server. Empty arguments are
+// shown as "-". The arguments are kept so that the number of arguments can still be checked.
+func dryRunPlaceholderFunc(funcName string, function interface{}) interface{} {
+	funcType := reflect.TypeOf(function)
+
+	argTypes := make([]reflect.Type, funcType.NumIn())
+	for i := range argTypes {
+		argTypes[i] = funcType.In(i)
+	}
+
+	placeholderType := reflect.FuncOf(
+		argTypes,
+		[]reflect.Type{reflect.TypeOf(""), reflect.TypeOf((*error)(nil)).Elem()},
+		funcType.IsVariadic(),
+	)
+
+	return reflect.MakeFunc(placeholderType, func(args []reflect.Value) []reflect.Value {
+		parts := []string{funcName}
+
+		for i, arg := range args {
+			if funcType.IsVariadic() && i == len(args)-1 {
+				for j := 0; j < arg.Len(); j++ {
+					parts = append(parts, dryRunArg(arg.Index(j).Interface()))
+				}
+
+				continue
+			}
+
+			parts = append(parts, dryRunArg(arg.Interface()))
+		}
+
+		placeholder := "<" + strings.Join(parts, " ") + ">"
+
+		return []reflect.Value{reflect.ValueOf(placeholder), reflect.Zero(placeholderType.Out(1))}
+	}).Interface()
+}
+
+// dryRunArg formats an argument of a placeholder from dryRunPlaceholderFunc.
+func dryRunArg(arg interface{}) string {
+	formatted := fmt.Sprint(arg)
+	if formatted == "" {
+		return "-"
+	}
+
+	return dryRunArgReplacer.Replace(formatted)
+}
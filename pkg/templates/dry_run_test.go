@@ -0,0 +1,110 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveTemplateDryRunUnresolvable(t *testing.T) {
+	t.Parallel()
+
+	// There are no objects, so any API query would not find the object
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	hubResolver, err := NewResolverFromSnapshot(nil, Config{StartDelim: "{{hub", StopDelim: "hub}}"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		resolver       *TemplateResolver
+		inputTmpl      string
+		expectedResult string
+	}{
+		"lookup": {
+			resolver:       resolver,
+			inputTmpl:      `secret: '{{ lookup "v1" "Secret" "testns" "testsecret" }}'`,
+			expectedResult: `{"secret":"\u003clookup v1 Secret testns testsecret\u003e"}`,
+		},
+		"lookup_list_with_selector": {
+			resolver:       resolver,
+			inputTmpl:      `configmaps: '{{ lookup "v1" "ConfigMap" "" "" "env=prod" "app" }}'`,
+			expectedResult: `{"configmaps":"\u003clookup v1 ConfigMap - - env=prod app\u003e"}`,
+		},
+		"unquoted": {
+			resolver:       resolver,
+			inputTmpl:      `value: {{ fromConfigMap "testns" "testconfigmap" "cmkey1" }}`,
+			expectedResult: `{"value":"\u003cfromConfigMap testns testconfigmap cmkey1\u003e"}`,
+		},
+		"special_characters": {
+			resolver:       resolver,
+			inputTmpl:      `value: {{ fromSecret "testns" "it's" "key: #1" }}`,
+			expectedResult: `{"value":"\u003cfromSecret testns it_s key_ _1\u003e"}`,
+		},
+		"non-string_result": {
+			resolver: resolver,
+			inputTmpl: `ready: {{ hasNodesWithExactRoles "infra" }}` + "\n" +
+				`count: {{ countResources "v1" "Node" }}`,
+			expectedResult: `{"count":"\u003ccountResources v1 Node\u003e",` +
+				`"ready":"\u003chasNodesWithExactRoles infra\u003e"}`,
+		},
+		"other_functions_resolved": {
+			resolver:       resolver,
+			inputTmpl:      `value: '{{ "My_App" | dns1123 }}-{{ fromClusterClaim "name" }}'`,
+			expectedResult: `{"value":"my-app-\u003cfromClusterClaim name\u003e"}`,
+		},
+		"hub": {
+			resolver: hubResolver,
+			inputTmpl: `value: '{{hub fromSecret "policies" "creds" "password" hub}}-` +
+				`{{ fromSecret "a" "b" "c" }}'`,
+			expectedResult: `{"value":"\u003cfromSecret policies creds password\u003e-` +
+				`{{ fromSecret \"a\" \"b\" \"c\" }}"}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := test.resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true, DryRunUnresolvable: true},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, string(result.ResolvedJSON))
+			}
+
+			if result.HasSensitiveData {
+				t.Fatalf("expected HasSensitiveData to be set to false")
+			}
+		})
+	}
+}
+
+func TestResolveTemplateDryRunUnresolvableArgumentCounts(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{StrictArgumentCounts: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplate(
+		[]byte(`value: '{{ fromSecret "testns" "testsecret" }}'`),
+		nil,
+		&ResolveOptions{InputIsYAML: true, DryRunUnresolvable: true},
+	)
+	if !errors.Is(err, ErrWrongArgumentCount) {
+		t.Fatalf("expected an ErrWrongArgumentCount error, got : %v", err)
+	}
+}
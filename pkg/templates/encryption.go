@@ -16,6 +16,20 @@ import (
 	"k8s.io/klog"
 )
 
+// encryptedStrRegex catches any encrypted string in the format of $ocm_encrypted:<base64 of the encrypted value>. Since
+// the base64 may be followed by other base64 characters when the encrypted string is embedded in a larger value, the
+// match is bounded by findEncryptedStrs.
+var encryptedStrRegex = regexp.MustCompile(regexp.QuoteMeta(protectedPrefix) + "([a-zA-Z0-9+/=]+)")
+
+// maskedEncryptedStr is the placeholder that encrypted strings are replaced with when
+// ResolveOptions.MaskEncryptedForDisplay is set.
+const maskedEncryptedStr = protectedPrefix + "<masked>"
+
+// embeddedIVVersion is the first byte of an encrypted value with an embedded IV, which is in the format of
+// <version byte><IV><encrypted value>. Legacy encrypted values that use the configured IV are always a multiple of the
+// AES block size, so a value with one extra byte set to this version is never mistaken for a legacy value.
+const embeddedIVVersion byte = 1
+
 func (t *TemplateResolver) protectHelper(options *ResolveOptions) func(string) (string, error) {
 	return func(value string) (string, error) {
 		return t.protect(options, value)
@@ -54,8 +68,9 @@ func (t *TemplateResolver) protect(options *ResolveOptions, value string) (strin
 	return protectedPrefix + base64.StdEncoding.EncodeToString(encryptedValue), nil
 }
 
-// decrypt will decrypt a string that was encrypted using the protect method. An error is returned if the base64 or
-// the AES key is invalid.
+// decrypt will decrypt a string that was encrypted using the protect method. Values with an embedded IV are decrypted
+// with that IV instead of the configured IV. See splitEmbeddedIV for how the formats are distinguished. An error is
+// returned if the base64, the format, or the AES key is invalid.
 func (t *TemplateResolver) decrypt(options *ResolveOptions, value string) (string, error) {
 	// This is already validated in the NewResolver method, but is checked again in case that method was bypassed
 	// to avoid a panic.
@@ -68,15 +83,15 @@ func (t *TemplateResolver) decrypt(options *ResolveOptions, value string) (strin
 		return "", fmt.Errorf("%s: %w: %w", value, ErrInvalidB64OfEncrypted, err)
 	}
 
+	iv, decodedValue, err := splitEmbeddedIV(options.InitializationVector, decodedValue)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", value, err)
+	}
+
 	var decryptionErr error
 	var decryptedValue []byte
 
-	var aesKeys [][]byte
-	if options.AESKeyFallback == nil {
-		aesKeys = [][]byte{options.AESKey}
-	} else {
-		aesKeys = [][]byte{options.AESKey, options.AESKeyFallback}
-	}
+	aesKeys := append([][]byte{options.AESKey}, options.fallbackKeys()...)
 
 	for _, aesKey := range aesKeys {
 		block, err := aes.NewCipher(aesKey)
@@ -87,7 +102,7 @@ func (t *TemplateResolver) decrypt(options *ResolveOptions, value string) (strin
 		}
 
 		// #nosec G407 -- Onus to randomize the IV is on the consuming controller.
-		blockMode := cipher.NewCBCDecrypter(block, options.InitializationVector)
+		blockMode := cipher.NewCBCDecrypter(block, iv)
 		decryptedValue = make([]byte, len(decodedValue))
 		blockMode.CryptBlocks(decryptedValue, decodedValue)
 
@@ -112,6 +127,33 @@ func (t *TemplateResolver) decrypt(options *ResolveOptions, value string) (strin
 	return string(decryptedValue), nil
 }
 
+// splitEmbeddedIV returns the IV and the encrypted bytes of the decoded encrypted value. Legacy values are a multiple
+// of the AES block size and are returned as is with the configured IV. Values with a length of one more than a
+// multiple of the AES block size must start with embeddedIVVersion and are returned with their embedded IV. Any other
+// length results in an error wrapping ErrInvalidEncryptedFormat.
+func splitEmbeddedIV(configuredIV []byte, decodedValue []byte) ([]byte, []byte, error) {
+	switch len(decodedValue) % aes.BlockSize {
+	case 0:
+		return configuredIV, decodedValue, nil
+	case 1:
+		if len(decodedValue) < 1+IVSize+aes.BlockSize {
+			return nil, nil, fmt.Errorf("%w: the value is too short to have an embedded IV", ErrInvalidEncryptedFormat)
+		}
+
+		if decodedValue[0] != embeddedIVVersion {
+			return nil, nil, fmt.Errorf(
+				"%w: the embedded IV version %d is not supported", ErrInvalidEncryptedFormat, decodedValue[0],
+			)
+		}
+
+		return decodedValue[1 : 1+IVSize], decodedValue[1+IVSize:], nil
+	default:
+		return nil, nil, fmt.Errorf(
+			"%w: the value length of %d bytes is not valid", ErrInvalidEncryptedFormat, len(decodedValue),
+		)
+	}
+}
+
 // pkcs7Pad right-pads the given value to match the input block size for AES encryption. The padding
 // ranges from 1 byte to the number of bytes equal to the block size.
 // Inspired from https://gist.github.com/huyinghuan/7bf174017bf54efb91ece04a48589b22.
@@ -158,18 +200,74 @@ func pkcs7Unpad(paddedValue []byte) ([]byte, error) {
 	return paddedValue[:len(paddedValue)-numPaddingBytes], nil
 }
 
+// findEncryptedStrs returns the indexes of the encrypted strings in the input in the same format as
+// regexp.FindAllStringSubmatchIndex with encryptedStrRegex, where indexes 0 and 1 are for the whole encrypted string
+// and indexes 2 and 3 are for the base64 of the encrypted value. The base64 ends after the padding if it decodes to a
+// valid encrypted value length. Since encrypted values whose length is a multiple of 3 bytes have no padding, the
+// base64 is otherwise the longest prefix before the padding that decodes to a valid length so that the base64
+// characters following an encrypted string embedded in a larger value, such as "/db" in a URL, aren't included. If no
+// prefix is a valid length, the base64 ends after the padding so that decrypt returns the error.
+func findEncryptedStrs(input string) [][]int {
+	submatches := encryptedStrRegex.FindAllStringSubmatchIndex(input, -1)
+
+	for _, submatch := range submatches {
+		encoded := input[submatch[2]:submatch[3]]
+
+		paddingStart := strings.IndexByte(encoded, '=')
+		if paddingStart == -1 {
+			paddingStart = len(encoded)
+		}
+
+		length := len(encoded) - len(strings.TrimLeft(encoded[paddingStart:], "="))
+
+		if !isValidEncryptedLength(encoded[:length]) {
+			for prefixLength := paddingStart - paddingStart%4; prefixLength > 0; prefixLength -= 4 {
+				if isValidEncryptedLength(encoded[:prefixLength]) {
+					length = prefixLength
+
+					break
+				}
+			}
+		}
+
+		submatch[3] = submatch[2] + length
+		submatch[1] = submatch[3]
+	}
+
+	return submatches
+}
+
+// isValidEncryptedLength returns true if the input base64 decodes to a valid length of an encrypted value, which is a
+// multiple of the AES block size for a legacy value or one more than that with room for an embedded IV.
+func isValidEncryptedLength(encoded string) bool {
+	if len(encoded) == 0 || len(encoded)%4 != 0 {
+		return false
+	}
+
+	decodedLength := len(encoded)/4*3 - (len(encoded) - len(strings.TrimRight(encoded, "=")))
+
+	switch decodedLength % aes.BlockSize {
+	case 0:
+		return decodedLength >= aes.BlockSize
+	case 1:
+		return decodedLength >= 1+IVSize+aes.BlockSize
+	default:
+		return false
+	}
+}
+
 // processEncryptedStrs replaces all encrypted strings with the decrypted values. Each decryption is handled
 // concurrently and the concurrency limit is controlled by t.config.DecryptionConcurrency. If a decryption fails,
-// the rest of the decryption is halted and an error is returned.
+// the rest of the decryption is halted and an error is returned. Encrypted strings may be embedded in larger values,
+// in which case only the encrypted string is replaced.
 func (t *TemplateResolver) processEncryptedStrs(
 	options *ResolveOptions,
 	templateResult *TemplateResult,
 	templateStr string,
 ) (string, error) {
-	// This catching any encrypted string in the format of $ocm_encrypted:<base64 of the encrypted value>.
-	re := regexp.MustCompile(regexp.QuoteMeta(protectedPrefix) + "([a-zA-Z0-9+/=]+)")
-	// Each submatch will have index 0 be the whole match and index 1 as the base64 of the encrypted value.
-	submatches := re.FindAllStringSubmatch(templateStr, -1)
+	// Each submatch will have indexes 0 and 1 for the whole match and indexes 2 and 3 for the base64 of the encrypted
+	// value.
+	submatches := findEncryptedStrs(templateStr)
 
 	if len(submatches) == 0 {
 		return templateStr, nil
@@ -188,7 +286,7 @@ func (t *TemplateResolver) processEncryptedStrs(
 		numWorkers = len(submatches)
 	}
 
-	submatchesChan := make(chan []string, len(submatches))
+	encryptedChan := make(chan encryptedMatch, len(submatches))
 	resultsChan := make(chan decryptResult, len(submatches))
 
 	klog.V(2).Infof("Will decrypt %d value(s) with %d Goroutines", len(submatches), numWorkers)
@@ -199,15 +297,19 @@ func (t *TemplateResolver) processEncryptedStrs(
 
 	// Start up all the Goroutines.
 	for i := 0; i < numWorkers; i++ {
-		go t.decryptWrapper(ctx, options, submatchesChan, resultsChan)
+		go t.decryptWrapper(ctx, options, encryptedChan, resultsChan)
 	}
 
 	// Send all the submatches of all the encrypted strings to the Goroutines to process.
-	for _, submatch := range submatches {
-		submatchesChan <- submatch
+	for i, submatch := range submatches {
+		encryptedChan <- encryptedMatch{
+			index:          i,
+			match:          templateStr[submatch[0]:submatch[1]],
+			encryptedValue: templateStr[submatch[2]:submatch[3]],
+		}
 	}
 
-	processed := templateStr
+	plaintexts := make([]string, len(submatches))
 	processedResults := 0
 
 	for result := range resultsChan {
@@ -215,30 +317,52 @@ func (t *TemplateResolver) processEncryptedStrs(
 		if result.err != nil {
 			// Cancel the context so the Goroutines exit before the channels close.
 			cancel()
-			close(submatchesChan)
+			close(encryptedChan)
 			close(resultsChan)
 			klog.Errorf("Decryption failed %v", result.err)
 
 			return "", fmt.Errorf("decryption of %s failed: %w", result.match, result.err)
 		}
 
-		processed = strings.Replace(processed, result.match, result.plaintext, 1)
+		plaintexts[result.index] = result.plaintext
 		processedResults++
 
 		// Once the decryption is complete, it's safe to close the channels and stop blocking in this Goroutine.
 		if processedResults == len(submatches) {
-			close(submatchesChan)
+			close(encryptedChan)
 			close(resultsChan)
 		}
 	}
 
+	// Replace each encrypted string by its position so that only the encrypted portion of a larger value is replaced.
+	var processed strings.Builder
+
+	lastIndex := 0
+
+	for i, submatch := range submatches {
+		processed.WriteString(templateStr[lastIndex:submatch[0]])
+		processed.WriteString(plaintexts[i])
+
+		lastIndex = submatch[1]
+	}
+
+	processed.WriteString(templateStr[lastIndex:])
+
 	klog.V(2).Infof("Finished decrypting %d value(s)", len(submatches))
 
-	return processed, nil
+	return processed.String(), nil
+}
+
+// encryptedMatch is an encrypted string sent on the "submatches" channel in decryptWrapper.
+type encryptedMatch struct {
+	index          int
+	match          string
+	encryptedValue string
 }
 
 // decryptResult is the result sent back on the "results" channel in decryptWrapper.
 type decryptResult struct {
+	index     int
 	match     string
 	plaintext string
 	err       error
@@ -246,25 +370,23 @@ type decryptResult struct {
 
 // decryptWrapper wraps the decrypt method for concurrency. ctx is the context that will get canceled if one or more
 // decryptions fail. This will halt the Goroutine early. submatches is the channel with the incoming strings to decrypt
-// which gets closed when all the encrypted values have been decrypted. Its values are string slices with the first
-// index being the whole string that will be replaced and second index being the base64 of the encrypted string. results
-// is a channel to communicate back to the calling Goroutine.
+// which gets closed when all the encrypted values have been decrypted. Its values contain the position of the match,
+// the whole string that will be replaced, and the base64 of the encrypted string. results is a channel to communicate
+// back to the calling Goroutine.
 func (t *TemplateResolver) decryptWrapper(
-	ctx context.Context, options *ResolveOptions, submatches <-chan []string, results chan<- decryptResult,
+	ctx context.Context, options *ResolveOptions, submatches <-chan encryptedMatch, results chan<- decryptResult,
 ) {
 	for submatch := range submatches {
-		match := submatch[0]
-		encryptedValue := submatch[1]
 		var result decryptResult
 
-		plaintext, err := t.decrypt(options, encryptedValue)
+		plaintext, err := t.decrypt(options, submatch.encryptedValue)
 		if err != nil {
-			result = decryptResult{match, "", err}
+			result = decryptResult{submatch.index, submatch.match, "", err}
 		} else {
 			// Escape new lines so that they do not affect the structure of the YAML document. This also allows piping
 			// the decrypted value to template function.
 			plaintext = strings.ReplaceAll(plaintext, "\n", "\\n")
-			result = decryptResult{match, plaintext, nil}
+			result = decryptResult{submatch.index, submatch.match, plaintext, nil}
 		}
 
 		select {
@@ -277,3 +399,24 @@ func (t *TemplateResolver) decryptWrapper(
 		}
 	}
 }
+
+// maskEncryptedStrs replaces all encrypted strings in the input with a placeholder so that the output is readable for
+// display purposes. It returns true if any encrypted strings were replaced.
+func maskEncryptedStrs(input []byte) ([]byte, bool) {
+	submatches := findEncryptedStrs(string(input))
+	if len(submatches) == 0 {
+		return input, false
+	}
+
+	masked := make([]byte, 0, len(input))
+	lastIndex := 0
+
+	for _, submatch := range submatches {
+		masked = append(masked, input[lastIndex:submatch[0]]...)
+		masked = append(masked, maskedEncryptedStr...)
+
+		lastIndex = submatch[1]
+	}
+
+	return append(masked, input[lastIndex:]...), true
+}
@@ -0,0 +1,90 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+var (
+	envFileKey         = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	envFileUnquotedVal = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+	envFileEscaper     = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`)
+	envFileUnescaper   = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\$`, "$", `\n`, "\n")
+)
+
+// toEnvFile renders the input map as environment file lines in the format of KEY=value sorted by key. Values that
+// contain characters other than letters, digits, and "_./:@%+,=-" are double quoted with backslashes, double quotes,
+// dollar signs, and new lines escaped. The output has no trailing new line so that it can be used with autoindent.
+func toEnvFile(envMap interface{}) (string, error) {
+	values, err := cast.ToStringMapStringE(envMap)
+	if err != nil {
+		return "", fmt.Errorf("%w: toEnvFile requires a map of strings: %w", ErrInvalidInput, err)
+	}
+
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		if !envFileKey.MatchString(key) {
+			return "", fmt.Errorf("%w: %s is not a valid environment variable name", ErrInvalidInput, key)
+		}
+
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	lines := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		value := values[key]
+
+		if !envFileUnquotedVal.MatchString(value) {
+			value = `"` + envFileEscaper.Replace(value) + `"`
+		}
+
+		lines = append(lines, key+"="+value)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// fromEnvFile parses environment file content, such as the output of toEnvFile, into a map. Empty lines, lines
+// starting with "#", and an "export " prefix are ignored. Double quoted values are unescaped and single quoted values
+// are used literally. An error is returned if a line is not in the format of KEY=value.
+func fromEnvFile(content string) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+
+		if !found || !envFileKey.MatchString(key) {
+			return nil, fmt.Errorf("%w: line %d is not a valid environment file entry", ErrInvalidInput, i+1)
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`):
+			value = envFileUnescaper.Replace(value[1 : len(value)-1])
+		case len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'"):
+			value = value[1 : len(value)-1]
+		}
+
+		result[key] = value
+	}
+
+	return result, nil
+}
@@ -0,0 +1,116 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestToEnvFile(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input       interface{}
+		expected    string
+		expectedErr error
+	}{
+		"sorted": {
+			input:    map[string]interface{}{"PORT": 8080, "HOST": "example.com", "DEBUG": true},
+			expected: "DEBUG=true\nHOST=example.com\nPORT=8080",
+		},
+		"spaces": {
+			input:    map[string]string{"GREETING": "hello world", "EMPTY": ""},
+			expected: "EMPTY=\nGREETING=\"hello world\"",
+		},
+		"equals": {
+			input:    map[string]string{"OPTS": "a=b,c=d", "QUERY": "a = b"},
+			expected: "OPTS=a=b,c=d\nQUERY=\"a = b\"",
+		},
+		"escaped": {
+			input:    map[string]string{"VALUE": "say \"hi\" to $USER\\\nbye"},
+			expected: `VALUE="say \"hi\" to \$USER\\\nbye"`,
+		},
+		"invalid_key": {
+			input:       map[string]string{"MY-KEY": "value"},
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid_map": {
+			input:       "KEY=value",
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := toEnvFile(test.input)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %q , got : %q", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestFromEnvFile(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input       string
+		expected    map[string]interface{}
+		expectedErr error
+	}{
+		"values": {
+			input: "# comment\n\nexport HOST=example.com\nGREETING=\"hello world\"\nOPTS=a=b,c=d\n" +
+				"LITERAL='no \\n escapes'\nEMPTY=\n",
+			expected: map[string]interface{}{
+				"HOST":     "example.com",
+				"GREETING": "hello world",
+				"OPTS":     "a=b,c=d",
+				"LITERAL":  `no \n escapes`,
+				"EMPTY":    "",
+			},
+		},
+		"escaped": {
+			input:    `VALUE="say \"hi\" to \$USER\\\nbye"`,
+			expected: map[string]interface{}{"VALUE": "say \"hi\" to $USER\\\nbye"},
+		},
+		"round_trip_equals_and_spaces": {
+			input:    "QUERY=\"a = b\"\nOPTS=a=b",
+			expected: map[string]interface{}{"QUERY": "a = b", "OPTS": "a=b"},
+		},
+		"missing_equals": {
+			input:       "KEY=value\nNOT_AN_ENTRY",
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid_key": {
+			input:       "MY-KEY=value",
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := fromEnvFile(test.input)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr == nil && !reflect.DeepEqual(val, test.expected) {
+				t.Fatalf("expected : %v , got : %v", test.expected, val)
+			}
+		})
+	}
+}
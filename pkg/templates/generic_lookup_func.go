@@ -5,12 +5,17 @@ package templates
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"path/filepath"
 	"slices"
+	"sort"
 	"strings"
 
 	"github.com/stolostron/kubernetes-dependency-watches/client"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -20,6 +25,12 @@ import (
 	"k8s.io/klog"
 )
 
+var selfSubjectAccessReviewGVR = schema.GroupVersionResource{
+	Group:    "authorization.k8s.io",
+	Version:  "v1",
+	Resource: "selfsubjectaccessreviews",
+}
+
 type ClusterScopedLookupRestrictedError struct {
 	kind string
 	name string
@@ -50,7 +61,41 @@ func (t *TemplateResolver) getNamespace(namespace string, lookupNamespace string
 	return namespace, nil
 }
 
-func (t *TemplateResolver) getOrList(
+// getScopedGVR converts the input GroupVersionKind to a ScopedGVR using the discovery data of the configured cache.
+// ErrMissingAPIResource is returned if the API resource is not installed on the API server.
+func (t *TemplateResolver) getScopedGVR(gvk schema.GroupVersionKind) (client.ScopedGVR, error) {
+	var scopedGVRObj client.ScopedGVR
+	var err error
+
+	if t.dynamicWatcher != nil {
+		scopedGVRObj, err = t.dynamicWatcher.GVKToGVR(gvk)
+	} else {
+		scopedGVRObj, err = t.tempCallCache.GVKToGVR(gvk)
+	}
+
+	if err != nil {
+		if errors.Is(err, client.ErrNoVersionedResource) {
+			return scopedGVRObj, ErrMissingAPIResource
+		}
+
+		return scopedGVRObj, err
+	}
+
+	return scopedGVRObj, nil
+}
+
+// lookupTarget is the validated target of a lookup query from prepareLookup.
+type lookupTarget struct {
+	gvk            schema.GroupVersionKind
+	scopedGVRObj   client.ScopedGVR
+	namespace      string
+	parsedSelector labels.Selector
+	lookupID       client.ObjectIdentifier
+}
+
+// prepareLookup validates that the lookup query is allowed by the ResolveOptions and returns its target. The query is
+// also recorded in the TemplateResult references and metrics when enabled.
+func (t *TemplateResolver) prepareLookup(
 	options *ResolveOptions,
 	templateResult *TemplateResult,
 	apiVersion string,
@@ -58,25 +103,19 @@ func (t *TemplateResolver) getOrList(
 	namespace string,
 	name string,
 	labelSelector ...string,
-) (
-	map[string]interface{}, error,
-) {
-	if options == nil {
-		options = &ResolveOptions{}
-	}
-
+) (lookupTarget, error) {
 	if apiVersion == "" || kind == "" {
-		return nil, errors.New("the apiVersion and kind are required")
+		return lookupTarget{}, errors.New("the apiVersion and kind are required")
 	}
 
 	ns, err := t.getNamespace(namespace, options.LookupNamespace)
 	if err != nil {
-		return nil, err
+		return lookupTarget{}, err
 	}
 
 	gv, err := schema.ParseGroupVersion(apiVersion)
 	if err != nil {
-		return nil, err
+		return lookupTarget{}, err
 	}
 
 	gvk := schema.GroupVersionKind{
@@ -85,32 +124,18 @@ func (t *TemplateResolver) getOrList(
 		Kind:    kind,
 	}
 
-	parsedSelector := labels.NewSelector()
-	// If labelSelector is defined, and is not an empty string, then add the labels to the listOptions
-	// Note there can be multiple values passed to labelSelector so we need to treat it as an array
-	// The ListOption requires a single string value.
-	if len(labelSelector) > 0 && labelSelector[0] != "" {
-		// We use the labels.Parse to validate the selector given.
-		// this should give us a better error output if the user misconfigured the selector
-		parsedSelector, err = labels.Parse(strings.Join(labelSelector, ","))
-		if err != nil {
-			return nil, err
-		}
+	if !kindAllowed(options.AllowedLookupKinds, gvk.GroupKind()) {
+		return lookupTarget{}, fmt.Errorf("%w: %s", ErrKindNotAllowed, gvk.GroupKind())
 	}
 
-	var scopedGVRObj client.ScopedGVR
-	if t.dynamicWatcher != nil {
-		scopedGVRObj, err = t.dynamicWatcher.GVKToGVR(gvk)
-	} else {
-		scopedGVRObj, err = t.tempCallCache.GVKToGVR(gvk)
+	parsedSelector, err := parseLabelSelector(labelSelector...)
+	if err != nil {
+		return lookupTarget{}, err
 	}
 
+	scopedGVRObj, err := t.getScopedGVR(gvk)
 	if err != nil {
-		if errors.Is(err, client.ErrNoVersionedResource) {
-			return nil, ErrMissingAPIResource
-		}
-
-		return nil, err
+		return lookupTarget{}, err
 	}
 
 	if !scopedGVRObj.Namespaced && options.LookupNamespace != "" {
@@ -120,18 +145,162 @@ func (t *TemplateResolver) getOrList(
 			Name:  name,
 		}
 		if !onAllowlist(options.ClusterScopedAllowList, rsrcIdentifier) {
-			return nil, ClusterScopedLookupRestrictedError{kind, name}
+			return lookupTarget{}, ClusterScopedLookupRestrictedError{kind, name}
 		}
 
 		// If the namespace is restricted but this is a cluster scoped resource, unset the namespace.
 		ns = ""
 	}
 
+	lookupID := client.ObjectIdentifier{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: ns,
+		Name:      name,
+		Selector:  parsedSelector.String(),
+	}
+
+	if options.TrackReferences && templateResult != nil {
+		templateResult.addReferencedObject(lookupID)
+	}
+
+	if options.TrackProvenance && templateResult != nil {
+		templateResult.recordProvenance(lookupID)
+	}
+
+	if templateResult != nil && templateResult.Metrics != nil {
+		templateResult.Metrics.APIQueries++
+	}
+
+	return lookupTarget{
+		gvk:            gvk,
+		scopedGVRObj:   scopedGVRObj,
+		namespace:      ns,
+		parsedSelector: parsedSelector,
+		lookupID:       lookupID,
+	}, nil
+}
+
+// getOrList gets the named object or lists the objects matching the label selector if name is empty. If
+// ResolveOptions.PinnedResourceVersions is set, an error wrapping ErrResourceVersionMismatch is returned if a pinned
+// object is returned with a different resource version or the named object is pinned and not found.
+func (t *TemplateResolver) getOrList(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+	labelSelector ...string,
+) (
+	map[string]interface{}, error,
+) {
+	if options == nil {
+		options = &ResolveOptions{}
+	}
+
+	result, target, err := t.getOrListTarget(
+		options, templateResult, apiVersion, kind, namespace, name, labelSelector...,
+	)
+	if name != "" && apierrors.IsNotFound(err) && options.TrackReferences && templateResult != nil {
+		templateResult.addNotFoundObject(target.lookupID)
+	}
+
+	if len(options.PinnedResourceVersions) == 0 {
+		return result, err
+	}
+
+	if err != nil {
+		if name != "" && apierrors.IsNotFound(err) {
+			if pinErr := checkPinnedResourceVersion(options, target.lookupID, ""); pinErr != nil {
+				return nil, pinErr
+			}
+		}
+
+		return nil, err
+	}
+
+	objects := []unstructured.Unstructured{{Object: result}}
+
+	if name == "" {
+		objList := unstructured.UnstructuredList{}
+		objList.SetUnstructuredContent(result)
+		objects = objList.Items
+	}
+
+	for _, obj := range objects {
+		objID := client.ObjectIdentifier{
+			Group:     target.gvk.Group,
+			Version:   target.gvk.Version,
+			Kind:      target.gvk.Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}
+
+		if err := checkPinnedResourceVersion(options, objID, obj.GetResourceVersion()); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// checkPinnedResourceVersion returns an error wrapping ErrResourceVersionMismatch if the object identifier is in
+// ResolveOptions.PinnedResourceVersions with a different resource version. An empty resourceVersion indicates that the
+// object was not found.
+func checkPinnedResourceVersion(options *ResolveOptions, objID client.ObjectIdentifier, resourceVersion string) error {
+	pinnedVersion, ok := options.PinnedResourceVersions[objID]
+	if !ok || pinnedVersion == resourceVersion {
+		return nil
+	}
+
+	if resourceVersion == "" {
+		return fmt.Errorf(
+			"%w: the object (%s) was not found but is pinned to the resource version %s",
+			ErrResourceVersionMismatch, objID, pinnedVersion,
+		)
+	}
+
+	return fmt.Errorf(
+		"%w: the object (%s) has the resource version %s instead of the pinned resource version %s",
+		ErrResourceVersionMismatch, objID, resourceVersion, pinnedVersion,
+	)
+}
+
+// getOrListTarget is getOrList without the PinnedResourceVersions check. The lookup target is also returned so that
+// the caller can identify the objects.
+func (t *TemplateResolver) getOrListTarget(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+	labelSelector ...string,
+) (
+	map[string]interface{}, lookupTarget, error,
+) {
+	target, err := t.prepareLookup(options, templateResult, apiVersion, kind, namespace, name, labelSelector...)
+	if err != nil {
+		return nil, target, err
+	}
+
+	gvk := target.gvk
+	scopedGVRObj := target.scopedGVRObj
+	ns := target.namespace
+	parsedSelector := target.parsedSelector
+	lookupID := target.lookupID
+
 	if t.dynamicWatcher != nil {
 		if name == "" {
 			result, err := t.dynamicWatcher.List(*options.Watcher, gvk, ns, parsedSelector)
 			if err != nil {
-				return nil, err
+				return nil, target, err
+			}
+
+			if options.snapshot != nil {
+				result = options.snapshot.list(lookupID, result)
 			}
 
 			resultList := unstructured.UnstructuredList{Items: result}
@@ -140,53 +309,49 @@ func (t *TemplateResolver) getOrList(
 				templateResult.HasSensitiveData = true
 			}
 
-			return resultList.UnstructuredContent(), nil
+			return resultList.UnstructuredContent(), target, nil
 		}
 
 		result, err := t.dynamicWatcher.Get(*options.Watcher, gvk, ns, name)
 		if err != nil {
-			return nil, err
+			return nil, target, err
+		}
+
+		if options.snapshot != nil {
+			result = options.snapshot.get(lookupID, result)
 		}
 
 		if result == nil {
-			return nil, apierrors.NewNotFound(scopedGVRObj.GroupResource(), name)
+			return nil, target, apierrors.NewNotFound(scopedGVRObj.GroupResource(), name)
 		}
 
 		if templateResult != nil && kind == "Secret" {
 			templateResult.HasSensitiveData = true
 		}
 
-		return result.UnstructuredContent(), nil
+		return result.UnstructuredContent(), target, nil
 	}
 
 	// The dynamic watcher is not used, so use the temporary call cache
-	lookupID := client.ObjectIdentifier{
-		Group:     gvk.Group,
-		Version:   gvk.Version,
-		Kind:      gvk.Kind,
-		Namespace: ns,
-		Name:      name,
-		Selector:  parsedSelector.String(),
-	}
-
 	cachedResults, err := t.tempCallCache.FromObjectIdentifier(lookupID)
 	if err != nil {
 		if !errors.Is(err, client.ErrNoCacheEntry) {
-			return nil, err
+			return nil, target, err
 		}
 	} else {
 		// Check if this is a Get or List query
 		if name != "" {
 			if len(cachedResults) > 0 {
-				return cachedResults[0].UnstructuredContent(), nil
+				return cachedResults[0].UnstructuredContent(), target, nil
 			}
 
-			return nil, nil
+			// A cached not found result is returned the same as the original not found error
+			return nil, target, apierrors.NewNotFound(scopedGVRObj.GroupResource(), name)
 		}
 
 		resultList := unstructured.UnstructuredList{Items: cachedResults}
 
-		return resultList.UnstructuredContent(), nil
+		return resultList.UnstructuredContent(), target, nil
 	}
 
 	// It's not cached so it must be retrieved using the dynamic client and then cached
@@ -204,7 +369,7 @@ func (t *TemplateResolver) getOrList(
 			context.TODO(), metav1.ListOptions{LabelSelector: parsedSelector.String()},
 		)
 		if err != nil {
-			return nil, err
+			return nil, target, err
 		}
 
 		t.tempCallCache.CacheFromObjectIdentifier(lookupID, resultUnstructuredList.Items)
@@ -216,7 +381,7 @@ func (t *TemplateResolver) getOrList(
 			templateResult.HasSensitiveData = true
 		}
 
-		return resultUnstructuredList.UnstructuredContent(), nil
+		return resultUnstructuredList.UnstructuredContent(), target, nil
 	}
 
 	resultUnstructured, err := dynamciClientRes.Get(context.TODO(), name, metav1.GetOptions{})
@@ -225,19 +390,20 @@ func (t *TemplateResolver) getOrList(
 	}
 
 	if err != nil {
-		// Cache a not found result
+		// Cache a not found result so that repeated lookups of a missing object in the same ResolveTemplate call don't
+		// query the API server again. The cache is cleared after each call, so a recreated object is still found.
 		if apierrors.IsNotFound(err) {
 			t.tempCallCache.CacheFromObjectIdentifier(lookupID, []unstructured.Unstructured{})
 		}
 
-		return nil, err
+		return nil, target, err
 	}
 
 	if templateResult != nil && kind == "Secret" {
 		templateResult.HasSensitiveData = true
 	}
 
-	return resultUnstructured.UnstructuredContent(), nil
+	return resultUnstructured.UnstructuredContent(), target, nil
 }
 
 func (t *TemplateResolver) lookupHelper(
@@ -275,11 +441,460 @@ func (t *TemplateResolver) lookup(
 		lookupErr = nil
 	}
 
+	if lookupErr == nil && len(result) != 0 && options != nil && options.ApplyDefaults {
+		result, lookupErr = t.applyServerDefaults(result)
+	}
+
 	klog.V(2).Infof("lookup result:  %v", result)
 
 	return result, lookupErr
 }
 
+func (t *TemplateResolver) lookupNameGlobHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string) (map[string]interface{}, error) {
+	return func(apiVersion string, kind string, namespace string, pattern string) (map[string]interface{}, error) {
+		return t.lookupNameGlob(options, templateResult, apiVersion, kind, namespace, pattern)
+	}
+}
+
+// lookupNameGlob lists the objects of the kind in the namespace and returns the ones whose name matches the shell-style
+// glob pattern (e.g. "app-*") in the same format as a lookup list query. This is useful when the names follow a
+// pattern but the objects don't have labels in common. An error is returned if the pattern is invalid.
+func (t *TemplateResolver) lookupNameGlob(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	pattern string,
+) (
+	map[string]interface{}, error,
+) {
+	klog.V(2).Infof("lookupNameGlob :  %v, %v, %v, %v", apiVersion, kind, namespace, pattern)
+
+	// Validate the pattern so that an invalid pattern isn't silently treated as not matching
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: the name pattern %s is invalid: %w", ErrInvalidInput, pattern, err)
+	}
+
+	list, err := t.getOrList(options, templateResult, apiVersion, kind, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	objList := unstructured.UnstructuredList{}
+	objList.SetUnstructuredContent(list)
+
+	matching := []unstructured.Unstructured{}
+
+	for _, obj := range objList.Items {
+		if matched, _ := path.Match(pattern, obj.GetName()); matched {
+			matching = append(matching, obj)
+		}
+	}
+
+	resultList := unstructured.UnstructuredList{Items: matching}
+	result := resultList.UnstructuredContent()
+
+	if len(matching) != 0 && options != nil && options.ApplyDefaults {
+		return t.applyServerDefaults(result)
+	}
+
+	return result, nil
+}
+
+func (t *TemplateResolver) lastAppliedHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string) (map[string]interface{}, error) {
+	return func(apiVersion string, kind string, namespace string, name string) (map[string]interface{}, error) {
+		return t.lastApplied(options, templateResult, apiVersion, kind, namespace, name)
+	}
+}
+
+// lastApplied returns the parsed JSON of the "kubectl.kubernetes.io/last-applied-configuration" annotation of the
+// given object. An empty map is returned if the object or the annotation doesn't exist. Since the lookup cache strips
+// this annotation, the object is always retrieved directly from the API server and the result isn't cached or watched.
+func (t *TemplateResolver) lastApplied(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+) (map[string]interface{}, error) {
+	klog.V(2).Infof("lastApplied :  %v, %v, %v, %v", apiVersion, kind, namespace, name)
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: the name must be specified", ErrInvalidInput)
+	}
+
+	if t.dynamicClient == nil {
+		return nil, errors.New("the lastApplied template function requires a Kubernetes client, " +
+			"which is not available when using NewResolverWithDynamicWatcher")
+	}
+
+	if options == nil {
+		options = &ResolveOptions{}
+	}
+
+	target, err := t.prepareLookup(options, templateResult, apiVersion, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var dynamicClientRes dynamic.ResourceInterface
+
+	gvr := target.scopedGVRObj.GroupVersionResource
+
+	if target.scopedGVRObj.Namespaced && target.namespace != "" {
+		dynamicClientRes = t.dynamicClient.Resource(gvr).Namespace(target.namespace)
+	} else {
+		dynamicClientRes = t.dynamicClient.Resource(gvr)
+	}
+
+	lastAppliedConfig := map[string]interface{}{}
+
+	obj, err := dynamicClientRes.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			if pinErr := checkPinnedResourceVersion(options, target.lookupID, ""); pinErr != nil {
+				return nil, pinErr
+			}
+
+			if options.TrackReferences && templateResult != nil {
+				templateResult.addNotFoundObject(target.lookupID)
+			}
+
+			return lastAppliedConfig, nil
+		}
+
+		return nil, err
+	}
+
+	if err := checkPinnedResourceVersion(options, target.lookupID, obj.GetResourceVersion()); err != nil {
+		return nil, err
+	}
+
+	if templateResult != nil && kind == "Secret" {
+		templateResult.HasSensitiveData = true
+	}
+
+	annotation := obj.GetAnnotations()[corev1.LastAppliedConfigAnnotation]
+	if annotation == "" {
+		return lastAppliedConfig, nil
+	}
+
+	err = json.Unmarshal([]byte(annotation), &lastAppliedConfig)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"the %s annotation of the %s %s is invalid JSON: %w",
+			corev1.LastAppliedConfigAnnotation, kind, name, err,
+		)
+	}
+
+	return lastAppliedConfig, nil
+}
+
+func (t *TemplateResolver) containerResourceHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string, string, string) (string, error) {
+	return func(apiVersion, kind, namespace, name, containerName, field string) (string, error) {
+		return t.containerResource(options, templateResult, apiVersion, kind, namespace, name, containerName, field)
+	}
+}
+
+// containerPaths are the paths to the container lists in the supported workload kinds. The first path that exists in
+// the object is used.
+var containerPaths = [][]string{
+	// Deployment, StatefulSet, DaemonSet, ReplicaSet, and Job
+	{"spec", "template", "spec"},
+	// CronJob
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+	// Pod
+	{"spec"},
+}
+
+// containerResource returns the quantity string of the resource field (e.g. "requests.cpu") of the named container in
+// the Pod or workload object. The containers and then the init containers are searched. An empty string is returned
+// if the object, container, or field doesn't exist.
+func (t *TemplateResolver) containerResource(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+	containerName string,
+	field string,
+) (string, error) {
+	klog.V(2).Infof("containerResource :  %v, %v, %v, %v, %v, %v", apiVersion, kind, namespace, name, containerName,
+		field)
+
+	if name == "" || containerName == "" || field == "" {
+		return "", fmt.Errorf("%w: the name, container name, and field must be specified", ErrInvalidInput)
+	}
+
+	obj, err := t.getOrList(options, templateResult, apiVersion, kind, namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	fieldPath := append([]string{"resources"}, strings.Split(field, ".")...)
+
+	for _, podSpecPath := range containerPaths {
+		podSpec, found, _ := unstructured.NestedMap(obj, podSpecPath...)
+		if !found {
+			continue
+		}
+
+		for _, containersField := range []string{"containers", "initContainers"} {
+			containers, _, _ := unstructured.NestedSlice(podSpec, containersField)
+
+			for _, container := range containers {
+				containerMap, ok := container.(map[string]interface{})
+				if !ok || containerMap["name"] != containerName {
+					continue
+				}
+
+				value, found, _ := unstructured.NestedFieldNoCopy(containerMap, fieldPath...)
+				if !found || value == nil {
+					return "", nil
+				}
+
+				return fmt.Sprint(value), nil
+			}
+		}
+
+		return "", nil
+	}
+
+	return "", nil
+}
+
+func (t *TemplateResolver) readyReplicasHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string) (int, error) {
+	return func(apiVersion, kind, namespace, name string) (int, error) {
+		return t.readyReplicas(options, templateResult, apiVersion, kind, namespace, name)
+	}
+}
+
+// readyReplicas returns the number of ready replicas in the status of the workload object, such as a Deployment or
+// StatefulSet. For a DaemonSet, the number of ready Pods in status.numberReady is returned. Zero is returned if the
+// object or the field doesn't exist.
+func (t *TemplateResolver) readyReplicas(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+) (int, error) {
+	klog.V(2).Infof("readyReplicas :  %v, %v, %v, %v", apiVersion, kind, namespace, name)
+
+	obj, err := t.getWorkload(options, templateResult, apiVersion, kind, namespace, name)
+	if err != nil || obj == nil {
+		return 0, err
+	}
+
+	ready, _ := workloadReplicas(obj, kind)
+
+	return int(ready), nil
+}
+
+func (t *TemplateResolver) isWorkloadReadyHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string) (bool, error) {
+	return func(apiVersion, kind, namespace, name string) (bool, error) {
+		return t.isWorkloadReady(options, templateResult, apiVersion, kind, namespace, name)
+	}
+}
+
+// isWorkloadReady returns true if the number of ready replicas in the status of the workload object is at least the
+// desired number of replicas in spec.replicas, which defaults to 1. For a DaemonSet, status.numberReady is compared to
+// status.desiredNumberScheduled instead. False is returned if the object doesn't exist.
+func (t *TemplateResolver) isWorkloadReady(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+) (bool, error) {
+	klog.V(2).Infof("isWorkloadReady :  %v, %v, %v, %v", apiVersion, kind, namespace, name)
+
+	obj, err := t.getWorkload(options, templateResult, apiVersion, kind, namespace, name)
+	if err != nil || obj == nil {
+		return false, err
+	}
+
+	ready, desired := workloadReplicas(obj, kind)
+
+	return ready >= desired, nil
+}
+
+// getWorkload returns the named workload object for readyReplicas and isWorkloadReady. A nil object and no error is
+// returned if the object doesn't exist.
+func (t *TemplateResolver) getWorkload(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+) (map[string]interface{}, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: the name must be specified", ErrInvalidInput)
+	}
+
+	obj, err := t.getOrList(options, templateResult, apiVersion, kind, namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// workloadReplicas returns the ready and desired number of replicas of the workload object. A DaemonSet has these in
+// status.numberReady and status.desiredNumberScheduled, and other kinds, such as a Deployment or StatefulSet, have
+// these in status.readyReplicas and spec.replicas, which defaults to 1.
+func workloadReplicas(obj map[string]interface{}, kind string) (ready int64, desired int64) {
+	if kind == "DaemonSet" {
+		ready, _, _ = unstructured.NestedInt64(obj, "status", "numberReady")
+		desired, _, _ = unstructured.NestedInt64(obj, "status", "desiredNumberScheduled")
+
+		return ready, desired
+	}
+
+	ready, _, _ = unstructured.NestedInt64(obj, "status", "readyReplicas")
+
+	desired, found, err := unstructured.NestedInt64(obj, "spec", "replicas")
+	if !found || err != nil {
+		desired = 1
+	}
+
+	return ready, desired
+}
+
+func (t *TemplateResolver) canLookupHelper(
+	options *ResolveOptions,
+) func(string, string, string, string) (bool, error) {
+	return func(apiVersion string, kind string, namespace string, name string) (bool, error) {
+		return t.canLookup(options, apiVersion, kind, namespace, name)
+	}
+}
+
+// canLookup performs a SelfSubjectAccessReview to determine if the resolver's client is allowed to get the object.
+// If the name is an empty string, the "list" verb is checked instead to match the lookup behavior. False is returned
+// rather than an error if the access is denied, including when the namespace or cluster-scoped resource is
+// restricted by the ResolveOptions.
+func (t *TemplateResolver) canLookup(
+	options *ResolveOptions, apiVersion string, kind string, namespace string, name string,
+) (bool, error) {
+	klog.V(2).Infof("canLookup :  %v, %v, %v, %v", apiVersion, kind, namespace, name)
+
+	if apiVersion == "" || kind == "" {
+		return false, errors.New("the apiVersion and kind are required")
+	}
+
+	if t.dynamicClient == nil {
+		return false, errors.New("the canLookup template function requires a Kubernetes client, " +
+			"which is not available when using NewResolverWithDynamicWatcher")
+	}
+
+	ns, err := t.getNamespace(namespace, options.LookupNamespace)
+	if err != nil {
+		if errors.Is(err, ErrRestrictedNamespace) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+
+	if !kindAllowed(options.AllowedLookupKinds, gv.WithKind(kind).GroupKind()) {
+		return false, nil
+	}
+
+	scopedGVRObj, err := t.getScopedGVR(gv.WithKind(kind))
+	if err != nil {
+		return false, err
+	}
+
+	if !scopedGVRObj.Namespaced {
+		if options.LookupNamespace != "" {
+			rsrcIdentifier := ClusterScopedObjectIdentifier{Group: scopedGVRObj.Group, Kind: kind, Name: name}
+			if !onAllowlist(options.ClusterScopedAllowList, rsrcIdentifier) {
+				return false, nil
+			}
+		}
+
+		ns = ""
+	}
+
+	verb := "get"
+	if name == "" {
+		verb = "list"
+	}
+
+	review := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "authorization.k8s.io/v1",
+			"kind":       "SelfSubjectAccessReview",
+			"spec": map[string]interface{}{
+				"resourceAttributes": map[string]interface{}{
+					"group":     scopedGVRObj.Group,
+					"version":   scopedGVRObj.Version,
+					"resource":  scopedGVRObj.Resource,
+					"namespace": ns,
+					"name":      name,
+					"verb":      verb,
+				},
+			},
+		},
+	}
+
+	result, err := t.dynamicClient.Resource(selfSubjectAccessReviewGVR).Create(
+		context.TODO(), &review, metav1.CreateOptions{},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to perform the SelfSubjectAccessReview: %w", err)
+	}
+
+	allowed, _, _ := unstructured.NestedBool(result.Object, "status", "allowed")
+
+	klog.V(2).Infof("canLookup result:  %v", allowed)
+
+	return allowed, nil
+}
+
+// kindAllowed returns true if the group kind is in the allowed list or if the allowed list is empty.
+func kindAllowed(allowed []schema.GroupKind, groupKind schema.GroupKind) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	return slices.Contains(allowed, groupKind)
+}
+
 func onAllowlist(allowlist []ClusterScopedObjectIdentifier, rsrc ClusterScopedObjectIdentifier) bool {
 	if len(allowlist) == 0 {
 		return false
@@ -377,22 +992,21 @@ func (t *TemplateResolver) getNodesWithExactRoles(
 
 func (t *TemplateResolver) hasNodesWithExactRolesHelper(
 	options *ResolveOptions,
+	templateResult *TemplateResult,
 ) func(...string) (bool, error) {
 	return func(name ...string) (
 		bool, error,
 	) {
-		return t.hasNodesWithExactRoles(options, name...)
+		return t.hasNodesWithExactRoles(options, templateResult, name...)
 	}
 }
 
 // function hasNodesWithExactRoles returns true if there are any nodes labeled with only the
 // specified roles.  Does not include nodes which have additional roles on them.
 func (t *TemplateResolver) hasNodesWithExactRoles(
-	options *ResolveOptions, name ...string,
+	options *ResolveOptions, templateResult *TemplateResult, name ...string,
 ) (bool, error) {
-	var resolvedResult TemplateResult
-
-	nodes, err := t.getNodesWithExactRoles(options, &resolvedResult, name...)
+	nodes, err := t.getNodesWithExactRoles(options, templateResult, name...)
 	if err != nil {
 		return false, err
 	}
@@ -401,3 +1015,264 @@ func (t *TemplateResolver) hasNodesWithExactRoles(
 
 	return len(items) > 0, nil
 }
+
+// countResourcesPageSize is the number of objects requested per page when countResources must page through the list
+// to count the objects.
+const countResourcesPageSize = 500
+
+func (t *TemplateResolver) countResourcesHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, ...string) (int, error) {
+	return func(apiVersion string, kind string, labelSelector ...string) (int, error) {
+		return t.countResources(options, templateResult, apiVersion, kind, labelSelector...)
+	}
+}
+
+// countResources returns the number of cluster-scoped objects of the kind matching the optional label selector. The
+// objects aren't returned, so when querying the API server, the list is requested with a limit and the count in the
+// list metadata is used when available. Otherwise, the list is paged through and only the number of items is kept.
+// Since the kind is cluster-scoped, the ClusterScopedAllowList applies when LookupNamespace is set.
+func (t *TemplateResolver) countResources(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	labelSelector ...string,
+) (int, error) {
+	klog.V(2).Infof("countResources :  %v, %v, %v", apiVersion, kind, labelSelector)
+
+	if options == nil {
+		options = &ResolveOptions{}
+	}
+
+	target, err := t.prepareLookup(options, templateResult, apiVersion, kind, "", "", labelSelector...)
+	if err != nil {
+		return 0, err
+	}
+
+	if target.scopedGVRObj.Namespaced {
+		return 0, fmt.Errorf("%w: countResources only supports cluster-scoped kinds, got %s", ErrInvalidInput, kind)
+	}
+
+	if t.dynamicWatcher != nil {
+		result, err := t.dynamicWatcher.List(*options.Watcher, target.gvk, "", target.parsedSelector)
+		if err != nil {
+			return 0, err
+		}
+
+		if options.snapshot != nil {
+			result = options.snapshot.list(target.lookupID, result)
+		}
+
+		return len(result), nil
+	}
+
+	cachedResults, err := t.tempCallCache.FromObjectIdentifier(target.lookupID)
+	if err == nil {
+		return len(cachedResults), nil
+	}
+
+	if !errors.Is(err, client.ErrNoCacheEntry) {
+		return 0, err
+	}
+
+	dynamicClientRes := t.dynamicClient.Resource(target.scopedGVRObj.GroupVersionResource)
+
+	listOptions := metav1.ListOptions{
+		LabelSelector: target.parsedSelector.String(),
+		// A single object is requested first since the API server returns the number of remaining objects in the list
+		// metadata when no field or label selectors are set.
+		Limit: 1,
+	}
+
+	count := 0
+
+	for {
+		resultList, err := dynamicClientRes.List(context.TODO(), listOptions)
+		if err != nil {
+			return 0, err
+		}
+
+		count += len(resultList.Items)
+
+		if remaining := resultList.GetRemainingItemCount(); remaining != nil {
+			return count + int(*remaining), nil
+		}
+
+		if resultList.GetContinue() == "" {
+			return count, nil
+		}
+
+		listOptions.Continue = resultList.GetContinue()
+		listOptions.Limit = countResourcesPageSize
+	}
+}
+
+func (t *TemplateResolver) matchingNamespacesHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, ...[]interface{}) ([]string, error) {
+	return func(labelSelector string, patterns ...[]interface{}) ([]string, error) {
+		return t.matchingNamespaces(options, templateResult, labelSelector, patterns...)
+	}
+}
+
+// matchingNamespaces returns the sorted names of the namespaces matching the label selector and the optional include
+// and exclude filepath patterns. This follows the same semantics as the ConfigurationPolicy namespaceSelector where
+// the first patterns argument is the include list and the second is the exclude list. An empty include list matches
+// all namespaces. Since Namespace is cluster-scoped, the ClusterScopedAllowList applies when LookupNamespace is set.
+func (t *TemplateResolver) matchingNamespaces(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	labelSelector string,
+	patterns ...[]interface{},
+) ([]string, error) {
+	klog.V(2).Infof("matchingNamespaces :  %v, %v", labelSelector, patterns)
+
+	if len(patterns) > 2 {
+		return nil, fmt.Errorf("%w: only include and exclude patterns may be specified", ErrInvalidInput)
+	}
+
+	var include, exclude []string
+
+	for i, patternList := range patterns {
+		for _, pattern := range patternList {
+			patternStr, ok := pattern.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: the namespace patterns must be strings", ErrInvalidInput)
+			}
+
+			// Validate the pattern so that an invalid pattern isn't silently ignored
+			if _, err := filepath.Match(patternStr, ""); err != nil {
+				return nil, fmt.Errorf("%w: the namespace pattern %s is invalid: %w", ErrInvalidInput, patternStr, err)
+			}
+
+			if i == 0 {
+				include = append(include, patternStr)
+			} else {
+				exclude = append(exclude, patternStr)
+			}
+		}
+	}
+
+	namespaces, err := t.getOrList(options, templateResult, "v1", "Namespace", "", "", labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	nsList := unstructured.UnstructuredList{}
+	nsList.SetUnstructuredContent(namespaces)
+
+	result := []string{}
+
+	for _, ns := range nsList.Items {
+		name := ns.GetName()
+
+		if len(include) != 0 && !matchesAnyPattern(include, name) {
+			continue
+		}
+
+		if matchesAnyPattern(exclude, name) {
+			continue
+		}
+
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+func (t *TemplateResolver) distinctFieldHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string, string) ([]string, error) {
+	return func(apiVersion, kind, namespace, labelSelector, field string) ([]string, error) {
+		return t.distinctField(options, templateResult, apiVersion, kind, namespace, labelSelector, field)
+	}
+}
+
+// distinctField lists the objects matching the label selector and returns the sorted and de-duplicated values of the
+// field at the dot separated path (e.g. "spec.nodeName") in the objects. Objects without the field are skipped, and an
+// error is returned if the field is not a string, number, or boolean.
+func (t *TemplateResolver) distinctField(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	apiVersion string,
+	kind string,
+	namespace string,
+	labelSelector string,
+	field string,
+) ([]string, error) {
+	klog.V(2).Infof("distinctField :  %v, %v, %v, %v, %v", apiVersion, kind, namespace, labelSelector, field)
+
+	if field == "" {
+		return nil, fmt.Errorf("%w: the field must be specified", ErrInvalidInput)
+	}
+
+	fieldPath := strings.Split(field, ".")
+
+	list, err := t.getOrList(options, templateResult, apiVersion, kind, namespace, "", labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	objList := unstructured.UnstructuredList{}
+	objList.SetUnstructuredContent(list)
+
+	values := map[string]bool{}
+
+	for _, obj := range objList.Items {
+		value, found, err := unstructured.NestedFieldNoCopy(obj.Object, fieldPath...)
+		if err != nil || !found || value == nil {
+			continue
+		}
+
+		switch value.(type) {
+		case string, bool, int64, float64:
+			values[fmt.Sprint(value)] = true
+		default:
+			return nil, fmt.Errorf(
+				"%w: the %s field of the %s %s is not a string, number, or boolean",
+				ErrInvalidInput, field, kind, obj.GetName(),
+			)
+		}
+	}
+
+	result := make([]string, 0, len(values))
+
+	for value := range values {
+		result = append(result, value)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// matchesAnyPattern returns true if the input name matches any of the input filepath patterns. The patterns must be
+// validated before calling this.
+func matchesAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseLabelSelector parses and joins the input label selector requirements as the lookup template function does. If
+// no requirements are provided or the first one is an empty string, a selector that matches everything is returned.
+func parseLabelSelector(labelSelector ...string) (labels.Selector, error) {
+	// Note there can be multiple values passed to labelSelector so we need to treat it as an array
+	if len(labelSelector) == 0 || labelSelector[0] == "" {
+		return labels.Everything(), nil
+	}
+
+	// We use the labels.Parse to validate the selector given.
+	// this should give us a better error output if the user misconfigured the selector
+	return labels.Parse(strings.Join(labelSelector, ","))
+}
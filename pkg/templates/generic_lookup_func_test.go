@@ -7,7 +7,17 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
 )
 
 func TestLookup(t *testing.T) {
@@ -97,6 +107,43 @@ func TestLookup(t *testing.T) {
 	}
 }
 
+func TestLookupAllowedKinds(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		inputAPIVersion string
+		inputKind       string
+		inputName       string
+		allowedKinds    []schema.GroupKind
+		expectedErr     error
+	}{
+		{"v1", "ConfigMap", "testconfigmap", nil, nil},
+		{"v1", "ConfigMap", "testconfigmap", []schema.GroupKind{{Kind: "ConfigMap"}, {Kind: "Secret"}}, nil},
+		{"v1", "Secret", "testsecret", []schema.GroupKind{{Kind: "ConfigMap"}}, ErrKindNotAllowed},
+		{"v1", "ConfigMap", "", []schema.GroupKind{{Group: "apps", Kind: "ConfigMap"}}, ErrKindNotAllowed},
+	}
+
+	for _, test := range testcases {
+		resolver, err := NewResolver(k8sConfig, Config{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		_, err = resolver.lookup(
+			&ResolveOptions{AllowedLookupKinds: test.allowedKinds},
+			&TemplateResult{},
+			test.inputAPIVersion,
+			test.inputKind,
+			"testns",
+			test.inputName,
+		)
+
+		if !errors.Is(err, test.expectedErr) {
+			t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+		}
+	}
+}
+
 func TestLookupWithLabels(t *testing.T) {
 	t.Parallel()
 
@@ -488,6 +535,7 @@ func TestHasNodesWithExactRoles(t *testing.T) {
 			LookupNamespace:        "",
 			ClusterScopedAllowList: nil,
 		},
+		&TemplateResult{},
 		testRole...,
 	)
 	if err != nil {
@@ -498,3 +546,948 @@ func TestHasNodesWithExactRoles(t *testing.T) {
 		t.Fatal("Infra nodes should exist, but returned false")
 	}
 }
+
+func TestCanLookup(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		inputAPIVersion string
+		inputKind       string
+		inputNs         string
+		inputName       string
+		lookupNamespace string
+		expectedResult  bool
+		expectedErr     error
+	}{
+		{"v1", "ConfigMap", "testns", "testconfigmap", "", true, nil},
+		{"v1", "Secret", "testns", "", "", true, nil},
+		{"v1", "ConfigMap", "", "testconfigmap", "testns", true, nil},
+		{"v1", "Node", "", "node-infra1", "", true, nil},
+		{"v1", "ConfigMap", "testns", "testconfigmap", "policies-ns", false, nil},
+		{"v1", "Node", "", "node-infra1", "testns", false, nil},
+		{"v1", "NotAResource", "testns", "object", "", false, ErrMissingAPIResource},
+		{"", "ConfigMap", "testns", "testconfigmap", "", false, errors.New("the apiVersion and kind are required")},
+	}
+
+	for _, test := range testcases {
+		resolver, err := NewResolver(k8sConfig, Config{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		val, err := resolver.canLookup(
+			&ResolveOptions{LookupNamespace: test.lookupNamespace},
+			test.inputAPIVersion,
+			test.inputKind,
+			test.inputNs,
+			test.inputName,
+		)
+
+		if err != nil {
+			if test.expectedErr == nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !(errors.Is(err, test.expectedErr) || strings.EqualFold(test.expectedErr.Error(), err.Error())) {
+				t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+			}
+		} else if test.expectedErr != nil {
+			t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+		}
+
+		if val != test.expectedResult {
+			t.Fatalf("expected : %v , got : %v", test.expectedResult, val)
+		}
+	}
+}
+
+func TestMatchingNamespaces(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		labelSelector   string
+		patterns        [][]interface{}
+		lookupNamespace string
+		allowList       []ClusterScopedObjectIdentifier
+		expectedResult  []string
+		expectedErr     error
+	}{
+		{"matchns-env", nil, "", nil, []string{"matchns-dev", "matchns-prod-a", "matchns-prod-b"}, nil},
+		{"matchns-env=prod", nil, "", nil, []string{"matchns-prod-a", "matchns-prod-b"}, nil},
+		{"matchns-env", [][]interface{}{{"*-prod-*"}}, "", nil, []string{"matchns-prod-a", "matchns-prod-b"}, nil},
+		{"matchns-env", [][]interface{}{{}, {"*-b", "*-dev"}}, "", nil, []string{"matchns-prod-a"}, nil},
+		{
+			"matchns-env",
+			[][]interface{}{{"matchns-*"}, {"*-a"}},
+			"",
+			nil,
+			[]string{"matchns-dev", "matchns-prod-b"},
+			nil,
+		},
+		{"matchns-env=staging", nil, "", nil, []string{}, nil},
+		{
+			"matchns-env=dev",
+			nil,
+			"testns",
+			[]ClusterScopedObjectIdentifier{{Group: "", Kind: "Namespace", Name: "*"}},
+			[]string{"matchns-dev"},
+			nil,
+		},
+		{"matchns-env", nil, "testns", nil, nil, ClusterScopedLookupRestrictedError{"Namespace", ""}},
+		{"matchns-env", [][]interface{}{{"["}}, "", nil, nil, ErrInvalidInput},
+		{"matchns-env", [][]interface{}{{}, {}, {}}, "", nil, nil, ErrInvalidInput},
+	}
+
+	for _, test := range testcases {
+		resolver, err := NewResolver(k8sConfig, Config{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		val, err := resolver.matchingNamespaces(
+			&ResolveOptions{LookupNamespace: test.lookupNamespace, ClusterScopedAllowList: test.allowList},
+			&TemplateResult{},
+			test.labelSelector,
+			test.patterns...,
+		)
+
+		if err != nil {
+			if test.expectedErr == nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !(errors.Is(err, test.expectedErr) || strings.EqualFold(test.expectedErr.Error(), err.Error())) {
+				t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+			}
+
+			continue
+		} else if test.expectedErr != nil {
+			t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+		}
+
+		if !slices.Equal(val, test.expectedResult) {
+			t.Fatalf("expected : %v , got : %v", test.expectedResult, val)
+		}
+	}
+}
+
+func TestLookupNameGlob(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		namespace       string
+		pattern         string
+		lookupNamespace string
+		expectedResult  []string
+		expectedErr     error
+	}{
+		{"testns", "testcm-env*", "", []string{"testcm-enva", "testcm-envb", "testcm-envc"}, nil},
+		{"testns", "testcm-env[ab]", "", []string{"testcm-enva", "testcm-envb"}, nil},
+		{"testns", "testcm-env?", "testns", []string{"testcm-enva", "testcm-envb", "testcm-envc"}, nil},
+		{"testns", "testcm-envc", "", []string{"testcm-envc"}, nil},
+		{"testns", "nomatch-*", "", []string{}, nil},
+		{"testns", "testcm-env*", "policies-ns", nil, ErrRestrictedNamespace},
+		{"testns", "testcm-env[", "", nil, ErrInvalidInput},
+	}
+
+	for _, test := range testcases {
+		resolver, err := NewResolver(k8sConfig, Config{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		val, err := resolver.lookupNameGlob(
+			&ResolveOptions{LookupNamespace: test.lookupNamespace},
+			&TemplateResult{},
+			"v1",
+			"ConfigMap",
+			test.namespace,
+			test.pattern,
+		)
+
+		if err != nil {
+			if test.expectedErr == nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+			}
+
+			continue
+		} else if test.expectedErr != nil {
+			t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+		}
+
+		names := []string{}
+
+		for _, item := range val["items"].([]interface{}) {
+			names = append(names, item.(map[string]interface{})["metadata"].(map[string]interface{})["name"].(string))
+		}
+
+		slices.Sort(names)
+
+		if !slices.Equal(names, test.expectedResult) {
+			t.Fatalf("expected : %v , got : %v", test.expectedResult, names)
+		}
+	}
+}
+
+func TestCountResources(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		kind            string
+		labelSelector   string
+		lookupNamespace string
+		allowList       []ClusterScopedObjectIdentifier
+		expectedResult  int
+		expectedErr     error
+	}{
+		{"Node", "node-role.kubernetes.io/infra", "", nil, 3, nil},
+		{"Node", "node-role.kubernetes.io/worker", "", nil, 1, nil},
+		{"Node", "node-role.kubernetes.io/storage", "", nil, 1, nil},
+		{"Node", "node-role.kubernetes.io/master", "", nil, 0, nil},
+		{
+			"Node",
+			"node-role.kubernetes.io/infra,!node-role.kubernetes.io/storage",
+			"testns",
+			[]ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "*"}},
+			2,
+			nil,
+		},
+		{"Node", "node-role.kubernetes.io/infra", "testns", nil, 0, ClusterScopedLookupRestrictedError{"Node", ""}},
+		{"ConfigMap", "", "", nil, 0, ErrInvalidInput},
+	}
+
+	for _, test := range testcases {
+		resolver, err := NewResolver(k8sConfig, Config{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		val, err := resolver.countResources(
+			&ResolveOptions{LookupNamespace: test.lookupNamespace, ClusterScopedAllowList: test.allowList},
+			&TemplateResult{},
+			"v1",
+			test.kind,
+			test.labelSelector,
+		)
+
+		if err != nil {
+			if test.expectedErr == nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !(errors.Is(err, test.expectedErr) || strings.EqualFold(test.expectedErr.Error(), err.Error())) {
+				t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+			}
+
+			continue
+		} else if test.expectedErr != nil {
+			t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+		}
+
+		if val != test.expectedResult {
+			t.Fatalf("expected : %d , got : %d", test.expectedResult, val)
+		}
+	}
+}
+
+func TestCountResourcesPaging(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-infra1
+  labels:
+    node-role.kubernetes.io/infra: ""
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-infra2
+  labels:
+    node-role.kubernetes.io/infra: ""
+    node-role.kubernetes.io/worker: ""
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-storage
+  labels:
+    node-role.kubernetes.io/storage: ""
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		tmpl          string
+		remaining     *int64
+		expectedJSON  string
+		expectedLists int
+	}{
+		"remaining_item_count": {
+			tmpl:          `nodes: '{{ countResources "v1" "Node" }}'`,
+			remaining:     ptr.To(int64(41)),
+			expectedJSON:  `{"nodes":"42"}`,
+			expectedLists: 1,
+		},
+		"continue": {
+			tmpl:          `nodes: '{{ countResources "v1" "Node" "node-role.kubernetes.io/infra" }}'`,
+			expectedJSON:  `{"nodes":"2"}`,
+			expectedLists: 2,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, fakeClient := newFakeClientResolver(t, objects, Config{})
+
+			var (
+				lock  sync.Mutex
+				lists int
+			)
+
+			// Simulate the API server returning one object per page
+			fakeClient.PrependReactor("list", "nodes", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				lock.Lock()
+				defer lock.Unlock()
+
+				lists++
+
+				listAction := action.(clienttesting.ListActionImpl)
+				selector := listAction.GetListRestrictions().Labels
+
+				matching := []unstructured.Unstructured{}
+
+				for _, obj := range objects {
+					if selector.Matches(labels.Set(obj.GetLabels())) {
+						matching = append(matching, obj)
+					}
+				}
+
+				page := &unstructured.UnstructuredList{Object: map[string]interface{}{
+					"apiVersion": "v1", "kind": "NodeList",
+				}}
+				page.Items = matching[lists-1 : lists]
+
+				if test.remaining != nil {
+					page.SetRemainingItemCount(test.remaining)
+				} else if lists < len(matching) {
+					page.SetContinue(fmt.Sprintf("page-%d", lists))
+				}
+
+				return true, page, nil
+			})
+
+			result, err := resolver.ResolveTemplate([]byte(test.tmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+			}
+
+			if lists != test.expectedLists {
+				t.Fatalf("expected %d list requests, got : %d", test.expectedLists, lists)
+			}
+		})
+	}
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		selectors   []string
+		expected    string
+		expectedErr bool
+	}{
+		"none":     {selectors: nil, expected: ""},
+		"empty":    {selectors: []string{""}, expected: ""},
+		"single":   {selectors: []string{"env=prod"}, expected: "env=prod"},
+		"multiple": {selectors: []string{"env in (a, b)", "app"}, expected: "app,env in (a,b)"},
+		"invalid":  {selectors: []string{"env in (a"}, expectedErr: true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			selector, err := parseLabelSelector(test.selectors...)
+			if err != nil {
+				if !test.expectedErr {
+					t.Fatalf(err.Error())
+				}
+
+				return
+			} else if test.expectedErr {
+				t.Fatal("An error was expected but not returned")
+			}
+
+			if selector.String() != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, selector.String())
+			}
+		})
+	}
+}
+
+const containerResourceBundle = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: app
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          resources:
+            requests:
+              memory: 64Mi
+      containers:
+        - name: sidecar
+          resources: {}
+        - name: app
+          resources:
+            requests:
+              cpu: 500m
+              memory: 1Gi
+            limits:
+              cpu: 2
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod
+  namespace: app
+spec:
+  containers:
+    - name: app
+      resources:
+        requests:
+          cpu: 100m
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: job
+  namespace: app
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: job
+              resources:
+                limits:
+                  memory: 256Mi
+`
+
+func TestContainerResource(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(containerResourceBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		apiVersion     string
+		kind           string
+		name           string
+		containerName  string
+		field          string
+		expectedResult string
+		expectedErr    error
+	}{
+		"deployment_requests": {"apps/v1", "Deployment", "app", "app", "requests.cpu", "500m", nil},
+		"deployment_limits":   {"apps/v1", "Deployment", "app", "app", "limits.cpu", "2", nil},
+		"init_container":      {"apps/v1", "Deployment", "app", "init", "requests.memory", "64Mi", nil},
+		"missing_field":       {"apps/v1", "Deployment", "app", "sidecar", "requests.cpu", "", nil},
+		"missing_container":   {"apps/v1", "Deployment", "app", "other", "requests.cpu", "", nil},
+		"missing_object":      {"apps/v1", "Deployment", "other", "app", "requests.cpu", "", nil},
+		"pod":                 {"v1", "Pod", "pod", "app", "requests.cpu", "100m", nil},
+		"cronjob":             {"batch/v1", "CronJob", "job", "job", "limits.memory", "256Mi", nil},
+		"missing_field_arg":   {"apps/v1", "Deployment", "app", "app", "", "", ErrInvalidInput},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := resolver.containerResource(
+				&ResolveOptions{}, &TemplateResult{}, test.apiVersion, test.kind, "app", test.name,
+				test.containerName, test.field,
+			)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if val != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, val)
+			}
+		})
+	}
+}
+
+func TestWorkloadReadiness(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ready
+  namespace: app
+spec:
+  replicas: 3
+status:
+  readyReplicas: 3
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: progressing
+  namespace: app
+spec:
+  replicas: 3
+status:
+  readyReplicas: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: no-status
+  namespace: app
+spec: {}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: scaled-down
+  namespace: app
+spec:
+  replicas: 0
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: app
+spec:
+  replicas: 2
+status:
+  readyReplicas: 2
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+  namespace: app
+status:
+  desiredNumberScheduled: 4
+  numberReady: 3
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		kind            string
+		name            string
+		expectedReady   int
+		expectedIsReady bool
+		expectedErr     error
+	}{
+		"deployment_ready":       {"Deployment", "ready", 3, true, nil},
+		"deployment_progressing": {"Deployment", "progressing", 1, false, nil},
+		"deployment_no_status":   {"Deployment", "no-status", 0, false, nil},
+		"deployment_scaled_down": {"Deployment", "scaled-down", 0, true, nil},
+		"statefulset":            {"StatefulSet", "db", 2, true, nil},
+		"daemonset":              {"DaemonSet", "agent", 3, false, nil},
+		"missing_object":         {"Deployment", "other", 0, false, nil},
+		"missing_name":           {"Deployment", "", 0, false, ErrInvalidInput},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			ready, err := resolver.readyReplicas(
+				&ResolveOptions{}, &TemplateResult{}, "apps/v1", test.kind, "app", test.name,
+			)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if ready != test.expectedReady {
+				t.Fatalf("expected : %d , got : %d", test.expectedReady, ready)
+			}
+
+			isReady, err := resolver.isWorkloadReady(
+				&ResolveOptions{}, &TemplateResult{}, "apps/v1", test.kind, "app", test.name,
+			)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if isReady != test.expectedIsReady {
+				t.Fatalf("expected : %v , got : %v", test.expectedIsReady, isReady)
+			}
+		})
+	}
+}
+
+func TestPinnedResourceVersions(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: app
+  resourceVersion: "10"
+  labels:
+    app: web
+data:
+  key: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+  namespace: app
+  resourceVersion: "20"
+  labels:
+    app: web
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	settingsID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "settings"}
+	missingID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "missing"}
+	mismatch := ErrResourceVersionMismatch
+
+	testcases := map[string]struct {
+		name          string
+		labelSelector string
+		pinned        map[client.ObjectIdentifier]string
+		expectedErr   error
+	}{
+		"not_pinned":       {"settings", "", nil, nil},
+		"matching":         {"settings", "", map[client.ObjectIdentifier]string{settingsID: "10"}, nil},
+		"mismatch":         {"settings", "", map[client.ObjectIdentifier]string{settingsID: "9"}, mismatch},
+		"list_matching":    {"", "app=web", map[client.ObjectIdentifier]string{settingsID: "10"}, nil},
+		"list_mismatch":    {"", "app=web", map[client.ObjectIdentifier]string{settingsID: "11"}, mismatch},
+		"other_pinned":     {"other", "", map[client.ObjectIdentifier]string{settingsID: "9"}, nil},
+		"missing_pinned":   {"missing", "", map[client.ObjectIdentifier]string{missingID: "1"}, mismatch},
+		"missing_unpinned": {"missing", "", map[client.ObjectIdentifier]string{settingsID: "10"}, nil},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{PinnedResourceVersions: test.pinned}
+
+			_, err := resolver.getOrList(
+				options, &TemplateResult{}, "v1", "ConfigMap", "app", test.name, test.labelSelector,
+			)
+			if test.name == "missing" && test.expectedErr == nil {
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("expected a not found error, got : %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestLastApplied(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: applied
+  namespace: app
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"apiVersion":"v1","kind":"ConfigMap","data":{"replicas":"3"}}
+data:
+  replicas: "5"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-applied
+  namespace: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: invalid
+  namespace: app
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: "{"
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+		expectedErr    string
+	}{
+		"annotation": {
+			inputTmpl:      `replicas: '{{ (lastApplied "v1" "ConfigMap" "app" "applied").data.replicas }}'`,
+			expectedResult: `{"replicas":"3"}`,
+		},
+		"no_annotation": {
+			inputTmpl:      `count: '{{ lastApplied "v1" "ConfigMap" "app" "not-applied" | len }}'`,
+			expectedResult: `{"count":"0"}`,
+		},
+		"missing_object": {
+			inputTmpl:      `count: '{{ lastApplied "v1" "ConfigMap" "app" "missing" | len }}'`,
+			expectedResult: `{"count":"0"}`,
+		},
+		"invalid_annotation": {
+			inputTmpl:   `value: '{{ lastApplied "v1" "ConfigMap" "app" "invalid" }}'`,
+			expectedErr: "annotation of the ConfigMap invalid is invalid JSON",
+		},
+		"no_name": {
+			inputTmpl:   `value: '{{ lastApplied "v1" "ConfigMap" "app" "" }}'`,
+			expectedErr: "the name must be specified",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an error containing %q, got : %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestDistinctField(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: app
+  labels:
+    app: web
+spec:
+  nodeName: worker-b
+  priority: 10
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-2
+  namespace: app
+  labels:
+    app: web
+spec:
+  nodeName: worker-a
+  priority: 10
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-3
+  namespace: app
+  labels:
+    app: web
+spec:
+  nodeName: worker-b
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-pending
+  namespace: app
+  labels:
+    app: web
+spec: {}
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: db-1
+  namespace: app
+  labels:
+    app: db
+spec:
+  nodeName: worker-c
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+		expectedErr    string
+	}{
+		"node_names": {
+			inputTmpl:      `nodes: '{{ distinctField "v1" "Pod" "app" "app=web" "spec.nodeName" | join "," }}'`,
+			expectedResult: `{"nodes":"worker-a,worker-b"}`,
+		},
+		"all_objects": {
+			inputTmpl:      `nodes: '{{ distinctField "v1" "Pod" "app" "" "spec.nodeName" | join "," }}'`,
+			expectedResult: `{"nodes":"worker-a,worker-b,worker-c"}`,
+		},
+		"number": {
+			inputTmpl:      `priorities: '{{ distinctField "v1" "Pod" "app" "app=web" "spec.priority" | join "," }}'`,
+			expectedResult: `{"priorities":"10"}`,
+		},
+		"no_matches": {
+			inputTmpl:      `count: '{{ distinctField "v1" "Pod" "app" "app=cache" "spec.nodeName" | len }}'`,
+			expectedResult: `{"count":"0"}`,
+		},
+		"not_scalar": {
+			inputTmpl:   `nodes: '{{ distinctField "v1" "Pod" "app" "app=db" "spec" }}'`,
+			expectedErr: "the spec field of the Pod db-1 is not a string, number, or boolean",
+		},
+		"no_field": {
+			inputTmpl:   `nodes: '{{ distinctField "v1" "Pod" "app" "app=web" "" }}'`,
+			expectedErr: "the field must be specified",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an error containing %q, got : %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestGetOrListCachesNotFound(t *testing.T) {
+	t.Parallel()
+
+	resolver, fakeClient := newFakeClientResolver(t, nil, Config{})
+
+	for i := 0; i < 3; i++ {
+		_, err := resolver.getOrList(&ResolveOptions{}, &TemplateResult{}, "v1", "ConfigMap", "app", "missing")
+		if !apierrors.IsNotFound(err) {
+			t.Fatalf("expected a not found error on lookup %d, got: %v", i, err)
+		}
+	}
+
+	if len(fakeClient.Actions()) != 1 {
+		t.Fatalf("expected 1 API query for the missing object, got: %d", len(fakeClient.Actions()))
+	}
+
+	resolver.tempCallCache.Clear()
+	fakeClient.ClearActions()
+
+	// The missing object is only queried once per ResolveTemplate call since the cache is cleared after each call
+	tmpl := "first: '{{ (lookup \"v1\" \"ConfigMap\" \"app\" \"missing\").data }}'\n" +
+		"second: '{{ (lookup \"v1\" \"ConfigMap\" \"app\" \"missing\").data }}'\n"
+
+	for i := 0; i < 2; i++ {
+		_, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{InputIsYAML: true})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	if len(fakeClient.Actions()) != 2 {
+		t.Fatalf("expected 2 API queries after resolving the templates, got: %d", len(fakeClient.Actions()))
+	}
+}
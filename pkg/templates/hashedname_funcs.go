@@ -0,0 +1,53 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// hashedNameSuffixLength is the number of hexadecimal characters of the SHA-256 content hash used by hashedName.
+const hashedNameSuffixLength = 10
+
+// hashedName returns the base name converted to a DNS-1123 label with a dash and a hash of the content appended, such
+// as "app-config-1a2b3c4d5e", so that the name changes when the content changes. The hash is the first
+// hashedNameSuffixLength characters of the hexadecimal SHA-256 digest of the content. A string content is hashed as is
+// and any other content, such as the map from configMapData, is hashed as JSON, which has sorted map keys, so that
+// the hash is stable. The base name is truncated so that the result is at most 63 characters.
+func hashedName(baseName string, content interface{}) (string, error) {
+	var contentBytes []byte
+
+	switch typedContent := content.(type) {
+	case string:
+		contentBytes = []byte(typedContent)
+	case []byte:
+		contentBytes = typedContent
+	default:
+		var err error
+
+		contentBytes, err = json.Marshal(content)
+		if err != nil {
+			return "", fmt.Errorf("%w: the content cannot be hashed: %w", ErrInvalidInput, err)
+		}
+	}
+
+	name, err := dns1123(baseName)
+	if err != nil {
+		return "", err
+	}
+
+	maxBaseLength := validation.DNS1123LabelMaxLength - hashedNameSuffixLength - 1
+	if len(name) > maxBaseLength {
+		name = strings.TrimRight(name[:maxBaseLength], "-")
+	}
+
+	digest := sha256.Sum256(contentBytes)
+
+	return name + "-" + hex.EncodeToString(digest[:])[:hashedNameSuffixLength], nil
+}
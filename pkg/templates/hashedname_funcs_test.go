@@ -0,0 +1,74 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashedName(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		baseName    string
+		content     interface{}
+		result      string
+		expectedErr error
+	}{
+		{"app-config", "replicas: 3", "app-config-776530bb9f", nil},
+		{"App_Config", "replicas: 3", "app-config-" + sha256Prefix("replicas: 3"), nil},
+		{"app-config", []byte("replicas: 3"), "app-config-" + sha256Prefix("replicas: 3"), nil},
+		{"app-config", "replicas: 4", "app-config-" + sha256Prefix("replicas: 4"), nil},
+		{"app-config", "", "app-config-e3b0c44298", nil},
+		// Maps are hashed as JSON with sorted keys
+		{
+			"app-config",
+			map[string]interface{}{"b": "2", "a": "1"},
+			"app-config-" + sha256Prefix(`{"a":"1","b":"2"}`),
+			nil,
+		},
+		{
+			strings.Repeat("a", 51) + "-b",
+			"content",
+			strings.Repeat("a", 51) + "-" + sha256Prefix("content"),
+			nil,
+		},
+		{strings.Repeat("a", 63), "content", strings.Repeat("a", 52) + "-" + sha256Prefix("content"), nil},
+		{"_!_", "content", "", ErrInvalidInput},
+		{"app-config", map[string]interface{}{"a": func() {}}, "", ErrInvalidInput},
+	}
+
+	for _, test := range testcases {
+		val, err := hashedName(test.baseName, test.content)
+		if !errors.Is(err, test.expectedErr) {
+			t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+		}
+
+		if val != test.result {
+			t.Fatalf("expected : %v , got : %v", test.result, val)
+		}
+
+		if err == nil && !isDNS1123(val) {
+			t.Fatalf("expected %s to be a valid DNS-1123 label", val)
+		}
+	}
+
+	// The same content must always produce the same name
+	first, _ := hashedName("app-config", map[string]interface{}{"x": "1", "y": map[string]interface{}{"z": "2"}})
+	second, _ := hashedName("app-config", map[string]interface{}{"y": map[string]interface{}{"z": "2"}, "x": "1"})
+
+	if first != second {
+		t.Fatalf("expected the name to be stable, got : %s and %s", first, second)
+	}
+}
+
+// sha256Prefix returns the first 10 characters of the hexadecimal SHA-256 digest of the input.
+func sha256Prefix(value string) string {
+	digest := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(digest[:])[:10]
+}
@@ -0,0 +1,66 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+)
+
+// serviceAccountUsername returns the Kubernetes username of the ServiceAccount, which is what is impersonated.
+func serviceAccountUsername(serviceAccount types.NamespacedName) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", serviceAccount.Namespace, serviceAccount.Name)
+}
+
+// impersonatingResolvers are the resolvers created by impersonatingResolver per ServiceAccount.
+type impersonatingResolvers struct {
+	lock      sync.Mutex
+	resolvers map[types.NamespacedName]*TemplateResolver
+}
+
+// impersonatingResolver returns a copy of the resolver with Kubernetes clients that impersonate the ServiceAccount for
+// ResolveOptions.ImpersonateServiceAccount. The API server adds the ServiceAccount groups to the impersonated user. The
+// copy is created on the first call for the ServiceAccount and is reused afterwards so that the clients and the object
+// cache aren't recreated on every ResolveTemplate call.
+func (t *TemplateResolver) impersonatingResolver(serviceAccount types.NamespacedName) (*TemplateResolver, error) {
+	if t.kubeConfig == nil || t.impersonatingResolvers == nil {
+		return nil, fmt.Errorf(
+			"%w: ImpersonateServiceAccount is only available with a resolver created with NewResolver",
+			ErrInvalidInput,
+		)
+	}
+
+	if serviceAccount.Namespace == "" || serviceAccount.Name == "" {
+		return nil, fmt.Errorf(
+			"%w: ImpersonateServiceAccount must have a namespace and a name, got %s", ErrInvalidInput, serviceAccount,
+		)
+	}
+
+	t.impersonatingResolvers.lock.Lock()
+	defer t.impersonatingResolvers.lock.Unlock()
+
+	if resolver, ok := t.impersonatingResolvers.resolvers[serviceAccount]; ok {
+		return resolver, nil
+	}
+
+	klog.V(2).Infof("Creating the clients to impersonate the ServiceAccount %s", serviceAccount)
+
+	impersonatedConfig := rest.CopyConfig(t.kubeConfig)
+	impersonatedConfig.Impersonate = rest.ImpersonationConfig{UserName: serviceAccountUsername(serviceAccount)}
+
+	resolver, err := NewResolver(impersonatedConfig, t.config)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to create the clients to impersonate the ServiceAccount %s: %w", serviceAccount, err,
+		)
+	}
+
+	resolver.remoteDynamicClient = t.remoteDynamicClient
+	t.impersonatingResolvers.resolvers[serviceAccount] = resolver
+
+	return resolver, nil
+}
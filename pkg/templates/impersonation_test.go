@@ -0,0 +1,212 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// newImpersonationTestServer returns an API server that serves the app/app-config ConfigMap and records the
+// Impersonate-User header of the ConfigMap requests.
+func newImpersonationTestServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var (
+		lock  sync.Mutex
+		users []string
+	)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+	})
+
+	mux.HandleFunc("/apis", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+	})
+
+	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+			`{"name":"configmaps","singularName":"configmap","namespaced":true,"kind":"ConfigMap",` +
+			`"verbs":["get","list"]}]}`))
+	})
+
+	mux.HandleFunc("/api/v1/namespaces/app/configmaps/app-config", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		users = append(users, r.Header.Get("Impersonate-User"))
+		lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"ConfigMap",` +
+			`"metadata":{"name":"app-config","namespace":"app"},"data":{"replicas":"3"}}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return append([]string{}, users...)
+	}
+}
+
+func TestResolveTemplateImpersonateServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	server, getUsers := newImpersonationTestServer(t)
+
+	resolver, err := NewResolver(&rest.Config{Host: server.URL}, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := []byte(`replicas: '{{ fromConfigMap "app" "app-config" "replicas" }}'`)
+
+	result, err := resolver.ResolveTemplate(tmpl, nil, &ResolveOptions{
+		InputIsYAML:               true,
+		ImpersonateServiceAccount: &types.NamespacedName{Namespace: "tenant-a", Name: "policy-sa"},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"replicas":"3"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("expected : %s , got : %s", expected, string(result.ResolvedJSON))
+	}
+
+	_, err = resolver.ResolveTemplate(tmpl, nil, &ResolveOptions{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	users := getUsers()
+	if len(users) != 2 || users[0] != "system:serviceaccount:tenant-a:policy-sa" || users[1] != "" {
+		t.Fatalf("expected only the first request to impersonate the ServiceAccount, got : %v", users)
+	}
+}
+
+func TestImpersonatingResolverReused(t *testing.T) {
+	t.Parallel()
+
+	server, getUsers := newImpersonationTestServer(t)
+
+	resolver, err := NewResolver(&rest.Config{Host: server.URL}, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	serviceAccount := types.NamespacedName{Namespace: "tenant-a", Name: "policy-sa"}
+	otherServiceAccount := types.NamespacedName{Namespace: "tenant-a", Name: "other-sa"}
+
+	first, err := resolver.impersonatingResolver(serviceAccount)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	second, err := resolver.impersonatingResolver(serviceAccount)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	other, err := resolver.impersonatingResolver(otherServiceAccount)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if first != second {
+		t.Fatal("expected the impersonating resolver to be reused for the same ServiceAccount")
+	}
+
+	if first == other {
+		t.Fatal("expected a separate impersonating resolver for each ServiceAccount")
+	}
+
+	tmpl := []byte(`replicas: '{{ fromConfigMap "app" "app-config" "replicas" }}'`)
+
+	for _, sa := range []types.NamespacedName{serviceAccount, otherServiceAccount, serviceAccount} {
+		sa := sa
+
+		_, err := resolver.ResolveTemplate(
+			tmpl, nil, &ResolveOptions{InputIsYAML: true, ImpersonateServiceAccount: &sa},
+		)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	users := getUsers()
+	expectedUsers := []string{
+		"system:serviceaccount:tenant-a:policy-sa",
+		"system:serviceaccount:tenant-a:other-sa",
+		"system:serviceaccount:tenant-a:policy-sa",
+	}
+
+	if !reflect.DeepEqual(users, expectedUsers) {
+		t.Fatalf("expected : %v , got : %v", expectedUsers, users)
+	}
+}
+
+func TestResolveTemplateImpersonateServiceAccountInvalid(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newImpersonationTestServer(t)
+
+	resolver, err := NewResolver(&rest.Config{Host: server.URL}, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	snapshotResolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		resolver       *TemplateResolver
+		serviceAccount types.NamespacedName
+	}{
+		"no_namespace": {
+			resolver:       resolver,
+			serviceAccount: types.NamespacedName{Name: "policy-sa"},
+		},
+		"no_name": {
+			resolver:       resolver,
+			serviceAccount: types.NamespacedName{Namespace: "tenant-a"},
+		},
+		"no_kubeconfig": {
+			resolver:       snapshotResolver,
+			serviceAccount: types.NamespacedName{Namespace: "tenant-a", Name: "policy-sa"},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := test.resolver.ResolveTemplate([]byte(`key: value`), nil, &ResolveOptions{
+				InputIsYAML:               true,
+				ImpersonateServiceAccount: &test.serviceAccount,
+			})
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// jqTimeout is the maximum duration of a jq expression evaluation so that an expression that doesn't terminate, such
+// as `repeat(.)`, doesn't block template resolution.
+const jqTimeout = 5 * time.Second
+
+// jq evaluates the jq expression against the input, such as the result of a lookup. If the expression produces a
+// single result, that result is returned. Otherwise, the results are returned as a list, which is empty if there are
+// none. The input is converted to JSON types first, and object keys are iterated in sorted order, so the results are
+// deterministic. The environment variables and the input and inputs functions are not available.
+func jq(expression string, input interface{}) (interface{}, error) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse the jq expression %q: %w", ErrInvalidInput, expression, err)
+	}
+
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to compile the jq expression %q: %w", ErrInvalidInput, expression, err)
+	}
+
+	// Convert the input to the types that gojq accepts, such as float64 instead of int64
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the jq input is not valid JSON: %w", ErrInvalidInput, err)
+	}
+
+	var jqInput interface{}
+
+	err = json.Unmarshal(inputJSON, &jqInput)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the jq input is not valid JSON: %w", ErrInvalidInput, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jqTimeout)
+	defer cancel()
+
+	results := []interface{}{}
+	iter := code.RunWithContext(ctx, jqInput)
+
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := result.(error); ok {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("the jq expression %q did not finish within %s", expression, jqTimeout)
+			}
+
+			return nil, fmt.Errorf("failed to evaluate the jq expression %q: %w", expression, err)
+		}
+
+		results = append(results, result)
+	}
+
+	if len(results) == 1 {
+		return results[0], nil
+	}
+
+	return results, nil
+}
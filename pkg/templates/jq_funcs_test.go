@@ -0,0 +1,143 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJQ(t *testing.T) {
+	t.Parallel()
+
+	services := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "web"},
+				"spec": map[string]interface{}{
+					"type": "LoadBalancer", "ports": []interface{}{int64(80), int64(443)},
+				},
+			},
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "db"},
+				"spec":     map[string]interface{}{"type": "ClusterIP", "ports": []interface{}{int64(5432)}},
+			},
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "api"},
+				"spec":     map[string]interface{}{"type": "LoadBalancer", "ports": []interface{}{int64(8080)}},
+			},
+		},
+	}
+
+	testcases := map[string]struct {
+		expression  string
+		input       interface{}
+		expected    interface{}
+		expectedErr error
+	}{
+		"select": {
+			`.items[] | select(.spec.type=="LoadBalancer") | .metadata.name`,
+			services,
+			[]interface{}{"web", "api"},
+			nil,
+		},
+		"single_result": {`.items | length`, services, 3, nil},
+		"int64_values":  {`[.items[].spec.ports[]] | add`, services, float64(14035), nil},
+		"no_results":    {`.items[] | select(.spec.type=="NodePort")`, services, []interface{}{}, nil},
+		"sorted_keys": {
+			`.[]`, map[string]string{"b": "2", "a": "1", "c": "3"}, []interface{}{"1", "2", "3"}, nil,
+		},
+		"invalid_expression": {`.items[`, services, nil, ErrInvalidInput},
+		"env_not_allowed":    {`$ENV | length`, services, 0, nil},
+		"input_not_allowed":  {`input`, services, nil, ErrInvalidInput},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := jq(test.expression, test.input)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected err: %v, got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(val, test.expected) {
+				t.Fatalf("expected : %v (%T), got : %v (%T)", test.expected, test.expected, val, val)
+			}
+		})
+	}
+}
+
+func TestJQEvaluationError(t *testing.T) {
+	t.Parallel()
+
+	_, err := jq(`.items[] | .metadata.name | ascii_downcase`, map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"metadata": map[string]interface{}{"name": 3}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to evaluate the jq expression") {
+		t.Fatalf("expected an evaluation error, got: %v", err)
+	}
+}
+
+func TestResolveTemplateJQ(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: default
+  labels:
+    tier: frontend
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db
+  namespace: default
+  labels:
+    tier: backend
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: api
+  namespace: default
+  labels:
+    tier: frontend
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `frontends: '{{ lookup "v1" "ConfigMap" "default" "" | jq "[.items[] | ` +
+		`select(.metadata.labels.tier==\"frontend\") | .metadata.name] | sort | join(\",\")" }}'` + "\n"
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"frontends":"api,web"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("expected : %s , got : %s", expected, string(result.ResolvedJSON))
+	}
+}
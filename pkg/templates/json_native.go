@@ -0,0 +1,275 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"k8s.io/klog"
+)
+
+// jsonNativeTemplate is JSON input parsed for ResolveOptions.JSONNative. The parts are written in order, where the
+// JSON text between the strings with templates is kept as is.
+type jsonNativeTemplate struct {
+	parts   []jsonNativePart
+	tmplRaw []byte
+}
+
+// jsonNativePart is either raw JSON text or a JSON string with templates.
+type jsonNativePart struct {
+	raw  string
+	tmpl *template.Template
+	// typed is true if the resolved template is converted to a JSON value rather than a JSON string, such as for a
+	// value with toInt.
+	typed bool
+}
+
+// jsonContainer tracks the position in a JSON object or array while parsing.
+type jsonContainer struct {
+	isObject bool
+	tokens   int
+}
+
+// parseJSONNative parses the JSON input for ResolveOptions.JSONNative. Each string value and object key with the start
+// delimiter is parsed as a separate template with a clone of the input template, which has the template functions set.
+// The rest of the JSON is kept as is, including the key order and the number formatting.
+func (t *TemplateResolver) parseJSONNative(
+	tmpl *template.Template, tmplRaw []byte, options *ResolveOptions, templateResult *TemplateResult,
+) (*jsonNativeTemplate, error) {
+	if options.InputIsYAML || options.WrapInList || options.AutoTypeNumericContext {
+		return nil, fmt.Errorf(
+			"%w: options.JSONNative cannot be set with InputIsYAML, WrapInList, or AutoTypeNumericContext",
+			ErrInvalidInput,
+		)
+	}
+
+	jsonTmpl := &jsonNativeTemplate{tmplRaw: tmplRaw}
+
+	d1 := regexp.QuoteMeta(t.config.StartDelim)
+	d2 := regexp.QuoteMeta(t.config.StopDelim)
+	// This matches the values that processForDataTypes removes the quotes from
+	typedValue := regexp.MustCompile(
+		`^\s*` + d1 + `(?:.*\|\s*(?:toInt|toBool|toLiteral)|(?:.*(?:copyConfigMapData|copySecretData))).*` +
+			d2 + `\s*$`,
+	)
+
+	var raw strings.Builder
+
+	containers := []jsonContainer{}
+	topLevelValues := 0
+
+	decoder := json.NewDecoder(bytes.NewReader(tmplRaw))
+	decoder.UseNumber()
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to parse the JSON input: %w", ErrInvalidInput, err)
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			raw.WriteRune(rune(delim))
+			containers = containers[:len(containers)-1]
+
+			continue
+		}
+
+		isKey := false
+
+		if len(containers) == 0 {
+			topLevelValues++
+
+			if topLevelValues > 1 {
+				return nil, fmt.Errorf("%w: the JSON input must be a single value", ErrInvalidInput)
+			}
+		} else {
+			container := &containers[len(containers)-1]
+
+			switch {
+			case !container.isObject && container.tokens > 0:
+				raw.WriteByte(',')
+			case container.isObject && container.tokens%2 == 1:
+				raw.WriteByte(':')
+			case container.isObject:
+				isKey = true
+
+				if container.tokens > 0 {
+					raw.WriteByte(',')
+				}
+			}
+
+			container.tokens++
+		}
+
+		switch value := token.(type) {
+		case json.Delim:
+			raw.WriteRune(rune(value))
+			containers = append(containers, jsonContainer{isObject: value == '{'})
+		case string:
+			if options.DecryptionEnabled && !isKey {
+				value, err = t.processEncryptedStrs(options, templateResult, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if !strings.Contains(value, t.config.StartDelim) {
+				raw.Write(marshalJSONString(value))
+
+				continue
+			}
+
+			part, err := t.parseJSONNativeString(tmpl, value, tmplRaw, templateResult)
+			if err != nil {
+				return nil, err
+			}
+
+			part.typed = !isKey && typedValue.MatchString(value)
+
+			jsonTmpl.parts = append(jsonTmpl.parts, jsonNativePart{raw: raw.String()}, part)
+			raw.Reset()
+		case json.Number:
+			raw.WriteString(value.String())
+		case bool:
+			raw.WriteString(strconv.FormatBool(value))
+		case nil:
+			raw.WriteString("null")
+		}
+	}
+
+	if topLevelValues == 0 {
+		return nil, fmt.Errorf("%w: the JSON input is empty", ErrInvalidInput)
+	}
+
+	if len(containers) != 0 {
+		return nil, fmt.Errorf("%w: failed to parse the JSON input: %w", ErrInvalidInput, io.ErrUnexpectedEOF)
+	}
+
+	jsonTmpl.parts = append(jsonTmpl.parts, jsonNativePart{raw: raw.String()})
+
+	return jsonTmpl, nil
+}
+
+// parseJSONNativeString parses a string from the JSON input as a template with a clone of the input template.
+func (t *TemplateResolver) parseJSONNativeString(
+	tmpl *template.Template, value string, tmplRaw []byte, templateResult *TemplateResult,
+) (jsonNativePart, error) {
+	err := t.checkPinnedSprigFunctions(value)
+	if err != nil {
+		return jsonNativePart{}, err
+	}
+
+	if templateResult.Metrics != nil {
+		scan := ScanTemplates([]byte(value), t.config.StartDelim, t.config.StopDelim)
+		templateResult.Metrics.Actions += len(scan.Actions)
+	}
+
+	strTmpl, err := tmpl.Clone()
+	if err != nil {
+		return jsonNativePart{}, fmt.Errorf("failed to parse the template: %w", err)
+	}
+
+	_, err = strTmpl.Parse(value)
+	if err != nil {
+		tmplRawStr := string(tmplRaw)
+		klog.Errorf("error parsing template string %v,\n template str %v,\n error: %v", tmplRawStr, value, err)
+
+		return jsonNativePart{}, newTemplateParseError(tmplRawStr, err)
+	}
+
+	if t.config.MissingKeyPlaceholder != "" {
+		applyMissingKeyPlaceholder(strTmpl)
+	}
+
+	return jsonNativePart{tmpl: strTmpl}, nil
+}
+
+// checkArgumentCounts runs checkArgumentCounts on the template of each JSON string with a template.
+func (j *jsonNativeTemplate) checkArgumentCounts(funcMap template.FuncMap) error {
+	for _, part := range j.parts {
+		if part.tmpl == nil {
+			continue
+		}
+
+		if err := checkArgumentCounts(part.tmpl, funcMap); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// execute executes the templates in the parsed JSON input and returns the resolved JSON. A resolved template is a JSON
+// string unless it's a typed value, such as with toInt, in which case the resolved YAML value is converted to JSON.
+func (j *jsonNativeTemplate) execute(
+	ctx interface{}, options *ResolveOptions, templateResult *TemplateResult,
+) ([]byte, error) {
+	var resolved bytes.Buffer
+
+	for _, part := range j.parts {
+		if part.tmpl == nil {
+			resolved.WriteString(part.raw)
+
+			continue
+		}
+
+		var buf bytes.Buffer
+
+		err := part.tmpl.Execute(&buf, ctx)
+		if err != nil {
+			tmplRawStr := string(j.tmplRaw)
+			klog.Errorf("error resolving the template %v,\n error: %v", tmplRawStr, err)
+
+			return nil, fmt.Errorf("failed to resolve the template %v: %w", tmplRawStr, err)
+		}
+
+		value := buf.Bytes()
+
+		if !part.typed {
+			resolved.Write(marshalJSONString(string(value)))
+
+			continue
+		}
+
+		typedJSON, err := yamlToJSON(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the resolved value %s to JSON: %w", string(value), err)
+		}
+
+		resolved.Write(typedJSON)
+	}
+
+	resolvedJSON := resolved.Bytes()
+
+	if options.MaskEncryptedForDisplay {
+		resolvedJSON, templateResult.EncryptedValuesMasked = maskEncryptedStrs(resolvedJSON)
+	}
+
+	return resolvedJSON, nil
+}
+
+// marshalJSONString returns the input as a JSON string. Unlike json.Marshal, the HTML characters are not escaped so
+// that the strings in the input are unchanged.
+func marshalJSONString(value string) []byte {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	// Encoding a string can't fail
+	_ = encoder.Encode(value)
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
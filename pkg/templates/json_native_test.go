@@ -0,0 +1,117 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveTemplateJSONNative(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+data:
+  replicas: "3"
+  url: https://example.com/?a=1&b=2
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{MissingKeyPlaceholder: "missing"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl    string
+		options      ResolveOptions
+		expectedJSON string
+		expectedErr  error
+	}{
+		"key_order_and_numbers": {
+			inputTmpl:    `{"z": 1.0, "a": "{{ \"b\" }}", "m": [1e3, true, null, "<no templates>"]}`,
+			expectedJSON: `{"z":1.0,"a":"b","m":[1e3,true,null,"<no templates>"]}`,
+		},
+		"lookup": {
+			inputTmpl:    `{"url": "{{ fromConfigMap \"default\" \"cm\" \"url\" }}", "n": 10}`,
+			expectedJSON: `{"url":"https://example.com/?a=1&b=2","n":10}`,
+		},
+		"typed_values": {
+			inputTmpl: `{"replicas": "{{ fromConfigMap \"default\" \"cm\" \"replicas\" | toInt }}", ` +
+				`"enabled": "{{ \"true\" | toBool }}", "text": "{{ \"true\" }}"}`,
+			expectedJSON: `{"replicas":3,"enabled":true,"text":"true"}`,
+		},
+		"key_and_escaping": {
+			inputTmpl:    `{"{{ \"key\" }}": "{{ \"a \\\"quoted\\\"\\nvalue\" }}"}`,
+			expectedJSON: `{"key":"a \"quoted\"\nvalue"}`,
+		},
+		"missing_key_placeholder": {
+			inputTmpl:    `["{{ .ObjectLabels.missing }}"]`,
+			expectedJSON: `["missing"]`,
+		},
+		"input_is_yaml": {
+			inputTmpl:   `{}`,
+			options:     ResolveOptions{InputIsYAML: true},
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid_json": {
+			inputTmpl:   `{"a": `,
+			expectedErr: ErrInvalidInput,
+		},
+		"multiple_values": {
+			inputTmpl:   `{} {}`,
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := test.options
+			options.JSONNative = true
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), TemplateContext{}, &options)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected err: %v, got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+			}
+		})
+	}
+}
+
+func TestResolveTemplateJSONNativeParseError(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(`{"a": "{{ if }}"}`), nil, &ResolveOptions{JSONNative: true})
+
+	var parseErr *TemplateParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a TemplateParseError, got: %v", err)
+	}
+}
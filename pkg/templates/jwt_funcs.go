@@ -0,0 +1,57 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jwtClaim decodes the payload of the input JWT and returns the claim at the dotted claim path (e.g. "iss" or
+// "realm_access.roles"). An empty string is returned if the claim doesn't exist. Note that the signature of the JWT is
+// NOT verified, so the claims must not be trusted for authentication or authorization decisions.
+func jwtClaim(token string, claimPath string) (interface{}, error) {
+	if claimPath == "" {
+		return nil, fmt.Errorf("%w: a claim path must be provided", ErrInvalidInput)
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: the JWT is malformed: expected 3 parts but got %d", ErrInvalidInput, len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: the JWT payload is malformed: %w", ErrInvalidInput, err)
+	}
+
+	var claims map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	// Keep the numeric claims such as exp as they are rather than converting them to floats
+	decoder.UseNumber()
+
+	err = decoder.Decode(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the JWT payload is malformed: %w", ErrInvalidInput, err)
+	}
+
+	var claim interface{} = claims
+
+	for _, field := range strings.Split(claimPath, ".") {
+		claimMap, ok := claim.(map[string]interface{})
+		if !ok {
+			return "", nil
+		}
+
+		claim, ok = claimMap[field]
+		if !ok {
+			return "", nil
+		}
+	}
+
+	return claim, nil
+}
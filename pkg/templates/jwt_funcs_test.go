@@ -0,0 +1,54 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testJWT has the payload of
+// {"iss":"https://issuer.example.com","aud":["a","b"],"exp":1793491200,"realm_access":{"roles":["admin"]}}.
+const testJWT = "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." +
+	"eyJpc3MiOiJodHRwczovL2lzc3Vlci5leGFtcGxlLmNvbSIsImF1ZCI6WyJhIiwiYiJdLCJleHAiOjE3OTM0OTEyMDAsInJlYWxtX2FjY2VzcyI6" +
+	"eyJyb2xlcyI6WyJhZG1pbiJdfX0.sig"
+
+func TestJWTClaim(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		token       string
+		claimPath   string
+		expected    string
+		expectedErr error
+	}{
+		"string_claim":   {testJWT, "iss", "https://issuer.example.com", nil},
+		"list_claim":     {testJWT, "aud", "[a b]", nil},
+		"number_claim":   {testJWT, "exp", "1793491200", nil},
+		"nested_claim":   {testJWT, "realm_access.roles", "[admin]", nil},
+		"missing_claim":  {testJWT, "sub", "", nil},
+		"missing_nested": {testJWT, "iss.value", "", nil},
+		"no_claim_path":  {testJWT, "", "", ErrInvalidInput},
+		"not_a_jwt":      {"not-a-jwt", "iss", "", ErrInvalidInput},
+		"invalid_base64": {"a.!!!.c", "iss", "", ErrInvalidInput},
+		"invalid_json":   {"a.bm90LWpzb24.c", "iss", "", ErrInvalidInput},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := jwtClaim(test.token, test.claimPath)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr == nil && fmt.Sprint(val) != test.expected {
+				t.Fatalf("expected : %s , got : %v", test.expected, val)
+			}
+		})
+	}
+}
@@ -4,10 +4,16 @@
 package templates
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/klog"
 )
@@ -36,6 +42,10 @@ func (t *TemplateResolver) fromSecret(
 		return "", fmt.Errorf("failed to get the secret %s from %s: %w", name, namespace, err)
 	}
 
+	if options.TrackProvenance && templateResult != nil {
+		templateResult.recordProvenanceKey(key)
+	}
+
 	keyVal, _, _ := unstructured.NestedString(secret, "data", key)
 
 	return keyVal, nil
@@ -62,6 +72,88 @@ func (t *TemplateResolver) fromSecretProtected(
 	return t.protect(options, value)
 }
 
+func (t *TemplateResolver) fromSecretFirstHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, []interface{}, string) (string, error) {
+	return func(namespace string, names []interface{}, key string) (string, error) {
+		return t.fromSecretFirst(options, templateResult, namespace, names, key)
+	}
+}
+
+// fromSecretFirst retrieves the value of the key in the first Secret in the list of names that exists and has the
+// key. An empty string is returned if none of the Secrets have the key.
+func (t *TemplateResolver) fromSecretFirst(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, names []interface{}, key string,
+) (string, error) {
+	klog.V(2).Infof("fromSecretFirst for namespace: %v, names: %v, key:%v", namespace, names, key)
+
+	return t.fromFirst(options, templateResult, "Secret", namespace, names, key)
+}
+
+func (t *TemplateResolver) fromSecretFirstProtectedHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, []interface{}, string) (string, error) {
+	return func(namespace string, names []interface{}, key string) (string, error) {
+		return t.fromSecretFirstProtected(options, templateResult, namespace, names, key)
+	}
+}
+
+// fromSecretFirstProtected wraps fromSecretFirst and encrypts the output value using the "protect" method.
+func (t *TemplateResolver) fromSecretFirstProtected(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, names []interface{}, key string,
+) (string, error) {
+	value, err := t.fromSecretFirst(options, templateResult, namespace, names, key)
+	if err != nil {
+		return "", err
+	}
+
+	return t.protect(options, value)
+}
+
+// fromFirst retrieves the value of the key in the data of the first object of the given kind in the list of names
+// that exists and has the key. Objects that aren't found are skipped, but any other error is returned.
+func (t *TemplateResolver) fromFirst(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	kind string,
+	namespace string,
+	names []interface{},
+	key string,
+) (string, error) {
+	if len(names) == 0 || (options.LookupNamespace == "" && namespace == "") || key == "" {
+		return "", fmt.Errorf("%w: namespace, names, and key must be specified", ErrInvalidInput)
+	}
+
+	for _, nameVal := range names {
+		name, ok := nameVal.(string)
+		if !ok || name == "" {
+			return "", fmt.Errorf("%w: the %s names must be non-empty strings", ErrInvalidInput, kind)
+		}
+
+		obj, err := t.getOrList(options, templateResult, "v1", kind, namespace, name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return "", fmt.Errorf("failed to get the %s %s from %s: %w", kind, name, namespace, err)
+		}
+
+		keyVal, found, _ := unstructured.NestedString(obj, "data", key)
+		if found {
+			if options.TrackProvenance && templateResult != nil {
+				templateResult.recordProvenanceKey(key)
+			}
+
+			return keyVal, nil
+		}
+	}
+
+	return "", nil
+}
+
 // copies all data in the given Secret, namespace.
 func (t *TemplateResolver) copySecretDataBase(
 	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string,
@@ -141,17 +233,69 @@ func (t *TemplateResolver) copySecretDataProtected(
 	return string(rawData), nil
 }
 
+func (t *TemplateResolver) secretDataHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string, string) (map[string]interface{}, error) {
+	return func(namespace string, name string) (map[string]interface{}, error) {
+		return t.secretData(options, templateResult, namespace, name)
+	}
+}
+
+// secretData returns the data map of the given Secret so that it can be used with "range" or "index" without parsing.
+func (t *TemplateResolver) secretData(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string,
+) (map[string]interface{}, error) {
+	data, err := t.copySecretDataBase(options, templateResult, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	return data, nil
+}
+
+func (t *TemplateResolver) secretDataProtectedHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string, string) (map[string]interface{}, error) {
+	return func(namespace string, name string) (map[string]interface{}, error) {
+		return t.secretDataProtected(options, templateResult, namespace, name)
+	}
+}
+
+// secretDataProtected wraps secretData and encrypts the values using the "protect" method.
+func (t *TemplateResolver) secretDataProtected(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string,
+) (map[string]interface{}, error) {
+	data, err := t.secretData(options, templateResult, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, val := range data {
+		data[key], err = t.protect(options, fmt.Sprint(val))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
 func (t *TemplateResolver) fromConfigMapHelper(
 	options *ResolveOptions,
+	templateResult *TemplateResult,
 ) func(string, string, string) (string, error) {
 	return func(namespace string, name string, key string) (string, error) {
-		return t.fromConfigMap(options, namespace, name, key)
+		return t.fromConfigMap(options, templateResult, namespace, name, key)
 	}
 }
 
 // retrieves value for the key in the given Configmap, namespace.
 func (t *TemplateResolver) fromConfigMap(
-	options *ResolveOptions, namespace string, name string, key string,
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string, key string,
 ) (string, error) {
 	klog.V(2).Infof("fromConfigMap for namespace: %s, name: %s, key: %s", namespace, name, key)
 
@@ -159,27 +303,112 @@ func (t *TemplateResolver) fromConfigMap(
 		return "", fmt.Errorf("%w: namespace, name, and key must be specified", ErrInvalidInput)
 	}
 
-	configmap, err := t.getOrList(options, nil, "v1", "ConfigMap", namespace, name)
+	configmap, err := t.getOrList(options, templateResult, "v1", "ConfigMap", namespace, name)
 	if err != nil {
 		err := fmt.Errorf("failed getting the ConfigMap %s from %s: %w", name, namespace, err)
 
 		return "", err
 	}
 
+	if options.TrackProvenance && templateResult != nil {
+		templateResult.recordProvenanceKey(key)
+	}
+
 	keyVal, _, _ := unstructured.NestedString(configmap, "data", key)
 
 	return keyVal, nil
 }
 
-func (t *TemplateResolver) copyConfigMapDataHelper(options *ResolveOptions) func(string, string) (string, error) {
+func (t *TemplateResolver) fromConfigMapFirstHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, []interface{}, string) (string, error) {
+	return func(namespace string, names []interface{}, key string) (string, error) {
+		return t.fromConfigMapFirst(options, templateResult, namespace, names, key)
+	}
+}
+
+// fromConfigMapFirst retrieves the value of the key in the first ConfigMap in the list of names that exists and has
+// the key. An empty string is returned if none of the ConfigMaps have the key.
+func (t *TemplateResolver) fromConfigMapFirst(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, names []interface{}, key string,
+) (string, error) {
+	klog.V(2).Infof("fromConfigMapFirst for namespace: %s, names: %v, key: %s", namespace, names, key)
+
+	return t.fromFirst(options, templateResult, "ConfigMap", namespace, names, key)
+}
+
+func (t *TemplateResolver) fromLatestConfigMapHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string) (string, error) {
+	return func(namespace string, labelSelector string, key string) (string, error) {
+		return t.fromLatestConfigMap(options, templateResult, namespace, labelSelector, key)
+	}
+}
+
+// fromLatestConfigMap retrieves the value of the key in the newest ConfigMap, by creation timestamp, that matches the
+// label selector. If multiple ConfigMaps have the newest creation timestamp, the one whose name sorts last is used so
+// that the result is deterministic. This is useful when the name of the active ConfigMap changes on every update. An
+// error is returned if no ConfigMaps match.
+func (t *TemplateResolver) fromLatestConfigMap(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, labelSelector string, key string,
+) (string, error) {
+	klog.V(2).Infof(
+		"fromLatestConfigMap for namespace: %s, labelSelector: %s, key: %s", namespace, labelSelector, key,
+	)
+
+	if (options.LookupNamespace == "" && namespace == "") || key == "" {
+		return "", fmt.Errorf("%w: namespace and key must be specified", ErrInvalidInput)
+	}
+
+	configMaps, err := t.getOrList(options, templateResult, "v1", "ConfigMap", namespace, "", labelSelector)
+	if err != nil {
+		return "", fmt.Errorf("failed listing the ConfigMaps in %s: %w", namespace, err)
+	}
+
+	configMapList := unstructured.UnstructuredList{}
+	configMapList.SetUnstructuredContent(configMaps)
+
+	if len(configMapList.Items) == 0 {
+		return "", fmt.Errorf("no ConfigMaps in %s match the label selector %q", namespace, labelSelector)
+	}
+
+	latest := slices.MaxFunc(configMapList.Items, func(a, b unstructured.Unstructured) int {
+		aTime := a.GetCreationTimestamp()
+		bTime := b.GetCreationTimestamp()
+
+		if !aTime.Equal(&bTime) {
+			if aTime.Before(&bTime) {
+				return -1
+			}
+
+			return 1
+		}
+
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+
+	if options.TrackProvenance && templateResult != nil {
+		templateResult.recordProvenanceKey(key)
+	}
+
+	keyVal, _, _ := unstructured.NestedString(latest.Object, "data", key)
+
+	return keyVal, nil
+}
+
+func (t *TemplateResolver) copyConfigMapDataHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string, string) (string, error) {
 	return func(namespace string, name string) (string, error) {
-		return t.copyConfigMapData(options, namespace, name)
+		return t.copyConfigMapData(options, templateResult, namespace, name)
 	}
 }
 
 // copies data values in the given Configmap, namespace.
 func (t *TemplateResolver) copyConfigMapData(
-	options *ResolveOptions, namespace string, name string,
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string,
 ) (string, error) {
 	klog.V(2).Infof("copyConfigMapData for namespace: %s, name: %s", namespace, name)
 
@@ -187,7 +416,7 @@ func (t *TemplateResolver) copyConfigMapData(
 		return "", fmt.Errorf("%w: namespace and name must be specified", ErrInvalidInput)
 	}
 
-	configmap, err := t.getOrList(options, nil, "v1", "ConfigMap", namespace, name)
+	configmap, err := t.getOrList(options, templateResult, "v1", "ConfigMap", namespace, name)
 	if err != nil {
 		return "", fmt.Errorf("failed getting the ConfigMap %s from %s: %w", name, namespace, err)
 	}
@@ -202,6 +431,111 @@ func (t *TemplateResolver) copyConfigMapData(
 	return string(rawData), nil
 }
 
+func (t *TemplateResolver) configMapDataHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string, string) (map[string]interface{}, error) {
+	return func(namespace string, name string) (map[string]interface{}, error) {
+		return t.configMapData(options, templateResult, namespace, name)
+	}
+}
+
+// configMapData returns the data map of the given ConfigMap so that it can be used with "range" or "index" without
+// parsing.
+func (t *TemplateResolver) configMapData(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string,
+) (map[string]interface{}, error) {
+	klog.V(2).Infof("configMapData for namespace: %s, name: %s", namespace, name)
+
+	if name == "" || (options.LookupNamespace == "" && namespace == "") {
+		return nil, fmt.Errorf("%w: namespace and name must be specified", ErrInvalidInput)
+	}
+
+	configmap, err := t.getOrList(options, templateResult, "v1", "ConfigMap", namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting the ConfigMap %s from %s: %w", name, namespace, err)
+	}
+
+	data, _, _ := unstructured.NestedMap(configmap, "data")
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	return data, nil
+}
+
+func (t *TemplateResolver) includeTemplateHelper(
+	options *ResolveOptions, templateResult *TemplateResult, funcMap template.FuncMap, depth int,
+) func(string, string, string, interface{}) (string, error) {
+	return func(namespace string, name string, key string, ctx interface{}) (string, error) {
+		return t.includeTemplate(options, templateResult, funcMap, depth, namespace, name, key, ctx)
+	}
+}
+
+// includeTemplate retrieves the value for the key in the given ConfigMap and executes it as a nested template with
+// the input context and the same template functions. Nested includes are allowed up to Config.MaxResolveDepth to
+// prevent infinite recursion.
+func (t *TemplateResolver) includeTemplate(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	funcMap template.FuncMap,
+	depth int,
+	namespace string,
+	name string,
+	key string,
+	ctx interface{},
+) (string, error) {
+	klog.V(2).Infof("includeTemplate for namespace: %s, name: %s, key: %s, depth: %d", namespace, name, key, depth)
+
+	if templateResult != nil && templateResult.FunctionCalls != nil {
+		templateResult.FunctionCalls["includeTemplate"]++
+	}
+
+	if depth > t.config.MaxResolveDepth {
+		return "", fmt.Errorf(
+			"%w: includeTemplate exceeded the maximum depth of %d", ErrMaxDepthExceeded, t.config.MaxResolveDepth,
+		)
+	}
+
+	tmplStr, err := t.fromConfigMap(options, templateResult, namespace, name, key)
+	if err != nil {
+		return "", err
+	}
+
+	err = t.checkPinnedSprigFunctions(tmplStr)
+	if err != nil {
+		return "", fmt.Errorf("the included template %s/%s %s is not allowed: %w", namespace, name, key, err)
+	}
+
+	// Copy the function map so that nested includes track their own depth
+	nestedFuncMap := maps.Clone(funcMap)
+	nestedFuncMap["includeTemplate"] = t.includeTemplateHelper(options, templateResult, funcMap, depth+1)
+
+	tmpl, err := template.New(name).Delims(t.config.StartDelim, t.config.StopDelim).Funcs(nestedFuncMap).Parse(tmplStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the included template %s/%s %s: %w", namespace, name, key, err)
+	}
+
+	if t.config.MissingKeyPlaceholder != "" {
+		applyMissingKeyPlaceholder(tmpl)
+	}
+
+	if t.config.StrictArgumentCounts {
+		err = checkArgumentCounts(tmpl, nestedFuncMap)
+		if err != nil {
+			return "", fmt.Errorf("the included template %s/%s %s is invalid: %w", namespace, name, key, err)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err = tmpl.Execute(&buf, ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the included template %s/%s %s: %w", namespace, name, key, err)
+	}
+
+	return buf.String(), nil
+}
+
 // convenience functions to base64 encode string values
 // for setting in value in Referencing Secret resources.
 func base64encode(v string) string {
@@ -216,3 +550,29 @@ func base64decode(v string) string {
 
 	return string(data)
 }
+
+// base64encLines base64 encodes the string value and wraps the output at the given width for consumers that require
+// line-wrapped base64 (e.g. 64 for PEM or 76 for MIME).
+func base64encLines(width int, v string) (string, error) {
+	if width <= 0 {
+		return "", fmt.Errorf("%w: the width must be a positive integer", ErrInvalidInput)
+	}
+
+	encoded := base64encode(v)
+	lines := make([]string, 0, len(encoded)/width+1)
+
+	for len(encoded) > width {
+		lines = append(lines, encoded[:width])
+		encoded = encoded[width:]
+	}
+
+	lines = append(lines, encoded)
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// base64decLines base64 decodes the string value after removing any whitespace such as the line breaks added by
+// base64encLines.
+func base64decLines(v string) string {
+	return base64decode(strings.Join(strings.Fields(v), ""))
+}
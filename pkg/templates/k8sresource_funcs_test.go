@@ -108,6 +108,278 @@ func TestFromSecret(t *testing.T) {
 	}
 }
 
+func TestFromSecretFirst(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		inputNs         string
+		inputNames      []interface{}
+		inputKey        string
+		lookupNamespace string
+		expectedResult  string
+		expectedErr     error
+	}{
+		{"testns", []interface{}{"testsecret"}, "secretkey1", "", "secretkey1Val", nil},
+		{"testns", []interface{}{"idontexist", "testsecret"}, "secretkey2", "", "secretkey2Val", nil},
+		{"", []interface{}{"idontexist", "testsecret"}, "secretkey2", "testns", "secretkey2Val", nil},
+		{"testns", []interface{}{"idontexist", "idontexist2"}, "secretkey1", "", "", nil},
+		{"testns", []interface{}{"testsecret"}, "blah", "", "", nil},
+		{
+			"testns",
+			[]interface{}{"idontexist", "testsecret"},
+			"secretkey2",
+			"policies-ns",
+			"",
+			errors.New(
+				"failed to get the Secret idontexist from testns: the namespace argument is restricted to policies-ns",
+			),
+		},
+		{
+			"testns",
+			[]interface{}{},
+			"secretkey2",
+			"",
+			"",
+			fmt.Errorf("%w: namespace, names, and key must be specified", ErrInvalidInput),
+		},
+		{
+			"testns",
+			[]interface{}{"idontexist", 5},
+			"secretkey2",
+			"",
+			"",
+			fmt.Errorf("%w: the Secret names must be non-empty strings", ErrInvalidInput),
+		},
+	}
+
+	for _, test := range testcases {
+		resolver, err := NewResolver(k8sConfig, Config{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		templateResult := &TemplateResult{}
+
+		val, err := resolver.fromSecretFirst(
+			&ResolveOptions{LookupNamespace: test.lookupNamespace},
+			templateResult,
+			test.inputNs,
+			test.inputNames,
+			test.inputKey,
+		)
+
+		if err != nil {
+			if test.expectedErr == nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !strings.EqualFold(test.expectedErr.Error(), err.Error()) {
+				t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+			}
+		} else {
+			if test.expectedErr != nil {
+				t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+			}
+
+			if test.expectedResult == "" {
+				if val != "" {
+					t.Fatalf("expected an empty value, got : %s", val)
+				}
+
+				continue
+			}
+
+			if val != base64encode(test.expectedResult) {
+				t.Fatalf("expected : %s , got : %s", base64encode(test.expectedResult), val)
+			}
+
+			if !templateResult.HasSensitiveData {
+				t.Fatalf("expected HasSensitiveData to be set to true")
+			}
+		}
+	}
+}
+
+func TestFromConfigMapFirst(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		inputNs        string
+		inputNames     []interface{}
+		inputKey       string
+		expectedResult string
+		expectedErr    error
+	}{
+		{"testns", []interface{}{"testconfigmap"}, "cmkey1", "cmkey1Val", nil},
+		{"testns", []interface{}{"idontexist", "testconfigmap"}, "cmkey2", "cmkey2Val", nil},
+		{"testns", []interface{}{"testcm-enva", "testconfigmap"}, "cmkey2", "cmkey2Val", nil},
+		{"testns", []interface{}{"idontexist"}, "cmkey1", "", nil},
+		{
+			"",
+			[]interface{}{"testconfigmap"},
+			"cmkey1",
+			"",
+			fmt.Errorf("%w: namespace, names, and key must be specified", ErrInvalidInput),
+		},
+		{
+			"testns",
+			[]interface{}{""},
+			"cmkey1",
+			"",
+			fmt.Errorf("%w: the ConfigMap names must be non-empty strings", ErrInvalidInput),
+		},
+	}
+
+	for _, test := range testcases {
+		resolver, err := NewResolver(k8sConfig, Config{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		val, err := resolver.fromConfigMapFirst(
+			&ResolveOptions{}, &TemplateResult{}, test.inputNs, test.inputNames, test.inputKey,
+		)
+
+		if err != nil {
+			if test.expectedErr == nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !strings.EqualFold(test.expectedErr.Error(), err.Error()) {
+				t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+			}
+		} else {
+			if test.expectedErr != nil {
+				t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+			}
+
+			if val != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, val)
+			}
+		}
+	}
+}
+
+const latestConfigMapBundle = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config-1
+  namespace: app
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  labels:
+    app: my-app
+data:
+  version: "1"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config-3
+  namespace: app
+  creationTimestamp: "2024-03-01T00:00:00Z"
+  labels:
+    app: my-app
+data:
+  version: "3"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config-2
+  namespace: app
+  creationTimestamp: "2024-02-01T00:00:00Z"
+  labels:
+    app: my-app
+data:
+  version: "2"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: tie-b
+  namespace: app
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  labels:
+    app: tie
+data:
+  version: b
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: tie-a
+  namespace: app
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  labels:
+    app: tie
+data:
+  version: a
+`
+
+func TestFromLatestConfigMap(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(latestConfigMapBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputNs        string
+		inputSelector  string
+		inputKey       string
+		expectedResult string
+		expectedErr    error
+	}{
+		"newest":      {"app", "app=my-app", "version", "3", nil},
+		"tie_by_name": {"app", "app=tie", "version", "b", nil},
+		"missing_key": {"app", "app=my-app", "other", "", nil},
+		"no_match": {
+			"app", "app=other", "version", "",
+			errors.New(`no ConfigMaps in app match the label selector "app=other"`),
+		},
+		"no_namespace": {
+			"", "app=my-app", "version", "",
+			fmt.Errorf("%w: namespace and key must be specified", ErrInvalidInput),
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := resolver.fromLatestConfigMap(
+				&ResolveOptions{}, &TemplateResult{}, test.inputNs, test.inputSelector, test.inputKey,
+			)
+			if err != nil {
+				if test.expectedErr == nil {
+					t.Fatalf(err.Error())
+				}
+
+				if !strings.EqualFold(test.expectedErr.Error(), err.Error()) {
+					t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+				}
+
+				return
+			} else if test.expectedErr != nil {
+				t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+			}
+
+			if val != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, val)
+			}
+		})
+	}
+}
+
 func TestFromConfigMap(t *testing.T) {
 	t.Parallel()
 
@@ -176,7 +448,7 @@ func TestFromConfigMap(t *testing.T) {
 		}
 
 		val, err := resolver.fromConfigMap(
-			&ResolveOptions{LookupNamespace: test.lookupNamespace}, test.inputNs, test.inputCMname, test.inputKey,
+			&ResolveOptions{LookupNamespace: test.lookupNamespace}, nil, test.inputNs, test.inputCMname, test.inputKey,
 		)
 
 		if err != nil {
@@ -484,7 +756,7 @@ func TestCopyConfigMapData(t *testing.T) {
 		}
 
 		val, err := resolver.copyConfigMapData(
-			&ResolveOptions{LookupNamespace: test.lookupNamespace}, test.inputNs, test.inputCMname,
+			&ResolveOptions{LookupNamespace: test.lookupNamespace}, nil, test.inputNs, test.inputCMname,
 		)
 
 		if err != nil {
@@ -512,3 +784,158 @@ func TestCopyConfigMapData(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigMapAndSecretData(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	encryptionConfig := EncryptionConfig{
+		AESKey:               bytes.Repeat([]byte{byte('A')}, 256/8),
+		EncryptionEnabled:    true,
+		InitializationVector: bytes.Repeat([]byte{byte('I')}, IVSize),
+	}
+
+	encryptedPassword, err := resolver.protect(&ResolveOptions{EncryptionConfig: encryptionConfig}, "cGFzc3dvcmQ=")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl         string
+		encryptionConfig  EncryptionConfig
+		expectedResult    string
+		expectedSensitive bool
+		expectedErr       error
+	}{
+		"configMapData_range": {
+			inputTmpl:      `{{ range $k, $v := configMapData "app" "app-config" }}{{ $k }}: '{{ $v }}'{{ end }}`,
+			expectedResult: `{"replicas":"3"}`,
+		},
+		"configMapData_no_name": {
+			inputTmpl:   `value: '{{ configMapData "app" "" }}'`,
+			expectedErr: ErrInvalidInput,
+		},
+		"secretData_index": {
+			inputTmpl:         `password: '{{ index (secretData "app" "app-secret") "password" }}'`,
+			expectedResult:    `{"password":"cGFzc3dvcmQ="}`,
+			expectedSensitive: true,
+		},
+		"secretData_protected": {
+			inputTmpl:         `password: '{{ index (secretData "app" "app-secret") "password" }}'`,
+			encryptionConfig:  encryptionConfig,
+			expectedResult:    `{"password":"` + encryptedPassword + `"}`,
+			expectedSensitive: true,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl),
+				nil,
+				&ResolveOptions{EncryptionConfig: test.encryptionConfig, InputIsYAML: true},
+			)
+			if err != nil {
+				if test.expectedErr == nil {
+					t.Fatalf(err.Error())
+				}
+
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+				}
+
+				return
+			} else if test.expectedErr != nil {
+				t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, tmplResult.ResolvedJSON)
+			}
+
+			if tmplResult.HasSensitiveData != test.expectedSensitive {
+				t.Fatalf(
+					"expected HasSensitiveData : %v , got : %v", test.expectedSensitive, tmplResult.HasSensitiveData,
+				)
+			}
+		})
+	}
+}
+
+func TestBase64encLines(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Repeat("user:$apr1$abcdefgh$0123456789abcdefghijkl\n", 4)
+	testcases := []struct {
+		width       int
+		input       string
+		expectedErr error
+	}{
+		{64, input, nil},
+		{76, input, nil},
+		{76, "short", nil},
+		{76, "", nil},
+		{0, input, ErrInvalidInput},
+		{-1, input, ErrInvalidInput},
+	}
+
+	for _, test := range testcases {
+		val, err := base64encLines(test.width, test.input)
+		if !errors.Is(err, test.expectedErr) {
+			t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(val, "\n")
+		for i, line := range lines {
+			if len(line) > test.width || (i < len(lines)-1 && len(line) != test.width) {
+				t.Fatalf("expected lines of width %d, got line %d of width %d: %s", test.width, i, len(line), line)
+			}
+		}
+
+		if strings.Join(lines, "") != base64encode(test.input) {
+			t.Fatalf("expected : %s , got : %s", base64encode(test.input), val)
+		}
+
+		if base64decLines(val) != test.input {
+			t.Fatalf("expected the decoded value to be %s, got : %s", test.input, base64decLines(val))
+		}
+	}
+}
+
+func TestBase64decLines(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		input          string
+		expectedResult string
+	}{
+		{"aGVsbG8gd29y\nbGQ=", "hello world"},
+		{"aGVsbG8gd29y\r\nbGQ=\n", "hello world"},
+		{"  aGVsbG8g\n\td29ybGQ=  ", "hello world"},
+		{"aGVsbG8gd29ybGQ=", "hello world"},
+	}
+
+	for _, test := range testcases {
+		val := base64decLines(test.input)
+		if val != test.expectedResult {
+			t.Fatalf("expected : %s , got : %s", test.expectedResult, val)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+// applyDefaultsFieldManager is the field manager of the update dry run requests for ResolveOptions.ApplyDefaults.
+const applyDefaultsFieldManager = "go-template-utils-defaults"
+
+// applyServerDefaults returns a copy of the lookup result with the API server defaults applied for
+// ResolveOptions.ApplyDefaults. If the result is a list, the defaults are applied to each item.
+func (t *TemplateResolver) applyServerDefaults(result map[string]interface{}) (map[string]interface{}, error) {
+	if t.dynamicClient == nil {
+		return nil, fmt.Errorf(
+			"%w: applying defaults requires a Kubernetes client, which is not available when using "+
+				"NewResolverWithDynamicWatcher",
+			ErrInvalidInput,
+		)
+	}
+
+	items, isList := result["items"].([]interface{})
+	if !isList {
+		defaulted, err := t.applyObjectServerDefaults(&unstructured.Unstructured{Object: result})
+		if err != nil {
+			return nil, err
+		}
+
+		return defaulted.Object, nil
+	}
+
+	defaultedResult := make(map[string]interface{}, len(result))
+
+	for key, value := range result {
+		defaultedResult[key] = value
+	}
+
+	defaultedItems := make([]interface{}, 0, len(items))
+
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			defaultedItems = append(defaultedItems, item)
+
+			continue
+		}
+
+		defaulted, err := t.applyObjectServerDefaults(&unstructured.Unstructured{Object: itemMap})
+		if err != nil {
+			return nil, err
+		}
+
+		defaultedItems = append(defaultedItems, defaulted.Object)
+	}
+
+	defaultedResult["items"] = defaultedItems
+
+	return defaultedResult, nil
+}
+
+// applyObjectServerDefaults performs an update dry run request of the input object and returns the object from the
+// response, which the API server has decoded with the defaults of the kind applied. The input object isn't modified.
+func (t *TemplateResolver) applyObjectServerDefaults(
+	obj *unstructured.Unstructured,
+) (*unstructured.Unstructured, error) {
+	klog.V(2).Infof("applyDefaults for the %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+
+	scopedGVRObj, err := t.getScopedGVR(obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+
+	obj = obj.DeepCopy()
+	// The object may be from a cache, so don't fail the request if it's outdated
+	obj.SetResourceVersion("")
+
+	var resourceClient dynamic.ResourceInterface
+
+	if scopedGVRObj.Namespaced {
+		resourceClient = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource).Namespace(obj.GetNamespace())
+	} else {
+		resourceClient = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource)
+	}
+
+	defaulted, err := resourceClient.Update(context.TODO(), obj, metav1.UpdateOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		FieldManager: applyDefaultsFieldManager,
+	})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to apply the defaults to the %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err,
+		)
+	}
+
+	// Match the lookup results, which don't have the managed fields
+	defaulted.SetManagedFields(nil)
+
+	return defaulted, nil
+}
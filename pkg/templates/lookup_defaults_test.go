@@ -0,0 +1,102 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestResolveTemplateApplyDefaults(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: small
+  namespace: default
+spec:
+  size: small
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: unsized
+  namespace: default
+spec: {}
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, fakeClient := newFakeClientResolver(t, objects, Config{})
+
+	// Simulate the API server defaulting spec.size in the update dry run response
+	fakeClient.PrependReactor(
+		"update", "widgets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			obj, _ := action.(clienttesting.UpdateAction).GetObject().(*unstructured.Unstructured)
+			obj = obj.DeepCopy()
+
+			if _, found, _ := unstructured.NestedString(obj.Object, "spec", "size"); !found {
+				_ = unstructured.SetNestedField(obj.Object, "medium", "spec", "size")
+			}
+
+			return true, obj, nil
+		},
+	)
+
+	tmpl := "unsized: '{{ or (lookup \"example.com/v1\" \"Widget\" \"default\" \"unsized\").spec.size \"unset\" }}'\n" +
+		"small: '{{ (lookup \"example.com/v1\" \"Widget\" \"default\" \"small\").spec.size }}'\n" +
+		"list: '{{ range (lookup \"example.com/v1\" \"Widget\" \"default\" \"\").items }}" +
+		"{{ or .spec.size \"unset\" }},{{ end }}'\n" +
+		"missing: '{{ len (lookup \"example.com/v1\" \"Widget\" \"default\" \"missing\") }}'\n"
+
+	testcases := map[string]struct {
+		applyDefaults bool
+		expectedJSON  string
+	}{
+		"apply_defaults": {
+			applyDefaults: true,
+			expectedJSON:  `{"list":"small,medium,","missing":"0","small":"small","unsized":"medium"}`,
+		},
+		"no_defaults": {
+			applyDefaults: false,
+			expectedJSON:  `{"list":"small,unset,","missing":"0","small":"small","unsized":"unset"}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{InputIsYAML: true, ApplyDefaults: test.applyDefaults}
+
+			result, err := resolver.ResolveTemplate([]byte(tmpl), nil, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+			}
+		})
+	}
+}
+
+func TestApplyServerDefaultsNoClient(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{}
+
+	_, err := resolver.applyServerDefaults(map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+)
+
+// missingKeyPlaceholderFunc is the internal template function appended to the pipeline of the template actions with
+// output when Config.MissingKeyPlaceholder is set.
+const missingKeyPlaceholderFunc = "_missingKeyPlaceholder"
+
+// missingKeyPlaceholder returns a template function for Config.MissingKeyPlaceholder that outputs the placeholder
+// instead of the value when the value is missing, such as a missing map key, and otherwise returns the value as is.
+// text/template passes a missing value as nil, which it would otherwise output as "<no value>".
+func missingKeyPlaceholder(placeholder string) func(interface{}) interface{} {
+	return func(value interface{}) interface{} {
+		if value == nil {
+			return placeholder
+		}
+
+		return value
+	}
+}
+
+// applyMissingKeyPlaceholder appends the missingKeyPlaceholderFunc template function to the pipeline of each template
+// action with output in the parsed template and the templates defined in it. The "missingkey=invalid" option is set
+// so that a missing map key is passed to the function as a missing value rather than the zero value of the map type.
+// Actions that assign a variable are not changed since they don't have output.
+func applyMissingKeyPlaceholder(tmpl *template.Template) {
+	tmpl.Option("missingkey=invalid")
+
+	for _, definedTmpl := range tmpl.Templates() {
+		if definedTmpl.Tree == nil || definedTmpl.Root == nil {
+			continue
+		}
+
+		appendMissingKeyPlaceholder(definedTmpl.Tree, definedTmpl.Root)
+	}
+}
+
+func appendMissingKeyPlaceholder(tree *parse.Tree, node parse.Node) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+
+		for _, child := range typedNode.Nodes {
+			appendMissingKeyPlaceholder(tree, child)
+		}
+	case *parse.ActionNode:
+		if typedNode.Pipe == nil || len(typedNode.Pipe.Decl) != 0 {
+			return
+		}
+
+		identifier := parse.NewIdentifier(missingKeyPlaceholderFunc).SetTree(tree).SetPos(typedNode.Pos)
+
+		typedNode.Pipe.Cmds = append(typedNode.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      typedNode.Pos,
+			Args:     []parse.Node{identifier},
+		})
+	case *parse.IfNode:
+		appendMissingKeyPlaceholder(tree, typedNode.List)
+		appendMissingKeyPlaceholder(tree, typedNode.ElseList)
+	case *parse.RangeNode:
+		appendMissingKeyPlaceholder(tree, typedNode.List)
+		appendMissingKeyPlaceholder(tree, typedNode.ElseList)
+	case *parse.WithNode:
+		appendMissingKeyPlaceholder(tree, typedNode.List)
+		appendMissingKeyPlaceholder(tree, typedNode.ElseList)
+	}
+}
+
+// validateMissingKeyPlaceholder returns an error wrapping ErrInvalidInput if the Config.MissingKeyPlaceholder would
+// change the structure of the YAML when output in a plain or quoted YAML value.
+func validateMissingKeyPlaceholder(placeholder string) error {
+	if !strings.ContainsAny(placeholder, `'"\#:`) && !strings.ContainsFunc(placeholder, unicode.IsControl) {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: the MissingKeyPlaceholder of %q cannot contain quotes, backslashes, \"#\", \":\", or control "+
+			"characters",
+		ErrInvalidInput, placeholder,
+	)
+}
@@ -0,0 +1,238 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/klog"
+)
+
+// ObjectTemplatesOptions is a struct containing configuration for calling ResolveObjectTemplatesWithOptions.
+//
+// - Context is the context passed to the templates in the object-templates or object-templates-raw. If this is not set,
+// the templates are resolved without a context.
+//
+// - Index, if set, restricts the object-templates to only the entry at this index, so the returned objectDefinition
+// only has that entry. An error wrapping ErrInvalidInput is returned if the index is out of range or the
+// objectDefinition has object-templates-raw, since its entries aren't known until it's resolved.
+//
+// - ResolveTemplate, if set, is called instead of TemplateResolver.ResolveTemplate to resolve the object-templates-raw
+// string and each object-templates entry, such as to reject certain templates or to record the referenced objects.
+// The fieldPath is the path of the resolved value relative to the spec, which is "object-templates" for
+// object-templates-raw and "object-templates[<index>]" for an entry, where the index is in the returned
+// object-templates.
+type ObjectTemplatesOptions struct {
+	Context         interface{}
+	Index           *int
+	ResolveTemplate func(
+		fieldPath string, tmplRaw []byte, context interface{}, options *ResolveOptions,
+	) (TemplateResult, error)
+}
+
+// ResolveObjectTemplates resolves the templates in the object-templates or object-templates-raw of the spec of a
+// ConfigurationPolicy objectDefinition and returns a copy of it with the resolved entries. It's the same as
+// ResolveObjectTemplatesWithOptions with the default ObjectTemplatesOptions, so the templates are resolved without a
+// context.
+func (t *TemplateResolver) ResolveObjectTemplates(
+	objectDefinition map[string]interface{}, options *ResolveOptions,
+) (map[string]interface{}, error) {
+	return t.ResolveObjectTemplatesWithOptions(objectDefinition, options, ObjectTemplatesOptions{})
+}
+
+// ResolveObjectTemplatesWithOptions resolves the templates in the object-templates or object-templates-raw of the spec
+// of a ConfigurationPolicy objectDefinition and returns a copy of it with the resolved entries. Each object-templates
+// entry is resolved separately as JSON. The object-templates-raw string is resolved as YAML and must resolve to an
+// array, an empty result being an empty array, which replaces it as the object-templates. The templates are resolved
+// with the ResolveDepth of options incremented since they are nested in the objectDefinition, and the InputIsYAML
+// field of options is ignored. An objectDefinition without either field is returned as is, and an error wrapping
+// ErrInvalidInput is returned if both are set or if either has an invalid type. See ObjectTemplatesOptions for the
+// additional configuration.
+func (t *TemplateResolver) ResolveObjectTemplatesWithOptions(
+	objectDefinition map[string]interface{}, options *ResolveOptions, objOptions ObjectTemplatesOptions,
+) (map[string]interface{}, error) {
+	if options == nil {
+		options = &ResolveOptions{}
+	}
+
+	if objOptions.ResolveTemplate == nil {
+		objOptions.ResolveTemplate = func(
+			_ string, tmplRaw []byte, context interface{}, resolveOptions *ResolveOptions,
+		) (TemplateResult, error) {
+			return t.ResolveTemplate(tmplRaw, context, resolveOptions)
+		}
+	}
+
+	if objectDefinition["spec"] == nil {
+		if objOptions.Index != nil {
+			return nil, objectTemplateIndexOutOfRange(*objOptions.Index, 0)
+		}
+
+		return objectDefinition, nil
+	}
+
+	spec, ok := objectDefinition["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: the spec must be an object", ErrInvalidInput)
+	}
+
+	rawTemplates, hasRaw := spec["object-templates-raw"]
+	objTemplates, hasObjTemplates := spec["object-templates"]
+
+	if !hasRaw && !hasObjTemplates {
+		if objOptions.Index != nil {
+			return nil, objectTemplateIndexOutOfRange(*objOptions.Index, 0)
+		}
+
+		return objectDefinition, nil
+	}
+
+	if hasRaw && hasObjTemplates {
+		return nil, fmt.Errorf("%w: object-templates and object-templates-raw cannot both be set", ErrInvalidInput)
+	}
+
+	var (
+		resolvedTemplates []interface{}
+		err               error
+	)
+
+	if hasRaw {
+		rawString, ok := rawTemplates.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: object-templates-raw must be a string", ErrInvalidInput)
+		}
+
+		if objOptions.Index != nil {
+			return nil, fmt.Errorf(
+				"%w: the object-templates index cannot be used with object-templates-raw", ErrInvalidInput,
+			)
+		}
+
+		resolvedTemplates, err = resolveObjectTemplatesRaw(rawString, options, objOptions)
+	} else {
+		objTemplatesList, ok := objTemplates.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: object-templates must be an array", ErrInvalidInput)
+		}
+
+		if objOptions.Index != nil {
+			index := *objOptions.Index
+			if index < 0 || index >= len(objTemplatesList) {
+				return nil, objectTemplateIndexOutOfRange(index, len(objTemplatesList))
+			}
+
+			objTemplatesList = objTemplatesList[index : index+1]
+		}
+
+		resolvedTemplates, err = resolveObjectTemplatesList(objTemplatesList, options, objOptions)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolvedSpec := make(map[string]interface{}, len(spec))
+
+	for key, value := range spec {
+		if key != "object-templates-raw" {
+			resolvedSpec[key] = value
+		}
+	}
+
+	resolvedSpec["object-templates"] = resolvedTemplates
+
+	resolvedObjectDefinition := make(map[string]interface{}, len(objectDefinition))
+
+	for key, value := range objectDefinition {
+		resolvedObjectDefinition[key] = value
+	}
+
+	resolvedObjectDefinition["spec"] = resolvedSpec
+
+	return resolvedObjectDefinition, nil
+}
+
+// objectTemplateIndexOutOfRange returns an error wrapping ErrInvalidInput for an ObjectTemplatesOptions.Index that is
+// out of range of the object-templates.
+func objectTemplateIndexOutOfRange(index int, count int) error {
+	return fmt.Errorf(
+		"%w: the object-templates index %d is out of range since there are %d object-templates",
+		ErrInvalidInput, index, count,
+	)
+}
+
+// resolveObjectTemplatesRaw resolves the object-templates-raw YAML string and returns the resulting array of
+// object-templates.
+func resolveObjectTemplatesRaw(
+	rawTemplates string, options *ResolveOptions, objOptions ObjectTemplatesOptions,
+) ([]interface{}, error) {
+	klog.V(2).Info("Resolving the templates in object-templates-raw")
+
+	rawOptions := *options
+	rawOptions.InputIsYAML = true
+	rawOptions.ResolveDepth++
+
+	tmplResult, err := objOptions.ResolveTemplate(
+		"object-templates", []byte(rawTemplates), objOptions.Context, &rawOptions,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the templates in object-templates-raw: %w", err)
+	}
+
+	var resolved interface{}
+
+	err = json.Unmarshal(tmplResult.ResolvedJSON, &resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the templates in object-templates-raw: %w", err)
+	}
+
+	switch v := resolved.(type) {
+	case []interface{}:
+		return v, nil
+	case nil:
+		return []interface{}{}, nil
+	default:
+		return nil, fmt.Errorf(
+			"%w: object-templates-raw was not an array after the templates were resolved", ErrInvalidInput,
+		)
+	}
+}
+
+// resolveObjectTemplatesList resolves each object-templates entry and returns the resolved entries in the same order.
+func resolveObjectTemplatesList(
+	objTemplates []interface{}, options *ResolveOptions, objOptions ObjectTemplatesOptions,
+) ([]interface{}, error) {
+	jsonOptions := *options
+	jsonOptions.InputIsYAML = false
+	jsonOptions.ResolveDepth++
+
+	resolvedTemplates := make([]interface{}, 0, len(objTemplates))
+
+	for i, objTemplate := range objTemplates {
+		klog.V(2).Infof("Resolving the templates in object-templates[%d]", i)
+
+		objTemplateJSON, err := json.Marshal(objTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: object-templates[%d] is invalid: %w", ErrInvalidInput, i, err)
+		}
+
+		tmplResult, err := objOptions.ResolveTemplate(
+			fmt.Sprintf("object-templates[%d]", i), objTemplateJSON, objOptions.Context, &jsonOptions,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the templates in object-templates[%d]: %w", i, err)
+		}
+
+		var resolved interface{}
+
+		err = json.Unmarshal(tmplResult.ResolvedJSON, &resolved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the templates in object-templates[%d]: %w", i, err)
+		}
+
+		resolvedTemplates = append(resolvedTemplates, resolved)
+	}
+
+	return resolvedTemplates, nil
+}
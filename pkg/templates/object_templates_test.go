@@ -0,0 +1,343 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolveObjectTemplates(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		input          string
+		expectedResult string
+		expectedErr    error
+	}{
+		"object-templates": {
+			input: `{"kind":"ConfigurationPolicy","spec":{"remediationAction":"inform","object-templates":[` +
+				`{"complianceType":"musthave","objectDefinition":{"data":{"replicas":` +
+				`"{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}"}}},` +
+				`{"complianceType":"mustnothave","objectDefinition":{"kind":"Pod"}}]}}`,
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"object-templates":[` +
+				`{"complianceType":"musthave","objectDefinition":{"data":{"replicas":"3"}}},` +
+				`{"complianceType":"mustnothave","objectDefinition":{"kind":"Pod"}}],"remediationAction":"inform"}}`,
+		},
+		"object-templates-raw": {
+			input: `{"kind":"ConfigurationPolicy","spec":{"object-templates-raw":` +
+				`"{{ range (lookup \"v1\" \"ConfigMap\" \"app\" \"\").items }}\n` +
+				`- complianceType: musthave\n  objectDefinition:\n    metadata:\n      name: {{ .metadata.name }}\n` +
+				`{{ end }}"}}`,
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"object-templates":[` +
+				`{"complianceType":"musthave","objectDefinition":{"metadata":{"name":"app-config"}}}]}}`,
+		},
+		"empty_object-templates-raw": {
+			input:          `{"kind":"ConfigurationPolicy","spec":{"object-templates-raw":""}}`,
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"object-templates":[]}}`,
+		},
+		"no_object-templates": {
+			input:          `{"kind":"ConfigurationPolicy","spec":{"remediationAction":"inform"}}`,
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"remediationAction":"inform"}}`,
+		},
+		"object-templates-raw_not_an_array": {
+			input:       `{"kind":"ConfigurationPolicy","spec":{"object-templates-raw":"complianceType: musthave"}}`,
+			expectedErr: ErrInvalidInput,
+		},
+		"both_set": {
+			input:       `{"kind":"ConfigurationPolicy","spec":{"object-templates-raw":"","object-templates":[]}}`,
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid_object-templates": {
+			input:       `{"kind":"ConfigurationPolicy","spec":{"object-templates":"musthave"}}`,
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid_spec": {
+			input:       `{"kind":"ConfigurationPolicy","spec":"inform"}`,
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			var objectDefinition map[string]interface{}
+
+			err := json.Unmarshal([]byte(test.input), &objectDefinition)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			resolved, err := resolver.ResolveObjectTemplates(objectDefinition, nil)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			resolvedJSON, err := json.Marshal(resolved)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(resolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, string(resolvedJSON))
+			}
+
+			// The input must not be modified
+			inputJSON, err := json.Marshal(objectDefinition)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			var expectedInput map[string]interface{}
+
+			_ = json.Unmarshal([]byte(test.input), &expectedInput)
+
+			expectedInputJSON, _ := json.Marshal(expectedInput)
+			if string(inputJSON) != string(expectedInputJSON) {
+				t.Fatalf("expected the input to be unchanged, got : %s", string(inputJSON))
+			}
+		})
+	}
+}
+
+func TestResolveObjectTemplatesMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: templates
+  namespace: app
+data:
+  greeting: hello
+  nested: '{{ includeTemplate "app" "templates" "greeting" . }}'
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{MaxResolveDepth: 2})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		key            string
+		resolveDepth   int
+		expectedResult string
+		expectedErr    error
+	}{
+		"include": {
+			key: "greeting",
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"object-templates":[` +
+				`{"complianceType":"musthave","objectDefinition":{"data":{"greeting":"hello"}}}]}}`,
+		},
+		"nested_include": {
+			key:         "nested",
+			expectedErr: ErrMaxDepthExceeded,
+		},
+		"resolve_depth_include": {
+			key:          "greeting",
+			resolveDepth: 1,
+			expectedErr:  ErrMaxDepthExceeded,
+		},
+		"resolve_depth_exceeded": {
+			key:          "greeting",
+			resolveDepth: 2,
+			expectedErr:  ErrMaxDepthExceeded,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			objectDefinition := map[string]interface{}{
+				"kind": "ConfigurationPolicy",
+				"spec": map[string]interface{}{
+					"object-templates": []interface{}{
+						map[string]interface{}{
+							"complianceType": "musthave",
+							"objectDefinition": map[string]interface{}{
+								"data": map[string]interface{}{
+									"greeting": `{{ includeTemplate "app" "templates" "` + test.key + `" . }}`,
+								},
+							},
+						},
+					},
+				},
+			}
+
+			resolved, err := resolver.ResolveObjectTemplates(
+				objectDefinition, &ResolveOptions{ResolveDepth: test.resolveDepth},
+			)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			resolvedJSON, err := json.Marshal(resolved)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(resolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, string(resolvedJSON))
+			}
+		})
+	}
+
+	// A top-level ResolveTemplate call with a ResolveDepth deeper than the maximum is rejected before resolving
+	_, err = resolver.ResolveTemplate([]byte(`{"greeting":"hello"}`), nil, &ResolveOptions{ResolveDepth: 3})
+	if !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Fatalf("expected err: %v got err: %v", ErrMaxDepthExceeded, err)
+	}
+}
+
+func TestResolveObjectTemplatesWithOptions(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	objTemplates := `{"kind":"ConfigurationPolicy","spec":{"object-templates":[` +
+		`{"complianceType":"musthave","objectDefinition":{"metadata":{"name":"{{ .ObjectName }}-first"}}},` +
+		`{"complianceType":"musthave","objectDefinition":{"metadata":{"name":"{{ .ObjectName }}-second"}}}]}}`
+	objTemplatesRaw := `{"kind":"ConfigurationPolicy","spec":{"object-templates-raw":` +
+		`"- complianceType: musthave\n  objectDefinition:\n    metadata:\n      name: {{ .ObjectName }}\n"}}`
+	index := func(i int) *int { return &i }
+
+	testcases := map[string]struct {
+		input              string
+		index              *int
+		expectedResult     string
+		expectedFieldPaths []string
+		expectedErr        error
+	}{
+		"object-templates": {
+			input: objTemplates,
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"object-templates":[` +
+				`{"complianceType":"musthave","objectDefinition":{"metadata":{"name":"my-obj-first"}}},` +
+				`{"complianceType":"musthave","objectDefinition":{"metadata":{"name":"my-obj-second"}}}]}}`,
+			expectedFieldPaths: []string{"object-templates[0]", "object-templates[1]"},
+		},
+		"object-templates_index": {
+			input: objTemplates,
+			index: index(1),
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"object-templates":[` +
+				`{"complianceType":"musthave","objectDefinition":{"metadata":{"name":"my-obj-second"}}}]}}`,
+			expectedFieldPaths: []string{"object-templates[0]"},
+		},
+		"object-templates-raw": {
+			input: objTemplatesRaw,
+			expectedResult: `{"kind":"ConfigurationPolicy","spec":{"object-templates":[` +
+				`{"complianceType":"musthave","objectDefinition":{"metadata":{"name":"my-obj"}}}]}}`,
+			expectedFieldPaths: []string{"object-templates"},
+		},
+		"index_out_of_range": {
+			input:       objTemplates,
+			index:       index(2),
+			expectedErr: ErrInvalidInput,
+		},
+		"negative_index": {
+			input:       objTemplates,
+			index:       index(-1),
+			expectedErr: ErrInvalidInput,
+		},
+		"index_without_object-templates": {
+			input:       `{"kind":"ConfigurationPolicy","spec":{"remediationAction":"inform"}}`,
+			index:       index(0),
+			expectedErr: ErrInvalidInput,
+		},
+		"index_with_object-templates-raw": {
+			input:       objTemplatesRaw,
+			index:       index(0),
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			var objectDefinition map[string]interface{}
+
+			err := json.Unmarshal([]byte(test.input), &objectDefinition)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			fieldPaths := []string{}
+
+			resolved, err := resolver.ResolveObjectTemplatesWithOptions(
+				objectDefinition,
+				nil,
+				ObjectTemplatesOptions{
+					Context: struct{ ObjectName string }{ObjectName: "my-obj"},
+					Index:   test.index,
+					ResolveTemplate: func(
+						fieldPath string, tmplRaw []byte, context interface{}, options *ResolveOptions,
+					) (TemplateResult, error) {
+						fieldPaths = append(fieldPaths, fieldPath)
+
+						return resolver.ResolveTemplate(tmplRaw, context, options)
+					},
+				},
+			)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			resolvedJSON, err := json.Marshal(resolved)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(resolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, string(resolvedJSON))
+			}
+
+			if !reflect.DeepEqual(fieldPaths, test.expectedFieldPaths) {
+				t.Fatalf("expected : %v , got : %v", test.expectedFieldPaths, fieldPaths)
+			}
+		})
+	}
+}
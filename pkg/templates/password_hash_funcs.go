@@ -0,0 +1,70 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// maxHtpasswdCost is the maximum bcrypt cost allowed by htpasswdCost. Each increment doubles the computation time,
+	// so this is lower than bcrypt.MaxCost to avoid a template blocking the resolver.
+	maxHtpasswdCost = 14
+	// The Argon2id parameters used by argon2Hash, which follow the OWASP recommendation.
+	argon2Time    = 2
+	argon2Memory  = 19 * 1024
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// htpasswdCost returns an htpasswd entry in the format of "user:hash" for the input user and password using a bcrypt
+// hash with the input cost. This is the same as the Sprig "htpasswd" function except that the cost is configurable.
+func htpasswdCost(user string, password string, cost int) (string, error) {
+	if strings.Contains(user, ":") {
+		return "", fmt.Errorf("%w: the htpasswd user must not contain a colon", ErrInvalidInput)
+	}
+
+	if cost < bcrypt.MinCost || cost > maxHtpasswdCost {
+		return "", fmt.Errorf(
+			"%w: the bcrypt cost must be between %d and %d, got %d",
+			ErrInvalidInput, bcrypt.MinCost, maxHtpasswdCost, cost,
+		)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to hash the password: %w", ErrInvalidInput, err)
+	}
+
+	return user + ":" + string(hash), nil
+}
+
+// argon2Hash returns the Argon2id hash of the input password with a random salt in the PHC string format, such as
+// "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>".
+func argon2Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate the salt for the argon2 hash: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		argon2Memory,
+		argon2Time,
+		argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
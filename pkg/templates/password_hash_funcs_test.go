@@ -0,0 +1,171 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHtpasswdCost(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		user        string
+		password    string
+		cost        int
+		expectedErr bool
+	}{
+		"min_cost":          {"admin", "secret", bcrypt.MinCost, false},
+		"cost":              {"admin", "p@ss:word", 6, false},
+		"cost_too_low":      {"admin", "secret", bcrypt.MinCost - 1, true},
+		"cost_too_high":     {"admin", "secret", maxHtpasswdCost + 1, true},
+		"colon_in_user":     {"ad:min", "secret", bcrypt.MinCost, true},
+		"password_too_long": {"admin", strings.Repeat("a", 73), bcrypt.MinCost, true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := htpasswdCost(test.user, test.password, test.cost)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			user, hash, found := strings.Cut(val, ":")
+			if !found || user != test.user {
+				t.Fatalf("expected an htpasswd entry for the user %s, got : %s", test.user, val)
+			}
+
+			err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(test.password))
+			if err != nil {
+				t.Fatalf("expected the hash to verify against the password, got : %v", err)
+			}
+
+			cost, err := bcrypt.Cost([]byte(hash))
+			if err != nil || cost != test.cost {
+				t.Fatalf("expected the cost %d, got : %d (%v)", test.cost, cost, err)
+			}
+		})
+	}
+}
+
+func TestArgon2Hash(t *testing.T) {
+	t.Parallel()
+
+	password := "p@ssw0rd"
+
+	val, err := argon2Hash(password)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var (
+		version, memory, iterations int
+		threads                     uint8
+	)
+
+	// The format is $argon2id$v=<version>$m=<memory>,t=<iterations>,p=<threads>$<salt>$<key>
+	fields := strings.Split(val, "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		t.Fatalf("expected an argon2id PHC string, got : %s", val)
+	}
+
+	_, err = fmt.Sscanf(fields[2]+" "+fields[3], "v=%d m=%d,t=%d,p=%d", &version, &memory, &iterations, &threads)
+	if err != nil {
+		t.Fatalf("failed to parse the parameters of %s: %v", val, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if version != argon2.Version {
+		t.Fatalf("expected the version %d, got : %d", argon2.Version, version)
+	}
+
+	computedKey := argon2.IDKey(
+		[]byte(password), salt, uint32(iterations), uint32(memory), threads, uint32(len(key)),
+	)
+	if !bytes.Equal(key, computedKey) {
+		t.Fatalf("expected the hash to verify against the password")
+	}
+
+	otherVal, err := argon2Hash(password)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if otherVal == val {
+		t.Fatalf("expected a different salt for each hash")
+	}
+}
+
+func TestResolveTemplatePasswordHashFunctions(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		inputTmpl   string
+		allow       bool
+		expectedErr error
+	}{
+		"htpasswdCost":          {`value: '{{ htpasswdCost "admin" "secret" 4 }}'`, true, nil},
+		"argon2":                {`value: '{{ argon2 "secret" }}'`, true, nil},
+		"htpasswdCost_disabled": {`value: '{{ htpasswdCost "admin" "secret" 4 }}'`, false, ErrPasswordHashDisabled},
+		"argon2_disabled":       {`value: '{{ argon2 "secret" }}'`, false, ErrPasswordHashDisabled},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolverFromSnapshot(nil, Config{AllowPasswordHashFunctions: test.allow})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected err: %v, got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !strings.Contains(string(tmplResult.ResolvedJSON), "$") {
+				t.Fatalf("expected a password hash, got : %s", tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
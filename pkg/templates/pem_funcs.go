@@ -0,0 +1,61 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+)
+
+// decodePEMBundle returns the PEM blocks in the input bundle. An error is returned if the bundle has content other
+// than whitespace that isn't a PEM block.
+func decodePEMBundle(bundle string) ([]*pem.Block, error) {
+	blocks := []*pem.Block{}
+	rest := []byte(bundle)
+
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return nil, fmt.Errorf(
+			"%w: the PEM bundle is malformed after %d valid PEM blocks", ErrInvalidInput, len(blocks),
+		)
+	}
+
+	return blocks, nil
+}
+
+// pemCount returns the number of PEM blocks, such as certificates, in the input bundle.
+func pemCount(bundle string) (int, error) {
+	blocks, err := decodePEMBundle(bundle)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(blocks), nil
+}
+
+// pemBlock returns the PEM block at the input index of the bundle, starting at 0, PEM encoded.
+func pemBlock(bundle string, index int) (string, error) {
+	blocks, err := decodePEMBundle(bundle)
+	if err != nil {
+		return "", err
+	}
+
+	if index < 0 || index >= len(blocks) {
+		return "", fmt.Errorf(
+			"%w: the index %d is out of range since the PEM bundle has %d blocks", ErrInvalidInput, index, len(blocks),
+		)
+	}
+
+	return string(pem.EncodeToMemory(blocks[index])), nil
+}
@@ -0,0 +1,144 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// provenanceRecord is an object referenced by a template function and the offset in the template output when the
+// function was called. The key is the data key read from the object, if any.
+type provenanceRecord struct {
+	offset int
+	objID  client.ObjectIdentifier
+	key    string
+}
+
+// provenanceField is the field path of a mapping key, sequence item, or root value in the resolved YAML and its
+// position.
+type provenanceField struct {
+	line   int
+	column int
+	path   string
+}
+
+// ResolveTemplateWithProvenance is the same as ResolveTemplate except that ResolveOptions.TrackProvenance is set to
+// true, so the returned TemplateResult.Provenance maps the resolved field paths to the objects whose data produced
+// them. The input options are not modified.
+func (t *TemplateResolver) ResolveTemplateWithProvenance(
+	tmplRaw []byte, context interface{}, options *ResolveOptions,
+) (TemplateResult, error) {
+	provenanceOptions := ResolveOptions{}
+	if options != nil {
+		provenanceOptions = *options
+	}
+
+	provenanceOptions.TrackProvenance = true
+
+	return t.ResolveTemplate(tmplRaw, context, &provenanceOptions)
+}
+
+// recordProvenance records the object referenced by a template function at the current position in the template
+// output for ResolveOptions.TrackProvenance. Nothing is recorded if the template isn't being executed.
+func (t *TemplateResult) recordProvenance(objID client.ObjectIdentifier) {
+	if t.provenanceOutput == nil {
+		return
+	}
+
+	t.provenanceRecords = append(t.provenanceRecords, provenanceRecord{offset: t.provenanceOutput.Len(), objID: objID})
+}
+
+// recordProvenanceKey records the data key read by a template function, such as "fromSecret", from the object most
+// recently recorded with recordProvenance for ResolveOptions.TrackProvenance. Nothing is recorded if the template isn't
+// being executed.
+func (t *TemplateResult) recordProvenanceKey(key string) {
+	if t.provenanceOutput == nil || len(t.provenanceRecords) == 0 {
+		return
+	}
+
+	t.provenanceRecords[len(t.provenanceRecords)-1].key = key
+}
+
+// buildProvenance sets Provenance and ProvenanceKeys from the provenance records and the resolved YAML output of the
+// template. Each object is attributed to the field that was being output when the template function referencing it was
+// called.
+func (t *TemplateResult) buildProvenance(resolvedYAML []byte) error {
+	t.Provenance = map[string][]client.ObjectIdentifier{}
+	t.ProvenanceKeys = map[string][]ProvenanceKey{}
+
+	if len(t.provenanceRecords) == 0 {
+		return nil
+	}
+
+	var document yaml.Node
+
+	if err := yaml.Unmarshal(resolvedYAML, &document); err != nil {
+		return fmt.Errorf("failed to determine the provenance of the resolved template: %w", err)
+	}
+
+	fields := []provenanceField{}
+
+	if len(document.Content) != 0 {
+		fields = collectProvenanceFields(document.Content[0], "", fields)
+	}
+
+	for _, record := range t.provenanceRecords {
+		line := bytes.Count(resolvedYAML[:record.offset], []byte("\n")) + 1
+		column := record.offset - bytes.LastIndexByte(resolvedYAML[:record.offset], '\n')
+
+		path := ""
+
+		// The field is the last one that starts at or before the position in the order of the output
+		for _, field := range fields {
+			if field.line < line || (field.line == line && field.column <= column) {
+				path = field.path
+			}
+		}
+
+		if !slices.Contains(t.Provenance[path], record.objID) {
+			t.Provenance[path] = append(t.Provenance[path], record.objID)
+		}
+
+		provenanceKey := ProvenanceKey{Object: record.objID, Key: record.key}
+
+		if record.key != "" && !slices.Contains(t.ProvenanceKeys[path], provenanceKey) {
+			t.ProvenanceKeys[path] = append(t.ProvenanceKeys[path], provenanceKey)
+		}
+	}
+
+	return nil
+}
+
+// collectProvenanceFields appends the field paths in the YAML node and its children in the order of the output. Map
+// keys are joined with dots and sequence items are in the format of "[index]".
+func collectProvenanceFields(node *yaml.Node, path string, fields []provenanceField) []provenanceField {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+
+			fields = append(fields, provenanceField{line: key.Line, column: key.Column, path: keyPath})
+			fields = collectProvenanceFields(node.Content[i+1], keyPath, fields)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+
+			fields = append(fields, provenanceField{line: item.Line, column: item.Column, path: itemPath})
+			fields = collectProvenanceFields(item, itemPath, fields)
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,138 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+)
+
+func TestResolveTemplateWithProvenance(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	secretID := client.ObjectIdentifier{Version: "v1", Kind: "Secret", Namespace: "app", Name: "app-secret"}
+	configMapID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "app-config"}
+	configMapListID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Selector: "env=prod"}
+	deploymentID := client.ObjectIdentifier{
+		Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "app", Name: "app",
+	}
+	claimID := client.ObjectIdentifier{
+		Group: "cluster.open-cluster-management.io", Version: "v1alpha1", Kind: "ClusterClaim", Name: "env",
+	}
+
+	testcases := map[string]struct {
+		inputTmpl          string
+		expectedProvenance map[string][]client.ObjectIdentifier
+		expectedKeys       map[string][]ProvenanceKey
+	}{
+		"fields": {
+			inputTmpl: "spec:\n" +
+				"  data:\n" +
+				"    password: '{{ fromSecret \"app\" \"app-secret\" \"password\" }}'\n" +
+				"    replicas: '{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}'\n" +
+				"    literal: value\n" +
+				"  items:\n" +
+				"  - name: '{{ (lookup \"apps/v1\" \"Deployment\" \"app\" \"app\").metadata.name }}'\n" +
+				"  - '{{ fromClusterClaim \"env\" }}'\n" +
+				"  inline: {first: '{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}', " +
+				"second: '{{ fromSecret \"app\" \"app-secret\" \"password\" }}'}\n",
+			expectedProvenance: map[string][]client.ObjectIdentifier{
+				"spec.data.password": {secretID},
+				"spec.data.replicas": {configMapID},
+				"spec.items[0].name": {deploymentID},
+				"spec.items[1]":      {claimID},
+				"spec.inline.first":  {configMapID},
+				"spec.inline.second": {secretID},
+			},
+			expectedKeys: map[string][]ProvenanceKey{
+				"spec.data.password": {{Object: secretID, Key: "password"}},
+				"spec.data.replicas": {{Object: configMapID, Key: "replicas"}},
+				"spec.inline.first":  {{Object: configMapID, Key: "replicas"}},
+				"spec.inline.second": {{Object: secretID, Key: "password"}},
+			},
+		},
+		"variable_and_range": {
+			inputTmpl: "{{ $password := fromSecret \"app\" \"app-secret\" \"password\" }}\n" +
+				"names:\n" +
+				"{{- range (lookup \"v1\" \"ConfigMap\" \"app\" \"\" \"env=prod\").items }}\n" +
+				"- {{ .metadata.name }}\n" +
+				"{{- end }}\n" +
+				"password: '{{ $password }}'\n",
+			expectedProvenance: map[string][]client.ObjectIdentifier{
+				"":      {secretID},
+				"names": {configMapListID},
+			},
+			expectedKeys: map[string][]ProvenanceKey{
+				"": {{Object: secretID, Key: "password"}},
+			},
+		},
+		"no_actions": {
+			inputTmpl:          "key: value\n",
+			expectedProvenance: map[string][]client.ObjectIdentifier{},
+			expectedKeys:       map[string][]ProvenanceKey{},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{InputIsYAML: true}
+
+			result, err := resolver.ResolveTemplateWithProvenance([]byte(test.inputTmpl), nil, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(result.Provenance, test.expectedProvenance) {
+				t.Fatalf("expected : %v , got : %v", test.expectedProvenance, result.Provenance)
+			}
+
+			if !reflect.DeepEqual(result.ProvenanceKeys, test.expectedKeys) {
+				t.Fatalf("expected : %v , got : %v", test.expectedKeys, result.ProvenanceKeys)
+			}
+
+			if options.TrackProvenance {
+				t.Fatalf("expected the input options to not be modified")
+			}
+
+			result, err = resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if result.Provenance != nil || result.ProvenanceKeys != nil {
+				t.Fatalf("expected no provenance without TrackProvenance, got : %v", result.Provenance)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateWithProvenanceJSONNative(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplateWithProvenance([]byte(`{"key": "value"}`), nil, &ResolveOptions{JSONNative: true})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+	}
+}
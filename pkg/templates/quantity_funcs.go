@@ -0,0 +1,71 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// parseQuantity parses the input Kubernetes quantity, such as "500m" or "1Gi".
+func parseQuantity(quantity string) (resource.Quantity, error) {
+	parsed, err := resource.ParseQuantity(quantity)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("%w: the quantity %q is invalid: %w", ErrInvalidInput, quantity, err)
+	}
+
+	return parsed, nil
+}
+
+// quantityAdd returns the sum of the input Kubernetes quantities in the canonical form, such as "1536Mi" for
+// quantityAdd "1Gi" "512Mi". The result uses the format of the first quantity, such as binary SI for "1Gi".
+func quantityAdd(q1 string, q2 string) (string, error) {
+	result, err := parseQuantity(q1)
+	if err != nil {
+		return "", err
+	}
+
+	addend, err := parseQuantity(q2)
+	if err != nil {
+		return "", err
+	}
+
+	result.Add(addend)
+
+	return result.String(), nil
+}
+
+// quantitySub returns the second Kubernetes quantity subtracted from the first in the canonical form, such as "500m"
+// for quantitySub "1" "500m". The result uses the format of the first quantity.
+func quantitySub(q1 string, q2 string) (string, error) {
+	result, err := parseQuantity(q1)
+	if err != nil {
+		return "", err
+	}
+
+	subtrahend, err := parseQuantity(q2)
+	if err != nil {
+		return "", err
+	}
+
+	result.Sub(subtrahend)
+
+	return result.String(), nil
+}
+
+// quantityCompare returns -1 if the first Kubernetes quantity is less than the second, 0 if they are equal, and 1 if
+// the first is greater, regardless of the units. For example, quantityCompare "1Gi" "1024Mi" is 0.
+func quantityCompare(q1 string, q2 string) (int, error) {
+	first, err := parseQuantity(q1)
+	if err != nil {
+		return 0, err
+	}
+
+	second, err := parseQuantity(q2)
+	if err != nil {
+		return 0, err
+	}
+
+	return first.Cmp(second), nil
+}
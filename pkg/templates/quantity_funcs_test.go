@@ -0,0 +1,139 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuantityAdd(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		q1          string
+		q2          string
+		expected    string
+		expectedErr bool
+	}{
+		"mebibytes_and_gibibytes": {"1Gi", "512Mi", "1536Mi", false},
+		"gibibytes_and_gibibytes": {"1Gi", "1Gi", "2Gi", false},
+		"millicores_and_cores":    {"500m", "2", "2500m", false},
+		"cores_and_millicores":    {"1", "500m", "1500m", false},
+		"decimal_and_binary":      {"1G", "1Mi", "1001048576", false},
+		"invalid_first":           {"1 Gi", "1Gi", "", true},
+		"invalid_second":          {"1Gi", "lots", "", true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := quantityAdd(test.q1, test.q2)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestQuantitySub(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		q1          string
+		q2          string
+		expected    string
+		expectedErr bool
+	}{
+		"gibibytes_and_mebibytes": {"2Gi", "512Mi", "1536Mi", false},
+		"cores_and_millicores":    {"1", "250m", "750m", false},
+		"negative":                {"500m", "1", "-500m", false},
+		"zero":                    {"1Gi", "1024Mi", "0", false},
+		"invalid":                 {"1Gi", "", "", true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := quantitySub(test.q1, test.q2)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestQuantityCompare(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		q1          string
+		q2          string
+		expected    int
+		expectedErr bool
+	}{
+		"mebibytes_less":       {"512Mi", "1Gi", -1, false},
+		"gibibytes_equal":      {"1Gi", "1024Mi", 0, false},
+		"mebibytes_greater":    {"1025Mi", "1Gi", 1, false},
+		"millicores_less":      {"999m", "1", -1, false},
+		"millicores_equal":     {"2000m", "2", 0, false},
+		"cores_greater":        {"1.5", "1200m", 1, false},
+		"decimal_below_binary": {"1G", "1Gi", -1, false},
+		"invalid":              {"1Gi", "1 Gi", 0, true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := quantityCompare(test.q1, test.q2)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %d , got : %d", test.expected, val)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+)
+
+// remoteKubeconfigKey is the key in the referenced Secret that contains the kubeconfig of the remote cluster.
+const remoteKubeconfigKey = "kubeconfig"
+
+var secretGVR = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// validateRemoteKubeconfig returns an error wrapping ErrInvalidInput if the kubeconfig has credentials that would run
+// a command or read a file on the resolver's host, such as an exec credential plugin, an auth provider, or a token,
+// client certificate, client key, or certificate authority file path. Anyone that can write the kubeconfig Secret
+// could otherwise run a binary as the resolver or send the resolver's own credentials, such as its service account
+// token file, to a server of their choosing.
+func validateRemoteKubeconfig(kubeconfig []byte) error {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("%w: the kubeconfig is invalid: %w", ErrInvalidInput, err)
+	}
+
+	for name, authInfo := range config.AuthInfos {
+		if authInfo == nil {
+			continue
+		}
+
+		var disallowed string
+
+		switch {
+		case authInfo.Exec != nil:
+			disallowed = "exec"
+		case authInfo.AuthProvider != nil:
+			disallowed = "auth-provider"
+		case authInfo.TokenFile != "":
+			disallowed = "tokenFile"
+		case authInfo.ClientCertificate != "":
+			disallowed = "client-certificate"
+		case authInfo.ClientKey != "":
+			disallowed = "client-key"
+		default:
+			continue
+		}
+
+		return fmt.Errorf("%w: the kubeconfig user %s must not use %s credentials", ErrInvalidInput, name, disallowed)
+	}
+
+	for name, cluster := range config.Clusters {
+		if cluster != nil && cluster.CertificateAuthority != "" {
+			return fmt.Errorf(
+				"%w: the kubeconfig cluster %s must use certificate-authority-data instead of certificate-authority",
+				ErrInvalidInput, name,
+			)
+		}
+	}
+
+	return nil
+}
+
+// newRemoteDynamicClient returns a dynamic client for the cluster of the input kubeconfig.
+func newRemoteDynamicClient(kubeconfig []byte) (dynamic.Interface, error) {
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamic.NewForConfig(restConfig)
+}
+
+func (t *TemplateResolver) fromRemoteSecretHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string, string) (string, error) {
+	return func(
+		kubeconfigNamespace string, kubeconfigName string, namespace string, name string, key string,
+	) (string, error) {
+		return t.fromRemoteSecret(
+			options, templateResult, kubeconfigNamespace, kubeconfigName, namespace, name, key,
+		)
+	}
+}
+
+// fromRemoteSecret retrieves the value of the key in the given Secret on the remote cluster of the kubeconfig in the
+// "kubeconfig" key of the given kubeconfig Secret. The kubeconfig Secret is subject to the same restrictions as
+// fromSecret, but the namespace of the remote Secret is not restricted by ResolveOptions.LookupNamespace since it is on
+// a different cluster.
+func (t *TemplateResolver) fromRemoteSecret(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+	kubeconfigNamespace string,
+	kubeconfigName string,
+	namespace string,
+	name string,
+	key string,
+) (string, error) {
+	klog.V(2).Infof(
+		"fromRemoteSecret for kubeconfig namespace: %v, kubeconfig name: %v, namespace: %v, name: %v, key: %v",
+		kubeconfigNamespace, kubeconfigName, namespace, name, key,
+	)
+
+	if namespace == "" || name == "" || key == "" {
+		return "", fmt.Errorf("%w: namespace, name, and key must be specified", ErrInvalidInput)
+	}
+
+	encodedKubeconfig, err := t.fromSecret(
+		options, templateResult, kubeconfigNamespace, kubeconfigName, remoteKubeconfigKey,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if encodedKubeconfig == "" {
+		return "", fmt.Errorf(
+			"%w: the secret %s in %s does not have the %s key",
+			ErrInvalidInput, kubeconfigName, kubeconfigNamespace, remoteKubeconfigKey,
+		)
+	}
+
+	kubeconfig, err := base64.StdEncoding.DecodeString(encodedKubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("the kubeconfig in the secret %s in %s is invalid base64: %w",
+			kubeconfigName, kubeconfigNamespace, err)
+	}
+
+	err = validateRemoteKubeconfig(kubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("the kubeconfig in the secret %s in %s is not allowed: %w",
+			kubeconfigName, kubeconfigNamespace, err)
+	}
+
+	newClient := t.remoteDynamicClient
+	if newClient == nil {
+		newClient = newRemoteDynamicClient
+	}
+
+	remoteClient, err := newClient(kubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a client from the kubeconfig in the secret %s in %s: %w",
+			kubeconfigName, kubeconfigNamespace, err)
+	}
+
+	secret, err := remoteClient.Resource(secretGVR).Namespace(namespace).Get(
+		context.TODO(), name, metav1.GetOptions{},
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the remote secret %s from %s: %w", name, namespace, err)
+	}
+
+	templateResult.HasSensitiveData = true
+
+	keyVal, _, _ := unstructured.NestedString(secret.Object, "data", key)
+
+	return keyVal, nil
+}
+
+func (t *TemplateResolver) fromRemoteSecretProtectedHelper(
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) func(string, string, string, string, string) (string, error) {
+	return func(
+		kubeconfigNamespace string, kubeconfigName string, namespace string, name string, key string,
+	) (string, error) {
+		value, err := t.fromRemoteSecret(
+			options, templateResult, kubeconfigNamespace, kubeconfigName, namespace, name, key,
+		)
+		if err != nil {
+			return "", err
+		}
+
+		return t.protect(options, value)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+)
+
+const remoteKubeconfig = "apiVersion: v1\nkind: Config\n"
+
+func newRemoteSecretResolver(t *testing.T, config Config) *TemplateResolver {
+	t.Helper()
+
+	kubeconfigSecret := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "spoke-kc", "namespace": "spoke"},
+		"data": map[string]interface{}{
+			"kubeconfig": base64.StdEncoding.EncodeToString([]byte(remoteKubeconfig)),
+		},
+	}}
+
+	resolver, err := NewResolverFromSnapshot([]unstructured.Unstructured{kubeconfigSecret}, config)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	remoteSecret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "app-secret", "namespace": "app"},
+		"data":       map[string]interface{}{"password": "cGFzc3dvcmQ="},
+	}}
+
+	resolver.remoteDynamicClient = func(kubeconfig []byte) (dynamic.Interface, error) {
+		if string(kubeconfig) != remoteKubeconfig {
+			t.Fatalf("expected the remote kubeconfig, got : %s", kubeconfig)
+		}
+
+		return fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), remoteSecret), nil
+	}
+
+	return resolver
+}
+
+func TestFromRemoteSecret(t *testing.T) {
+	t.Parallel()
+
+	resolver := newRemoteSecretResolver(t, Config{AllowRemoteSecrets: true})
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+		expectedErr    string
+	}{
+		"valid": {
+			inputTmpl:      `password: '{{ fromRemoteSecret "spoke" "spoke-kc" "app" "app-secret" "password" }}'`,
+			expectedResult: `{"password":"cGFzc3dvcmQ="}`,
+		},
+		"missing_key": {
+			inputTmpl:      `password: '{{ fromRemoteSecret "spoke" "spoke-kc" "app" "app-secret" "missing" }}'`,
+			expectedResult: `{"password":""}`,
+		},
+		"missing_remote_secret": {
+			inputTmpl:   `password: '{{ fromRemoteSecret "spoke" "spoke-kc" "app" "other" "password" }}'`,
+			expectedErr: "failed to get the remote secret other from app",
+		},
+		"missing_kubeconfig_secret": {
+			inputTmpl:   `password: '{{ fromRemoteSecret "spoke" "other" "app" "app-secret" "password" }}'`,
+			expectedErr: "failed to get the secret other from spoke",
+		},
+		"missing_args": {
+			inputTmpl:   `password: '{{ fromRemoteSecret "spoke" "spoke-kc" "app" "" "password" }}'`,
+			expectedErr: "namespace, name, and key must be specified",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an error containing %q, got : %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, tmplResult.ResolvedJSON)
+			}
+
+			if !tmplResult.HasSensitiveData {
+				t.Fatalf("expected HasSensitiveData to be true")
+			}
+		})
+	}
+}
+
+func TestFromRemoteSecretDisabled(t *testing.T) {
+	t.Parallel()
+
+	resolver := newRemoteSecretResolver(t, Config{})
+
+	tmpl := `password: '{{ fromRemoteSecret "spoke" "spoke-kc" "app" "app-secret" "password" }}'`
+
+	_, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{InputIsYAML: true})
+	if !errors.Is(err, ErrRemoteSecretsDisabled) {
+		t.Fatalf("expected an ErrRemoteSecretsDisabled error, got : %v", err)
+	}
+}
+
+func TestFromRemoteSecretDisallowedKubeconfig(t *testing.T) {
+	t.Parallel()
+
+	kubeconfigs := map[string]string{
+		"exec": "users:\n- name: spoke\n  user:\n    exec:\n      apiVersion: client.authentication.k8s.io/v1\n" +
+			"      command: /bin/sh\n",
+		"auth-provider": "users:\n- name: spoke\n  user:\n    auth-provider:\n      name: oidc\n",
+		"token-file": "users:\n- name: spoke\n  user:\n" +
+			"    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token\n",
+		"client-certificate": "users:\n- name: spoke\n  user:\n    client-certificate: /etc/tls/tls.crt\n",
+		"client-key":         "users:\n- name: spoke\n  user:\n    client-key: /etc/tls/tls.key\n",
+		"certificate-authority": "clusters:\n- name: spoke\n  cluster:\n    server: https://spoke.example.com\n" +
+			"    certificate-authority: /etc/tls/ca.crt\n",
+	}
+
+	kubeconfigSecrets := make([]unstructured.Unstructured, 0, len(kubeconfigs))
+
+	for name, kubeconfig := range kubeconfigs {
+		kubeconfigSecrets = append(kubeconfigSecrets, unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "spoke"},
+			"data": map[string]interface{}{
+				"kubeconfig": base64.StdEncoding.EncodeToString([]byte(remoteKubeconfig + kubeconfig)),
+			},
+		}})
+	}
+
+	resolver, err := NewResolverFromSnapshot(kubeconfigSecrets, Config{AllowRemoteSecrets: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver.remoteDynamicClient = func(_ []byte) (dynamic.Interface, error) {
+		t.Errorf("expected the kubeconfig to be rejected before creating a client")
+
+		return nil, errors.New("unexpected client")
+	}
+
+	for name := range kubeconfigs {
+		name := name
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl := `password: '{{ fromRemoteSecret "spoke" "` + name + `" "app" "app-secret" "password" }}'`
+
+			_, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+			}
+		})
+	}
+}
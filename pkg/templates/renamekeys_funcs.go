@@ -0,0 +1,55 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+
+	"github.com/spf13/cast"
+)
+
+// renameKeys returns a copy of the input map with the keys renamed based on the mapping of old keys to new keys. Keys
+// that aren't in the mapping are kept as is, and mapping entries for keys that aren't in the input are ignored. The
+// keys can be swapped, but an error is returned if two keys would result in the same key, such as when a key is
+// renamed to a key that is kept.
+func renameKeys(object interface{}, mapping interface{}) (map[string]interface{}, error) {
+	objectMap, err := cast.ToStringMapE(object)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the input must be a map: %w", ErrInvalidInput, err)
+	}
+
+	renames, err := cast.ToStringMapStringE(mapping)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the mapping must be a map of strings: %w", ErrInvalidInput, err)
+	}
+
+	renamed := make(map[string]interface{}, len(objectMap))
+	// The original key of each key in the result is tracked to report collisions
+	sources := make(map[string]string, len(objectMap))
+
+	for key, value := range objectMap {
+		newKey, ok := renames[key]
+		if !ok {
+			newKey = key
+		} else if newKey == "" {
+			return nil, fmt.Errorf("%w: the key %s cannot be renamed to an empty key", ErrInvalidInput, key)
+		}
+
+		if source, exists := sources[newKey]; exists {
+			first, second := source, key
+			if first > second {
+				first, second = second, first
+			}
+
+			return nil, fmt.Errorf(
+				"%w: the keys %s and %s would both be the key %s after renaming",
+				ErrInvalidInput, first, second, newKey,
+			)
+		}
+
+		sources[newKey] = key
+		renamed[newKey] = value
+	}
+
+	return renamed, nil
+}
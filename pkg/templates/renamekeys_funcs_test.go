@@ -0,0 +1,83 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRenameKeys(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		object      interface{}
+		mapping     interface{}
+		expected    map[string]interface{}
+		expectedErr error
+	}{
+		"partial_rename": {
+			object:   map[string]interface{}{"host": "db.example.com", "port": "5432", "tls": true},
+			mapping:  map[string]interface{}{"host": "DB_HOST", "port": "DB_PORT", "user": "DB_USER"},
+			expected: map[string]interface{}{"DB_HOST": "db.example.com", "DB_PORT": "5432", "tls": true},
+		},
+		"swap": {
+			object:   map[string]interface{}{"a": "1", "b": "2"},
+			mapping:  map[string]interface{}{"a": "b", "b": "a"},
+			expected: map[string]interface{}{"a": "2", "b": "1"},
+		},
+		"same_name": {
+			object:   map[string]interface{}{"a": "1"},
+			mapping:  map[string]interface{}{"a": "a"},
+			expected: map[string]interface{}{"a": "1"},
+		},
+		"empty_mapping": {
+			object:   map[string]interface{}{"a": "1"},
+			mapping:  map[string]interface{}{},
+			expected: map[string]interface{}{"a": "1"},
+		},
+		"collision_with_kept_key": {
+			object:      map[string]interface{}{"host": "a", "DB_HOST": "b"},
+			mapping:     map[string]interface{}{"host": "DB_HOST"},
+			expectedErr: ErrInvalidInput,
+		},
+		"collision_of_renamed_keys": {
+			object:      map[string]interface{}{"host": "a", "hostname": "b"},
+			mapping:     map[string]interface{}{"host": "DB_HOST", "hostname": "DB_HOST"},
+			expectedErr: ErrInvalidInput,
+		},
+		"empty_new_key": {
+			object:      map[string]interface{}{"host": "a"},
+			mapping:     map[string]interface{}{"host": ""},
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid_object": {
+			object:      "host",
+			mapping:     map[string]interface{}{},
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid_mapping": {
+			object:      map[string]interface{}{"host": "a"},
+			mapping:     []interface{}{"host"},
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := renameKeys(test.object, test.mapping)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr == nil && !reflect.DeepEqual(val, test.expected) {
+				t.Fatalf("expected : %v , got : %v", test.expected, val)
+			}
+		})
+	}
+}
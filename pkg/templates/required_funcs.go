@@ -0,0 +1,21 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+)
+
+// required returns the input value unchanged if it isn't nil or an empty string. Otherwise, the input message is
+// returned as an error to abort template resolution, similar to the Helm "required" function.
+func required(message string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, errors.New(message)
+	}
+
+	if str, ok := value.(string); ok && str == "" {
+		return nil, errors.New(message)
+	}
+
+	return value, nil
+}
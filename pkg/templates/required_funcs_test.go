@@ -0,0 +1,49 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequired(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		value       interface{}
+		expectedErr bool
+	}{
+		"string":       {"value", false},
+		"empty_string": {"", true},
+		"nil":          {nil, true},
+		"zero":         {0, false},
+		"false":        {false, false},
+		"empty_map":    {map[string]interface{}{}, false},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := required("the value is required", test.value)
+			if test.expectedErr {
+				if err == nil || err.Error() != "the value is required" {
+					t.Fatalf("expected err: the value is required got err: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(val, test.value) {
+				t.Fatalf("expected : %v , got : %v", test.value, val)
+			}
+		})
+	}
+}
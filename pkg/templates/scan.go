@@ -0,0 +1,115 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// scanStringLiterals matches interpreted strings, raw strings, and character constants in a template action so
+	// that their contents are not mistaken for function names.
+	scanStringLiterals = regexp.MustCompile("\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`|'(?:[^'\\\\]|\\\\.)*'")
+	// scanIdentifiers matches identifiers that are not fields, variables, or part of another word.
+	scanIdentifiers = regexp.MustCompile(`(?:^|[^\w.$])([A-Za-z_]\w*)`)
+	// scanEncryption matches the template actions that generate encrypted values.
+	scanEncryption = regexp.MustCompile(
+		`^(\s*fromSecret(?:First)?\s+.*|\s*(?:copySecretData|fromRemoteSecret|secretData)\s+.*|.*\|\s*protect\s*)$`,
+	)
+)
+
+// scanKeywords are the identifiers in template actions that are not function calls.
+var scanKeywords = []string{
+	"block", "break", "continue", "define", "else", "end", "false", "if", "nil", "range", "template", "true", "with",
+}
+
+// TemplateAction is a template action found by ScanTemplates.
+type TemplateAction struct {
+	// Line is the 1-based line of the start delimiter.
+	Line int
+	// Column is the 1-based column of the start delimiter.
+	Column int
+	// Text is the template action including the delimiters. If the action is not closed with a stop delimiter, this
+	// is the rest of the input.
+	Text string
+}
+
+// TemplateScan is the result of ScanTemplates.
+type TemplateScan struct {
+	// Actions are the template actions in the order they appear in the input.
+	Actions []TemplateAction
+	// Functions are the sorted unique names of the functions called in the template actions.
+	Functions []string
+	// EncryptedValues is the number of "$ocm_encrypted" values in the input.
+	EncryptedValues int
+	// UsesEncryption is true if a template action would generate an encrypted value.
+	UsesEncryption bool
+}
+
+// ScanTemplates scans the input for template actions and "$ocm_encrypted" values without parsing or resolving the
+// templates. If the startDelim or stopDelim arguments are empty strings, the default delimiters of "{{" and "}}" are
+// used.
+func ScanTemplates(input []byte, startDelim string, stopDelim string) TemplateScan {
+	if startDelim == "" {
+		startDelim = defaultStartDelim
+	}
+
+	if stopDelim == "" {
+		stopDelim = defaultStopDelim
+	}
+
+	inputStr := string(input)
+	scan := TemplateScan{
+		Actions:         []TemplateAction{},
+		Functions:       []string{},
+		EncryptedValues: strings.Count(inputStr, protectedPrefix),
+	}
+
+	offset := 0
+
+	for {
+		start := strings.Index(inputStr[offset:], startDelim)
+		if start == -1 {
+			break
+		}
+
+		start += offset
+		innerStart := start + len(startDelim)
+		end := len(inputStr)
+		inner := inputStr[innerStart:]
+
+		stop := strings.Index(inputStr[innerStart:], stopDelim)
+		if stop != -1 {
+			end = innerStart + stop + len(stopDelim)
+			inner = inputStr[innerStart : innerStart+stop]
+		}
+
+		line := strings.Count(inputStr[:start], "\n") + 1
+		column := len([]rune(inputStr[strings.LastIndex(inputStr[:start], "\n")+1:start])) + 1
+
+		scan.Actions = append(scan.Actions, TemplateAction{Line: line, Column: column, Text: inputStr[start:end]})
+
+		if scanEncryption.MatchString(inner) {
+			scan.UsesEncryption = true
+		}
+
+		// Comments don't call any functions
+		if !strings.HasPrefix(strings.TrimLeft(inner, " -"), "/*") {
+			code := scanStringLiterals.ReplaceAllString(inner, `""`)
+
+			for _, match := range scanIdentifiers.FindAllStringSubmatch(code, -1) {
+				if !slices.Contains(scanKeywords, match[1]) && !slices.Contains(scan.Functions, match[1]) {
+					scan.Functions = append(scan.Functions, match[1])
+				}
+			}
+		}
+
+		offset = end
+	}
+
+	slices.Sort(scan.Functions)
+
+	return scan
+}
@@ -0,0 +1,105 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanTemplates(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input      string
+		startDelim string
+		stopDelim  string
+		expected   TemplateScan
+	}{
+		"no_templates": {
+			input: "key: value",
+			expected: TemplateScan{
+				Actions:   []TemplateAction{},
+				Functions: []string{},
+			},
+		},
+		"actions": {
+			input: "key: '{{ fromConfigMap \"ns\" \"name\" \"key\" | toInt }}'\n" +
+				"list:\n  {{- range $i, $v := until 2 }}\n  - '{{ $v | printf \"lookup %d\" }}'\n  {{- end }}",
+			expected: TemplateScan{
+				Actions: []TemplateAction{
+					{Line: 1, Column: 7, Text: `{{ fromConfigMap "ns" "name" "key" | toInt }}`},
+					{Line: 3, Column: 3, Text: `{{- range $i, $v := until 2 }}`},
+					{Line: 4, Column: 6, Text: `{{ $v | printf "lookup %d" }}`},
+					{Line: 5, Column: 3, Text: `{{- end }}`},
+				},
+				Functions: []string{"fromConfigMap", "printf", "toInt", "until"},
+			},
+		},
+		"fields_and_comments": {
+			input: "{{/* lookup is not called */}}{{ .ManagedClusterName | lower }}{{ if (.Labels.env) }}{{ end }}",
+			expected: TemplateScan{
+				Actions: []TemplateAction{
+					{Line: 1, Column: 1, Text: "{{/* lookup is not called */}}"},
+					{Line: 1, Column: 31, Text: "{{ .ManagedClusterName | lower }}"},
+					{Line: 1, Column: 64, Text: "{{ if (.Labels.env) }}"},
+					{Line: 1, Column: 86, Text: "{{ end }}"},
+				},
+				Functions: []string{"lower"},
+			},
+		},
+		"encryption": {
+			input: "a: '{{ fromSecret \"ns\" \"name\" \"key\" }}'\nb: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==\n" +
+				"c: $ocm_encrypted:rBaGZbpT4WOXZzFI+XBrgg==",
+			expected: TemplateScan{
+				Actions: []TemplateAction{
+					{Line: 1, Column: 5, Text: `{{ fromSecret "ns" "name" "key" }}`},
+				},
+				Functions:       []string{"fromSecret"},
+				EncryptedValues: 2,
+				UsesEncryption:  true,
+			},
+		},
+		"protect": {
+			input: `a: '{{ "value" | protect }}'`,
+			expected: TemplateScan{
+				Actions:        []TemplateAction{{Line: 1, Column: 5, Text: `{{ "value" | protect }}`}},
+				Functions:      []string{"protect"},
+				UsesEncryption: true,
+			},
+		},
+		"custom_delimiters": {
+			input: "a: '{{hub fromSecret \"ns\" \"name\" \"key\" hub}}'\n" +
+				"b: '{{ lookup \"v1\" \"Pod\" \"\" \"\" }}'",
+			startDelim: "{{hub",
+			stopDelim:  "hub}}",
+			expected: TemplateScan{
+				Actions: []TemplateAction{
+					{Line: 1, Column: 5, Text: `{{hub fromSecret "ns" "name" "key" hub}}`},
+				},
+				Functions:      []string{"fromSecret"},
+				UsesEncryption: true,
+			},
+		},
+		"unclosed": {
+			input: "a: '{{ lookup \"v1\"",
+			expected: TemplateScan{
+				Actions:   []TemplateAction{{Line: 1, Column: 5, Text: `{{ lookup "v1"`}},
+				Functions: []string{"lookup"},
+			},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			scan := ScanTemplates([]byte(test.input), test.startDelim, test.stopDelim)
+			if !reflect.DeepEqual(scan, test.expected) {
+				t.Fatalf("expected : %+v , got : %+v", test.expected, scan)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+const (
+	// schemaValidationFieldManager is the field manager of the server-side apply dry run requests.
+	schemaValidationFieldManager = "go-template-utils-validation"
+	// schemaValidationDefaultName is the name used to validate an objectDefinition without a name.
+	schemaValidationDefaultName = "go-template-utils-validation"
+	// schemaValidationDefaultNamespace is the namespace used to validate a namespaced objectDefinition without a
+	// namespace, such as when the namespaceSelector of a ConfigurationPolicy is used.
+	schemaValidationDefaultNamespace = "default"
+)
+
+// collectObjectDefinitions recursively finds the "objectDefinition" values in the input that have an apiVersion and
+// kind. If the input itself has an apiVersion and kind, it is included first.
+func collectObjectDefinitions(value interface{}, isObjectDefinition bool) []map[string]interface{} {
+	objectDefinitions := []map[string]interface{}{}
+
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if isObjectDefinition {
+			apiVersion, _ := typedValue["apiVersion"].(string)
+			kind, _ := typedValue["kind"].(string)
+
+			if apiVersion != "" && kind != "" {
+				objectDefinitions = append(objectDefinitions, typedValue)
+			}
+		}
+
+		for key, child := range typedValue {
+			objectDefinitions = append(objectDefinitions, collectObjectDefinitions(child, key == "objectDefinition")...)
+		}
+	case []interface{}:
+		for _, child := range typedValue {
+			objectDefinitions = append(objectDefinitions, collectObjectDefinitions(child, false)...)
+		}
+	}
+
+	return objectDefinitions
+}
+
+// validateAgainstSchema validates the objectDefinitions in the resolved JSON against the OpenAPI schema of their kind
+// on the API server using server-side apply dry run requests with strict field validation. The returned error wraps
+// ErrSchemaValidation and contains every validation failure.
+func (t *TemplateResolver) validateAgainstSchema(resolvedJSON []byte) error {
+	if t.dynamicClient == nil {
+		return fmt.Errorf(
+			"%w: schema validation requires a Kubernetes client, which is not available when using "+
+				"NewResolverWithDynamicWatcher",
+			ErrInvalidInput,
+		)
+	}
+
+	var resolved interface{}
+
+	err := json.Unmarshal(resolvedJSON, &resolved)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal the resolved template for schema validation: %w", err)
+	}
+
+	validationErrs := []error{}
+
+	for _, objectDefinition := range collectObjectDefinitions(resolved, true) {
+		obj := unstructured.Unstructured{Object: objectDefinition}
+
+		err := t.validateObjectAgainstSchema(obj.DeepCopy())
+		if err != nil {
+			validationErrs = append(validationErrs, fmt.Errorf(
+				"%w: the %s %s failed validation: %w", ErrSchemaValidation, obj.GetKind(), obj.GetName(), err,
+			))
+		}
+	}
+
+	return errors.Join(validationErrs...)
+}
+
+// validateObjectAgainstSchema performs a server-side apply dry run request with strict field validation of the input
+// object.
+func (t *TemplateResolver) validateObjectAgainstSchema(obj *unstructured.Unstructured) error {
+	klog.V(2).Infof("validateAgainstSchema for the %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+
+	scopedGVRObj, err := t.getScopedGVR(obj.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	if obj.GetName() == "" {
+		obj.SetName(schemaValidationDefaultName)
+	}
+
+	var resourceClient dynamic.ResourceInterface
+
+	if scopedGVRObj.Namespaced {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(schemaValidationDefaultNamespace)
+		}
+
+		resourceClient = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource).Namespace(obj.GetNamespace())
+	} else {
+		obj.SetNamespace("")
+
+		resourceClient = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource)
+	}
+
+	objJSON, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	force := true
+
+	_, err = resourceClient.Patch(context.TODO(), obj.GetName(), types.ApplyPatchType, objJSON, metav1.PatchOptions{
+		DryRun:          []string{metav1.DryRunAll},
+		FieldManager:    schemaValidationFieldManager,
+		FieldValidation: "Strict",
+		Force:           &force,
+	})
+
+	return err
+}
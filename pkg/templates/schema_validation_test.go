@@ -0,0 +1,127 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCollectObjectDefinitions(t *testing.T) {
+	t.Parallel()
+
+	configMap := map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}
+	policy := map[string]interface{}{
+		"apiVersion": "policy.open-cluster-management.io/v1",
+		"kind":       "ConfigurationPolicy",
+		"spec": map[string]interface{}{
+			"object-templates": []interface{}{
+				map[string]interface{}{"objectDefinition": configMap},
+				map[string]interface{}{"objectDefinition": map[string]interface{}{"kind": "Secret"}},
+			},
+		},
+	}
+
+	objectDefinitions := collectObjectDefinitions(policy, true)
+
+	expected := []map[string]interface{}{policy, configMap}
+	if !reflect.DeepEqual(objectDefinitions, expected) {
+		t.Fatalf("expected : %v , got : %v", expected, objectDefinitions)
+	}
+
+	objectDefinitions = collectObjectDefinitions(map[string]interface{}{"key": configMap}, true)
+	if len(objectDefinitions) != 0 {
+		t.Fatalf("expected no objectDefinitions, got : %v", objectDefinitions)
+	}
+}
+
+func TestValidateAgainstSchemaNoClient(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{}
+
+	err := resolver.validateAgainstSchema([]byte(`{"apiVersion":"v1","kind":"ConfigMap"}`))
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+	}
+}
+
+func TestResolveTemplateValidateAgainstSchemaMissingKind(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: '{{ \"my\" }}-widget'\n"
+	options := &ResolveOptions{InputIsYAML: true, ValidateAgainstSchema: true}
+
+	tmplResult, err := resolver.ResolveTemplate([]byte(tmpl), nil, options)
+	if !errors.Is(err, ErrSchemaValidation) || !errors.Is(err, ErrMissingAPIResource) {
+		t.Fatalf("expected an ErrSchemaValidation and ErrMissingAPIResource error, got : %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "the Widget my-widget failed validation") {
+		t.Fatalf("expected the error to identify the object, got : %v", err)
+	}
+
+	expectedJSON := `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"my-widget"}}`
+	if string(tmplResult.ResolvedJSON) != expectedJSON {
+		t.Fatalf("expected : %s , got : %s", expectedJSON, tmplResult.ResolvedJSON)
+	}
+}
+
+func TestResolveTemplateValidateAgainstSchema(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl   string
+		expectedErr string
+	}{
+		"valid": {
+			inputTmpl: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: validate\n  namespace: testns\n" +
+				"data:\n  key: '{{ \"value\" }}'\n",
+		},
+		"unknown_field": {
+			inputTmpl: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: validate\n  namespace: testns\n" +
+				"spec:\n  key: '{{ \"value\" }}'\n",
+			expectedErr: `unknown field "spec"`,
+		},
+		"invalid_objectDefinition": {
+			inputTmpl: "spec:\n  object-templates:\n  - objectDefinition:\n      apiVersion: v1\n" +
+				"      kind: ConfigMap\n      data:\n        key: {{ 6 }}\n",
+			expectedErr: "the ConfigMap",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{InputIsYAML: true, ValidateAgainstSchema: true}
+
+			_, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrSchemaValidation) || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected an error containing %q, got : %v", test.expectedErr, err)
+			}
+		})
+	}
+}
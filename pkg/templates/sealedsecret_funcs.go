@@ -0,0 +1,106 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+const (
+	sealedSecretAPIVersion string = "bitnami.com/v1alpha1"
+	// These are the annotations that the SealedSecrets controller uses to relax the scope of a SealedSecret, which is
+	// strict by default.
+	sealedSecretClusterWideAnnotation   string = "sealedsecrets.bitnami.com/cluster-wide"
+	sealedSecretNamespaceWideAnnotation string = "sealedsecrets.bitnami.com/namespace-wide"
+)
+
+func (t *TemplateResolver) fromSealedSecretHelper(
+	options *ResolveOptions, templateResult *TemplateResult,
+) func(string, string) (map[string]interface{}, error) {
+	return func(namespace string, name string) (map[string]interface{}, error) {
+		return t.fromSealedSecret(options, templateResult, namespace, name)
+	}
+}
+
+// fromSealedSecret returns the metadata of the Secret that the SealedSecrets controller creates from the given
+// SealedSecret, so that templates can coordinate with it without decrypting any values. The returned map has the
+// following keys:
+//
+//   - name and namespace are of the target Secret, which default to those of the SealedSecret.
+//   - type is the type of the target Secret, which defaults to "Opaque".
+//   - keys is the sorted list of the keys in spec.encryptedData.
+//   - scope is "cluster-wide", "namespace-wide", or "strict" based on the SealedSecret annotations.
+//
+// An empty map is returned if the SealedSecret or the SealedSecret API doesn't exist.
+func (t *TemplateResolver) fromSealedSecret(
+	options *ResolveOptions, templateResult *TemplateResult, namespace string, name string,
+) (map[string]interface{}, error) {
+	klog.V(2).Infof("fromSealedSecret :  %v, %v", namespace, name)
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: the name must be specified", ErrInvalidInput)
+	}
+
+	sealedSecret, err := t.getOrList(options, templateResult, sealedSecretAPIVersion, "SealedSecret", namespace, name)
+	if err != nil {
+		if errors.Is(err, ErrMissingAPIResource) || apierrors.IsNotFound(err) {
+			return map[string]interface{}{}, nil
+		}
+
+		return nil, err
+	}
+
+	obj := unstructured.Unstructured{Object: sealedSecret}
+
+	secretName, _, _ := unstructured.NestedString(sealedSecret, "spec", "template", "metadata", "name")
+	if secretName == "" {
+		secretName = obj.GetName()
+	}
+
+	secretNamespace, _, _ := unstructured.NestedString(sealedSecret, "spec", "template", "metadata", "namespace")
+	if secretNamespace == "" {
+		secretNamespace = obj.GetNamespace()
+	}
+
+	secretType, _, _ := unstructured.NestedString(sealedSecret, "spec", "template", "type")
+	if secretType == "" {
+		secretType = "Opaque"
+	}
+
+	encryptedData, _, _ := unstructured.NestedMap(sealedSecret, "spec", "encryptedData")
+
+	keys := make([]string, 0, len(encryptedData))
+	for key := range encryptedData {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	keyList := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		keyList = append(keyList, key)
+	}
+
+	scope := "strict"
+
+	switch {
+	case obj.GetAnnotations()[sealedSecretClusterWideAnnotation] == "true":
+		scope = "cluster-wide"
+	case obj.GetAnnotations()[sealedSecretNamespaceWideAnnotation] == "true":
+		scope = "namespace-wide"
+	}
+
+	return map[string]interface{}{
+		"name":      secretName,
+		"namespace": secretNamespace,
+		"type":      secretType,
+		"keys":      keyList,
+		"scope":     scope,
+	}, nil
+}
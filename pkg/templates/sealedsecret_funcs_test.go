@@ -0,0 +1,172 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFromSealedSecret(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: db-creds
+  namespace: app
+spec:
+  encryptedData:
+    username: AgBy3i4OJSWK+PiTySYZZA==
+    password: AgCtr8OJSWK+PiTySYZZA==
+  template:
+    metadata:
+      name: database
+    type: kubernetes.io/basic-auth
+---
+apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: shared
+  namespace: app
+  annotations:
+    sealedsecrets.bitnami.com/cluster-wide: "true"
+spec:
+  encryptedData:
+    token: AgAKAoiQm7QDLrSWKg==
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	sealedSecretsResolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	noSealedSecretsResolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		resolver       *TemplateResolver
+		options        *ResolveOptions
+		namespace      string
+		name           string
+		expectedResult map[string]interface{}
+		expectedErr    error
+	}{
+		"template": {
+			resolver:  sealedSecretsResolver,
+			options:   &ResolveOptions{},
+			namespace: "app",
+			name:      "db-creds",
+			expectedResult: map[string]interface{}{
+				"name":      "database",
+				"namespace": "app",
+				"type":      "kubernetes.io/basic-auth",
+				"keys":      []interface{}{"password", "username"},
+				"scope":     "strict",
+			},
+		},
+		"defaults": {
+			resolver:  sealedSecretsResolver,
+			options:   &ResolveOptions{},
+			namespace: "app",
+			name:      "shared",
+			expectedResult: map[string]interface{}{
+				"name":      "shared",
+				"namespace": "app",
+				"type":      "Opaque",
+				"keys":      []interface{}{"token"},
+				"scope":     "cluster-wide",
+			},
+		},
+		"not_found": {
+			resolver:       sealedSecretsResolver,
+			options:        &ResolveOptions{},
+			namespace:      "app",
+			name:           "missing",
+			expectedResult: map[string]interface{}{},
+		},
+		"missing_api": {
+			resolver:       noSealedSecretsResolver,
+			options:        &ResolveOptions{},
+			namespace:      "app",
+			name:           "db-creds",
+			expectedResult: map[string]interface{}{},
+		},
+		"restricted_namespace": {
+			resolver:    sealedSecretsResolver,
+			options:     &ResolveOptions{LookupNamespace: "other"},
+			namespace:   "app",
+			name:        "db-creds",
+			expectedErr: ErrRestrictedNamespace,
+		},
+		"no_name": {
+			resolver:    sealedSecretsResolver,
+			options:     &ResolveOptions{},
+			namespace:   "app",
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := test.resolver.fromSealedSecret(test.options, &TemplateResult{}, test.namespace, test.name)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedResult) {
+				t.Fatalf("expected : %v , got : %v", test.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateFromSealedSecret(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: db-creds
+  namespace: app
+spec:
+  encryptedData:
+    password: AgCtr8OJSWK+PiTySYZZA==
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `secretName: '{{ (fromSealedSecret "app" "db-creds").name }}'` + "\n"
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"secretName":"db-creds"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("expected : %s , got : %s", expected, string(result.ResolvedJSON))
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// parseSemver parses the input version, which may have a leading "v" and be missing the minor or patch version.
+func parseSemver(version string) (*semver.Version, error) {
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the version %q is not a valid semantic version: %w", ErrInvalidInput, version, err)
+	}
+
+	return parsed, nil
+}
+
+// semverCanonical returns the input version in the canonical semantic version format without a leading "v" and with
+// any missing minor or patch version set to 0. Pre-release and build metadata are kept. For example, "v4.10" is
+// "4.10.0".
+func semverCanonical(version string) (string, error) {
+	parsed, err := parseSemver(version)
+	if err != nil {
+		return "", err
+	}
+
+	return parsed.String(), nil
+}
+
+// semverSatisfies returns true if the input version satisfies the version constraint, such as ">= 4.10, < 4.12".
+// The version is the last argument so that it can be piped. As with the semver library, pre-release versions only
+// satisfy constraints that include a pre-release.
+func semverSatisfies(constraint string, version string) (bool, error) {
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, fmt.Errorf(
+			"%w: the version constraint %q is invalid: %w", ErrInvalidInput, constraint, err,
+		)
+	}
+
+	parsed, err := parseSemver(version)
+	if err != nil {
+		return false, err
+	}
+
+	return constraints.Check(parsed), nil
+}
@@ -0,0 +1,97 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSemverCanonical(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		version     string
+		expected    string
+		expectedErr bool
+	}{
+		"v_prefix_missing_patch": {"v4.10", "4.10.0", false},
+		"canonical":              {"4.10.0", "4.10.0", false},
+		"missing_minor":          {"4", "4.0.0", false},
+		"pre-release":            {"v4.11.0-rc.1", "4.11.0-rc.1", false},
+		"pre-release_metadata":   {"4.11-ec.2+build.5", "4.11.0-ec.2+build.5", false},
+		"empty":                  {"", "", true},
+		"invalid":                {"four.ten", "", true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := semverCanonical(test.version)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestSemverSatisfies(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		constraint  string
+		version     string
+		expected    bool
+		expectedErr bool
+	}{
+		"v_prefix_missing_patch":    {">= 4.10", "v4.10", true, false},
+		"canonical":                 {">= 4.10, < 4.12", "4.10.0", true, false},
+		"not_satisfied":             {"< 4.10", "4.10.0", false, false},
+		"tilde":                     {"~4.10", "4.10.7", true, false},
+		"pre-release_excluded":      {">= 4.10", "4.11.0-rc.1", false, false},
+		"pre-release_constraint":    {">= 4.11.0-0", "4.11.0-rc.1", true, false},
+		"pre-release_below_release": {"< 4.11.0-0", "4.11.0-rc.1", false, false},
+		"invalid_constraint":        {">= four", "4.10.0", false, true},
+		"invalid_version":           {">= 4.10", "latest", false, true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := semverSatisfies(test.constraint, test.version)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %v , got : %v", test.expected, val)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	yaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// snapshotBaseResources are the API resources used by the built-in template functions. These are always available
+// when resolving against a snapshot so that lookups of these kinds return no results rather than a missing API
+// resource error when the snapshot doesn't contain any objects of that kind.
+var snapshotBaseResources = []metav1.APIResource{
+	{Group: "", Version: "v1", Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+	{Group: "", Version: "v1", Name: "secrets", Kind: "Secret", Namespaced: true},
+	{Group: "", Version: "v1", Name: "namespaces", Kind: "Namespace", Namespaced: false},
+	{Group: "", Version: "v1", Name: "nodes", Kind: "Node", Namespaced: false},
+	{
+		Group:      "cluster.open-cluster-management.io",
+		Version:    "v1alpha1",
+		Name:       "clusterclaims",
+		Kind:       "ClusterClaim",
+		Namespaced: false,
+	},
+}
+
+// NewResolverFromSnapshot creates a new (non-caching) TemplateResolver instance which resolves templates entirely in
+// memory against the input objects rather than an API server. This is useful for hermetic testing of templates. The
+// objects are read-only, so "canLookup" only allows reading them, ApplyDefaults returns them as is, and
+// ValidateAgainstSchema is not supported since there are no schemas.
+//
+//   - objects is the set of objects that the template functions such as "lookup" and "fromConfigMap" can access. The
+//     API resources available are determined from the objects as well as the kinds used by the built-in template
+//     functions (ConfigMap, Secret, Namespace, Node, and ClusterClaim). A kind is considered namespaced if any of its
+//     objects has a namespace set.
+//
+//   - config is the Config instance for configuring optional values for template processing.
+func NewResolverFromSnapshot(objects []unstructured.Unstructured, config Config) (*TemplateResolver, error) {
+	dynamicClient, discoveryClient, err := newSnapshotClients(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResolverWithClients(dynamicClient, discoveryClient, config)
+}
+
+// newSnapshotClients returns the in-memory dynamic and discovery clients serving the input objects for
+// NewResolverFromSnapshot.
+func newSnapshotClients(
+	objects []unstructured.Unstructured,
+) (*snapshotDynamicClient, *snapshotDiscoveryClient, error) {
+	resources := map[schema.GroupVersionKind]metav1.APIResource{}
+
+	for _, resource := range snapshotBaseResources {
+		gvk := schema.GroupVersionKind{Group: resource.Group, Version: resource.Version, Kind: resource.Kind}
+		resources[gvk] = resource
+	}
+
+	for i := range objects {
+		gvk := objects[i].GroupVersionKind()
+
+		if gvk.Version == "" || gvk.Kind == "" {
+			return nil, nil, fmt.Errorf(
+				"%w: the object at index %d must have an apiVersion and kind", ErrInvalidInput, i,
+			)
+		}
+
+		resource, known := resources[gvk]
+		if !known {
+			plural, _ := meta.UnsafeGuessKindToResource(gvk)
+
+			resource = metav1.APIResource{
+				Group:   gvk.Group,
+				Version: gvk.Version,
+				Name:    plural.Resource,
+				Kind:    gvk.Kind,
+			}
+		}
+
+		if objects[i].GetNamespace() != "" {
+			resource.Namespaced = true
+		}
+
+		resources[gvk] = resource
+	}
+
+	dynamicClient := &snapshotDynamicClient{
+		listKinds: make(map[schema.GroupVersionResource]string, len(resources)),
+		objects:   map[schema.GroupVersionResource][]*unstructured.Unstructured{},
+	}
+	discoveryClient := &snapshotDiscoveryClient{}
+	resourceLists := map[string]*metav1.APIResourceList{}
+
+	for gvk, resource := range resources {
+		gv := gvk.GroupVersion()
+		dynamicClient.listKinds[gv.WithResource(resource.Name)] = gvk.Kind + "List"
+
+		resourceList, ok := resourceLists[gv.String()]
+		if !ok {
+			resourceList = &metav1.APIResourceList{GroupVersion: gv.String()}
+			resourceLists[gv.String()] = resourceList
+			discoveryClient.resourceLists = append(discoveryClient.resourceLists, resourceList)
+		}
+
+		resource.Verbs = metav1.Verbs{"get", "list"}
+		resourceList.APIResources = append(resourceList.APIResources, resource)
+	}
+
+	for i := range objects {
+		gvk := objects[i].GroupVersionKind()
+		gvr := gvk.GroupVersion().WithResource(resources[gvk].Name)
+
+		dynamicClient.objects[gvr] = append(dynamicClient.objects[gvr], objects[i].DeepCopy())
+	}
+
+	// Sort the objects and API resources so that the results don't depend on the input or map iteration order
+	for _, gvrObjects := range dynamicClient.objects {
+		slices.SortStableFunc(gvrObjects, func(a, b *unstructured.Unstructured) int {
+			return cmp.Or(
+				cmp.Compare(a.GetNamespace(), b.GetNamespace()), cmp.Compare(a.GetName(), b.GetName()),
+			)
+		})
+	}
+
+	slices.SortFunc(discoveryClient.resourceLists, func(a, b *metav1.APIResourceList) int {
+		return cmp.Compare(a.GroupVersion, b.GroupVersion)
+	})
+
+	for _, resourceList := range discoveryClient.resourceLists {
+		slices.SortFunc(resourceList.APIResources, func(a, b metav1.APIResource) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+	}
+
+	return dynamicClient, discoveryClient, nil
+}
+
+// ParseResourceBundle parses a multi-document YAML resource bundle into a list of objects that can be passed to
+// NewResolverFromSnapshot. Empty documents are ignored.
+func ParseResourceBundle(bundle []byte) ([]unstructured.Unstructured, error) {
+	objects := []unstructured.Unstructured{}
+	decoder := yaml.NewDecoder(bytes.NewReader(bundle))
+
+	for i := 0; ; i++ {
+		var doc interface{}
+
+		err := decoder.Decode(&doc)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to parse the resource bundle: %w", err)
+		}
+
+		if doc == nil {
+			continue
+		}
+
+		// Convert to JSON so that the values have the types expected in unstructured objects (e.g. int64)
+		docJSON, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the resource bundle document at index %d: %w", i, err)
+		}
+
+		obj := unstructured.Unstructured{}
+
+		err = obj.UnmarshalJSON(docJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the resource bundle document at index %d: %w", i, err)
+		}
+
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
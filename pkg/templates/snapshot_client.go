@@ -0,0 +1,314 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/openapi"
+	restclient "k8s.io/client-go/rest"
+)
+
+// snapshotDynamicClient is a read-only, in-memory dynamic.Interface implementation for NewResolverFromSnapshot. Only
+// the requests made by the template functions are supported: get, list, SelfSubjectAccessReview creation, and update
+// dry runs. All other requests return a method not supported error.
+type snapshotDynamicClient struct {
+	// listKinds maps the resources to the list kind returned when listing them
+	listKinds map[schema.GroupVersionResource]string
+	// objects maps the resources to their objects sorted by namespace and then name
+	objects map[schema.GroupVersionResource][]*unstructured.Unstructured
+}
+
+var _ dynamic.Interface = &snapshotDynamicClient{}
+
+func (c *snapshotDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &snapshotResourceClient{client: c, resource: resource}
+}
+
+// snapshotResourceClient is the dynamic.NamespaceableResourceInterface implementation of snapshotDynamicClient.
+type snapshotResourceClient struct {
+	client    *snapshotDynamicClient
+	resource  schema.GroupVersionResource
+	namespace string
+}
+
+var _ dynamic.NamespaceableResourceInterface = &snapshotResourceClient{}
+
+func (r *snapshotResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	return &snapshotResourceClient{client: r.client, resource: r.resource, namespace: namespace}
+}
+
+// find returns the object in the snapshot with the input name in the namespace of the client or nil if there isn't
+// one.
+func (r *snapshotResourceClient) find(name string) *unstructured.Unstructured {
+	for _, obj := range r.client.objects[r.resource] {
+		if obj.GetNamespace() == r.namespace && obj.GetName() == name {
+			return obj
+		}
+	}
+
+	return nil
+}
+
+func (r *snapshotResourceClient) unsupported(verb string) error {
+	return apierrors.NewMethodNotSupported(r.resource.GroupResource(), verb)
+}
+
+func (r *snapshotResourceClient) Get(
+	_ context.Context, name string, _ metav1.GetOptions, subresources ...string,
+) (*unstructured.Unstructured, error) {
+	if len(subresources) != 0 {
+		return nil, r.unsupported("get")
+	}
+
+	obj := r.find(name)
+	if obj == nil {
+		return nil, apierrors.NewNotFound(r.resource.GroupResource(), name)
+	}
+
+	return obj.DeepCopy(), nil
+}
+
+// List returns the objects in the namespace of the client, or in all namespaces if it's not set, matching the label
+// selector. Paging is not supported, so all the matching objects are always returned.
+func (r *snapshotResourceClient) List(
+	_ context.Context, opts metav1.ListOptions,
+) (*unstructured.UnstructuredList, error) {
+	listKind, ok := r.client.listKinds[r.resource]
+	if !ok {
+		return nil, apierrors.NewNotFound(r.resource.GroupResource(), "")
+	}
+
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	list.SetAPIVersion(r.resource.GroupVersion().String())
+	list.SetKind(listKind)
+
+	for _, obj := range r.client.objects[r.resource] {
+		if r.namespace != "" && obj.GetNamespace() != r.namespace {
+			continue
+		}
+
+		if !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+
+		list.Items = append(list.Items, *obj.DeepCopy())
+	}
+
+	return list, nil
+}
+
+// Create only supports SelfSubjectAccessReviews, which are allowed for the get and list verbs since the snapshot
+// objects can always be read.
+func (r *snapshotResourceClient) Create(
+	_ context.Context, obj *unstructured.Unstructured, _ metav1.CreateOptions, subresources ...string,
+) (*unstructured.Unstructured, error) {
+	if r.resource != selfSubjectAccessReviewGVR || len(subresources) != 0 {
+		return nil, r.unsupported("create")
+	}
+
+	review := obj.DeepCopy()
+
+	verb, _, _ := unstructured.NestedString(review.Object, "spec", "resourceAttributes", "verb")
+
+	err := unstructured.SetNestedField(review.Object, verb == "get" || verb == "list", "status", "allowed")
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	return review, nil
+}
+
+// Update only supports dry runs, which return the input object as is since the snapshot has no defaulting.
+func (r *snapshotResourceClient) Update(
+	_ context.Context, obj *unstructured.Unstructured, opts metav1.UpdateOptions, subresources ...string,
+) (*unstructured.Unstructured, error) {
+	if !slices.Contains(opts.DryRun, metav1.DryRunAll) || len(subresources) != 0 {
+		return nil, r.unsupported("update")
+	}
+
+	if r.find(obj.GetName()) == nil {
+		return nil, apierrors.NewNotFound(r.resource.GroupResource(), obj.GetName())
+	}
+
+	return obj.DeepCopy(), nil
+}
+
+func (r *snapshotResourceClient) UpdateStatus(
+	_ context.Context, _ *unstructured.Unstructured, _ metav1.UpdateOptions,
+) (*unstructured.Unstructured, error) {
+	return nil, r.unsupported("update")
+}
+
+func (r *snapshotResourceClient) Delete(
+	_ context.Context, _ string, _ metav1.DeleteOptions, _ ...string,
+) error {
+	return r.unsupported("delete")
+}
+
+func (r *snapshotResourceClient) DeleteCollection(
+	_ context.Context, _ metav1.DeleteOptions, _ metav1.ListOptions,
+) error {
+	return r.unsupported("deletecollection")
+}
+
+func (r *snapshotResourceClient) Watch(_ context.Context, _ metav1.ListOptions) (watch.Interface, error) {
+	return nil, r.unsupported("watch")
+}
+
+func (r *snapshotResourceClient) Patch(
+	_ context.Context, _ string, _ types.PatchType, _ []byte, _ metav1.PatchOptions, _ ...string,
+) (*unstructured.Unstructured, error) {
+	return nil, r.unsupported("patch")
+}
+
+func (r *snapshotResourceClient) Apply(
+	_ context.Context, _ string, _ *unstructured.Unstructured, _ metav1.ApplyOptions, _ ...string,
+) (*unstructured.Unstructured, error) {
+	return nil, r.unsupported("patch")
+}
+
+func (r *snapshotResourceClient) ApplyStatus(
+	_ context.Context, _ string, _ *unstructured.Unstructured, _ metav1.ApplyOptions,
+) (*unstructured.Unstructured, error) {
+	return nil, r.unsupported("patch")
+}
+
+// snapshotDiscoveryClient is an in-memory discovery.DiscoveryInterface implementation for NewResolverFromSnapshot
+// that serves the API resources of the snapshot. The server version and OpenAPI schemas are not available.
+type snapshotDiscoveryClient struct {
+	resourceLists []*metav1.APIResourceList
+}
+
+var _ discovery.DiscoveryInterface = &snapshotDiscoveryClient{}
+
+func (d *snapshotDiscoveryClient) RESTClient() restclient.Interface {
+	return nil
+}
+
+func (d *snapshotDiscoveryClient) ServerGroups() (*metav1.APIGroupList, error) {
+	groupList := &metav1.APIGroupList{}
+	groupIndexes := map[string]int{}
+
+	for _, resourceList := range d.resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		groupVersion := metav1.GroupVersionForDiscovery{GroupVersion: gv.String(), Version: gv.Version}
+
+		i, ok := groupIndexes[gv.Group]
+		if !ok {
+			i = len(groupList.Groups)
+			groupIndexes[gv.Group] = i
+
+			groupList.Groups = append(groupList.Groups, metav1.APIGroup{Name: gv.Group, PreferredVersion: groupVersion})
+		}
+
+		groupList.Groups[i].Versions = append(groupList.Groups[i].Versions, groupVersion)
+	}
+
+	return groupList, nil
+}
+
+func (d *snapshotDiscoveryClient) ServerResourcesForGroupVersion(
+	groupVersion string,
+) (*metav1.APIResourceList, error) {
+	for _, resourceList := range d.resourceLists {
+		if resourceList.GroupVersion == groupVersion {
+			return resourceList.DeepCopy(), nil
+		}
+	}
+
+	return nil, &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   http.StatusNotFound,
+		Reason: metav1.StatusReasonNotFound,
+		Message: fmt.Sprintf(
+			"the server could not find the requested resource, GroupVersion %q not found", groupVersion,
+		),
+	}}
+}
+
+func (d *snapshotDiscoveryClient) ServerGroupsAndResources() (
+	[]*metav1.APIGroup, []*metav1.APIResourceList, error,
+) {
+	groupList, err := d.ServerGroups()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups := make([]*metav1.APIGroup, 0, len(groupList.Groups))
+	for i := range groupList.Groups {
+		groups = append(groups, &groupList.Groups[i])
+	}
+
+	resourceLists, err := d.ServerPreferredResources()
+
+	return groups, resourceLists, err
+}
+
+func (d *snapshotDiscoveryClient) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	resourceLists := make([]*metav1.APIResourceList, 0, len(d.resourceLists))
+	for _, resourceList := range d.resourceLists {
+		resourceLists = append(resourceLists, resourceList.DeepCopy())
+	}
+
+	return resourceLists, nil
+}
+
+func (d *snapshotDiscoveryClient) ServerPreferredNamespacedResources() ([]*metav1.APIResourceList, error) {
+	resourceLists := []*metav1.APIResourceList{}
+
+	for _, resourceList := range d.resourceLists {
+		namespacedList := &metav1.APIResourceList{GroupVersion: resourceList.GroupVersion}
+
+		for _, resource := range resourceList.APIResources {
+			if resource.Namespaced {
+				namespacedList.APIResources = append(namespacedList.APIResources, *resource.DeepCopy())
+			}
+		}
+
+		if len(namespacedList.APIResources) != 0 {
+			resourceLists = append(resourceLists, namespacedList)
+		}
+	}
+
+	return resourceLists, nil
+}
+
+func (d *snapshotDiscoveryClient) ServerVersion() (*version.Info, error) {
+	return nil, apierrors.NewMethodNotSupported(schema.GroupResource{Resource: "version"}, "get")
+}
+
+func (d *snapshotDiscoveryClient) OpenAPISchema() (*openapi_v2.Document, error) {
+	return nil, apierrors.NewMethodNotSupported(schema.GroupResource{Resource: "openapi/v2"}, "get")
+}
+
+func (d *snapshotDiscoveryClient) OpenAPIV3() openapi.Client {
+	return nil
+}
+
+func (d *snapshotDiscoveryClient) WithLegacy() discovery.DiscoveryInterface {
+	return d
+}
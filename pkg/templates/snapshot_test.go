@@ -0,0 +1,198 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const snapshotBundle = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: app
+  labels:
+    env: prod
+data:
+  replicas: "3"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+  namespace: app
+data:
+  password: cGFzc3dvcmQ=
+---
+apiVersion: cluster.open-cluster-management.io/v1alpha1
+kind: ClusterClaim
+metadata:
+  name: env
+spec:
+  value: dev
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: app
+spec:
+  replicas: 2
+---
+`
+
+// newFakeClientResolver returns a TemplateResolver with the same API resources and objects as NewResolverFromSnapshot
+// but backed by the client-go fake clients so that tests can add reactors and inspect the API requests.
+func newFakeClientResolver(
+	t *testing.T, objects []unstructured.Unstructured, config Config,
+) (*TemplateResolver, *fakedynamic.FakeDynamicClient) {
+	t.Helper()
+
+	snapshotClient, snapshotDiscovery, err := newSnapshotClients(objects)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	discoveryClient.Resources = snapshotDiscovery.resourceLists
+
+	runtimeObjects := make([]runtime.Object, 0, len(objects))
+	for i := range objects {
+		runtimeObjects = append(runtimeObjects, objects[i].DeepCopy())
+	}
+
+	dynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(), snapshotClient.listKinds, runtimeObjects...,
+	)
+
+	resolver, err := NewResolverWithClients(dynamicClient, discoveryClient, config)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	return resolver, dynamicClient
+}
+
+func TestNewResolverFromSnapshot(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if len(objects) != 4 {
+		t.Fatalf("expected 4 objects in the bundle, got %d", len(objects))
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+		expectedErr    error
+	}{
+		"fromConfigMap": {
+			inputTmpl:      `replicas: '{{ fromConfigMap "app" "app-config" "replicas" }}'`,
+			expectedResult: `{"replicas":"3"}`,
+		},
+		"lookup_not_found": {
+			inputTmpl:      `count: '{{ len (lookup "v1" "ConfigMap" "app" "other-config") }}'`,
+			expectedResult: `{"count":"0"}`,
+		},
+		"fromSecret": {
+			inputTmpl:      `password: '{{ fromSecret "app" "app-secret" "password" }}'`,
+			expectedResult: `{"password":"cGFzc3dvcmQ="}`,
+		},
+		"fromClusterClaim": {
+			inputTmpl:      `env: '{{ fromClusterClaim "env" }}'`,
+			expectedResult: `{"env":"dev"}`,
+		},
+		"lookup": {
+			inputTmpl:      `replicas: '{{ (lookup "apps/v1" "Deployment" "app" "app").spec.replicas }}'`,
+			expectedResult: `{"replicas":"2"}`,
+		},
+		"lookup_labels": {
+			inputTmpl:      `count: '{{ len (lookup "v1" "ConfigMap" "app" "" "env=prod").items }}'`,
+			expectedResult: `{"count":"1"}`,
+		},
+		"lookup_all_namespaces": {
+			inputTmpl: `names: '{{ range (lookup "v1" "ConfigMap" "" "").items }}` +
+				`{{ .metadata.namespace }}/{{ .metadata.name }},{{ end }}'`,
+			expectedResult: `{"names":"app/app-config,"}`,
+		},
+		"canLookup": {
+			inputTmpl:      `get: '{{ canLookup "v1" "Secret" "app" "app-secret" }}'`,
+			expectedResult: `{"get":"true"}`,
+		},
+		"canLookup_list": {
+			inputTmpl:      `list: '{{ canLookup "v1" "Secret" "app" "" }}'`,
+			expectedResult: `{"list":"true"}`,
+		},
+		"lookup_missing_kind": {
+			inputTmpl:   `value: '{{ lookup "v1" "Pod" "app" "app" }}'`,
+			expectedErr: ErrMissingAPIResource,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true},
+			)
+			if err != nil {
+				if test.expectedErr == nil {
+					t.Fatalf(err.Error())
+				}
+
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+				}
+
+				return
+			} else if test.expectedErr != nil {
+				t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestParseResourceBundleErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseResourceBundle([]byte("kind: ConfigMap\n---\n- not an object\n"))
+	if err == nil {
+		t.Fatal("expected an error for a document that is not an object")
+	}
+
+	_, err = ParseResourceBundle([]byte("metadata:\n  name: no-kind\n"))
+	if err == nil {
+		t.Fatal("expected an error for a document without a kind")
+	}
+
+	objects := []unstructured.Unstructured{{Object: map[string]interface{}{"kind": "ConfigMap"}}}
+
+	_, err = NewResolverFromSnapshot(objects, Config{})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected err: %s got err: %v", ErrInvalidInput, err)
+	}
+}
@@ -4,6 +4,9 @@
 package templates
 
 import (
+	"fmt"
+	"slices"
+
 	sprig "github.com/Masterminds/sprig/v3"
 )
 
@@ -85,3 +88,65 @@ func getSprigFunc(funcName string) (result interface{}) {
 func AvailableSprigFunctions() []string {
 	return append(make([]string, 0, len(exportedSprigFunctions)), exportedSprigFunctions...)
 }
+
+// unstableSprigFunctions maps the exported Sprig functions whose behavior isn't guaranteed to be the same across
+// versions of this library to a description of how it may differ.
+var unstableSprigFunctions = map[string]string{
+	"date": "The output depends on the time zone of the process resolving the templates.",
+	"htpasswd": "The output is salted and so differs on every call, and the hashing algorithm may change in future " +
+		"Sprig versions.",
+	"mustToDate": "The parsing of ambiguous layouts and time zones has changed across Sprig versions.",
+	"now":        "The output differs on every call, which causes the resolved template to change on every resolution.",
+	"round":      "The rounding of values exactly at the midpoint has changed across Sprig versions.",
+	"semver": "The parsing of versions with a leading \"v\", missing parts, or pre-release identifiers has changed " +
+		"across versions of the underlying semver library.",
+	"semverCompare": "The handling of pre-release versions in constraints has changed across versions of the " +
+		"underlying semver library.",
+	"toDate": "The parsing of ambiguous layouts and time zones has changed across Sprig versions, and invalid input " +
+		"returns the zero time rather than an error.",
+}
+
+// SprigFunctionInfo describes a function that this library makes available from the Sprig library.
+type SprigFunctionInfo struct {
+	Name string
+	// Stable is true if the library guarantees that the behavior of the function is the same across versions.
+	Stable bool
+	// Notes describes how the behavior of an unstable function may differ. This is empty for stable functions.
+	Notes string
+}
+
+// SprigFunctionRegistry returns the functions that this library makes available from the Sprig library along with
+// whether their behavior is guaranteed to be stable. This can be used to choose the Config.PinnedSprigFunctions.
+func SprigFunctionRegistry() []SprigFunctionInfo {
+	registry := make([]SprigFunctionInfo, 0, len(exportedSprigFunctions))
+
+	for _, funcName := range exportedSprigFunctions {
+		notes, unstable := unstableSprigFunctions[funcName]
+
+		registry = append(registry, SprigFunctionInfo{Name: funcName, Stable: !unstable, Notes: notes})
+	}
+
+	return registry
+}
+
+// checkPinnedSprigFunctions returns an error wrapping ErrSprigFunctionNotPinned if the template uses a Sprig function
+// that isn't in Config.PinnedSprigFunctions. Nothing is checked if Config.PinnedSprigFunctions is nil.
+func (t *TemplateResolver) checkPinnedSprigFunctions(tmplStr string) error {
+	if t.config.PinnedSprigFunctions == nil {
+		return nil
+	}
+
+	scan := ScanTemplates([]byte(tmplStr), t.config.StartDelim, t.config.StopDelim)
+
+	for _, funcName := range scan.Functions {
+		if !slices.Contains(exportedSprigFunctions, funcName) || slices.Contains(t.config.DisabledFunctions, funcName) {
+			continue
+		}
+
+		if !slices.Contains(t.config.PinnedSprigFunctions, funcName) {
+			return fmt.Errorf("%w: %s", ErrSprigFunctionNotPinned, funcName)
+		}
+	}
+
+	return nil
+}
@@ -4,8 +4,11 @@
 package templates
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
 	yaml "gopkg.in/yaml.v3"
@@ -304,3 +307,72 @@ data:
 		})
 	}
 }
+
+func TestSprigFunctionRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := SprigFunctionRegistry()
+	if len(registry) != len(exportedSprigFunctions) {
+		t.Fatalf("expected %d functions, got : %d", len(exportedSprigFunctions), len(registry))
+	}
+
+	for _, info := range registry {
+		_, unstable := unstableSprigFunctions[info.Name]
+		if info.Stable == unstable || (info.Notes == "") != info.Stable {
+			t.Fatalf("the registry entry is inconsistent: %+v", info)
+		}
+	}
+
+	for funcName := range unstableSprigFunctions {
+		if !slices.Contains(exportedSprigFunctions, funcName) {
+			t.Fatalf("the unstable function %s is not an exported Sprig function", funcName)
+		}
+	}
+}
+
+func TestResolveTemplatePinnedSprigFunctions(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{PinnedSprigFunctions: []string{"upper", "list"}})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl   string
+		expectedErr string
+	}{
+		"pinned": {
+			inputTmpl: `value: '{{ list "a" "b" | len }}{{ "a" | upper | dns1123 }}'`,
+		},
+		"not_pinned": {
+			inputTmpl:   `value: '{{ "a" | upper }}{{ semver "1.2.3" }}'`,
+			expectedErr: "semver",
+		},
+		"not_executed": {
+			inputTmpl:   `value: '{{ if false }}{{ now }}{{ end }}'`,
+			expectedErr: "now",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrSprigFunctionNotPinned) || !strings.HasSuffix(err.Error(), ": "+test.expectedErr) {
+				t.Fatalf("expected an ErrSprigFunctionNotPinned error for %s, got : %v", test.expectedErr, err)
+			}
+		})
+	}
+}
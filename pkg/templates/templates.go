@@ -10,19 +10,27 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
 	"github.com/spf13/cast"
 	"github.com/stolostron/kubernetes-dependency-watches/client"
 	yaml "gopkg.in/yaml.v3"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfield "k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
@@ -37,19 +45,24 @@ const (
 	IVSize            = 16 // Size in bytes
 	protectedPrefix   = "$ocm_encrypted:"
 	yamlIndentation   = 2
+	defaultMaxDepth   = 10
 )
 
 var (
-	ErrAESKeyNotSet          = errors.New("AESKey must be set to use this encryption mode")
-	ErrInvalidAESKey         = errors.New("the AES key is invalid")
-	ErrInvalidB64OfEncrypted = errors.New("the encrypted string is invalid base64")
-	ErrIVNotSet              = errors.New("initialization vector must be set to use this encryption mode")
-	ErrInvalidIV             = errors.New("initialization vector must be 128 bits")
-	ErrInvalidPKCS7Padding   = errors.New("invalid PCKS7 padding")
-	ErrMissingAPIResource    = errors.New("one or more API resources are not installed on the API server")
-	ErrProtectNotEnabled     = errors.New("the protect template function is not enabled in this mode")
-	ErrNewLinesNotAllowed    = errors.New("new lines are not allowed in the string passed to the toLiteral function")
-	ErrInvalidContextType    = errors.New(
+	ErrAESKeyNotSet           = errors.New("AESKey must be set to use this encryption mode")
+	ErrInvalidAESKey          = errors.New("the AES key is invalid")
+	ErrInvalidB64OfEncrypted  = errors.New("the encrypted string is invalid base64")
+	ErrInvalidEncryptedFormat = errors.New("the encrypted string is in an invalid format")
+	ErrIVNotSet               = errors.New("initialization vector must be set to use this encryption mode")
+	ErrInvalidIV              = errors.New("initialization vector must be 128 bits")
+	ErrInvalidPKCS7Padding    = errors.New("invalid PCKS7 padding")
+	ErrMissingAPIResource     = errors.New("one or more API resources are not installed on the API server")
+	ErrProtectNotEnabled      = errors.New("the protect template function is not enabled in this mode")
+	ErrEnvNotEnabled          = errors.New("the env template function is not enabled")
+	ErrPasswordHashDisabled   = errors.New("the htpasswdCost and argon2 template functions are not enabled")
+	ErrRemoteSecretsDisabled  = errors.New("the fromRemoteSecret template function is not enabled")
+	ErrNewLinesNotAllowed     = errors.New("new lines are not allowed in the string passed to the toLiteral function")
+	ErrInvalidContextType     = errors.New(
 		"the input context must be a struct with fields (recursively) of type string, map[string]string, " +
 			"map[string]interface{}, or struct",
 	)
@@ -61,40 +74,257 @@ var (
 	ErrCacheDisabled            = client.ErrCacheDisabled
 	ErrNoCacheEntry             = client.ErrNoCacheEntry
 	ErrContextTransformerFailed = errors.New("the context transformer failed")
+	ErrPostResolveFailed        = errors.New("the PostResolve function failed")
+	ErrResourceVersionMismatch  = errors.New("the resource version of the object does not match the pinned version")
+	ErrMaxDepthExceeded         = errors.New("the maximum template resolution depth was exceeded")
+	ErrKindNotAllowed           = errors.New("the lookup of this kind is not allowed")
+	ErrInvalidMetadata          = errors.New("the resolved object has invalid labels or annotations")
+	ErrSchemaValidation         = errors.New("the resolved object failed schema validation")
+	ErrSensitiveDataInConfigMap = errors.New("sensitive data was used in a ConfigMap")
+	ErrSprigFunctionNotPinned   = errors.New("the Sprig function is not in the pinned set")
+	ErrWrongArgumentCount       = errors.New("the template function was called with the wrong number of arguments")
 )
 
+// parseErrorPosition matches the position in a text/template parse error such as "template: tmpl:3: ..." or
+// "template: tmpl:3:10: ...".
+var parseErrorPosition = regexp.MustCompile(`^template: [^:]*:(\d+)(?::(\d+))?:`)
+
+// TemplateParseError is returned by ResolveTemplate when the template fails to parse. Line and Column are the position
+// of the error in the template as it was passed to text/template, which is the YAML form of the input when
+// ResolveOptions.InputIsYAML is not set. Either is 0 when text/template does not provide it.
+type TemplateParseError struct {
+	Line   int
+	Column int
+	// Template is the raw input template.
+	Template string
+	Err      error
+}
+
+// newTemplateParseError returns a TemplateParseError with the position extracted from the text/template parse error.
+func newTemplateParseError(tmplRawStr string, err error) *TemplateParseError {
+	parseErr := &TemplateParseError{Template: tmplRawStr, Err: err}
+
+	match := parseErrorPosition.FindStringSubmatch(err.Error())
+	if match != nil {
+		parseErr.Line, _ = strconv.Atoi(match[1])
+		parseErr.Column, _ = strconv.Atoi(match[2])
+	}
+
+	return parseErr
+}
+
+func (e *TemplateParseError) Error() string {
+	return fmt.Sprintf("failed to parse the template JSON string %v: %v", e.Template, e.Err)
+}
+
+func (e *TemplateParseError) Unwrap() error {
+	return e.Err
+}
+
 // Config is a struct containing configuration for the API.
 //
 // - AdditionalIndentation sets the number of additional spaces to be added to the input number
 // to the indent method. This is useful in situations when the indentation should be relative
 // to a logical starting point in a YAML file.
 //
+// - AllowEnvFunction can be set to true to enable the "env" template function, which returns the value of the input
+// environment variable of the process resolving the templates. This is disabled by default since it exposes the
+// environment, which may contain credentials, to template authors. It should only be enabled in trusted contexts such
+// as local testing with the CLI, and not in a controller that resolves user provided templates.
+//
+// - AllowRemoteSecrets can be set to true to enable the "fromRemoteSecret" template function, which reads a value from
+// a Secret on a different cluster using the kubeconfig in the "kubeconfig" key of a referenced Secret. This is disabled
+// by default since the template then has the permissions of the referenced kubeconfig on the remote cluster rather
+// than just the permissions of the resolver, and the remote Secret is not restricted by
+// ResolveOptions.LookupNamespace. Any template author that can read the kubeconfig Secret can read every Secret the
+// kubeconfig grants access to, so this should only be enabled when the kubeconfig Secrets and template authors are
+// trusted. Since a kubeconfig can also run exec credential plugins and read credential files on the host of the
+// resolver, anyone that can write a kubeconfig Secret could run a command as the resolver or send its credentials,
+// such as its service account token file, to a server of their choosing. To prevent this, kubeconfigs with exec or
+// auth-provider credentials or with token, client certificate, client key, or certificate authority file paths are
+// rejected, so the credentials and certificates must be embedded in the kubeconfig.
+//
+// - AllowPasswordHashFunctions can be set to true to enable the "htpasswdCost" and "argon2" template functions, which
+// return salted password hashes. These are disabled by default since they are CPU intensive and return a different
+// value each time the template is resolved, which causes the resolved object to change on every resolution. They
+// should only be enabled when the resolved template is applied once or the change is acceptable.
+//
+// - CoalesceQueryBatches can be set to true to allow concurrent calls to ResolveTemplate for the same
+// ResolveOptions.Watcher when caching is enabled. Rather than returning an error wrapping
+// client.ErrQueryBatchInProgress, the concurrent calls share a single query batch, which is started by the first call
+// and ended by the last call to finish. The API watches that are no longer needed are only cleaned up when the batch
+// ends, and the watches for the queries of all the calls in the batch are kept, even if a call doesn't share any of
+// them. Calls that don't overlap each have their own batch as usual. This has no effect if SkipBatchManagement is set
+// or caching is not enabled.
+//
 // - DisabledFunctions is a slice of default template function names that should be disabled.
 //
+// - EmptyMapRendering controls how the empty maps, empty lists, and null values in the resolved template are rendered,
+// such as when copyConfigMapData or a lookup returns an object without data. See the EmptyMapRendering constants for
+// the options. If this is not set, the values are rendered as they are resolved, so an absent map may be null in one
+// template and {} in another.
+//
+// - ExplicitDataTypes can be set to true to set the value of a template action whose pipeline ends with the toInt or
+// toBool template function as an integer or boolean in the resolved template rather than removing the quotes around
+// the template and relying on YAML to infer the type. This also applies when the template is in a double quoted
+// string or is a part of the string, in which case the value is kept as a string.
+//
+// - StrictArgumentCounts can be set to true to check the number of arguments of every call to a template function in
+// the template after it's parsed, rather than only when a call is executed, and return an error wrapping
+// ErrWrongArgumentCount with the position of the first invalid call. This catches mistakes, such as fromSecret with
+// two arguments, in branches that aren't executed before the template is resolved against a cluster. Variadic
+// functions, such as lookup, only need their required arguments.
+//
 // - StartDelim customizes the start delimiter used to distinguish a template action. This defaults
-// to "{{". If StopDelim is set, this must also be set.
+// to "{{". If StopDelim is set, this must also be set. The delimiters must be different, must not contain each other,
+// and must not contain whitespace, quotes, or "#".
 //
 // - StopDelim customizes the stop delimiter used to distinguish a template action. This defaults
 // to "}}". If StartDelim is set, this must also be set.
 //
+// - StructuredValues can be set to true to set the output of a template action that is the whole quoted value of a
+// mapping key or list item, such as key: '{{ configMapData "namespace" "name" }}', as a nested YAML map or list in the
+// resolved template when the output is a map or a list. This avoids converting the output with toJson and toLiteral.
+// The template action must be the only content in the quoted value, apart from an end of line comment, and other
+// output, such as a string, or a map that is part of a larger string, is kept as is.
+//
 // - MissingAPIResourceCacheTTL can be set if you want to temporarily cache an API resource is missing to avoid
 // duplicate API queries when a CRD is missing. By default, this will not be cached. Note that this only affects
 // when caching is enabled.
 //
+// - MissingKeyPlaceholder is the value to output instead of "<no value>" when a template action outputs a missing
+// value, such as a context field that is absent or a field of an object that doesn't exist. This is useful for optional
+// context fields. The templates are executed with the "missingkey=invalid" option and the placeholder is only output
+// by the actions whose value is missing, so a literal "<no value>" in the input or in the data of a referenced object
+// is kept as is. The placeholder can't contain quotes, backslashes, "#", ":", or control characters so that it can't
+// change the structure of the YAML. Missing values are never an error with this set. If this is not set, the
+// text/template default of "<no value>" is output.
+//
+// - MaxResolveDepth is the maximum depth of nested template resolution, such as nested "includeTemplate" calls and
+// the object-templates resolved by ResolveObjectTemplates, before ErrMaxDepthExceeded is returned. The depth is carried
+// across nested calls with ResolveOptions.ResolveDepth. This defaults to 10.
+//
+// - PinnedSprigFunctions is an optional list of the Sprig functions that templates are allowed to use. If this is set,
+// ResolveTemplate returns an error wrapping ErrSprigFunctionNotPinned when a template uses another Sprig function, even
+// if it's not executed. This guards against relying on functions whose behavior may change, and SprigFunctionRegistry
+// describes which functions are stable. Functions that aren't from Sprig are not affected. If this is nil, all the
+// Sprig functions are allowed.
+//
+// - PreserveComments can be set to populate TemplateResult.ResolvedYAML with the resolved template as YAML with the
+// comments from the input retained. This only has an effect when ResolveOptions.InputIsYAML is true since JSON input
+// can't contain comments. Note that comments are resolved as part of the template, so comments on lines that are
+// removed by a template action are removed and comments on lines generated in a loop are repeated.
+//
+// - OutputStringStyle can be set to populate TemplateResult.ResolvedYAML with the resolved template as YAML with the
+// string values formatted in the configured style. See the StringStyle constants for the options. This is combined
+// with PreserveComments when both are set.
+//
+// - OutputTrailingNewline controls whether TemplateResult.ResolvedYAML ends with a newline when it is populated by
+// PreserveComments or OutputStringStyle. If this is nil, it defaults to true to match the yaml.v3 encoder. Setting this
+// to false is useful when the resolved YAML is written to a file whose newline policy doesn't allow a final newline.
+// An empty ResolvedYAML is not modified.
+//
 // - SkipBatchManagement can be set if multiple calls to ResolveTemplate are needed for one watcher before API watches
 // and cache entries are cleaned up. The manual control is done with the StartQueryBatch and EndQueryBatch methods.
 // This has no effect if caching is not enabled.
 type Config struct {
 	AdditionalIndentation      uint32
+	AllowEnvFunction           bool
+	AllowRemoteSecrets         bool
+	AllowPasswordHashFunctions bool
+	CoalesceQueryBatches       bool
 	DisabledFunctions          []string
+	EmptyMapRendering          EmptyMapRendering
+	ExplicitDataTypes          bool
+	StrictArgumentCounts       bool
 	StartDelim                 string
 	StopDelim                  string
+	StructuredValues           bool
 	MissingAPIResourceCacheTTL time.Duration
+	MissingKeyPlaceholder      string
+	MaxResolveDepth            int
+	OutputStringStyle          StringStyle
+	OutputTrailingNewline      *bool
+	PinnedSprigFunctions       []string
+	PreserveComments           bool
 	SkipBatchManagement        bool
 }
 
+// StringStyle is the style of the string values in YAML output.
+type StringStyle string
+
+const (
+	// StringStylePreserve keeps the yaml.v3 default style, which only quotes strings when required, or the style from
+	// the input when comments are preserved.
+	StringStylePreserve StringStyle = "preserve"
+	// StringStyleDoubleQuoted double quotes all string values.
+	StringStyleDoubleQuoted StringStyle = "doubleQuoted"
+	// StringStyleSingleQuoted single quotes all string values.
+	StringStyleSingleQuoted StringStyle = "singleQuoted"
+	// StringStyleLiteralForMultiline uses literal block scalars for multiline string values.
+	StringStyleLiteralForMultiline StringStyle = "literal-for-multiline"
+)
+
+// EmptyMapRendering is how the empty maps, empty lists, and null values are rendered in the resolved template.
+type EmptyMapRendering string
+
+const (
+	// EmptyMapRenderingNull renders the empty maps and empty lists as null.
+	EmptyMapRenderingNull EmptyMapRendering = "null"
+	// EmptyMapRenderingEmptyObject renders the null values as {}. Empty lists are kept as [].
+	EmptyMapRenderingEmptyObject EmptyMapRendering = "empty-object"
+	// EmptyMapRenderingOmit removes the map keys whose value is an empty map, an empty list, or null, including the
+	// keys of the maps that are empty after the removal. List items are kept so that the indexes don't change.
+	EmptyMapRenderingOmit EmptyMapRendering = "omit"
+)
+
 // ResolveOptions is a struct containing configuration for calling ResolveTemplate.
 //
+// - AllowNestedContextValues can be set to true to allow values of any type that results from unmarshaling YAML or
+// JSON, such as booleans, numbers, lists, and nested maps, under the maps with interface values in the input context.
+// This is useful for passing arbitrary data to templates, such as a Helm-like values file under a Values field of type
+// map[string]interface{}. The fields of the input context itself are still validated as usual.
+//
+// - AllowedLookupKinds is a list of group kinds which are allowed to be used in "lookup" calls and the template
+// functions built on it. If this is not set, then all kinds are allowed.
+//
+// - AllowedContextFields is a list of top-level field names of the input context, such as "ObjectName", that the
+// template can access. Other fields are removed from the context before the template is resolved, so referencing them
+// has the same result as referencing a map key that is not set. This is useful for restricting the data available to
+// templates when a resolver is shared, such as hiding the PolicyMetadata field from certain users. This also applies
+// to the context returned by ContextTransformers. If this is not set, then all fields are allowed.
+//
+// - ApplyDefaults can be set to true to apply the API server defaults to the objects returned by the "lookup" template
+// function, such as those from the defaults in a CustomResourceDefinition schema that was updated after the object was
+// created, so that templates see the defaulted values of fields that the object omits. This is done with an update
+// dry run request for each returned object, including each item of a list, so the result is not persisted, but it
+// adds an API request per object, requires the update permission on the objects, and runs the mutating admission
+// webhooks. This is not available when using NewResolverWithDynamicWatcher.
+//
+// - AutoTypeNumericContext can be set to true to allow fields and map values of integer and floating point types in
+// the input context and to output them as numbers rather than strings. A template that only outputs a numeric context
+// field, such as '{{ .ClusterID }}' or "{{ .Cluster.Port }}", has its enclosing quotes removed before it is resolved,
+// similar to how processForDataTypes handles templates whose pipeline ends with toInt, toBool, or toLiteral. Other
+// templates that reference a numeric context field, such as '{{ .ClusterID | printf "id-%d" }}', are unchanged and
+// still resolve to strings, so toInt is still required to output those as numbers. The quotes are removed based on
+// the input context, so this does not account for changes made by ContextTransformers.
+//
+// - CollectMetrics can be set to true to populate TemplateResult.Metrics with a summary of the template resolution,
+// such as the number of API queries performed. This is useful for enforcing quotas or limits on templates.
+//
+// - CollectFunctionStats can be set to true to populate TemplateResult.FunctionCalls with the number of times each
+// template function was called, including the calls in included templates. This is useful for identifying expensive
+// templates, such as those that call "lookup" in a loop. Each function is wrapped in a counting function, so this adds
+// a small overhead to every call.
+//
+// - ConsistentSnapshot can be set to true in caching mode so that all the lookups in the ResolveTemplate call are
+// served from a point-in-time snapshot of the cache. The snapshot starts with a copy of the objects watched by
+// options.Watcher when the call starts, and the objects and list queries first read during the call are added to it,
+// so a template reading the same or related objects multiple times sees the same versions even if the cache is
+// updated mid-resolve. The watches are still maintained as usual. The trade-off is that a copy of every watched and
+// read object is held in memory for the duration of the call, which can be significant for watchers of large lists.
+// This has no effect if caching is not enabled since each ResolveTemplate call already caches its queries.
+//
 // - ContextTransformers is a list of functions that can modify the input context to ResolveTemplate using the caching
 // query API. This is useful if you want to add information about a Kubernetes object in the context and be notified
 // when the object changes.
@@ -106,31 +336,150 @@ type Config struct {
 //
 // - CustomFunctions is an optional map of custom functions available during template resolution.
 //
+// - DryRunUnresolvable can be set to true to preview the structure of the templates without cluster access. The
+// template functions that query the API server, such as "lookup" and "fromSecret", are not called and instead output
+// a placeholder of the call, such as `<lookup v1 Secret testns testsecret>`, so that the calls that would run are
+// visible. This applies to both managed and hub templates. Since the placeholder is a string, field access on the
+// result, such as `(lookup ...).data`, and functions that require another type of input fail.
+//
 // - EncryptionConfig is the configuration for template encryption/decryption functionality.
 //
+// - ImpersonateServiceAccount can be set to the namespace and name of a ServiceAccount to resolve the templates as that
+// ServiceAccount instead of the identity of the resolver's kubeconfig. All API requests, including the access reviews,
+// then use Kubernetes user impersonation, so a single hub resolver can resolve each policy as the ServiceAccount it
+// declares. The kubeconfig identity must be allowed the "impersonate" verb on the "serviceaccounts" resource, ideally
+// restricted to the namespaces and resourceNames of the ServiceAccounts. The impersonating clients are created on first
+// use for each ServiceAccount and kept for the lifetime of the resolver. This is only available with NewResolver.
+//
 // - InputIsYAML can be set to true to indicate that the input to the template is already in YAML format and thus does
 // not need to be converted from JSON to YAML before template processing occurs. This should be set to true when
 // passing raw YAML directly to the template resolver.
 //
+// - JSONNative can be set to true to resolve the templates in the JSON input without converting it to YAML and back,
+// so that the key order, the number formatting, and the values without templates are unchanged. Each string value and
+// object key with templates is resolved as a separate template, so variables can't be shared between them, and the
+// result is a string unless the value is a single template that ends in toInt, toBool, or toLiteral or that uses
+// copyConfigMapData or copySecretData. The YAML specific processing, such as autoindent, Config.ExplicitDataTypes,
+// and Config.StructuredValues, is not applied, and this can't be set with InputIsYAML, WrapInList, or
+// AutoTypeNumericContext.
+//
 // - LookupNamespace is the namespace to restrict "lookup" template functions (e.g. fromConfigMap)
 // to. If this is not set (i.e. an empty string), then all namespaces can be used.
 //
+// - MaskEncryptedForDisplay can be set to true to replace the "$ocm_encrypted:" values in the resolved template, such
+// as those from the "protect" template function, with a fixed "$ocm_encrypted:<masked>" placeholder. This is to make
+// previews of resolved templates readable and TemplateResult.EncryptedValuesMasked indicates that values were masked.
+// The result must not be applied since the encrypted values are lost.
+//
+// - Now is the current time used by the "ageSince" and "olderThan" template functions. If this is not set, the time
+// when ResolveTemplate is called is used. Setting this makes the resolved template deterministic, such as for tests.
+//
+// - PinnedResourceVersions is a map of object identifiers to the resource versions that the template must be resolved
+// with, such as the versions from a previous resolution. If an object returned by a lookup, such as with
+// "fromConfigMap" or a list query, is in the map and has a different resource version, or a pinned object that is
+// looked up by name is not found, an error wrapping ErrResourceVersionMismatch is returned. This allows optimistic
+// resolution for controllers that require the template to be resolved against consistent objects. The identifiers
+// have the group, version, kind, namespace (empty for cluster-scoped objects), and name of the object without a
+// selector. Note that past versions of objects are not retained, so the template is always resolved against the
+// current objects and this only detects changes.
+//
+// - PostResolve is an optional function to modify the resolved template, such as to add a label to every resolved
+// object. The resolved template is unmarshaled and passed to the function, and the returned object is marshaled as
+// TemplateResult.ResolvedJSON. It is called after the MaskEncryptedForDisplay and WrapInList options are applied, so
+// it receives the masked values and the List, and before the RejectSecretInConfigMap, ValidateMetadata, and
+// ValidateAgainstSchema checks, so those check the modified object. An error returned by the function is wrapped in
+// ErrPostResolveFailed. The resolved template must be an object, and when this is set, TemplateResult.ResolvedYAML
+// does not retain the comments from Config.PreserveComments since the object is rebuilt.
+//
+// - RemoveEmptyFields can be set to true to remove the map keys whose resolved value is an empty string or null, such
+// as a field set to '{{ fromConfigMap "ns" "name" "optional-key" }}' when the key doesn't exist, so that the field is
+// omitted rather than set to an empty value. This applies to the nested maps, including those in lists, and a map that
+// is empty after its keys are removed is also removed from its parent map. List items are never removed. This is
+// applied before PostResolve, and like PostResolve, TemplateResult.ResolvedYAML does not retain the comments from
+// Config.PreserveComments and the key order from JSONNative is not kept since the object is rebuilt.
+//
+// - RejectSecretInConfigMap can be set to true to return an error wrapping ErrSensitiveDataInConfigMap if the template
+// references sensitive data, as indicated by TemplateResult.HasSensitiveData, and the resolved template or an
+// "objectDefinition" in it is a ConfigMap. This prevents Secret values from being stored in plain text. Since
+// sensitive data is tracked for the whole template, resolve separate objects in separate calls to avoid rejecting a
+// ConfigMap that doesn't use the sensitive data.
+//
+// - ResolveDepth is the depth of nested template resolution that the call is made at, such as 1 when resolving the
+// object-templates of a ConfigurationPolicy that is itself resolved. The nested resolution within the call, such as
+// "includeTemplate", is counted from this depth, and ErrMaxDepthExceeded is returned if it exceeds
+// Config.MaxResolveDepth. ResolveObjectTemplates increments this for the object-templates. This defaults to 0 for a
+// top-level call.
+//
+// - TrackReferences can be set to true to populate TemplateResult.ReferencedObjects with the identifiers of all the
+// objects and list queries referenced by the template functions during template resolution. The named objects that
+// were not found are also added to TemplateResult.NotFoundObjects.
+//
+// - ValidateMetadata can be set to true to validate the label keys, label values, and annotation keys of the resolved
+// template and each "objectDefinition" in it that has an apiVersion and kind against the Kubernetes rules, such as the
+// maximum length and allowed characters. An invalid value returns the resolved template with an error wrapping
+// ErrInvalidMetadata that lists every invalid entry. This catches mistakes, such as a label value with a slash, before
+// the object is applied.
+//
+// - ValidateAgainstSchema can be set to true to validate the resolved template and each "objectDefinition" in it that
+// has an apiVersion and kind against the OpenAPI schema on the API server. This is done with server-side apply dry run
+// requests with strict field validation, so the result is not persisted. A validation failure returns the resolved
+// template with an error wrapping ErrSchemaValidation for each invalid object. This is not available when using
+// NewResolverWithDynamicWatcher.
+//
+// - TrackProvenance can be set to true to populate TemplateResult.Provenance with the objects referenced by the
+// template functions for each resolved field. The field is determined by the position in the template output when
+// the function is called, so an object referenced in a variable assignment is attributed to the field where the
+// variable is assigned, not where it's used. This requires parsing the resolved template an additional time and is not
+// available with JSONNative. ResolveTemplateWithProvenance is a shortcut for setting this.
+//
 // - Watcher is the Kubernetes object that includes the templates. This is only used when caching is enabled.
+//
+// - WrapInList can be set to true to wrap the resolved template in a Kubernetes "v1" "List" object when it resolves to
+// an array, such as from an object-templates-raw template that generates Kubernetes objects. The array is set as the
+// items of the List, which is convenient for "kubectl apply -f". An empty result is wrapped as a List with no items,
+// and other results are not changed.
 type ResolveOptions struct {
-	ContextTransformers []func(
+	AllowNestedContextValues bool
+	AllowedContextFields     []string
+	AllowedLookupKinds       []schema.GroupKind
+	ApplyDefaults            bool
+	AutoTypeNumericContext   bool
+	CollectFunctionStats     bool
+	CollectMetrics           bool
+	ContextTransformers      []func(
 		queryAPI CachingQueryAPI, context interface{},
 	) (transformedContext interface{}, err error)
 	ClusterScopedAllowList []ClusterScopedObjectIdentifier
+	ConsistentSnapshot     bool
 	CustomFunctions        template.FuncMap
+	DryRunUnresolvable     bool
 	EncryptionConfig
-	InputIsYAML     bool
-	LookupNamespace string
-	Watcher         *client.ObjectIdentifier
+	ImpersonateServiceAccount *types.NamespacedName
+	InputIsYAML               bool
+	JSONNative                bool
+	LookupNamespace           string
+	MaskEncryptedForDisplay   bool
+	Now                       time.Time
+	PinnedResourceVersions    map[client.ObjectIdentifier]string
+	PostResolve               func(resolved map[string]interface{}) (map[string]interface{}, error)
+	RejectSecretInConfigMap   bool
+	RemoveEmptyFields         bool
+	ResolveDepth              int
+	TrackProvenance           bool
+	TrackReferences           bool
+	ValidateAgainstSchema     bool
+	ValidateMetadata          bool
+	Watcher                   *client.ObjectIdentifier
+	WrapInList                bool
+	// snapshot is the point-in-time view of the cache for ConsistentSnapshot.
+	snapshot *resolveSnapshot
 }
 
 type TemplateContext struct {
-	ObjectNamespace string
-	ObjectName      string
+	ObjectNamespace   string
+	ObjectName        string
+	ObjectLabels      map[string]string
+	ObjectAnnotations map[string]string
 }
 
 type ClusterScopedObjectIdentifier struct {
@@ -144,7 +493,11 @@ type ClusterScopedObjectIdentifier struct {
 // - AESKey is an AES key (e.g. AES-256) to use for the "protect" template function and decrypting
 // such values.
 //
-// - AESKeyFallback is an AES key to try if the decryption fails using AESKey.
+// - AESKeyFallback is an AES key to try if the decryption fails using AESKey. Deprecated: use AESKeyFallbacks instead.
+// If both are set, AESKeyFallback is tried before the keys in AESKeyFallbacks.
+//
+// - AESKeyFallbacks is a list of AES keys to try in order if the decryption fails using AESKey, such as the previous
+// keys during a key rotation with multiple stages.
 //
 // - DecryptionConcurrency is the concurrency (i.e. number of Goroutines) limit when decrypting encrypted strings. Not
 // setting this value is the equivalent of setting this to 1, which means no concurrency.
@@ -164,6 +517,7 @@ type ClusterScopedObjectIdentifier struct {
 type EncryptionConfig struct {
 	AESKey                []byte
 	AESKeyFallback        []byte
+	AESKeyFallbacks       [][]byte
 	DecryptionConcurrency uint8
 	DecryptionEnabled     bool
 	EncryptionEnabled     bool
@@ -174,7 +528,8 @@ type EncryptionConfig struct {
 // instead of instantiating this directly so that configuration defaults and validation are applied.
 type TemplateResolver struct {
 	config Config
-	// Used when caching is disabled.
+	// Used when caching is disabled. This is also set with NewResolverWithCaching for API requests that can't be
+	// cached such as access reviews.
 	dynamicClient dynamic.Interface
 	// Used when instantiated with NewResolverWithCaching. This will create watches and the cache will get
 	// automatically updated.
@@ -182,12 +537,142 @@ type TemplateResolver struct {
 	// If caching is disabled, this will act as a temporary cache for objects during the execution of the
 	// ResolveTemplate call.
 	tempCallCache client.ObjectCache
+	// Used when instantiated with NewResolverWithCaching or NewResolverWithDynamicWatcher to share the query batches of
+	// concurrent ResolveTemplate calls when Config.CoalesceQueryBatches is set.
+	sharedBatches *sharedQueryBatches
+	// Used to create the clients that impersonate ResolveOptions.ImpersonateServiceAccount. This is only set with
+	// NewResolver.
+	kubeConfig *rest.Config
+	// The resolvers that impersonate each ResolveOptions.ImpersonateServiceAccount so that their clients are only
+	// created once. This is only set with NewResolver.
+	impersonatingResolvers *impersonatingResolvers
+	// Creates the client for the remote cluster in fromRemoteSecret. This defaults to newRemoteDynamicClient and is
+	// only overridden in tests.
+	remoteDynamicClient func(kubeconfig []byte) (dynamic.Interface, error)
+}
+
+// sharedQueryBatches is the number of in progress ResolveTemplate calls per watcher that share a query batch.
+type sharedQueryBatches struct {
+	lock  sync.Mutex
+	calls map[client.ObjectIdentifier]int
 }
 
 type TemplateResult struct {
 	ResolvedJSON []byte
 	// HasSensitiveData is true if a template references a secret or decrypts an encrypted value.
 	HasSensitiveData bool
+	// ReferencedObjects is the list of unique object and list query identifiers referenced by the template
+	// functions. This is only populated when ResolveOptions.TrackReferences is set to true.
+	ReferencedObjects []client.ObjectIdentifier
+	// NotFoundObjects is the subset of ReferencedObjects of the named objects that were not found. List queries are
+	// never included. This is only populated when ResolveOptions.TrackReferences is set to true.
+	NotFoundObjects []client.ObjectIdentifier
+	// ResolvedYAML is the resolved template as YAML. This is only populated when Config.OutputStringStyle is set or
+	// when Config.PreserveComments is set to true and the input is YAML, in which case the comments from the input are
+	// retained.
+	ResolvedYAML []byte
+	// EncryptedValuesMasked is true if ResolveOptions.MaskEncryptedForDisplay is set to true and the resolved template
+	// had encrypted values that were replaced with a placeholder.
+	EncryptedValuesMasked bool
+	// Metrics is a summary of the template resolution. This is only populated when ResolveOptions.CollectMetrics is set
+	// to true.
+	Metrics *TemplateMetrics
+	// FunctionCalls is the number of times each template function was called by name. Functions that weren't called
+	// are not included. This is only populated when ResolveOptions.CollectFunctionStats is set to true.
+	FunctionCalls map[string]int
+	// Provenance maps the paths of the resolved fields, such as "spec.data.password" or "items[0].name", to the
+	// identifiers of the objects referenced by the template functions called while the field was output. A function
+	// called outside of any field, such as at the start of a map template, is under the "" path. This is only
+	// populated when ResolveOptions.TrackProvenance is set to true.
+	Provenance map[string][]client.ObjectIdentifier
+	// ProvenanceKeys maps the paths in Provenance to the data keys read from the objects by the "fromSecret" and
+	// "fromConfigMap" family of template functions, such as "password" for '{{ fromSecret "ns" "name" "password" }}'.
+	// The objects referenced by other template functions, such as "lookup", are only in Provenance. This is only
+	// populated when ResolveOptions.TrackProvenance is set to true.
+	ProvenanceKeys map[string][]ProvenanceKey
+	// provenanceOutput is the output of the template being executed for ResolveOptions.TrackProvenance.
+	provenanceOutput *bytes.Buffer
+	// provenanceRecords are the objects referenced by the template functions for ResolveOptions.TrackProvenance.
+	provenanceRecords []provenanceRecord
+}
+
+// ProvenanceKey is a data key read from an object by a template function in TemplateResult.ProvenanceKeys.
+type ProvenanceKey struct {
+	Object client.ObjectIdentifier
+	Key    string
+}
+
+// TemplateMetrics is a summary of a template resolution returned in TemplateResult.Metrics.
+type TemplateMetrics struct {
+	// Actions is the number of template actions in the template, excluding those in included templates.
+	Actions int
+	// APIQueries is the number of object get and list queries performed by the template functions. Queries that are
+	// answered from a cache are included.
+	APIQueries int
+	// OutputBytes is the size of TemplateResult.ResolvedJSON in bytes.
+	OutputBytes int
+	// CachingUsed is true if the TemplateResolver caches the objects queried by the template functions across calls
+	// to ResolveTemplate.
+	CachingUsed bool
+}
+
+// addReferencedObject adds the object identifier to ReferencedObjects if it isn't already present.
+func (t *TemplateResult) addReferencedObject(objID client.ObjectIdentifier) {
+	if slices.Contains(t.ReferencedObjects, objID) {
+		return
+	}
+
+	t.ReferencedObjects = append(t.ReferencedObjects, objID)
+}
+
+// addNotFoundObject adds the object identifier to NotFoundObjects if it isn't already present.
+func (t *TemplateResult) addNotFoundObject(objID client.ObjectIdentifier) {
+	if slices.Contains(t.NotFoundObjects, objID) {
+		return
+	}
+
+	t.NotFoundObjects = append(t.NotFoundObjects, objID)
+}
+
+// ReferencesObject returns true if the template functions referenced the input object during template resolution,
+// either directly or through a list query that may include it. This is only accurate if ResolveOptions.TrackReferences
+// was set to true. Since the labels of the input object are not known, list queries with a label selector are assumed
+// to include the object.
+func (t TemplateResult) ReferencesObject(objID client.ObjectIdentifier) bool {
+	for _, ref := range t.ReferencedObjects {
+		// The version is ignored since the same object can be retrieved using different API versions
+		if ref.Group != objID.Group || ref.Kind != objID.Kind {
+			continue
+		}
+
+		// An empty namespace is either a cluster-scoped object or a list query across all namespaces
+		if ref.Namespace != "" && ref.Namespace != objID.Namespace {
+			continue
+		}
+
+		// An empty name is a list query
+		if ref.Name == "" || ref.Name == objID.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TemplatesToReResolve returns the indexes of the previous template results that reference the changed object and
+// must be resolved again. The other templates can reuse their previous results. For this to work, each template (e.g.
+// each object template in a policy) must have been resolved with its own ResolveTemplate call with
+// ResolveOptions.TrackReferences set to true so that the references are tracked per template.
+func TemplatesToReResolve(previous []TemplateResult, changed client.ObjectIdentifier) []int {
+	indexes := []int{}
+
+	for i, result := range previous {
+		if result.ReferencesObject(changed) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
 }
 
 // NewResolver creates a new (non-caching) TemplateResolver instance, which is the API for processing templates.
@@ -206,7 +691,17 @@ func NewResolver(kubeConfig *rest.Config, config Config) (*TemplateResolver, err
 		return nil, err
 	}
 
-	return NewResolverWithClients(dynamicClient, discoveryClient, config)
+	resolver, err := NewResolverWithClients(dynamicClient, discoveryClient, config)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver.kubeConfig = kubeConfig
+	resolver.impersonatingResolvers = &impersonatingResolvers{
+		resolvers: map[types.NamespacedName]*TemplateResolver{},
+	}
+
+	return resolver, nil
 }
 
 // NewResolverWithClients creates a new (non-caching) TemplateResolver instance, which is the API for processing
@@ -216,14 +711,8 @@ func NewResolverWithClients(
 	discoveryClient discovery.DiscoveryInterface,
 	config Config,
 ) (*TemplateResolver, error) {
-	if (config.StartDelim != "" && config.StopDelim == "") || (config.StartDelim == "" && config.StopDelim != "") {
-		return nil, fmt.Errorf("the configurations StartDelim and StopDelim cannot be set independently")
-	}
-
-	// It's only required to check config.StartDelim since it's invalid to set these independently
-	if config.StartDelim == "" {
-		config.StartDelim = defaultStartDelim
-		config.StopDelim = defaultStopDelim
+	if err := applyConfigDefaults(&config); err != nil {
+		return nil, err
 	}
 
 	klog.V(2).Infof("Using the delimiters of %s and %s", config.StartDelim, config.StopDelim)
@@ -251,6 +740,11 @@ func NewResolverWithClients(
 // Channel is also returned to trigger reconciles on the watched object provided in ResolveTemplate when a watched
 // object is added, updated, or removed.
 //
+// A watched object that is deleted and recreated with the same name triggers a reconcile for both the deletion and the
+// creation, and the cache reflects the recreated object, so no additional configuration is needed to pick up the new
+// object. Note that the reconciles may be combined into one if the events are received before the reconcile for the
+// watcher starts.
+//
 //   - ctx should be a cancelable context that should be canceled when you want the background goroutines involving
 //     caching to be stopped.
 //
@@ -288,8 +782,11 @@ func NewResolverWithCaching(
 	<-dynamicWatcher.Started()
 
 	resolver.dynamicWatcher = dynamicWatcher
-	resolver.dynamicClient = nil
+	resolver.sharedBatches = &sharedQueryBatches{calls: map[client.ObjectIdentifier]int{}}
+	// The dynamic client is kept for API requests that can't be cached such as access reviews.
 	resolver.tempCallCache = nil
+	// Impersonation would bypass the cache, so ResolveOptions.ImpersonateServiceAccount is not supported.
+	resolver.kubeConfig = nil
 
 	return resolver, channel, err
 }
@@ -297,19 +794,14 @@ func NewResolverWithCaching(
 // NewResolverWithDynamicWatcher creates a new caching TemplateResolver instance, using the provided dependency-watcher.
 // The caller is responsible for managing the given DynamicWatcher, including starting and stopping it. The caller must
 // start a query batch on the DynamicWatcher for the "watcher" object before calling ResolveTemplate.
+// Reconciles for a watched object that is deleted and recreated are the same as described in NewResolverWithCaching.
 //
 // - dynWatcher is an already running DynamicWatcher from kubernetes-dependency-watches.
 //
 // - config is the Config instance for configuring optional values for template processing.
 func NewResolverWithDynamicWatcher(dynWatcher client.DynamicWatcher, config Config) (*TemplateResolver, error) {
-	if (config.StartDelim != "" && config.StopDelim == "") || (config.StartDelim == "" && config.StopDelim != "") {
-		return nil, fmt.Errorf("the configurations StartDelim and StopDelim cannot be set independently")
-	}
-
-	// It's only required to check config.StartDelim since it's invalid to set these independently
-	if config.StartDelim == "" {
-		config.StartDelim = defaultStartDelim
-		config.StopDelim = defaultStopDelim
+	if err := applyConfigDefaults(&config); err != nil {
+		return nil, err
 	}
 
 	return &TemplateResolver{
@@ -317,28 +809,95 @@ func NewResolverWithDynamicWatcher(dynWatcher client.DynamicWatcher, config Conf
 		dynamicClient:  nil,
 		dynamicWatcher: dynWatcher,
 		tempCallCache:  nil,
+		sharedBatches:  &sharedQueryBatches{calls: map[client.ObjectIdentifier]int{}},
 	}, nil
 }
 
-// HasTemplate performs a simple check for the template start delimiter or the "$ocm_encrypted" prefix
-// (checkForEncrypted must be set to true) to indicate if the input byte slice has a template. If the startDelim
-// argument is an empty string, the default start delimiter of "{{" will be used.
-func HasTemplate(template []byte, startDelim string, checkForEncrypted bool) bool {
+// applyConfigDefaults validates the input Config and sets the defaults of the unset fields. It's used by all the
+// resolver constructors so that the configuration is handled the same way regardless of the mode.
+func applyConfigDefaults(config *Config) error {
+	if err := validateDelims(config.StartDelim, config.StopDelim); err != nil {
+		return err
+	}
+
+	// It's only required to check config.StartDelim since it's invalid to set these independently
+	if config.StartDelim == "" {
+		config.StartDelim = defaultStartDelim
+		config.StopDelim = defaultStopDelim
+	}
+
+	if config.MaxResolveDepth <= 0 {
+		config.MaxResolveDepth = defaultMaxDepth
+	}
+
+	if !validStringStyle(config.OutputStringStyle) {
+		return fmt.Errorf(
+			"%w: the OutputStringStyle of %s is not supported", ErrInvalidInput, config.OutputStringStyle,
+		)
+	}
+
+	if !validEmptyMapRendering(config.EmptyMapRendering) {
+		return fmt.Errorf(
+			"%w: the EmptyMapRendering of %s is not supported", ErrInvalidInput, config.EmptyMapRendering,
+		)
+	}
+
+	if err := validateMissingKeyPlaceholder(config.MissingKeyPlaceholder); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateDelims returns an error if the StartDelim and StopDelim configurations would not reliably distinguish
+// template actions. Both must be set or neither, they must be different and not contain each other, and they must not
+// contain whitespace, quotes, or "#" since those break the processing of the templates in YAML, such as removing the
+// quotes enclosing a template that uses toInt.
+func validateDelims(startDelim, stopDelim string) error {
+	if (startDelim != "" && stopDelim == "") || (startDelim == "" && stopDelim != "") {
+		return fmt.Errorf("the configurations StartDelim and StopDelim cannot be set independently")
+	}
+
 	if startDelim == "" {
-		startDelim = defaultStartDelim
+		return nil
+	}
+
+	if startDelim == stopDelim {
+		return fmt.Errorf("%w: the configurations StartDelim and StopDelim cannot be the same", ErrInvalidInput)
+	}
+
+	if strings.Contains(startDelim, stopDelim) || strings.Contains(stopDelim, startDelim) {
+		return fmt.Errorf(
+			"%w: the configurations StartDelim and StopDelim cannot contain each other, got %s and %s",
+			ErrInvalidInput, startDelim, stopDelim,
+		)
 	}
 
-	templateStr := string(template)
-	klog.V(2).Infof("HasTemplate template str:  %v", templateStr)
-	klog.V(2).Infof("Checking for the start delimiter:  %s", startDelim)
+	for i, delim := range []string{startDelim, stopDelim} {
+		if strings.ContainsAny(delim, " \t\r\n'\"#") {
+			name := "StartDelim"
+			if i == 1 {
+				name = "StopDelim"
+			}
 
-	hasTemplate := false
-	if strings.Contains(templateStr, startDelim) {
-		hasTemplate = true
-	} else if checkForEncrypted && strings.Contains(templateStr, protectedPrefix) {
-		hasTemplate = true
+			return fmt.Errorf(
+				"%w: the configuration %s of %q cannot contain whitespace, quotes, or #", ErrInvalidInput, name, delim,
+			)
+		}
 	}
 
+	return nil
+}
+
+// HasTemplate performs a simple check for the template start delimiter or the "$ocm_encrypted" prefix
+// (checkForEncrypted must be set to true) to indicate if the input byte slice has a template. If the startDelim
+// argument is an empty string, the default start delimiter of "{{" will be used.
+func HasTemplate(template []byte, startDelim string, checkForEncrypted bool) bool {
+	klog.V(2).Infof("HasTemplate template str:  %v", string(template))
+
+	scan := ScanTemplates(template, startDelim, "")
+	hasTemplate := len(scan.Actions) > 0 || (checkForEncrypted && scan.EncryptedValues > 0)
+
 	klog.V(2).Infof("hasTemplate: %v", hasTemplate)
 
 	return hasTemplate
@@ -347,26 +906,9 @@ func HasTemplate(template []byte, startDelim string, checkForEncrypted bool) boo
 // UsesEncryption searches for templates that would generate encrypted values and returns a boolean
 // whether one was found.
 func UsesEncryption(template []byte, startDelim string, stopDelim string) bool {
-	if startDelim == "" {
-		startDelim = defaultStartDelim
-	}
-
-	if stopDelim == "" {
-		stopDelim = defaultStopDelim
-	}
+	klog.V(2).Infof("usesEncryption template str:  %v", string(template))
 
-	templateStr := string(template)
-	klog.V(2).Infof("usesEncryption template str:  %v", templateStr)
-	klog.V(2).Infof("Checking for encryption functions")
-
-	// Check for encryption template functions:
-	// {{ fromSecret ... }}
-	// {{ copySecretData ... }}
-	// {{ ... | protect }}
-	d1 := regexp.QuoteMeta(startDelim)
-	d2 := regexp.QuoteMeta(stopDelim)
-	re := regexp.MustCompile(d1 + `(\s*fromSecret\s+.*|\s*copySecretData\s+.*|.*\|\s*protect\s*)` + d2)
-	usesEncryption := re.MatchString(templateStr)
+	usesEncryption := ScanTemplates(template, startDelim, stopDelim).UsesEncryption
 
 	klog.V(2).Infof("usesEncryption: %v", usesEncryption)
 
@@ -374,8 +916,10 @@ func UsesEncryption(template []byte, startDelim string, stopDelim string) bool {
 }
 
 // getValidContext takes an input context struct and validates it. If it is valid, the context will be returned as is.
-// If the input context is nil, an empty struct will be returned. If it's not valid, an error will be returned.
-func getValidContext(value interface{}) (interface{}, error) {
+// If the input context is nil, an empty struct will be returned. If it's not valid, an error will be returned. If
+// allowNumbers is true, fields and map values of integer and floating point types are also valid. If allowNested is
+// true, the values of maps with interface values can be any YAML or JSON value, such as a list or a boolean.
+func getValidContext(value interface{}, allowNumbers bool, allowNested bool) (interface{}, error) {
 	if value == nil {
 		return struct{}{}, nil
 	}
@@ -386,7 +930,7 @@ func getValidContext(value interface{}) (interface{}, error) {
 	}
 
 	// Require the context to have fields of strings or maps/structs with string/map values/fields.
-	err := getValidContextHelper(value)
+	err := getValidContextHelper(value, allowNumbers, allowNested)
 	if err != nil {
 		return nil, err
 	}
@@ -394,15 +938,24 @@ func getValidContext(value interface{}) (interface{}, error) {
 	return value, nil
 }
 
-func getValidContextHelper(value interface{}) error {
+func getValidContextHelper(value interface{}, allowNumbers bool, allowNested bool) error {
 	f := reflect.TypeOf(value)
 
+	// A nil value, such as a null value in a map with interface values, has no type
+	if f == nil {
+		return ErrInvalidContextType
+	}
+
+	if allowNumbers && isNumericKind(f.Kind()) {
+		return nil
+	}
+
 	switch f.Kind() {
 	case reflect.String:
 		return nil
 	case reflect.Struct:
 		for i := 0; i < f.NumField(); i++ {
-			err := getValidContextHelper(reflect.ValueOf(value).Field(i).Interface())
+			err := getValidContextHelper(reflect.ValueOf(value).Field(i).Interface(), allowNumbers, allowNested)
 			if err != nil {
 				return err
 			}
@@ -419,7 +972,17 @@ func getValidContextHelper(value interface{}) error {
 		// string (e.g. name) and map[string]string (e.g. labels) values.
 		if f.Elem().Kind() == reflect.Interface || f.Elem().Kind() == reflect.Map {
 			for _, key := range reflect.ValueOf(value).MapKeys() {
-				err := getValidContextHelper(reflect.ValueOf(value).MapIndex(key).Interface())
+				mapValue := reflect.ValueOf(value).MapIndex(key).Interface()
+
+				if allowNested && f.Elem().Kind() == reflect.Interface {
+					if !isNestedContextValue(mapValue) {
+						return ErrInvalidContextType
+					}
+
+					continue
+				}
+
+				err := getValidContextHelper(mapValue, allowNumbers, allowNested)
 				if err != nil {
 					return err
 				}
@@ -428,7 +991,11 @@ func getValidContextHelper(value interface{}) error {
 			return nil
 		}
 
-		// Check if it's map[string]string
+		// Check if it's map[string]string or a map of numbers when they are allowed
+		if allowNumbers && isNumericKind(f.Elem().Kind()) {
+			return nil
+		}
+
 		if f.Elem().Kind() != reflect.String {
 			return ErrInvalidContextType
 		}
@@ -439,6 +1006,82 @@ func getValidContextHelper(value interface{}) error {
 	}
 }
 
+// isNestedContextValue returns true if the input is a value that results from unmarshaling YAML or JSON, which are
+// the values allowed under the maps in the context when ResolveOptions.AllowNestedContextValues is set.
+func isNestedContextValue(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case nil, string, bool, int, int64, float64:
+		return true
+	case []interface{}:
+		for _, item := range typedValue {
+			if !isNestedContextValue(item) {
+				return false
+			}
+		}
+
+		return true
+	case map[string]interface{}:
+		for _, item := range typedValue {
+			if !isNestedContextValue(item) {
+				return false
+			}
+		}
+
+		return true
+	case map[string]string:
+		return true
+	default:
+		return false
+	}
+}
+
+// filterContextFields returns the input context as a map of only the top-level fields or map keys in allowedFields,
+// including the fields promoted from embedded structs. If allowedFields is empty or the context is not a struct or a
+// map with string keys, the context is returned as is.
+func filterContextFields(value interface{}, allowedFields []string) interface{} {
+	if len(allowedFields) == 0 || value == nil {
+		return value
+	}
+
+	contextValue := reflect.ValueOf(value)
+	filtered := make(map[string]interface{}, len(allowedFields))
+
+	switch contextValue.Kind() {
+	case reflect.Struct:
+		for _, field := range reflect.VisibleFields(contextValue.Type()) {
+			if !field.IsExported() || !slices.Contains(allowedFields, field.Name) {
+				continue
+			}
+
+			filtered[field.Name] = contextValue.FieldByIndex(field.Index).Interface()
+		}
+	case reflect.Map:
+		if contextValue.Type().Key().Kind() != reflect.String {
+			return value
+		}
+
+		for _, key := range contextValue.MapKeys() {
+			if slices.Contains(allowedFields, key.String()) {
+				filtered[key.String()] = contextValue.MapIndex(key).Interface()
+			}
+		}
+	default:
+		return value
+	}
+
+	return filtered
+}
+
+// fallbackKeys returns the AES keys to try in order if the decryption fails using AESKey, which is AESKeyFallback, if
+// set, followed by AESKeyFallbacks.
+func (e EncryptionConfig) fallbackKeys() [][]byte {
+	if e.AESKeyFallback == nil {
+		return e.AESKeyFallbacks
+	}
+
+	return append([][]byte{e.AESKeyFallback}, e.AESKeyFallbacks...)
+}
+
 // validateEncryptionConfig validates an EncryptionConfig struct to ensure that if encryption
 // and/or decryption are enabled that the AES Key and Initialization Vector are valid.
 func validateEncryptionConfig(encryptionConfig EncryptionConfig) error {
@@ -453,9 +1096,9 @@ func validateEncryptionConfig(encryptionConfig EncryptionConfig) error {
 			return fmt.Errorf("%w: %w", ErrInvalidAESKey, err)
 		}
 
-		// Validate the fallback AES Key
-		if encryptionConfig.AESKeyFallback != nil {
-			_, err = aes.NewCipher(encryptionConfig.AESKeyFallback)
+		// Validate the fallback AES Keys
+		for _, aesKeyFallback := range encryptionConfig.fallbackKeys() {
+			_, err = aes.NewCipher(aesKeyFallback)
 			if err != nil {
 				return fmt.Errorf("%w: %w", ErrInvalidAESKey, err)
 			}
@@ -512,6 +1155,41 @@ func (t *TemplateResolver) EndQueryBatch(watcher client.ObjectIdentifier) error
 	return t.dynamicWatcher.EndQueryBatch(watcher)
 }
 
+// startSharedQueryBatch starts the query batch for the watcher unless another ResolveTemplate call already started it
+// for Config.CoalesceQueryBatches. Each successful call must be followed by a call to endSharedQueryBatch.
+func (t *TemplateResolver) startSharedQueryBatch(watcher client.ObjectIdentifier) error {
+	t.sharedBatches.lock.Lock()
+	defer t.sharedBatches.lock.Unlock()
+
+	if t.sharedBatches.calls[watcher] == 0 {
+		err := t.dynamicWatcher.StartQueryBatch(watcher)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.sharedBatches.calls[watcher]++
+
+	return nil
+}
+
+// endSharedQueryBatch ends the query batch for the watcher if no other ResolveTemplate call started with
+// startSharedQueryBatch is still in progress.
+func (t *TemplateResolver) endSharedQueryBatch(watcher client.ObjectIdentifier) error {
+	t.sharedBatches.lock.Lock()
+	defer t.sharedBatches.lock.Unlock()
+
+	t.sharedBatches.calls[watcher]--
+
+	if t.sharedBatches.calls[watcher] > 0 {
+		return nil
+	}
+
+	delete(t.sharedBatches.calls, watcher)
+
+	return t.dynamicWatcher.EndQueryBatch(watcher)
+}
+
 // ResolveTemplate accepts a map marshaled as JSON or YAML. It also accepts a combination of structs and maps that
 // ultimately end in a string value to be made available when the template is processed.
 // For example, if the argument is `struct{ClusterName string}{"cluster1"}`,
@@ -529,7 +1207,9 @@ func (t *TemplateResolver) EndQueryBatch(watcher client.ObjectIdentifier) error
 //
 // This method is only concurrency safe when caching is enabled. When caching is disabled, a local cache of objects
 // is stored just for the ResolveTemplate execution to avoid duplicate API queries. If running this method concurrently
-// with caching disabled, you may get some items from the temporary cache while others will be from API queries.
+// with caching disabled, you may get some items from the temporary cache while others will be from API queries. When
+// caching is enabled, concurrent calls with the same options.Watcher return an error unless
+// Config.CoalesceQueryBatches is set, in which case they share a query batch.
 func (t *TemplateResolver) ResolveTemplate(
 	tmplRaw []byte, context interface{}, options *ResolveOptions,
 ) (TemplateResult, error) {
@@ -541,6 +1221,44 @@ func (t *TemplateResolver) ResolveTemplate(
 
 	var resolvedResult TemplateResult
 
+	if options.ResolveDepth > t.config.MaxResolveDepth {
+		return resolvedResult, fmt.Errorf(
+			"%w: the template resolution exceeded the maximum depth of %d", ErrMaxDepthExceeded,
+			t.config.MaxResolveDepth,
+		)
+	}
+
+	if options.ImpersonateServiceAccount != nil {
+		impersonatingResolver, err := t.impersonatingResolver(*options.ImpersonateServiceAccount)
+		if err != nil {
+			return resolvedResult, err
+		}
+
+		impersonatedOptions := *options
+		impersonatedOptions.ImpersonateServiceAccount = nil
+
+		return impersonatingResolver.ResolveTemplate(tmplRaw, context, &impersonatedOptions)
+	}
+
+	if options.CollectMetrics {
+		resolvedResult.Metrics = &TemplateMetrics{CachingUsed: t.dynamicWatcher != nil}
+	}
+
+	if options.CollectFunctionStats {
+		resolvedResult.FunctionCalls = map[string]int{}
+	}
+
+	if options.TrackProvenance {
+		if options.JSONNative {
+			return resolvedResult, fmt.Errorf(
+				"%w: options.TrackProvenance cannot be set with options.JSONNative", ErrInvalidInput,
+			)
+		}
+
+		resolvedResult.Provenance = map[string][]client.ObjectIdentifier{}
+		resolvedResult.ProvenanceKeys = map[string][]ProvenanceKey{}
+	}
+
 	err := validateEncryptionConfig(options.EncryptionConfig)
 	if err != nil {
 		return resolvedResult, fmt.Errorf("error validating EncryptionConfig: %w", err)
@@ -553,6 +1271,18 @@ func (t *TemplateResolver) ResolveTemplate(
 				ErrInvalidInput,
 			)
 		}
+
+		if options.ConsistentSnapshot {
+			snapshot, err := t.newResolveSnapshot(*options.Watcher)
+			if err != nil {
+				return resolvedResult, err
+			}
+
+			// The options are copied so that the snapshot is only used for this call
+			snapshotOptions := *options
+			snapshotOptions.snapshot = snapshot
+			options = &snapshotOptions
+		}
 	} else if len(options.ContextTransformers) != 0 {
 		return resolvedResult, fmt.Errorf(
 			"%w: options.ContextTransformers cannot be set if caching is disabled",
@@ -560,31 +1290,221 @@ func (t *TemplateResolver) ResolveTemplate(
 		)
 	}
 
-	ctx, err := getValidContext(context)
+	ctx, err := getValidContext(context, options.AutoTypeNumericContext, options.AllowNestedContextValues)
 	if err != nil {
 		return resolvedResult, err
 	}
 
-	// Build Map of supported template functions
-	funcMap := template.FuncMap{
-		"copyConfigMapData":      t.copyConfigMapDataHelper(options),
-		"copySecretData":         t.copySecretDataHelper(options, &resolvedResult),
-		"fromSecret":             t.fromSecretHelper(options, &resolvedResult),
-		"fromConfigMap":          t.fromConfigMapHelper(options),
-		"fromClusterClaim":       t.fromClusterClaimHelper(options),
-		"getNodesWithExactRoles": t.getNodesWithExactRolesHelper(options, &resolvedResult),
-		"hasNodesWithExactRoles": t.hasNodesWithExactRolesHelper(options),
-		"lookup":                 t.lookupHelper(options, &resolvedResult),
-		"base64enc":              base64encode,
-		"base64dec":              base64decode,
-		"b64enc":                 base64encode, // Link the Sprig name to our function
-		"b64dec":                 base64decode, // Link the Sprig name to our function
-		"autoindent":             autoindent,
-		"indent":                 t.indent,
-		"atoi":                   atoi,
-		"toInt":                  toInt,
-		"toBool":                 toBool,
+	ctx = filterContextFields(ctx, options.AllowedContextFields)
+
+	var resolvedYAMLBytes, resolvedTemplateBytes []byte
+
+	switch {
+	case !t.canSkipTemplates(tmplRaw, options):
+		resolvedYAMLBytes, resolvedTemplateBytes, err = t.resolveActions(
+			tmplRaw, context, ctx, options, &resolvedResult,
+		)
+	case !options.InputIsYAML && !t.needsYAMLPostProcessing(options):
+		// The JSON input only needs to be normalized, so the conversion to YAML and back is skipped
+		resolvedTemplateBytes, err = normalizeJSON(tmplRaw)
+	default:
+		var templateStr string
+
+		templateStr, err = templateInputToYAML(tmplRaw, options.InputIsYAML)
+		if err != nil {
+			return resolvedResult, err
+		}
+
+		resolvedYAMLBytes, resolvedTemplateBytes, err = t.postProcessResolved(
+			[]byte(templateStr), options, &resolvedResult,
+		)
+	}
+
+	if err != nil {
+		return resolvedResult, err
+	}
+
+	if options.RemoveEmptyFields {
+		resolvedTemplateBytes, err = removeEmptyFields(resolvedTemplateBytes)
+		if err != nil {
+			return resolvedResult, err
+		}
+	}
+
+	if t.config.EmptyMapRendering != "" {
+		resolvedTemplateBytes, err = renderEmptyMaps(resolvedTemplateBytes, t.config.EmptyMapRendering)
+		if err != nil {
+			return resolvedResult, err
+		}
+	}
+
+	if options.PostResolve != nil {
+		resolvedTemplateBytes, err = applyPostResolve(resolvedTemplateBytes, options.PostResolve)
+		if err != nil {
+			return resolvedResult, err
+		}
+	}
+
+	resolvedResult.ResolvedJSON = resolvedTemplateBytes
+
+	if resolvedResult.Metrics != nil {
+		resolvedResult.Metrics.OutputBytes = len(resolvedTemplateBytes)
+	}
+
+	preserveComments := t.config.PreserveComments && options.InputIsYAML
+	// The comments can only be preserved if the resolved JSON wasn't modified after the YAML was resolved
+	resolvedJSONModified := options.PostResolve != nil || options.RemoveEmptyFields || t.config.EmptyMapRendering != ""
+
+	if preserveComments && !resolvedJSONModified {
+		resolvedResult.ResolvedYAML, err = formatYAML(resolvedYAMLBytes, t.config.OutputStringStyle)
+		if err != nil {
+			return resolvedResult, fmt.Errorf("failed to format the resolved template as YAML: %w", err)
+		}
+	} else if t.config.OutputStringStyle != "" || preserveComments {
+		resolvedResult.ResolvedYAML, err = JSONToYAMLWithStyle(resolvedTemplateBytes, t.config.OutputStringStyle)
+		if err != nil {
+			return resolvedResult, fmt.Errorf("failed to format the resolved template as YAML: %w", err)
+		}
+	}
+
+	if t.config.OutputTrailingNewline != nil {
+		resolvedResult.ResolvedYAML = SetTrailingNewline(resolvedResult.ResolvedYAML, *t.config.OutputTrailingNewline)
+	}
+
+	if options.RejectSecretInConfigMap && resolvedResult.HasSensitiveData {
+		err = rejectConfigMaps(resolvedTemplateBytes)
+		if err != nil {
+			return resolvedResult, err
+		}
+	}
+
+	if options.ValidateMetadata {
+		err = validateMetadata(resolvedTemplateBytes)
+		if err != nil {
+			return resolvedResult, err
+		}
+	}
+
+	if options.ValidateAgainstSchema {
+		err = t.validateAgainstSchema(resolvedTemplateBytes)
+		if err != nil {
+			return resolvedResult, err
+		}
+	}
+
+	return resolvedResult, nil
+}
+
+// canSkipTemplates returns true if there is nothing to resolve in the input template, which is when it has no template
+// actions or encrypted values to decrypt. This is a fast path that skips building and parsing the template, since
+// executing a template without actions returns it unchanged, so only the post-processing is needed. The fast path isn't
+// used with JSONNative or ContextTransformers, or when caching is enabled and the query batches are managed, since
+// ending the query batch removes the watches that are no longer used.
+func (t *TemplateResolver) canSkipTemplates(tmplRaw []byte, options *ResolveOptions) bool {
+	if options.JSONNative || len(options.ContextTransformers) != 0 ||
+		(t.dynamicWatcher != nil && !t.config.SkipBatchManagement) {
+		return false
+	}
+
+	return !HasTemplate(tmplRaw, t.config.StartDelim, options.DecryptionEnabled)
+}
+
+// needsYAMLPostProcessing returns true if postProcessResolved modifies the resolved YAML with the configuration and
+// options, as opposed to only converting it to JSON.
+func (t *TemplateResolver) needsYAMLPostProcessing(options *ResolveOptions) bool {
+	return t.config.ExplicitDataTypes || t.config.StructuredValues ||
+		options.MaskEncryptedForDisplay || options.WrapInList
+}
+
+// normalizeJSON returns the JSON input in the same form as converting it to YAML and back to JSON, which has the
+// object keys sorted and no whitespace.
+func normalizeJSON(j []byte) ([]byte, error) {
+	var jsonObj interface{}
+
+	err := yaml.Unmarshal(j, &jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the policy template to YAML: %w", err)
+	}
+
+	normalized, err := json.Marshal(jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the resolved template to JSON: %w", err)
+	}
+
+	return normalized, nil
+}
+
+// resolveActions builds the template with the template functions, parses the input, and executes it with the context,
+// which is modified by the ContextTransformers, if any. The resolved template is returned as YAML and JSON.
+func (t *TemplateResolver) resolveActions(
+	tmplRaw []byte,
+	context interface{},
+	ctx interface{},
+	options *ResolveOptions,
+	resolvedResult *TemplateResult,
+) ([]byte, []byte, error) {
+	// Build Map of supported template functions
+	funcMap := template.FuncMap{
+		"canLookup":              t.canLookupHelper(options),
+		"configMapData":          t.configMapDataHelper(options, resolvedResult),
+		"containerResource":      t.containerResourceHelper(options, resolvedResult),
+		"copyConfigMapData":      t.copyConfigMapDataHelper(options, resolvedResult),
+		"copySecretData":         t.copySecretDataHelper(options, resolvedResult),
+		"countResources":         t.countResourcesHelper(options, resolvedResult),
+		"distinctField":          t.distinctFieldHelper(options, resolvedResult),
+		"dockerRegistries":       t.dockerRegistriesHelper(options, resolvedResult),
+		"fromSecret":             t.fromSecretHelper(options, resolvedResult),
+		"fromSecretFirst":        t.fromSecretFirstHelper(options, resolvedResult),
+		"fromConfigMap":          t.fromConfigMapHelper(options, resolvedResult),
+		"fromConfigMapFirst":     t.fromConfigMapFirstHelper(options, resolvedResult),
+		"fromLatestConfigMap":    t.fromLatestConfigMapHelper(options, resolvedResult),
+		"fromClusterClaim":       t.fromClusterClaimHelper(options, resolvedResult),
+		"fromManagedClusterInfo": t.fromManagedClusterInfoHelper(options, resolvedResult),
+		"fromSealedSecret":       t.fromSealedSecretHelper(options, resolvedResult),
+		"getNodesWithExactRoles": t.getNodesWithExactRolesHelper(options, resolvedResult),
+		"hasNodesWithExactRoles": t.hasNodesWithExactRolesHelper(options, resolvedResult),
+		"infrastructure":         t.infrastructureHelper(options, resolvedResult),
+		"ingressDomain":          t.ingressDomainHelper(options, resolvedResult),
+		"isWorkloadReady":        t.isWorkloadReadyHelper(options, resolvedResult),
+		"lastApplied":            t.lastAppliedHelper(options, resolvedResult),
+		"lookup":                 t.lookupHelper(options, resolvedResult),
+		"lookupNameGlob":         t.lookupNameGlobHelper(options, resolvedResult),
+		"matchingNamespaces":     t.matchingNamespacesHelper(options, resolvedResult),
+		"readyReplicas":          t.readyReplicasHelper(options, resolvedResult),
+		"secretData":             t.secretDataHelper(options, resolvedResult),
+		"base64enc":              base64encode,
+		"base64dec":              base64decode,
+		"b64enc":                 base64encode, // Link the Sprig name to our function
+		"b64dec":                 base64decode, // Link the Sprig name to our function
+		"base64encLines":         base64encLines,
+		"base64decLines":         base64decLines,
+		"autoindent":             autoindent,
+		"indent":                 t.indent,
+		"atoi":                   atoi,
+		"toInt":                  toInt,
+		"toBool":                 toBool,
 		"toLiteral":              toLiteral,
+		"dns1123":                dns1123,
+		"isDNS1123":              isDNS1123,
+		"annotationSafe":         annotationSafe,
+		"hashedName":             hashedName,
+		"jq":                     jq,
+		"toEnvFile":              toEnvFile,
+		"fromEnvFile":            fromEnvFile,
+		"renameKeys":             renameKeys,
+		"jwtClaim":               jwtClaim,
+		"required":               required,
+		"cidrHost":               cidrHost,
+		"cidrSubnet":             cidrSubnet,
+		"pemBlock":               pemBlock,
+		"pemCount":               pemCount,
+		"quantityAdd":            quantityAdd,
+		"quantitySub":            quantitySub,
+		"quantityCompare":        quantityCompare,
+		"semverCanonical":        semverCanonical,
+		"semverSatisfies":        semverSatisfies,
+		"ageSince":               ageSinceHelper(options),
+		"olderThan":              olderThanHelper(options),
 	}
 
 	// Add all the functions from sprig we will support
@@ -592,15 +1512,51 @@ func (t *TemplateResolver) ResolveTemplate(
 		funcMap[fname] = getSprigFunc(fname)
 	}
 
+	// includeTemplate references funcMap so that included templates have the same functions available
+	funcMap["includeTemplate"] = t.includeTemplateHelper(options, resolvedResult, funcMap, options.ResolveDepth+1)
+
 	if options.EncryptionEnabled {
-		funcMap["fromSecret"] = t.fromSecretProtectedHelper(options, &resolvedResult)
+		funcMap["fromSecret"] = t.fromSecretProtectedHelper(options, resolvedResult)
+		funcMap["fromSecretFirst"] = t.fromSecretFirstProtectedHelper(options, resolvedResult)
 		funcMap["protect"] = t.protectHelper(options)
-		funcMap["copySecretData"] = t.copySecretDataProtectedHelper(options, &resolvedResult)
+		funcMap["copySecretData"] = t.copySecretDataProtectedHelper(options, resolvedResult)
+		funcMap["secretData"] = t.secretDataProtectedHelper(options, resolvedResult)
 	} else {
 		// In other encryption modes, return a readable error if the protect template function is accidentally used.
 		funcMap["protect"] = func(s string) (string, error) { return "", ErrProtectNotEnabled }
 	}
 
+	if t.config.AllowEnvFunction {
+		funcMap["env"] = os.Getenv
+	} else {
+		// Return a readable error if the env template function is used without being enabled.
+		funcMap["env"] = func(s string) (string, error) { return "", ErrEnvNotEnabled }
+	}
+
+	if t.config.AllowPasswordHashFunctions {
+		funcMap["argon2"] = argon2Hash
+		funcMap["htpasswdCost"] = htpasswdCost
+	} else {
+		// Return a readable error if the password hash template functions are used without being enabled.
+		funcMap["argon2"] = func(string) (string, error) { return "", ErrPasswordHashDisabled }
+		funcMap["htpasswdCost"] = func(string, string, int) (string, error) { return "", ErrPasswordHashDisabled }
+	}
+
+	if !t.config.AllowRemoteSecrets {
+		// Return a readable error if the fromRemoteSecret template function is used without being enabled.
+		funcMap["fromRemoteSecret"] = func(string, string, string, string, string) (string, error) {
+			return "", ErrRemoteSecretsDisabled
+		}
+	} else if options.EncryptionEnabled {
+		funcMap["fromRemoteSecret"] = t.fromRemoteSecretProtectedHelper(options, resolvedResult)
+	} else {
+		funcMap["fromRemoteSecret"] = t.fromRemoteSecretHelper(options, resolvedResult)
+	}
+
+	if options.DryRunUnresolvable {
+		setDryRunPlaceholders(funcMap)
+	}
+
 	for _, funcName := range t.config.DisabledFunctions {
 		delete(funcMap, funcName)
 	}
@@ -609,52 +1565,52 @@ func (t *TemplateResolver) ResolveTemplate(
 		funcMap[customFuncName] = customFunc
 	}
 
-	// create template processor and Initialize function map
-	tmpl := template.New("tmpl").Delims(t.config.StartDelim, t.config.StopDelim).Funcs(funcMap)
-
-	// convert the JSON to YAML if necessary
-	var templateStr string
-
-	if !options.InputIsYAML {
-		templateYAMLBytes, err := JSONToYAML(tmplRaw)
-		if err != nil {
-			return resolvedResult, fmt.Errorf("failed to convert the policy template to YAML: %w", err)
-		}
+	if t.config.ExplicitDataTypes {
+		funcMap[explicitDataTypeFunc] = explicitDataType
+	}
 
-		templateStr = string(templateYAMLBytes)
-	} else {
-		templateStr = string(tmplRaw)
+	if t.config.StructuredValues {
+		funcMap[structuredValueFunc] = structuredValue
 	}
 
-	klog.V(2).Infof("Initial template str to resolve : %v ", templateStr)
+	if t.config.MissingKeyPlaceholder != "" {
+		funcMap[missingKeyPlaceholderFunc] = missingKeyPlaceholder(t.config.MissingKeyPlaceholder)
+	}
 
-	if options.DecryptionEnabled {
-		templateStr, err = t.processEncryptedStrs(options, &resolvedResult, templateStr)
-		if err != nil {
-			return resolvedResult, err
-		}
+	if options.CollectFunctionStats {
+		countFunctionCalls(funcMap, resolvedResult.FunctionCalls)
 	}
 
-	// processForDataTypes handles scenarios where quotes need to be removed for
-	// special data types or cases where multiple values are returned
-	templateStr = t.processForDataTypes(templateStr)
+	// create template processor and Initialize function map
+	tmpl := template.New("tmpl").Delims(t.config.StartDelim, t.config.StopDelim).Funcs(funcMap)
 
-	// convert `autoindent` placeholders to `indent N`
-	if strings.Contains(templateStr, "autoindent") {
-		templateStr = t.processForAutoIndent(templateStr)
+	var (
+		templateStr string
+		jsonTmpl    *jsonNativeTemplate
+		err         error
+	)
+
+	if options.JSONNative {
+		jsonTmpl, err = t.parseJSONNative(tmpl, tmplRaw, options, resolvedResult)
+	} else {
+		templateStr, err = t.parseTemplate(tmpl, tmplRaw, ctx, options, resolvedResult)
 	}
 
-	tmpl, err = tmpl.Parse(templateStr)
 	if err != nil {
-		tmplRawStr := string(tmplRaw)
-		klog.Errorf(
-			"error parsing template string %v,\n template str %v,\n error: %v", tmplRawStr, templateStr, err,
-		)
-
-		return resolvedResult, fmt.Errorf("failed to parse the template JSON string %v: %w", tmplRawStr, err)
+		return nil, nil, err
 	}
 
-	var buf bytes.Buffer
+	if t.config.StrictArgumentCounts {
+		if options.JSONNative {
+			err = jsonTmpl.checkArgumentCounts(funcMap)
+		} else {
+			err = checkArgumentCounts(tmpl, funcMap)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// If the dynamic watcher caching style is disabled, clear the cache after resolving the template.
 	if t.tempCallCache != nil {
@@ -664,14 +1620,26 @@ func (t *TemplateResolver) ResolveTemplate(
 	if t.dynamicWatcher != nil {
 		watcher := *options.Watcher
 
-		if !t.config.SkipBatchManagement {
+		if t.config.CoalesceQueryBatches && !t.config.SkipBatchManagement {
+			err := t.startSharedQueryBatch(watcher)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			defer func() {
+				err := t.endSharedQueryBatch(watcher)
+				if err != nil {
+					klog.Errorf("failed to end the query batch for %s: %v", watcher, err)
+				}
+			}()
+		} else if !t.config.SkipBatchManagement {
 			err := t.dynamicWatcher.StartQueryBatch(watcher)
 			if err != nil {
 				if !errors.Is(err, client.ErrQueryBatchInProgress) {
-					return resolvedResult, err
+					return nil, nil, err
 				}
 
-				return resolvedResult, fmt.Errorf(
+				return nil, nil, fmt.Errorf(
 					"ResolveTemplate cannot be called with the same watchedObject in parallel: %w", err,
 				)
 			}
@@ -691,33 +1659,529 @@ func (t *TemplateResolver) ResolveTemplate(
 
 			ctx, err = contextTransformer(&queryObj, context)
 			if err != nil {
-				return resolvedResult, fmt.Errorf(
+				return nil, nil, fmt.Errorf(
 					"%w at options.ContextTransformers[%d]: %w", ErrContextTransformerFailed, i, err,
 				)
 			}
+
+			ctx = filterContextFields(ctx, options.AllowedContextFields)
 		}
 	}
 
-	err = tmpl.Execute(&buf, ctx)
+	if jsonTmpl != nil {
+		resolvedJSON, err := jsonTmpl.execute(ctx, options, resolvedResult)
+
+		return nil, resolvedJSON, err
+	}
+
+	return t.executeTemplate(tmpl, templateStr, tmplRaw, ctx, options, resolvedResult)
+}
+
+// parseTemplate converts the input to YAML if it isn't already, prepares it for the template functions and data types
+// that need special handling, and parses it into the input template. The prepared template string is returned.
+func (t *TemplateResolver) parseTemplate(
+	tmpl *template.Template,
+	tmplRaw []byte,
+	ctx interface{},
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) (string, error) {
+	templateStr, err := templateInputToYAML(tmplRaw, options.InputIsYAML)
+	if err != nil {
+		return "", err
+	}
+
+	klog.V(2).Infof("Initial template str to resolve : %v ", templateStr)
+
+	if options.DecryptionEnabled {
+		templateStr, err = t.processEncryptedStrs(options, templateResult, templateStr)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// processForDataTypes handles scenarios where quotes need to be removed for
+	// special data types or cases where multiple values are returned
+	templateStr = t.processForDataTypes(templateStr)
+
+	if options.AutoTypeNumericContext {
+		templateStr = t.processForNumericContext(templateStr, numericContextFields(reflect.ValueOf(ctx), ""))
+	}
+
+	if t.config.StructuredValues {
+		templateStr = t.processForStructuredValues(templateStr)
+	}
+
+	if t.config.ExplicitDataTypes {
+		templateStr = t.processForExplicitDataTypes(templateStr)
+	}
+
+	// convert `autoindent` placeholders to `indent N`
+	if strings.Contains(templateStr, "autoindent") {
+		templateStr = t.processForAutoIndent(templateStr)
+	}
+
+	err = t.checkPinnedSprigFunctions(templateStr)
+	if err != nil {
+		return "", err
+	}
+
+	if templateResult.Metrics != nil {
+		templateResult.Metrics.Actions = len(
+			ScanTemplates([]byte(templateStr), t.config.StartDelim, t.config.StopDelim).Actions,
+		)
+	}
+
+	_, err = tmpl.Parse(templateStr)
+	if err != nil {
+		tmplRawStr := string(tmplRaw)
+		klog.Errorf(
+			"error parsing template string %v,\n template str %v,\n error: %v", tmplRawStr, templateStr, err,
+		)
+
+		return "", newTemplateParseError(tmplRawStr, err)
+	}
+
+	if t.config.MissingKeyPlaceholder != "" {
+		applyMissingKeyPlaceholder(tmpl)
+	}
+
+	return templateStr, nil
+}
+
+// executeTemplate executes the template parsed by parseTemplate and returns the resolved template as YAML and JSON
+// after the post-processing set in the Config and ResolveOptions.
+func (t *TemplateResolver) executeTemplate(
+	tmpl *template.Template,
+	templateStr string,
+	tmplRaw []byte,
+	ctx interface{},
+	options *ResolveOptions,
+	templateResult *TemplateResult,
+) ([]byte, []byte, error) {
+	var buf bytes.Buffer
+
+	if options.TrackProvenance {
+		templateResult.provenanceOutput = &buf
+	}
+
+	err := tmpl.Execute(&buf, ctx)
+
+	templateResult.provenanceOutput = nil
+
 	if err != nil {
 		tmplRawStr := string(tmplRaw)
 		klog.Errorf("error resolving the template %v,\n template str %v,\n error: %v", tmplRawStr, templateStr, err)
 
-		return resolvedResult, fmt.Errorf("failed to resolve the template %v: %w", tmplRawStr, err)
+		return nil, nil, fmt.Errorf("failed to resolve the template %v: %w", tmplRawStr, err)
 	}
 
 	resolvedTemplateStr := buf.String()
 	klog.V(3).Infof("resolved template str: %v ", resolvedTemplateStr)
 	// unmarshall before returning
 
-	resolvedTemplateBytes, err := yamlToJSON(buf.Bytes())
+	resolvedYAMLBytes, resolvedJSONBytes, err := t.postProcessResolved(buf.Bytes(), options, templateResult)
 	if err != nil {
-		return resolvedResult, fmt.Errorf("failed to convert the resolved template to JSON: %w", err)
+		return nil, nil, err
 	}
 
-	resolvedResult.ResolvedJSON = resolvedTemplateBytes
+	if options.TrackProvenance {
+		err = templateResult.buildProvenance(buf.Bytes())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-	return resolvedResult, nil
+	return resolvedYAMLBytes, resolvedJSONBytes, nil
+}
+
+// postProcessResolved applies the post-processing set in the Config and ResolveOptions to the resolved YAML and returns
+// the result as YAML and JSON.
+func (t *TemplateResolver) postProcessResolved(
+	resolvedYAMLBytes []byte, options *ResolveOptions, templateResult *TemplateResult,
+) ([]byte, []byte, error) {
+	var err error
+
+	if t.config.ExplicitDataTypes {
+		resolvedYAMLBytes, err = applyExplicitDataTypes(resolvedYAMLBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to set the explicit data types in the resolved template: %w", err)
+		}
+	}
+
+	if t.config.StructuredValues {
+		resolvedYAMLBytes, err = applyStructuredValues(resolvedYAMLBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to set the structured values in the resolved template: %w", err)
+		}
+	}
+
+	if options.MaskEncryptedForDisplay {
+		resolvedYAMLBytes, templateResult.EncryptedValuesMasked = maskEncryptedStrs(resolvedYAMLBytes)
+	}
+
+	if options.WrapInList {
+		resolvedYAMLBytes, err = wrapInList(resolvedYAMLBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to wrap the resolved template in a List: %w", err)
+		}
+	}
+
+	resolvedJSONBytes, err := yamlToJSON(resolvedYAMLBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert the resolved template to JSON: %w", err)
+	}
+
+	return resolvedYAMLBytes, resolvedJSONBytes, nil
+}
+
+// countFunctionCalls replaces the functions in the function map with functions that increment the count of the
+// function name in calls before calling the original function. The internal functions added by processForDataTypes
+// are not counted, and includeTemplate counts its own calls so that nested includes are also counted.
+func countFunctionCalls(funcMap template.FuncMap, calls map[string]int) {
+	for name, fn := range funcMap {
+		if name == explicitDataTypeFunc || name == structuredValueFunc || name == missingKeyPlaceholderFunc ||
+			name == "includeTemplate" {
+			continue
+		}
+
+		fnValue := reflect.ValueOf(fn)
+		if fnValue.Kind() != reflect.Func {
+			continue
+		}
+
+		funcMap[name] = reflect.MakeFunc(fnValue.Type(), func(args []reflect.Value) []reflect.Value {
+			calls[name]++
+
+			if fnValue.Type().IsVariadic() {
+				return fnValue.CallSlice(args)
+			}
+
+			return fnValue.Call(args)
+		}).Interface()
+	}
+}
+
+// applyPostResolve unmarshals the resolved JSON, passes it to the postResolve function, and returns the marshaled
+// result. An error returned by the function is wrapped in ErrPostResolveFailed.
+func applyPostResolve(
+	resolvedJSON []byte, postResolve func(map[string]interface{}) (map[string]interface{}, error),
+) ([]byte, error) {
+	var resolved map[string]interface{}
+
+	err := json.Unmarshal(resolvedJSON, &resolved)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: the resolved template must be an object to use PostResolve: %w", ErrInvalidInput, err,
+		)
+	}
+
+	resolved, err = postResolve(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPostResolveFailed, err)
+	}
+
+	modifiedJSON, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the returned object is invalid JSON: %w", ErrPostResolveFailed, err)
+	}
+
+	return modifiedJSON, nil
+}
+
+// removeEmptyFields unmarshals the resolved JSON, removes the map keys with an empty string or null value as described
+// in ResolveOptions.RemoveEmptyFields, and returns the marshaled result. Numbers are kept as is.
+func removeEmptyFields(resolvedJSON []byte) ([]byte, error) {
+	var resolved interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(resolvedJSON))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the resolved template: %w", err)
+	}
+
+	pruneEmptyFields(resolved)
+
+	prunedJSON, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the resolved template after removing the empty fields: %w", err)
+	}
+
+	return prunedJSON, nil
+}
+
+// pruneEmptyFields removes the map keys with an empty string or null value in the input, including in nested maps and
+// lists, and the keys of the nested maps that are empty after pruning. It returns true if the input is a map that had
+// keys and is empty after pruning, in which case the parent map removes it as well.
+func pruneEmptyFields(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if len(typedValue) == 0 {
+			return false
+		}
+
+		for key, field := range typedValue {
+			if field == nil || field == "" || pruneEmptyFields(field) {
+				delete(typedValue, key)
+			}
+		}
+
+		return len(typedValue) == 0
+	case []interface{}:
+		for _, item := range typedValue {
+			pruneEmptyFields(item)
+		}
+	}
+
+	return false
+}
+
+// renderEmptyMaps unmarshals the resolved JSON, renders the empty maps, empty lists, and null values as described in
+// Config.EmptyMapRendering, and returns the marshaled result. Numbers are kept as is.
+func renderEmptyMaps(resolvedJSON []byte, rendering EmptyMapRendering) ([]byte, error) {
+	var resolved interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(resolvedJSON))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the resolved template: %w", err)
+	}
+
+	renderedJSON, err := json.Marshal(renderEmptyValue(resolved, rendering))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the resolved template after rendering the empty maps: %w", err)
+	}
+
+	return renderedJSON, nil
+}
+
+// isEmptyValue returns true if the value is null, an empty map, or an empty list.
+func isEmptyValue(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(typedValue) == 0
+	case []interface{}:
+		return len(typedValue) == 0
+	default:
+		return false
+	}
+}
+
+// renderEmptyValue returns the value with the empty maps, empty lists, and null values in it, including in nested maps
+// and lists, rendered based on the EmptyMapRendering. The maps and lists in the input are modified in place.
+func renderEmptyValue(value interface{}, rendering EmptyMapRendering) interface{} {
+	switch typedValue := value.(type) {
+	case nil:
+		if rendering == EmptyMapRenderingEmptyObject {
+			return map[string]interface{}{}
+		}
+	case map[string]interface{}:
+		for key, field := range typedValue {
+			rendered := renderEmptyValue(field, rendering)
+
+			if rendering == EmptyMapRenderingOmit && isEmptyValue(rendered) {
+				delete(typedValue, key)
+
+				continue
+			}
+
+			typedValue[key] = rendered
+		}
+
+		if rendering == EmptyMapRenderingNull && len(typedValue) == 0 {
+			return nil
+		}
+	case []interface{}:
+		for i, item := range typedValue {
+			typedValue[i] = renderEmptyValue(item, rendering)
+		}
+
+		if rendering == EmptyMapRenderingNull && len(typedValue) == 0 {
+			return nil
+		}
+	}
+
+	return value
+}
+
+// rejectConfigMaps returns an error wrapping ErrSensitiveDataInConfigMap if the resolved JSON or an "objectDefinition"
+// in it is a ConfigMap.
+func rejectConfigMaps(resolvedJSON []byte) error {
+	var resolved interface{}
+
+	err := json.Unmarshal(resolvedJSON, &resolved)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal the resolved template: %w", err)
+	}
+
+	for _, objectDefinition := range collectObjectDefinitions(resolved, true) {
+		obj := unstructured.Unstructured{Object: objectDefinition}
+
+		if obj.GetAPIVersion() == "v1" && obj.GetKind() == "ConfigMap" {
+			return fmt.Errorf(
+				"%w: the ConfigMap %s must not contain sensitive data, use a Secret instead",
+				ErrSensitiveDataInConfigMap, obj.GetName(),
+			)
+		}
+	}
+
+	return nil
+}
+
+// validateMetadata returns an error wrapping ErrInvalidMetadata if the label keys, label values, or annotation keys of
+// the resolved JSON or an "objectDefinition" in it are invalid for Kubernetes. The error lists every invalid entry.
+func validateMetadata(resolvedJSON []byte) error {
+	var resolved interface{}
+
+	err := json.Unmarshal(resolvedJSON, &resolved)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal the resolved template: %w", err)
+	}
+
+	invalid := []string{}
+
+	for _, objectDefinition := range collectObjectDefinitions(resolved, true) {
+		obj := unstructured.Unstructured{Object: objectDefinition}
+		objID := fmt.Sprintf("the %s %s", obj.GetKind(), obj.GetName())
+
+		for _, field := range []string{"labels", "annotations"} {
+			fieldPath := k8sfield.NewPath("metadata", field)
+
+			values, _, err := unstructured.NestedStringMap(obj.Object, "metadata", field)
+			if err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s: %s: must be a map of strings", objID, fieldPath))
+
+				continue
+			}
+
+			var fieldErrs k8sfield.ErrorList
+
+			if field == "labels" {
+				fieldErrs = metav1validation.ValidateLabels(values, fieldPath)
+			} else {
+				fieldErrs = apivalidation.ValidateAnnotations(values, fieldPath)
+			}
+
+			for _, fieldErr := range fieldErrs {
+				invalid = append(invalid, fmt.Sprintf("%s: %s", objID, fieldErr.Error()))
+			}
+		}
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	sort.Strings(invalid)
+
+	return fmt.Errorf("%w: %s", ErrInvalidMetadata, strings.Join(invalid, "; "))
+}
+
+// wrapInList wraps the input YAML in a "v1" "List" object if it's an array or empty. The YAML node tree is used so that
+// comments are retained.
+func wrapInList(y []byte) ([]byte, error) {
+	var node yaml.Node
+
+	err := yaml.Unmarshal(y, &node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if node.Kind == 0 {
+		node = yaml.Node{Kind: yaml.DocumentNode}
+	}
+
+	var items *yaml.Node
+
+	switch {
+	case len(node.Content) == 0:
+		items = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	case node.Content[0].Kind == yaml.SequenceNode:
+		items = node.Content[0]
+	case node.Content[0].ShortTag() == "!!null":
+		items = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", HeadComment: node.Content[0].HeadComment}
+	default:
+		return y, nil
+	}
+
+	scalar := func(value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	}
+
+	node.Content = []*yaml.Node{{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			scalar("apiVersion"), scalar("v1"), scalar("kind"), scalar("List"), scalar("items"), items,
+		},
+	}}
+
+	var b bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&b)
+	yamlEncoder.SetIndent(yamlIndentation)
+
+	err = yamlEncoder.Encode(&node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return b.Bytes(), nil
+}
+
+// TemplateResultWithOriginal is the result of ResolveTemplateWithOriginal.
+type TemplateResultWithOriginal struct {
+	TemplateResult
+	// OriginalJSON is the unresolved input template normalized to JSON the same way as the input is normalized before
+	// template resolution.
+	OriginalJSON []byte
+}
+
+// ResolveTemplateWithOriginal is the same as ResolveTemplate except that the result also contains the unresolved input
+// template normalized to JSON. This is useful for storing both versions for auditing or producing consistent diffs
+// without normalizing the input separately. Since the input is normalized before template resolution, YAML input
+// (i.e. options.InputIsYAML is true) must be valid YAML before the templates are resolved.
+func (t *TemplateResolver) ResolveTemplateWithOriginal(
+	tmplRaw []byte, context interface{}, options *ResolveOptions,
+) (TemplateResultWithOriginal, error) {
+	var result TemplateResultWithOriginal
+
+	if options == nil {
+		options = &ResolveOptions{}
+	}
+
+	templateStr, err := templateInputToYAML(tmplRaw, options.InputIsYAML)
+	if err != nil {
+		return result, err
+	}
+
+	result.OriginalJSON, err = yamlToJSON([]byte(templateStr))
+	if err != nil {
+		return result, fmt.Errorf("failed to convert the original template to JSON: %w", err)
+	}
+
+	result.TemplateResult, err = t.ResolveTemplate(tmplRaw, context, options)
+
+	return result, err
+}
+
+// templateInputToYAML returns the input template as the YAML string that is resolved. JSON input is converted to YAML.
+func templateInputToYAML(tmplRaw []byte, inputIsYAML bool) (string, error) {
+	if inputIsYAML {
+		return string(tmplRaw), nil
+	}
+
+	templateYAMLBytes, err := JSONToYAML(tmplRaw)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert the policy template to YAML: %w", err)
+	}
+
+	return string(templateYAMLBytes), nil
 }
 
 // UncacheWatcher will clear the watcher from the cache and remove all associated API watches.
@@ -760,6 +2224,23 @@ func (t *TemplateResolver) GetWatchCount() uint {
 	return 0
 }
 
+// EffectiveConfig returns a copy of the Config used by the TemplateResolver with the defaults set by the constructor,
+// such as the delimiters and MaxResolveDepth, filled in. Fields without a default, such as a nil
+// OutputTrailingNewline, are returned as they were set. Modifying the returned Config has no effect on the
+// TemplateResolver.
+func (t *TemplateResolver) EffectiveConfig() Config {
+	config := t.config
+	config.DisabledFunctions = slices.Clone(t.config.DisabledFunctions)
+	config.PinnedSprigFunctions = slices.Clone(t.config.PinnedSprigFunctions)
+
+	if t.config.OutputTrailingNewline != nil {
+		outputTrailingNewline := *t.config.OutputTrailingNewline
+		config.OutputTrailingNewline = &outputTrailingNewline
+	}
+
+	return config
+}
+
 //nolint:wsl
 func (t *TemplateResolver) processForDataTypes(str string) string {
 	// The idea is to remove the quotes enclosing the template if it has toBool, toInt, or toLiteral.
@@ -771,6 +2252,7 @@ func (t *TemplateResolver) processForDataTypes(str string) string {
 	// ex-1 key : '{{ "6" | toInt }}'  .. is replaced with  key : {{ "6" | toInt }}
 	// ex-2 key : |
 	//						'{{ "true" | toBool }}' .. is replaced with key : {{ "true" | toBool }}
+	// ex-3 key : '{{- "6" | toInt -}}'  .. is replaced with  key : {{ "6" | toInt }}
 
 	// NOTES : on testing it was found that
 	// outer quotes around key-values are always single quotes
@@ -789,12 +2271,40 @@ func (t *TemplateResolver) processForDataTypes(str string) string {
 	}
 	klog.V(2).Infof("\n All Submatches:\n%v", submatchall)
 
-	processeddata := re.ReplaceAllString(str, ": $1")
+	processeddata := re.ReplaceAllStringFunc(str, func(match string) string {
+		return ": " + t.trimChompMarkers(re.FindStringSubmatch(match)[1])
+	})
 	klog.V(2).Infof("\n processed data :\n%v", processeddata)
 
 	return processeddata
 }
 
+// trimChompMarkers removes the whitespace chomping markers from the outer delimiters of the input template string, such
+// as `{{- "1" | toInt -}}` to `{{ "1" | toInt }}`. This is used when the quotes enclosing a template are removed or
+// its indentation is computed since the markers would otherwise trim the whitespace separating the template from the
+// YAML key, its indentation, or the next line.
+func (t *TemplateResolver) trimChompMarkers(tmpl string) string {
+	leftMarker := t.config.StartDelim + "-"
+	if strings.HasPrefix(tmpl, leftMarker) && len(tmpl) > len(leftMarker) && isChompSpace(tmpl[len(leftMarker)]) {
+		tmpl = t.config.StartDelim + tmpl[len(leftMarker):]
+	}
+
+	rightMarker := "-" + t.config.StopDelim
+	rightMarkerStart := len(tmpl) - len(rightMarker)
+
+	if strings.HasSuffix(tmpl, rightMarker) && rightMarkerStart > len(t.config.StartDelim) &&
+		isChompSpace(tmpl[rightMarkerStart-1]) {
+		tmpl = tmpl[:rightMarkerStart] + t.config.StopDelim
+	}
+
+	return tmpl
+}
+
+// isChompSpace returns true if the input character is whitespace that text/template requires around a chomping marker.
+func isChompSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
 // processForAutoIndent converts any `autoindent` placeholders into `indent N` in the string.
 // The processed input string is returned.
 func (t *TemplateResolver) processForAutoIndent(str string) string {
@@ -805,7 +2315,7 @@ func (t *TemplateResolver) processForAutoIndent(str string) string {
 	// This is not a very strict regex as occasionally, a user will make a mistake such as
 	// `config: '{{ "hello\nworld" | autoindent }}'`. In that event, `autoindent` will change to
 	// `indent 1`, but `indent` properly handles this.
-	re := regexp.MustCompile(`( *)(?:'|")?(` + d1 + `.*\| *autoindent *` + d2 + `)`)
+	re := regexp.MustCompile(`( *)(?:'|")?(` + d1 + `.*\| *autoindent *-?` + d2 + `)`)
 	klog.V(2).Infof("\n Pattern: %v\n", re.String())
 
 	submatches := re.FindAllStringSubmatch(str, -1)
@@ -816,7 +2326,9 @@ func (t *TemplateResolver) processForAutoIndent(str string) string {
 	for _, submatch := range submatches {
 		numSpaces := len(submatch[1]) - int(t.config.AdditionalIndentation)
 		matchStr := submatch[2]
-		newMatchStr := strings.Replace(matchStr, "autoindent", fmt.Sprintf("indent %d", numSpaces), 1)
+		newMatchStr := strings.Replace(
+			t.trimChompMarkers(matchStr), "autoindent", fmt.Sprintf("indent %d", numSpaces), 1,
+		)
 		processed = strings.Replace(processed, matchStr, newMatchStr, 1)
 	}
 
@@ -849,6 +2361,135 @@ func JSONToYAML(j []byte) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// JSONToYAMLWithStyle is the same as JSONToYAML but the string values are formatted in the input style. An empty
+// style is the same as StringStylePreserve.
+func JSONToYAMLWithStyle(j []byte, style StringStyle) ([]byte, error) {
+	if !validStringStyle(style) {
+		return nil, fmt.Errorf("%w: the string style of %s is not supported", ErrInvalidInput, style)
+	}
+
+	var jsonObj interface{}
+
+	err := yaml.Unmarshal(j, &jsonObj)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	var node yaml.Node
+
+	err = node.Encode(jsonObj)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return encodeYAMLNode(&node, style)
+}
+
+// SetTrailingNewline returns the input YAML ending with a newline if trailingNewline is true, or with the final
+// newline removed if it is false. An empty input is returned as is. Note that with a keep chomping indicator, such as
+// "|+", on the last value, the removed newline is a part of the value.
+func SetTrailingNewline(y []byte, trailingNewline bool) []byte {
+	if len(y) == 0 {
+		return y
+	}
+
+	if !trailingNewline {
+		return bytes.TrimSuffix(y, []byte("\n"))
+	}
+
+	if bytes.HasSuffix(y, []byte("\n")) {
+		return y
+	}
+
+	return append(y, '\n')
+}
+
+// formatYAML formats the YAML with consistent indentation and the input string style while retaining the comments by
+// using the YAML node tree rather than unmarshaling to an object.
+func formatYAML(y []byte, style StringStyle) ([]byte, error) {
+	var node yaml.Node
+
+	err := yaml.Unmarshal(y, &node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	// The input was empty or only had comments
+	if node.Kind == 0 {
+		return []byte{}, nil
+	}
+
+	return encodeYAMLNode(&node, style)
+}
+
+// encodeYAMLNode applies the string style to the YAML node tree and encodes it with consistent indentation.
+func encodeYAMLNode(node *yaml.Node, style StringStyle) ([]byte, error) {
+	applyStringStyle(node, style)
+
+	var b bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&b)
+	yamlEncoder.SetIndent(yamlIndentation)
+
+	err := yamlEncoder.Encode(node)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return b.Bytes(), nil
+}
+
+func validEmptyMapRendering(rendering EmptyMapRendering) bool {
+	switch rendering {
+	case "", EmptyMapRenderingNull, EmptyMapRenderingEmptyObject, EmptyMapRenderingOmit:
+		return true
+	default:
+		return false
+	}
+}
+
+func validStringStyle(style StringStyle) bool {
+	switch style {
+	case "", StringStylePreserve, StringStyleDoubleQuoted, StringStyleSingleQuoted, StringStyleLiteralForMultiline:
+		return true
+	default:
+		return false
+	}
+}
+
+// applyStringStyle recursively sets the style of the string value nodes in the YAML node tree. Mapping keys are left
+// as is.
+func applyStringStyle(node *yaml.Node, style StringStyle) {
+	if style == "" || style == StringStylePreserve {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			applyStringStyle(child, style)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			applyStringStyle(node.Content[i], style)
+		}
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!str" {
+			return
+		}
+
+		switch style {
+		case StringStyleDoubleQuoted:
+			node.Style = yaml.DoubleQuotedStyle
+		case StringStyleSingleQuoted:
+			node.Style = yaml.SingleQuotedStyle
+		case StringStyleLiteralForMultiline:
+			if strings.Contains(node.Value, "\n") {
+				node.Style = yaml.LiteralStyle
+			}
+		}
+	}
+}
+
 // yamlToJSON converts YAML to JSON.
 func yamlToJSON(y []byte) ([]byte, error) {
 	// Convert the YAML to an object.
@@ -930,3 +2571,16 @@ func (c *cachingQueryAPI) List(
 ) ([]unstructured.Unstructured, error) {
 	return c.dynamicWatcher.List(c.watcher, gvk, namespace, selector)
 }
+
+// ListByStrings is the same as CachingQueryAPI.List but the label selector requirements are parsed and joined from
+// strings in the same way as the lookup template function (e.g. "env=prod", "app in (a, b)").
+func ListByStrings(
+	api CachingQueryAPI, gvk schema.GroupVersionKind, namespace string, selectors ...string,
+) ([]unstructured.Unstructured, error) {
+	selector, err := parseLabelSelector(selectors...)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.List(gvk, namespace, selector)
+}
@@ -74,6 +74,21 @@ func setUp() {
 		panic(err.Error())
 	}
 
+	// labeled namespaces to test matchingNamespaces
+	for name, env := range map[string]string{"matchns-prod-a": "prod", "matchns-prod-b": "prod", "matchns-dev": "dev"} {
+		labeledNs := corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"matchns-env": env},
+			},
+		}
+
+		_, err = k8sClient.CoreV1().Namespaces().Create(ctx, &labeledNs, metav1.CreateOptions{})
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+
 	// sample secret
 	secret := corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -185,6 +200,28 @@ func setUp() {
 		panic(err.Error())
 	}
 
+	// sample configmap with template snippets to test includeTemplate. This is in the default namespace to not
+	// affect the list queries in the test namespace.
+	configmapTemplates := corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "testtemplates",
+		},
+		Data: map[string]string{
+			"greeting":  `Hello {{ .ClusterName }}{{ includeTemplate "default" "testtemplates" "suffix" . }}`,
+			"suffix":    `! {{ fromConfigMap "testns" "testconfigmap" "cmkey1" }}`,
+			"recursive": `{{ includeTemplate "default" "testtemplates" "recursive" . }}`,
+		},
+	}
+
+	_, err = k8sClient.CoreV1().ConfigMaps("default").Create(ctx, &configmapTemplates, metav1.CreateOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
 	// sample Nodes to test Infra node lookups
 	nodea1 := corev1.Node{
 		TypeMeta: metav1.TypeMeta{
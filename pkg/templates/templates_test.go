@@ -6,17 +6,30 @@ package templates
 import (
 	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/stolostron/kubernetes-dependency-watches/client"
 	yaml "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -37,6 +50,57 @@ func TestNewResolver(t *testing.T) {
 	}
 }
 
+func TestEffectiveConfig(t *testing.T) {
+	t.Parallel()
+
+	outputTrailingNewline := false
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{
+		AdditionalIndentation: 4,
+		DisabledFunctions:     []string{"lookup"},
+		OutputTrailingNewline: &outputTrailingNewline,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	config := resolver.EffectiveConfig()
+
+	if config.StartDelim != defaultStartDelim || config.StopDelim != defaultStopDelim {
+		t.Fatalf("Expected delimiters: {{ and }}  got: %s and %s", config.StartDelim, config.StopDelim)
+	}
+
+	if config.MaxResolveDepth != defaultMaxDepth {
+		t.Fatalf("expected : %d , got : %d", defaultMaxDepth, config.MaxResolveDepth)
+	}
+
+	if config.AdditionalIndentation != 4 || !slices.Equal(config.DisabledFunctions, []string{"lookup"}) ||
+		config.OutputTrailingNewline == nil || *config.OutputTrailingNewline {
+		t.Fatalf("expected the configured values to be returned, got : %+v", config)
+	}
+
+	// Modifying the returned Config must not affect the resolver
+	config.DisabledFunctions[0] = "fromSecret"
+	*config.OutputTrailingNewline = true
+
+	config = resolver.EffectiveConfig()
+
+	if config.DisabledFunctions[0] != "lookup" || *config.OutputTrailingNewline {
+		t.Fatalf("expected the returned Config to be a copy, got : %+v", config)
+	}
+
+	resolver, err = NewResolverFromSnapshot(nil, Config{StartDelim: "{{hub", StopDelim: "hub}}", MaxResolveDepth: 3})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	config = resolver.EffectiveConfig()
+
+	if config.StartDelim != "{{hub" || config.StopDelim != "hub}}" || config.MaxResolveDepth != 3 {
+		t.Fatalf("expected the configured values to be returned, got : %+v", config)
+	}
+}
+
 func TestNewResolverWithCaching(t *testing.T) {
 	t.Parallel()
 
@@ -92,6 +156,43 @@ func TestNewResolverFailures(t *testing.T) {
 			ResolveOptions{},
 			"the configurations StartDelim and StopDelim cannot be set independently",
 		},
+		{
+			Config{StartDelim: "%%", StopDelim: "%%"},
+			ResolveOptions{},
+			"the input is invalid: the configurations StartDelim and StopDelim cannot be the same",
+		},
+		{
+			Config{StartDelim: "<<", StopDelim: "<<<"},
+			ResolveOptions{},
+			"the input is invalid: the configurations StartDelim and StopDelim cannot contain each other, " +
+				"got << and <<<",
+		},
+		{
+			Config{StartDelim: "{{ ", StopDelim: "}}"},
+			ResolveOptions{},
+			`the input is invalid: the configuration StartDelim of "{{ " cannot contain whitespace, quotes, or #`,
+		},
+		{
+			Config{StartDelim: "[[", StopDelim: "#]]"},
+			ResolveOptions{},
+			`the input is invalid: the configuration StopDelim of "#]]" cannot contain whitespace, quotes, or #`,
+		},
+		{
+			Config{OutputStringStyle: "plain"},
+			ResolveOptions{},
+			"the input is invalid: the OutputStringStyle of plain is not supported",
+		},
+		{
+			Config{EmptyMapRendering: "empty"},
+			ResolveOptions{},
+			"the input is invalid: the EmptyMapRendering of empty is not supported",
+		},
+		{
+			Config{MissingKeyPlaceholder: "#"},
+			ResolveOptions{},
+			`the input is invalid: the MissingKeyPlaceholder of "#" cannot contain quotes, backslashes, "#", ":", ` +
+				"or control characters",
+		},
 	}
 
 	for _, test := range testcases {
@@ -125,6 +226,20 @@ func TestNewResolverFailures(t *testing.T) {
 				t.Fatalf("error \"%s\" != \"%s\"", err.Error(), test.expectedErr)
 			}
 		})
+
+		t.Run("NewResolverWithDynamicWatcher: "+testName, func(t *testing.T) {
+			t.Parallel()
+
+			// The Config is validated before the DynamicWatcher is used
+			_, err := NewResolverWithDynamicWatcher(nil, test.config)
+			if err == nil {
+				t.Fatal("No error was provided")
+			}
+
+			if err.Error() != test.expectedErr {
+				t.Fatalf("error \"%s\" != \"%s\"", err.Error(), test.expectedErr)
+			}
+		})
 	}
 }
 
@@ -151,6 +266,17 @@ func TestValidateEncryptionFailures(t *testing.T) {
 			},
 			"initialization vector must be set to use this encryption mode",
 		},
+		{
+			ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey:               bytes.Repeat([]byte{byte('A')}, 256/8),
+					AESKeyFallbacks:      [][]byte{bytes.Repeat([]byte{byte('B')}, 256/8), []byte("short")},
+					DecryptionEnabled:    true,
+					InitializationVector: bytes.Repeat([]byte{byte('I')}, IVSize),
+				},
+			},
+			"the AES key is invalid: crypto/aes: invalid key size 5",
+		},
 	}
 
 	for _, test := range testcases {
@@ -222,6 +348,216 @@ func doResolveTest(t *testing.T, test resolveTestCase) {
 	}
 }
 
+func TestResolveTemplatePreserveComments(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{PreserveComments: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+	}{
+		"comments": {
+			inputTmpl: "# The app config\nkind: ConfigMap # the kind\ndata:\n  # The cluster name\n" +
+				"  name: '{{ .ClusterName }}'\n",
+			expectedResult: "# The app config\nkind: ConfigMap # the kind\ndata:\n  # The cluster name\n" +
+				"  name: 'cluster1'\n",
+		},
+		"comments_in_range": {
+			inputTmpl:      "items:\n{{- range $i := until 2 }}\n  # item {{ $i }}\n  - '{{ $i }}'\n{{- end }}\n",
+			expectedResult: "items:\n  # item 0\n  - '0'\n  # item 1\n  - '1'\n",
+		},
+		"comments_removed": {
+			inputTmpl:      "key: value\n{{- if false }}\n# removed\nother: value\n{{- end }}\n",
+			expectedResult: "key: value\n",
+		},
+		"only_comments": {
+			inputTmpl:      "# only a comment\n",
+			expectedResult: "",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), struct{ ClusterName string }{"cluster1"}, &ResolveOptions{InputIsYAML: true},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedYAML) != test.expectedResult {
+				t.Fatalf("expected : '%s' , got : '%s'", test.expectedResult, tmplResult.ResolvedYAML)
+			}
+		})
+	}
+
+	// Comments can't be preserved when the input is JSON
+	tmplResult, err := resolver.ResolveTemplate([]byte(`{"key": "value"}`), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if tmplResult.ResolvedYAML != nil {
+		t.Fatalf("expected ResolvedYAML to not be set for JSON input, got: '%s'", tmplResult.ResolvedYAML)
+	}
+}
+
+func TestJSONToYAMLWithStyle(t *testing.T) {
+	t.Parallel()
+
+	input := []byte(`{"name":"app","replicas":3,"enabled":true,"version":"1.0","script":"line1\nline2\n",` +
+		`"list":["a","b"]}`)
+
+	testcases := map[string]struct {
+		style          StringStyle
+		expectedResult string
+	}{
+		"default": {
+			style: "",
+			expectedResult: "enabled: true\nlist:\n  - a\n  - b\nname: app\nreplicas: 3\n" +
+				"script: |\n  line1\n  line2\nversion: \"1.0\"\n",
+		},
+		"preserve": {
+			style: StringStylePreserve,
+			expectedResult: "enabled: true\nlist:\n  - a\n  - b\nname: app\nreplicas: 3\n" +
+				"script: |\n  line1\n  line2\nversion: \"1.0\"\n",
+		},
+		"doubleQuoted": {
+			style: StringStyleDoubleQuoted,
+			expectedResult: "enabled: true\nlist:\n  - \"a\"\n  - \"b\"\nname: \"app\"\nreplicas: 3\n" +
+				"script: \"line1\\nline2\\n\"\nversion: \"1.0\"\n",
+		},
+		"singleQuoted": {
+			style: StringStyleSingleQuoted,
+			expectedResult: "enabled: true\nlist:\n  - 'a'\n  - 'b'\nname: 'app'\nreplicas: 3\n" +
+				"script: 'line1\n\n  line2\n\n'\nversion: '1.0'\n",
+		},
+		"literal-for-multiline": {
+			style: StringStyleLiteralForMultiline,
+			expectedResult: "enabled: true\nlist:\n  - a\n  - b\nname: app\nreplicas: 3\n" +
+				"script: |\n  line1\n  line2\nversion: \"1.0\"\n",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := JSONToYAMLWithStyle(input, test.style)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(val) != test.expectedResult {
+				t.Fatalf("expected : %q , got : %q", test.expectedResult, val)
+			}
+		})
+	}
+
+	_, err := JSONToYAMLWithStyle(input, "plain")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected err: %s got err: %v", ErrInvalidInput, err)
+	}
+}
+
+func TestResolveTemplateOutputStringStyle(t *testing.T) {
+	t.Parallel()
+
+	tmpl := "# The script\nscript: |\n  {{ \"line1\\nline2\" | autoindent }}\nname: '{{ .ClusterName }}'\n"
+	noTrailingNewline := false
+
+	testcases := map[string]struct {
+		config         Config
+		expectedResult string
+	}{
+		"literal-for-multiline": {
+			config:         Config{OutputStringStyle: StringStyleLiteralForMultiline},
+			expectedResult: "name: cluster1\nscript: |\n  line1\n  line2\n",
+		},
+		"literal-for-multiline_preserve_comments": {
+			config:         Config{OutputStringStyle: StringStyleLiteralForMultiline, PreserveComments: true},
+			expectedResult: "# The script\nscript: |\n  line1\n  line2\nname: 'cluster1'\n",
+		},
+		"doubleQuoted_preserve_comments": {
+			config:         Config{OutputStringStyle: StringStyleDoubleQuoted, PreserveComments: true},
+			expectedResult: "# The script\nscript: \"line1\\nline2\\n\"\nname: \"cluster1\"\n",
+		},
+		"no_trailing_newline": {
+			config: Config{
+				OutputStringStyle: StringStyleLiteralForMultiline, OutputTrailingNewline: &noTrailingNewline,
+			},
+			expectedResult: "name: cluster1\nscript: |\n  line1\n  line2",
+		},
+		"no_trailing_newline_preserve_comments": {
+			config:         Config{PreserveComments: true, OutputTrailingNewline: &noTrailingNewline},
+			expectedResult: "# The script\nscript: |\n  line1\n  line2\nname: 'cluster1'",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolverFromSnapshot(nil, test.config)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			tmplResult, err := resolver.ResolveTemplate(
+				[]byte(tmpl), struct{ ClusterName string }{"cluster1"}, &ResolveOptions{InputIsYAML: true},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedYAML) != test.expectedResult {
+				t.Fatalf("expected : %q , got : %q", test.expectedResult, tmplResult.ResolvedYAML)
+			}
+		})
+	}
+}
+
+func TestSetTrailingNewline(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input           string
+		trailingNewline bool
+		expected        string
+	}{
+		"add":             {"name: app", true, "name: app\n"},
+		"keep":            {"name: app\n", true, "name: app\n"},
+		"remove":          {"name: app\n", false, "name: app"},
+		"already_removed": {"name: app", false, "name: app"},
+		"empty":           {"", true, ""},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val := SetTrailingNewline([]byte(test.input), test.trailingNewline)
+			if string(val) != test.expected {
+				t.Fatalf("expected : %q , got : %q", test.expected, val)
+			}
+		})
+	}
+}
+
 func TestResolveTemplateWithCaching(t *testing.T) {
 	t.Parallel()
 
@@ -345,6 +681,121 @@ data5: '{{ customFunc }}'
 	}
 }
 
+func TestResolveTemplateTrackReferences(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplStr := `
+data1: '{{ fromSecret "testns" "testsecret" "secretkey1" }}'
+data2: '{{ fromSecret "testns" "testsecret" "secretkey2" }}'
+data3: '{{ (lookup "v1" "ConfigMap" "testns" "does-not-exist").data.key }}'
+data4: '{{ (lookup "v1" "ConfigMap" "testns" "" "env=a").items | len }}'
+`
+
+	tmplStrBytes, err := yamlToJSON([]byte(tmplStr))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	result, err := resolver.ResolveTemplate(tmplStrBytes, nil, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(result.ReferencedObjects) != 0 {
+		t.Fatalf("Expected no referenced objects without TrackReferences but got %v", result.ReferencedObjects)
+	}
+
+	result, err = resolver.ResolveTemplate(tmplStrBytes, nil, &ResolveOptions{TrackReferences: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []client.ObjectIdentifier{
+		{Version: "v1", Kind: "Secret", Namespace: "testns", Name: "testsecret"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "does-not-exist"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Selector: "env=a"},
+	}
+
+	if !reflect.DeepEqual(result.ReferencedObjects, expected) {
+		t.Fatalf("Expected referenced objects %v but got %v", expected, result.ReferencedObjects)
+	}
+
+	expectedNotFound := []client.ObjectIdentifier{expected[1]}
+
+	if !reflect.DeepEqual(result.NotFoundObjects, expectedNotFound) {
+		t.Fatalf("Expected not found objects %v but got %v", expectedNotFound, result.NotFoundObjects)
+	}
+}
+
+func TestTemplatesToReResolve(t *testing.T) {
+	t.Parallel()
+
+	previous := []TemplateResult{
+		{ReferencedObjects: []client.ObjectIdentifier{
+			{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "testconfigmap"},
+		}},
+		{ReferencedObjects: []client.ObjectIdentifier{
+			{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Selector: "env=a"},
+		}},
+		{ReferencedObjects: []client.ObjectIdentifier{
+			{Version: "v1", Kind: "Secret", Namespace: "testns", Name: "testsecret"},
+			{Group: "cluster.open-cluster-management.io", Version: "v1alpha1", Kind: "ClusterClaim", Name: "env"},
+		}},
+		{ReferencedObjects: []client.ObjectIdentifier{{Version: "v1", Kind: "Namespace"}}},
+		{},
+	}
+
+	testcases := map[string]struct {
+		changed  client.ObjectIdentifier
+		expected []int
+	}{
+		"configmap_get_and_list": {
+			client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "testconfigmap"},
+			[]int{0, 1},
+		},
+		"configmap_list_only": {
+			client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "testcm-enva"},
+			[]int{1},
+		},
+		"configmap_other_namespace": {
+			client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "default", Name: "testconfigmap"},
+			[]int{},
+		},
+		"cluster_scoped": {
+			client.ObjectIdentifier{
+				Group: "cluster.open-cluster-management.io", Version: "v1beta1", Kind: "ClusterClaim", Name: "env",
+			},
+			[]int{2},
+		},
+		"cluster_scoped_list": {
+			client.ObjectIdentifier{Version: "v1", Kind: "Namespace", Name: "testns"},
+			[]int{3},
+		},
+		"not_referenced": {
+			client.ObjectIdentifier{Version: "v1", Kind: "Secret", Namespace: "testns", Name: "othersecret"},
+			[]int{},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			indexes := TemplatesToReResolve(previous, test.changed)
+			if !reflect.DeepEqual(indexes, test.expected) {
+				t.Fatalf("Expected indexes %v but got %v", test.expected, indexes)
+			}
+		})
+	}
+}
+
 func TestStartQueryBatchNoCaching(t *testing.T) {
 	t.Parallel()
 
@@ -432,39 +883,210 @@ func TestResolveTemplateWithCachingManualCleanUp(t *testing.T) {
 	}
 }
 
-func TestResolveTemplateWithCachingNotAllowedClusterScoped(t *testing.T) {
-	t.Parallel()
+// fakeBatchWatcher is a DynamicWatcher that only supports query batches, which are counted.
+type fakeBatchWatcher struct {
+	client.DynamicWatcher
+	lock       sync.Mutex
+	inProgress bool
+	starts     int
+	ends       int
+}
 
-	ctx, cancelFunc := context.WithCancel(context.Background())
-	defer cancelFunc()
+func (f *fakeBatchWatcher) StartQueryBatch(watcher client.ObjectIdentifier) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 
-	resolver, _, err := NewResolverWithCaching(ctx, k8sConfig, Config{})
-	if err != nil {
-		t.Fatalf(err.Error())
+	if f.inProgress {
+		return fmt.Errorf("%w: %s", client.ErrQueryBatchInProgress, watcher)
 	}
 
-	tmplStr := `data1: '{{ lookup "v1" "Namespace" "" "some-namespace" }}'`
+	f.inProgress = true
+	f.starts++
 
-	tmplStrBytes, err := yamlToJSON([]byte(tmplStr))
-	if err != nil {
-		t.Fatalf(err.Error())
-	}
+	return nil
+}
 
-	// No watcher should cause an error
-	_, err = resolver.ResolveTemplate(
-		tmplStrBytes,
-		nil,
-		&ResolveOptions{
-			ClusterScopedAllowList: []ClusterScopedObjectIdentifier{
-				{
-					Group: "cluster.open-cluster-management.io",
-					Kind:  "ManagedCluster",
-					Name:  "local-cluster",
-				},
-			},
-			LookupNamespace: "testns",
-			Watcher: &client.ObjectIdentifier{
-				Version:   "v1",
+func (f *fakeBatchWatcher) EndQueryBatch(_ client.ObjectIdentifier) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.inProgress = false
+	f.ends++
+
+	return nil
+}
+
+func TestResolveTemplateCoalesceQueryBatches(t *testing.T) {
+	t.Parallel()
+
+	for _, coalesce := range []bool{true, false} {
+		coalesce := coalesce
+
+		t.Run(fmt.Sprintf("coalesce=%t", coalesce), func(t *testing.T) {
+			t.Parallel()
+
+			dynWatcher := &fakeBatchWatcher{}
+
+			resolver, err := NewResolverWithDynamicWatcher(dynWatcher, Config{CoalesceQueryBatches: coalesce})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			const calls = 3
+
+			// Each call waits for the others to start, so the calls overlap, or until some calls have failed
+			inside := sync.WaitGroup{}
+			inside.Add(calls)
+
+			watcher := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "watcher"}
+			options := ResolveOptions{
+				Watcher: &watcher,
+				CustomFunctions: template.FuncMap{
+					"waitForOthers": func() string {
+						inside.Done()
+						inside.Wait()
+
+						return "done"
+					},
+				},
+			}
+
+			errs := make(chan error, calls)
+			results := make(chan string, calls)
+
+			for i := 0; i < calls; i++ {
+				go func() {
+					result, err := resolver.ResolveTemplate([]byte(`{"a": "{{ waitForOthers }}"}`), nil, &options)
+					if err != nil {
+						// Let the other calls finish
+						inside.Done()
+						errs <- err
+
+						return
+					}
+
+					results <- string(result.ResolvedJSON)
+				}()
+			}
+
+			failures := 0
+
+			for i := 0; i < calls; i++ {
+				select {
+				case err := <-errs:
+					if !errors.Is(err, client.ErrQueryBatchInProgress) {
+						t.Fatalf("Expected a query batch in progress error but got: %v", err)
+					}
+
+					failures++
+				case result := <-results:
+					if result != `{"a":"done"}` {
+						t.Fatalf("Unexpected template: %s", result)
+					}
+				}
+			}
+
+			if coalesce && (failures != 0 || dynWatcher.starts != 1 || dynWatcher.ends != 1) {
+				t.Fatalf(
+					"Expected one shared query batch but got %d failures, %d starts, and %d ends",
+					failures, dynWatcher.starts, dynWatcher.ends,
+				)
+			}
+
+			if !coalesce && failures != calls-1 {
+				t.Fatalf("Expected %d failures but got %d", calls-1, failures)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateWithCachingCoalesceQueryBatches(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	server, _ := newImpersonationTestServer(t)
+
+	resolver, _, err := NewResolverWithCaching(
+		ctx, &rest.Config{Host: server.URL}, Config{CoalesceQueryBatches: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	const calls = 3
+
+	// Each call waits for the others to start, so the calls overlap
+	inside := sync.WaitGroup{}
+	inside.Add(calls)
+
+	watcher := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "watcher"}
+	options := ResolveOptions{
+		Watcher: &watcher,
+		CustomFunctions: template.FuncMap{
+			"waitForOthers": func() string {
+				inside.Done()
+				inside.Wait()
+
+				return "done"
+			},
+		},
+	}
+
+	errs := make(chan error, calls)
+
+	for i := 0; i < calls; i++ {
+		go func() {
+			result, err := resolver.ResolveTemplate([]byte(`{"a": "{{ waitForOthers }}"}`), nil, &options)
+			if err == nil && string(result.ResolvedJSON) != `{"a":"done"}` {
+				err = fmt.Errorf("unexpected template: %s", result.ResolvedJSON)
+			}
+
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < calls; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Expected the calls to share a query batch but got: %v", err)
+		}
+	}
+}
+
+func TestResolveTemplateWithCachingNotAllowedClusterScoped(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	resolver, _, err := NewResolverWithCaching(ctx, k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplStr := `data1: '{{ lookup "v1" "Namespace" "" "some-namespace" }}'`
+
+	tmplStrBytes, err := yamlToJSON([]byte(tmplStr))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// No watcher should cause an error
+	_, err = resolver.ResolveTemplate(
+		tmplStrBytes,
+		nil,
+		&ResolveOptions{
+			ClusterScopedAllowList: []ClusterScopedObjectIdentifier{
+				{
+					Group: "cluster.open-cluster-management.io",
+					Kind:  "ManagedCluster",
+					Name:  "local-cluster",
+				},
+			},
+			LookupNamespace: "testns",
+			Watcher: &client.ObjectIdentifier{
+				Version:   "v1",
 				Kind:      "ConfigMap",
 				Namespace: "testns",
 				Name:      "watcher",
@@ -535,6 +1157,78 @@ func TestResolveTemplateWithCachingListQuery(t *testing.T) {
 	}
 }
 
+func TestResolveTemplateWithCachingListByStrings(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	resolver, _, err := NewResolverWithCaching(ctx, k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplStrBytes, err := yamlToJSON([]byte(`data: '{{ .Names }}'`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	watcher := client.ObjectIdentifier{
+		Version:   "v1",
+		Kind:      "ConfigMap",
+		Namespace: "testns",
+		Name:      "watcher-list-by-strings",
+	}
+
+	transformer := func(api CachingQueryAPI, _ interface{}) (interface{}, error) {
+		configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+		configMaps, err := ListByStrings(api, configMapGVK, "testns", "env in (a, b)", "env!=b")
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{}
+
+		for _, configMap := range configMaps {
+			names = append(names, configMap.GetName())
+		}
+
+		return struct{ Names []string }{Names: names}, nil
+	}
+
+	resolveOptions := &ResolveOptions{
+		Watcher:             &watcher,
+		ContextTransformers: []func(CachingQueryAPI, interface{}) (interface{}, error){transformer},
+	}
+
+	result, err := resolver.ResolveTemplate(tmplStrBytes, struct{ Names []string }{}, resolveOptions)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(result.ResolvedJSON) != `{"data":"[testcm-enva]"}` {
+		t.Fatalf("Unexpected template: %s", string(result.ResolvedJSON))
+	}
+
+	if resolver.GetWatchCount() != 1 {
+		t.Fatalf("Expected a watch count of 1 but got: %d", resolver.GetWatchCount())
+	}
+
+	badTransformer := func(api CachingQueryAPI, _ interface{}) (interface{}, error) {
+		return ListByStrings(
+			api, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "testns", "env in (a",
+		)
+	}
+
+	resolveOptions.ContextTransformers = []func(CachingQueryAPI, interface{}) (interface{}, error){badTransformer}
+
+	_, err = resolver.ResolveTemplate(tmplStrBytes, struct{ Names []string }{}, resolveOptions)
+	if !errors.Is(err, ErrContextTransformerFailed) {
+		t.Fatalf("Expected ErrContextTransformerFailed for an invalid label selector but got %v", err)
+	}
+}
+
 type fakeReconciler struct{}
 
 func (r fakeReconciler) Reconcile(_ context.Context, _ client.ObjectIdentifier) (reconcile.Result, error) {
@@ -620,97 +1314,368 @@ func TestResolveTemplateWithPreexistingWatcher(t *testing.T) {
 	}
 }
 
-func TestResolveTemplateDefaultConfig(t *testing.T) {
+type recordingReconciler struct {
+	reconciles chan client.ObjectIdentifier
+}
+
+func (r recordingReconciler) Reconcile(_ context.Context, watcher client.ObjectIdentifier) (reconcile.Result, error) {
+	r.reconciles <- watcher
+
+	return reconcile.Result{}, nil
+}
+
+func TestResolveTemplateWithCachingRecreatedObject(t *testing.T) {
 	t.Parallel()
 
-	testcases := map[string]resolveTestCase{
-		"fromSecret": {
-			inputTmpl:      `data: '{{ fromSecret "testns" "testsecret" "secretkey1" }}'`,
-			expectedResult: "data: c2VjcmV0a2V5MVZhbA==",
-		},
-		"fromSecret_duplicate_uses_resolve_cache": {
-			inputTmpl: `data: '{{ fromSecret "testns" "testsecret" "secretkey1" }}` +
-				`{{ fromSecret "testns" "testsecret" "secretkey1" }}'`,
-			expectedResult: "data: c2VjcmV0a2V5MVZhbA==c2VjcmV0a2V5MVZhbA==",
-		},
-		"fromConfigMap": {
-			inputTmpl:      `param: '{{ fromConfigMap "testns" "testconfigmap" "cmkey1"  }}'`,
-			expectedResult: "param: cmkey1Val",
-		},
-		"toLiteral": {
-			inputTmpl:      `param: '{{ fromConfigMap "testns" "testconfigmap" "ingressSources" | toLiteral }}'`,
-			expectedResult: "param:\n  - 10.10.10.10\n  - 1.1.1.1",
-		},
-		"b64enc": {
-			inputTmpl:      `config1: '{{ "testdata" | b64enc  }}'`,
-			expectedResult: "config1: dGVzdGRhdGE=",
-		},
-		"base64enc": {
-			inputTmpl:      `config1: '{{ "testdata" | base64enc  }}'`,
-			expectedResult: "config1: dGVzdGRhdGE=",
-		},
-		"b64dec": {
-			inputTmpl:      `config2: '{{ "dGVzdGRhdGE=" | b64dec  }}'`,
-			expectedResult: "config2: testdata",
-		},
-		"base64dec": {
-			inputTmpl:      `config2: '{{ "dGVzdGRhdGE=" | base64dec  }}'`,
-			expectedResult: "config2: testdata",
-		},
-		"indent_pipe": {
-			inputTmpl:      "spec:\n  config1: |\n    {{ " + `"hello\nworld\n"` + " | indent 4 }}\n",
-			expectedResult: "spec:\n  config1: |\n    hello\n    world",
-		},
-		"indent_pipe_strip": {
-			inputTmpl:      "spec:\n  config1: |-\n    {{ " + `"hello\nworld\n"` + " | indent 4 }}\n",
-			expectedResult: "spec:\n  config1: hello world",
-		},
-		"autoindent_pipe": {
-			inputTmpl:      "spec:\n  config1: |\n    {{ " + `"hello\nworld\n"` + " | autoindent }}\n",
-			expectedResult: "spec:\n  config1: |\n    hello\n    world",
-		},
-		"autoindent_pipe_strip": {
-			inputTmpl:      "spec:\n  config1: |-\n    {{ " + `"hello\nworld\n"` + " | autoindent }}\n",
-			expectedResult: "spec:\n  config1: hello world",
-		},
-		"fromClusterClaim": {
-			inputTmpl:      `value: '{{ fromClusterClaim "env" }}'`,
-			expectedResult: "value: dev",
-		},
-		"lookup_duplicate_list_uses_resolve_cache": {
-			inputTmpl: `data: '{{ (index (lookup "v1" "ConfigMap" "testns" "" "env=a").items 0).data.cmkey1 }}` +
-				`{{ (index (lookup "v1" "ConfigMap" "testns" "" "env=a").items 0).data.cmkey1 }}'`,
-			expectedResult: "data: cmkey1Valcmkey1Val",
-		},
-		"copyConfigMapData": {
-			inputTmpl: `data: '{{ copyConfigMapData "testns" "testconfigmap" }}'`,
-			expectedResult: "data:\n  cmkey1: cmkey1Val\n  cmkey2: cmkey2Val\n" +
-				"  ingressSources: '[10.10.10.10, 1.1.1.1]'",
-		},
-		"copySecretData": {
-			inputTmpl:      `data: '{{ copySecretData "testns" "testsecret" }}'`,
-			expectedResult: "data:\n  secretkey1: c2VjcmV0a2V5MVZhbA==\n  secretkey2: c2VjcmV0a2V5MlZhbA==",
-		},
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	k8sClient, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
 	}
 
-	for testName, test := range testcases {
-		test := test
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "testcm-recreate", Namespace: "default"},
+		Data:       map[string]string{"value": "original"},
+	}
 
-		t.Run(testName, func(t *testing.T) {
-			t.Parallel()
+	_, err = k8sClient.CoreV1().ConfigMaps("default").Create(ctx, &configMap, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
 
-			doResolveTest(t, test)
-		})
+	defer func() {
+		_ = k8sClient.CoreV1().ConfigMaps("default").Delete(
+			context.Background(), configMap.Name, metav1.DeleteOptions{},
+		)
+	}()
+
+	reconciler := recordingReconciler{reconciles: make(chan client.ObjectIdentifier, 10)}
+
+	dynWatcher, err := client.New(
+		k8sConfig, reconciler, &client.Options{EnableCache: true, DisableInitialReconcile: true},
+	)
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
 	}
-}
 
-func TestResolveTemplateErrors(t *testing.T) {
-	t.Parallel()
+	go func() {
+		_ = dynWatcher.Start(ctx)
+	}()
 
-	testcases := map[string]resolveTestCase{
-		"toLiteral_with_newlines": {
-			inputTmpl:   `param: '{{ "something\n  with\n  new\n lines\n" | toLiteral }}'`,
-			expectedErr: ErrNewLinesNotAllowed,
+	<-dynWatcher.Started()
+
+	resolver, err := NewResolverWithDynamicWatcher(dynWatcher, Config{SkipBatchManagement: true})
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	tmplStrBytes, err := yamlToJSON([]byte(`data: '{{ fromConfigMap "default" "testcm-recreate" "value" }}'`))
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	watcher := client.ObjectIdentifier{
+		Version:   "v1",
+		Kind:      "ConfigMap",
+		Namespace: "testns",
+		Name:      "watcher-recreate",
+	}
+
+	resolve := func() string {
+		t.Helper()
+
+		err := resolver.StartQueryBatch(watcher)
+		if err != nil {
+			t.Fatalf("No error was expected: %v", err)
+		}
+
+		result, err := resolver.ResolveTemplate(tmplStrBytes, nil, &ResolveOptions{Watcher: &watcher})
+		if err != nil {
+			t.Fatalf("No error was expected: %v", err)
+		}
+
+		err = resolver.EndQueryBatch(watcher)
+		if err != nil {
+			t.Fatalf("No error was expected: %v", err)
+		}
+
+		return string(result.ResolvedJSON)
+	}
+
+	waitForReconcile := func() {
+		t.Helper()
+
+		select {
+		case reconciled := <-reconciler.reconciles:
+			if reconciled != watcher {
+				t.Fatalf("Expected a reconcile of %s but got %s", watcher, reconciled)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatalf("Timed out waiting for a reconcile of %s", watcher)
+		}
+	}
+
+	if result := resolve(); result != `{"data":"original"}` {
+		t.Fatalf("Unexpected template: %s", result)
+	}
+
+	err = k8sClient.CoreV1().ConfigMaps("default").Delete(ctx, configMap.Name, metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	waitForReconcile()
+
+	configMap.Data["value"] = "recreated"
+
+	_, err = k8sClient.CoreV1().ConfigMaps("default").Create(ctx, &configMap, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	waitForReconcile()
+
+	if result := resolve(); result != `{"data":"recreated"}` {
+		t.Fatalf("Unexpected template: %s", result)
+	}
+}
+
+func TestResolveTemplateConsistentSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	k8sClient, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "testcm-snapshot", Namespace: "default"},
+		Data:       map[string]string{"value": "0"},
+	}
+
+	_, err = k8sClient.CoreV1().ConfigMaps("default").Create(ctx, &configMap, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	defer func() {
+		_ = k8sClient.CoreV1().ConfigMaps("default").Delete(
+			context.Background(), configMap.Name, metav1.DeleteOptions{},
+		)
+	}()
+
+	resolver, _, err := NewResolverWithCaching(ctx, k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	// updateConfigMap increments the value of the ConfigMap and waits for the cache to be updated so that the object
+	// changes in the middle of the resolve.
+	updateConfigMap := func() (string, error) {
+		current, err := k8sClient.CoreV1().ConfigMaps("default").Get(ctx, configMap.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+
+		value, err := strconv.Atoi(current.Data["value"])
+		if err != nil {
+			return "", err
+		}
+
+		current.Data["value"] = strconv.Itoa(value + 1)
+
+		_, err = k8sClient.CoreV1().ConfigMaps("default").Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return "", err
+		}
+
+		for start := time.Now(); time.Since(start) < 30*time.Second; time.Sleep(100 * time.Millisecond) {
+			cached, err := resolver.GetFromCache(configMapGVK, "default", configMap.Name)
+			if err == nil && cached.GetResourceVersion() != current.ResourceVersion {
+				return "", nil
+			}
+		}
+
+		return "", errors.New("timed out waiting for the cache to be updated")
+	}
+
+	tmplStrBytes, err := yamlToJSON([]byte(
+		`first: '{{ fromConfigMap "default" "testcm-snapshot" "value" }}'` + "\n" +
+			`update: '{{ updateConfigMap }}'` + "\n" +
+			`second: '{{ fromConfigMap "default" "testcm-snapshot" "value" }}'` + "\n" +
+			`list: '{{ range (lookup "v1" "ConfigMap" "default" "").items }}` +
+			`{{ if eq .metadata.name "testcm-snapshot" }}{{ .data.value }}{{ end }}{{ end }}'`,
+	))
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	watcher := client.ObjectIdentifier{
+		Version:   "v1",
+		Kind:      "ConfigMap",
+		Namespace: "testns",
+		Name:      "watcher-snapshot",
+	}
+
+	testcases := []struct {
+		consistentSnapshot bool
+		expected           string
+	}{
+		{false, `{"first":"0","list":"1","second":"1","update":""}`},
+		{true, `{"first":"1","list":"1","second":"1","update":""}`},
+	}
+
+	for _, test := range testcases {
+		result, err := resolver.ResolveTemplate(tmplStrBytes, nil, &ResolveOptions{
+			ConsistentSnapshot: test.consistentSnapshot,
+			CustomFunctions:    template.FuncMap{"updateConfigMap": updateConfigMap},
+			Watcher:            &watcher,
+		})
+		if err != nil {
+			t.Fatalf("No error was expected: %v", err)
+		}
+
+		if string(result.ResolvedJSON) != test.expected {
+			t.Fatalf("expected : %s , got : %s", test.expected, string(result.ResolvedJSON))
+		}
+	}
+}
+
+func TestResolveTemplateDefaultConfig(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"fromSecret": {
+			inputTmpl:      `data: '{{ fromSecret "testns" "testsecret" "secretkey1" }}'`,
+			expectedResult: "data: c2VjcmV0a2V5MVZhbA==",
+		},
+		"fromSecret_duplicate_uses_resolve_cache": {
+			inputTmpl: `data: '{{ fromSecret "testns" "testsecret" "secretkey1" }}` +
+				`{{ fromSecret "testns" "testsecret" "secretkey1" }}'`,
+			expectedResult: "data: c2VjcmV0a2V5MVZhbA==c2VjcmV0a2V5MVZhbA==",
+		},
+		"fromConfigMap": {
+			inputTmpl:      `param: '{{ fromConfigMap "testns" "testconfigmap" "cmkey1"  }}'`,
+			expectedResult: "param: cmkey1Val",
+		},
+		"toLiteral": {
+			inputTmpl:      `param: '{{ fromConfigMap "testns" "testconfigmap" "ingressSources" | toLiteral }}'`,
+			expectedResult: "param:\n  - 10.10.10.10\n  - 1.1.1.1",
+		},
+		"b64enc": {
+			inputTmpl:      `config1: '{{ "testdata" | b64enc  }}'`,
+			expectedResult: "config1: dGVzdGRhdGE=",
+		},
+		"base64enc": {
+			inputTmpl:      `config1: '{{ "testdata" | base64enc  }}'`,
+			expectedResult: "config1: dGVzdGRhdGE=",
+		},
+		"b64dec": {
+			inputTmpl:      `config2: '{{ "dGVzdGRhdGE=" | b64dec  }}'`,
+			expectedResult: "config2: testdata",
+		},
+		"base64dec": {
+			inputTmpl:      `config2: '{{ "dGVzdGRhdGE=" | base64dec  }}'`,
+			expectedResult: "config2: testdata",
+		},
+		"indent_pipe": {
+			inputTmpl:      "spec:\n  config1: |\n    {{ " + `"hello\nworld\n"` + " | indent 4 }}\n",
+			expectedResult: "spec:\n  config1: |\n    hello\n    world",
+		},
+		"indent_pipe_strip": {
+			inputTmpl:      "spec:\n  config1: |-\n    {{ " + `"hello\nworld\n"` + " | indent 4 }}\n",
+			expectedResult: "spec:\n  config1: hello world",
+		},
+		"autoindent_pipe": {
+			inputTmpl:      "spec:\n  config1: |\n    {{ " + `"hello\nworld\n"` + " | autoindent }}\n",
+			expectedResult: "spec:\n  config1: |\n    hello\n    world",
+		},
+		"autoindent_pipe_strip": {
+			inputTmpl:      "spec:\n  config1: |-\n    {{ " + `"hello\nworld\n"` + " | autoindent }}\n",
+			expectedResult: "spec:\n  config1: hello world",
+		},
+		"toEnvFile_autoindent": {
+			inputTmpl: "data:\n  app.env: |\n    " +
+				`{{ dict "PORT" "8080" "GREETING" "hello world" | toEnvFile | autoindent }}` + "\n",
+			expectedResult: "data:\n  app.env: |\n    GREETING=\"hello world\"\n    PORT=8080",
+		},
+		"fromEnvFile": {
+			inputTmpl:      `value: '{{ (fromEnvFile "PORT=8080\nGREETING=\"hello world\"").GREETING }}'`,
+			expectedResult: "value: hello world",
+		},
+		"jwtClaim": {
+			inputTmpl:      `value: '{{ if eq (jwtClaim .Token "iss") "https://issuer.example.com" }}match{{ end }}'`,
+			ctx:            struct{ Token string }{testJWT},
+			expectedResult: "value: match",
+		},
+		"required": {
+			inputTmpl:      `value: '{{ fromConfigMap "testns" "testconfigmap" "cmkey1" | required "unset" }}'`,
+			expectedResult: "value: cmkey1Val",
+		},
+		"fromClusterClaim": {
+			inputTmpl:      `value: '{{ fromClusterClaim "env" }}'`,
+			expectedResult: "value: dev",
+		},
+		"lookup_duplicate_list_uses_resolve_cache": {
+			inputTmpl: `data: '{{ (index (lookup "v1" "ConfigMap" "testns" "" "env=a").items 0).data.cmkey1 }}` +
+				`{{ (index (lookup "v1" "ConfigMap" "testns" "" "env=a").items 0).data.cmkey1 }}'`,
+			expectedResult: "data: cmkey1Valcmkey1Val",
+		},
+		"copyConfigMapData": {
+			inputTmpl: `data: '{{ copyConfigMapData "testns" "testconfigmap" }}'`,
+			expectedResult: "data:\n  cmkey1: cmkey1Val\n  cmkey2: cmkey2Val\n" +
+				"  ingressSources: '[10.10.10.10, 1.1.1.1]'",
+		},
+		"copySecretData": {
+			inputTmpl:      `data: '{{ copySecretData "testns" "testsecret" }}'`,
+			expectedResult: "data:\n  secretkey1: c2VjcmV0a2V5MVZhbA==\n  secretkey2: c2VjcmV0a2V5MlZhbA==",
+		},
+		"dns1123": {
+			inputTmpl:      `name: '{{ printf "%s_App" .ClusterName | dns1123 }}'`,
+			ctx:            struct{ ClusterName string }{"Cluster1"},
+			expectedResult: "name: cluster1-app",
+		},
+		"annotationSafe": {
+			inputTmpl:      `note: '{{ annotationSafe "Owned by\tthe platform team" 18 }}'`,
+			expectedResult: "note: Owned bythe pla...",
+		},
+		"renameKeys": {
+			inputTmpl: `data: '{{ (renameKeys (configMapData "testns" "testconfigmap") ` +
+				`(dict "cmkey1" "key1")).key1 }}'`,
+			expectedResult: "data: cmkey1Val",
+		},
+		"includeTemplate": {
+			inputTmpl:      `data: '{{ includeTemplate "default" "testtemplates" "greeting" . }}'`,
+			ctx:            struct{ ClusterName string }{"cluster1"},
+			expectedResult: "data: Hello cluster1! cmkey1Val",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			doResolveTest(t, test)
+		})
+	}
+}
+
+func TestResolveTemplateErrors(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"toLiteral_with_newlines": {
+			inputTmpl:   `param: '{{ "something\n  with\n  new\n lines\n" | toLiteral }}'`,
+			expectedErr: ErrNewLinesNotAllowed,
 		},
 		"undefined_function": {
 			inputTmpl: `test: '{{ blah "asdf"  }}'`,
@@ -719,6 +1684,14 @@ func TestResolveTemplateErrors(t *testing.T) {
 					`function "blah" not defined`,
 			),
 		},
+		"required_missing": {
+			inputTmpl: `value: '{{ fromConfigMap "testns" "testconfigmap" "missing" | required "key is missing" }}'`,
+			expectedErr: errors.New(
+				`failed to resolve the template {"value":"{{ fromConfigMap \"testns\" \"testconfigmap\" ` +
+					`\"missing\" | required \"key is missing\" }}"}: template: tmpl:1:62: executing "tmpl" at ` +
+					`<required "key is missing">: error calling required: key is missing`,
+			),
+		},
 		"invalid_context_int": {
 			inputTmpl:   `test: '{{ printf "hello %s" "world" }}'`,
 			ctx:         123,
@@ -758,6 +1731,16 @@ func TestResolveTemplateErrors(t *testing.T) {
 					`not defined`,
 			),
 		},
+		"includeTemplate_max_depth": {
+			inputTmpl:   `data: '{{ includeTemplate "default" "testtemplates" "recursive" . }}'`,
+			expectedErr: ErrMaxDepthExceeded,
+		},
+		"includeTemplate_custom_max_depth": {
+			inputTmpl:   `data: '{{ includeTemplate "default" "testtemplates" "greeting" . }}'`,
+			config:      Config{MaxResolveDepth: 1},
+			ctx:         struct{ ClusterName string }{"cluster1"},
+			expectedErr: ErrMaxDepthExceeded,
+		},
 		"missing_api_resource": {
 			inputTmpl:   `value: '{{ lookup "v1" "NotAResource" "namespace" "object" }}'`,
 			config:      Config{},
@@ -795,23 +1778,374 @@ func TestResolveTemplateErrors(t *testing.T) {
 	}
 }
 
-func TestResolveTemplateWithConfig(t *testing.T) {
+func TestResolveTemplateParseError(t *testing.T) {
 	t.Parallel()
 
-	testcases := map[string]resolveTestCase{
-		"ignores_default_delimiter": {
-			inputTmpl:      `config1: '{{ "testdata" | base64enc  }}'`,
-			config:         Config{StartDelim: "{{hub", StopDelim: "hub}}"},
-			expectedResult: `config1: '{{ "testdata" | base64enc  }}'`,
-		},
-		"base64enc_hub": {
-			inputTmpl:      `config1: '{{hub "testdata" | base64enc  hub}}'`,
-			config:         Config{StartDelim: "{{hub", StopDelim: "hub}}"},
-			expectedResult: "config1: dGVzdGRhdGE=",
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := "first: value\nsecond:\n  third: '{{ blah \"asdf\" }}'\n"
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{InputIsYAML: true})
+
+	var parseErr *TemplateParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a TemplateParseError, got : %v", err)
+	}
+
+	if parseErr.Line != 3 || parseErr.Column != 0 {
+		t.Fatalf("expected the error on line 3 without a column, got : %d:%d", parseErr.Line, parseErr.Column)
+	}
+
+	expectedErr := "failed to parse the template JSON string " + tmpl +
+		`: template: tmpl:3: function "blah" not defined`
+	if err.Error() != expectedErr {
+		t.Fatalf("expected : %s , got : %s", expectedErr, err)
+	}
+}
+
+func TestResolveTemplateNestedContextValues(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ctx := struct {
+		ObjectName string
+		Values     map[string]interface{}
+	}{
+		ObjectName: "my-app",
+		Values: map[string]interface{}{
+			"image":    map[string]interface{}{"repository": "quay.io/app", "tag": "v1.2.3"},
+			"replicas": int64(3),
+			"debug":    true,
+			"ports":    []interface{}{float64(8080), float64(8443)},
+			"extra":    nil,
 		},
-		"base64dec_hub_base64enc_regular": {
-			inputTmpl:      `config1: '{{ "{{hub "dGVzdGRhdGE=" | base64dec hub}}" | base64enc }}'`,
-			config:         Config{StartDelim: "{{hub", StopDelim: "hub}}"},
+	}
+
+	tmpl := "name: '{{ .ObjectName }}'\nimage: '{{ .Values.image.repository }}:{{ .Values.image.tag }}'\n" +
+		"replicas: '{{ .Values.replicas }}'\ndebug: '{{ .Values.debug }}'\nport: '{{ index .Values.ports 1 }}'\n"
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), ctx, &ResolveOptions{InputIsYAML: true})
+	if !errors.Is(err, ErrInvalidContextType) {
+		t.Fatalf("expected an ErrInvalidContextType error, got : %v", err)
+	}
+
+	result, err := resolver.ResolveTemplate(
+		[]byte(tmpl), ctx, &ResolveOptions{InputIsYAML: true, AllowNestedContextValues: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"debug":"true","image":"quay.io/app:v1.2.3","name":"my-app","port":"8443","replicas":"3"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("expected : %s , got : %s", expected, string(result.ResolvedJSON))
+	}
+
+	// A null value is only valid with AllowNestedContextValues
+	nullCtx := struct{ Values map[string]interface{} }{Values: map[string]interface{}{"extra": nil}}
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), nullCtx, &ResolveOptions{InputIsYAML: true})
+	if !errors.Is(err, ErrInvalidContextType) {
+		t.Fatalf("expected an ErrInvalidContextType error, got : %v", err)
+	}
+
+	// The fields of the context itself are still validated
+	invalidCtx := struct{ ClusterID int }{12}
+
+	_, err = resolver.ResolveTemplate(
+		[]byte(tmpl), invalidCtx, &ResolveOptions{InputIsYAML: true, AllowNestedContextValues: true},
+	)
+	if !errors.Is(err, ErrInvalidContextType) {
+		t.Fatalf("expected an ErrInvalidContextType error, got : %v", err)
+	}
+}
+
+func TestResolveTemplateRemoveEmptyFields(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: default
+data:
+  owner: platform
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl    string
+		expectedJSON string
+	}{
+		"empty_lookup": {
+			inputTmpl: "owner: '{{ fromConfigMap \"default\" \"settings\" \"owner\" }}'\n" +
+				"team: '{{ fromConfigMap \"default\" \"settings\" \"team\" }}'\n",
+			expectedJSON: `{"owner":"platform"}`,
+		},
+		"nested_empties": {
+			inputTmpl: "metadata:\n  name: app\n  labels:\n    team: '{{ \"\" }}'\n    tier: null\n" +
+				"  annotations:\n    nested:\n      empty: ''\n",
+			expectedJSON: `{"metadata":{"name":"app"}}`,
+		},
+		"lists": {
+			inputTmpl:    "items:\n- name: a\n  value: ''\n- name: ''\n- ''\n- null\n- 2\n",
+			expectedJSON: `{"items":[{"name":"a"},{},"",null,2]}`,
+		},
+		"kept_values": {
+			inputTmpl:    "zero: 0\ndisabled: false\nempty_map: {}\nempty_list: []\nbig: 12345678901234567890\n",
+			expectedJSON: `{"big":12345678901234567890,"disabled":false,"empty_list":[],"empty_map":{},"zero":0}`,
+		},
+		"all_empty": {
+			inputTmpl:    "a: ''\nb:\n  c: null\n",
+			expectedJSON: `{}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true, RemoveEmptyFields: true},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+			}
+		})
+	}
+}
+
+func TestResolveTemplateEmptyMapRendering(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: no-data
+  namespace: default
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	inputTmpl := "absent: '{{ copyConfigMapData \"default\" \"no-data\" }}'\n" +
+		"empty_map: {}\nempty_list: []\nnested:\n  inner: {}\nitems:\n- null\n- {}\n- name: a\n" +
+		"kept: 0\nempty_string: ''\n"
+
+	testcases := map[string]struct {
+		rendering    EmptyMapRendering
+		expectedJSON string
+	}{
+		"not_set": {
+			rendering: "",
+			expectedJSON: `{"absent":null,"empty_list":[],"empty_map":{},"empty_string":"",` +
+				`"items":[null,{},{"name":"a"}],"kept":0,"nested":{"inner":{}}}`,
+		},
+		"null": {
+			rendering: EmptyMapRenderingNull,
+			expectedJSON: `{"absent":null,"empty_list":null,"empty_map":null,"empty_string":"",` +
+				`"items":[null,null,{"name":"a"}],"kept":0,"nested":{"inner":null}}`,
+		},
+		"empty-object": {
+			rendering: EmptyMapRenderingEmptyObject,
+			expectedJSON: `{"absent":{},"empty_list":[],"empty_map":{},"empty_string":"",` +
+				`"items":[{},{},{"name":"a"}],"kept":0,"nested":{"inner":{}}}`,
+		},
+		"omit": {
+			rendering:    EmptyMapRenderingOmit,
+			expectedJSON: `{"empty_string":"","items":[null,{},{"name":"a"}],"kept":0}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolverFromSnapshot(objects, Config{EmptyMapRendering: test.rendering})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			result, err := resolver.ResolveTemplate([]byte(inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+			}
+		})
+	}
+}
+
+func TestEmptyMapRenderingInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewResolverFromSnapshot(nil, Config{EmptyMapRendering: "empty"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+	}
+}
+
+func TestResolveTemplateWithoutActions(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		inputTmpl string
+		// templatedTmpl is the same input with a template action so that it isn't resolved with the fast path.
+		templatedTmpl string
+		config        Config
+		options       ResolveOptions
+		expectedJSON  string
+	}{
+		"json": {
+			inputTmpl: `{"kind": "ConfigMap", "data": {"b": "2", "a": 1}, "n": [1.0, 1e3, 1.5e30, "<&>", "yes"]}`,
+			templatedTmpl: `{"kind": "ConfigMap", "data": {"b": "{{ \"2\" }}", "a": 1}, ` +
+				`"n": [1.0, 1e3, 1.5e30, "<&>", "yes"]}`,
+			expectedJSON: `{"data":{"a":1,"b":"2"},"kind":"ConfigMap",` +
+				`"n":[1,1000,1.5e+30,"\u003c\u0026\u003e","yes"]}`,
+		},
+		"yaml": {
+			inputTmpl:     "kind: ConfigMap\ndata:\n  b: \"2\"\n  a: 1\n",
+			templatedTmpl: "kind: ConfigMap\ndata:\n  b: '{{ \"2\" }}'\n  a: 1\n",
+			options:       ResolveOptions{InputIsYAML: true},
+			expectedJSON:  `{"data":{"a":1,"b":"2"},"kind":"ConfigMap"}`,
+		},
+		"missing_key_placeholder": {
+			inputTmpl:     `{"value": "<no value>"}`,
+			templatedTmpl: `{"value": "{{ \"<no value>\" }}"}`,
+			config:        Config{MissingKeyPlaceholder: "missing"},
+			expectedJSON:  `{"value":"\u003cno value\u003e"}`,
+		},
+		"wrap_in_list": {
+			inputTmpl:     "- kind: ConfigMap\n",
+			templatedTmpl: "- kind: '{{ \"ConfigMap\" }}'\n",
+			options:       ResolveOptions{InputIsYAML: true, WrapInList: true},
+			expectedJSON:  `{"apiVersion":"v1","items":[{"kind":"ConfigMap"}],"kind":"List"}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolverFromSnapshot(nil, test.config)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			for _, inputTmpl := range []string{test.inputTmpl, test.templatedTmpl} {
+				options := test.options
+
+				result, err := resolver.ResolveTemplate([]byte(inputTmpl), TemplateContext{}, &options)
+				if err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				if string(result.ResolvedJSON) != test.expectedJSON {
+					t.Fatalf("expected : %s , got : %s", test.expectedJSON, string(result.ResolvedJSON))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkResolveTemplate(b *testing.B) {
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		b.Fatalf(err.Error())
+	}
+
+	var manifest strings.Builder
+
+	manifest.WriteString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: large\ndata:\n")
+
+	for i := 0; i < 1000; i++ {
+		manifest.WriteString(fmt.Sprintf("  key%d: value%d\n", i, i))
+	}
+
+	manifestYAML := manifest.String()
+	templatedYAML := manifestYAML + "  templated: '{{ \"value\" }}'\n"
+
+	manifestJSON, err := yamlToJSON([]byte(manifestYAML))
+	if err != nil {
+		b.Fatalf(err.Error())
+	}
+
+	templatedJSON, err := yamlToJSON([]byte(templatedYAML))
+	if err != nil {
+		b.Fatalf(err.Error())
+	}
+
+	benchmarks := map[string]struct {
+		tmpl    []byte
+		options ResolveOptions
+	}{
+		"json_no_actions":   {manifestJSON, ResolveOptions{}},
+		"json_with_actions": {templatedJSON, ResolveOptions{}},
+		"yaml_no_actions":   {[]byte(manifestYAML), ResolveOptions{InputIsYAML: true}},
+		"yaml_with_actions": {[]byte(templatedYAML), ResolveOptions{InputIsYAML: true}},
+	}
+
+	for name, benchmark := range benchmarks {
+		benchmark := benchmark
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				options := benchmark.options
+
+				_, err := resolver.ResolveTemplate(benchmark.tmpl, nil, &options)
+				if err != nil {
+					b.Fatalf(err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestResolveTemplateWithConfig(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"ignores_default_delimiter": {
+			inputTmpl:      `config1: '{{ "testdata" | base64enc  }}'`,
+			config:         Config{StartDelim: "{{hub", StopDelim: "hub}}"},
+			expectedResult: `config1: '{{ "testdata" | base64enc  }}'`,
+		},
+		"base64enc_hub": {
+			inputTmpl:      `config1: '{{hub "testdata" | base64enc  hub}}'`,
+			config:         Config{StartDelim: "{{hub", StopDelim: "hub}}"},
+			expectedResult: "config1: dGVzdGRhdGE=",
+		},
+		"base64dec_hub_base64enc_regular": {
+			inputTmpl:      `config1: '{{ "{{hub "dGVzdGRhdGE=" | base64dec hub}}" | base64enc }}'`,
+			config:         Config{StartDelim: "{{hub", StopDelim: "hub}}"},
 			expectedResult: `config1: '{{ "testdata" | base64enc }}'`,
 		},
 		"additionalIndentation": {
@@ -922,145 +2256,866 @@ func TestResolveTemplateWithContext(t *testing.T) {
 		t.Run(testName, func(t *testing.T) {
 			t.Parallel()
 
-			doResolveTest(t, test)
+			doResolveTest(t, test)
+		})
+	}
+}
+
+func TestResolveTemplateWithCrypto(t *testing.T) {
+	t.Parallel()
+
+	// Generate a 256 bit for AES-256. It can't be random so that the test results are deterministic.
+	keyBytesSize := 256 / 8
+	key := bytes.Repeat([]byte{byte('A')}, keyBytesSize)
+	otherKey := bytes.Repeat([]byte{byte('B')}, keyBytesSize)
+	thirdKey := bytes.Repeat([]byte{byte('C')}, keyBytesSize)
+	iv := bytes.Repeat([]byte{byte('I')}, IVSize)
+
+	encrypt := ResolveOptions{
+		EncryptionConfig: EncryptionConfig{
+			AESKey:               key,
+			EncryptionEnabled:    true,
+			InitializationVector: iv,
+		},
+	}
+
+	decrypt := ResolveOptions{
+		EncryptionConfig: EncryptionConfig{
+			AESKey:               key,
+			DecryptionEnabled:    true,
+			InitializationVector: iv,
+		},
+	}
+
+	encryptMasked := encrypt
+	encryptMasked.MaskEncryptedForDisplay = true
+
+	testcases := map[string]resolveTestCase{
+		"encrypt_protect_masked": {
+			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
+			resolveOptions: encryptMasked,
+			expectedResult: "value: $ocm_encrypted:<masked>",
+		},
+		"encrypt_copySecretData_masked": {
+			inputTmpl:      `data: '{{ copySecretData "testns" "testsecret" }}'`,
+			resolveOptions: encryptMasked,
+			expectedResult: "data:\n  secretkey1: $ocm_encrypted:<masked>\n  secretkey2: $ocm_encrypted:<masked>",
+		},
+		"encrypt_protect": {
+			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
+			resolveOptions: encrypt,
+			expectedResult: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+		},
+		"encrypt_protect_empty": {
+			inputTmpl:      `value: '{{ "" | protect }}'`,
+			resolveOptions: encrypt,
+			expectedResult: `value: ""`,
+		},
+		"encrypt_fromSecret": {
+			inputTmpl:      `data: '{{ fromSecret "testns" "testsecret" "secretkey1" }}'`,
+			resolveOptions: encrypt,
+			expectedResult: "data: $ocm_encrypted:c6PNhsEfbM9NRUqeJ+HbcECCyVdFnRbLdd+n8r1fS9M=",
+		},
+		"encrypt_copySecretData": {
+			inputTmpl:      `data: '{{ copySecretData "testns" "testsecret" }}'`,
+			resolveOptions: encrypt,
+			expectedResult: "data:\n  secretkey1: $ocm_encrypted:c6PNhsEfbM9NRUqeJ+HbcECCyVdFnRbLdd+n8r1fS9M=\n" +
+				"  secretkey2: $ocm_encrypted:VlXOhKuKGoHimHAYlQ2xz5EBw7mriqtt7fEP5ShP5cw=",
+		},
+		"decrypt": {
+			inputTmpl:      "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: decrypt,
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_fallback_unused": {
+			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey: key, AESKeyFallback: otherKey, DecryptionEnabled: true, InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_fallback": {
+			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey: otherKey, AESKeyFallback: key, DecryptionEnabled: true, InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_second_fallback": {
+			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey:               otherKey,
+					AESKeyFallbacks:      [][]byte{thirdKey, key},
+					DecryptionEnabled:    true,
+					InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_fallback_and_fallbacks": {
+			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey:               otherKey,
+					AESKeyFallback:       thirdKey,
+					AESKeyFallbacks:      [][]byte{key},
+					DecryptionEnabled:    true,
+					InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_fallbacks_wrong_keys": {
+			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey:               otherKey,
+					AESKeyFallbacks:      [][]byte{thirdKey},
+					DecryptionEnabled:    true,
+					InitializationVector: iv,
+				},
+			},
+			expectedErr: ErrInvalidPKCS7Padding,
+		},
+		"decryptionConcurrency": {
+			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==\n" +
+				"value2: $ocm_encrypted:rBaGZbpT4WOXZzFI+XBrgg==\n" +
+				"value3: $ocm_encrypted:rcKUPnLe4rejwXzsm2/g/w==",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey: key, DecryptionConcurrency: 5, DecryptionEnabled: true, InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: Raleigh\nvalue2: Raleigh2\nvalue3: Raleigh3",
+		},
+		"decrypt_embedded": {
+			inputTmpl:      "value: postgres://user:$ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==@db:5432/app",
+			resolveOptions: decrypt,
+			expectedResult: "value: postgres://user:Raleigh@db:5432/app",
+		},
+		"decrypt_embedded_multiple": {
+			inputTmpl: "value: a=$ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==;" +
+				"b=$ocm_encrypted:rBaGZbpT4WOXZzFI+XBrgg==;c=$ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: decrypt,
+			expectedResult: "value: a=Raleigh;b=Raleigh2;c=Raleigh",
+		},
+		"decrypt_embedded_followed_by_base64": {
+			inputTmpl:      "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==/path+more",
+			resolveOptions: decrypt,
+			expectedResult: "value: Raleigh/path+more",
+		},
+		"nothing_to_decrypt": {
+			inputTmpl:      "value: Raleigh",
+			resolveOptions: decrypt,
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_multiline": {
+			inputTmpl:      "value: $ocm_encrypted:x7Ix9DQueY+gf08PM6VSVA==",
+			resolveOptions: decrypt,
+			expectedResult: "value: Hello\\nRaleigh",
+		},
+		"decrypt_ignores_nonbase64": {
+			inputTmpl:      "value: $ocm_encrypted:😱😱😱😱",
+			resolveOptions: decrypt,
+			expectedResult: `value: "$ocm_encrypted:\U0001F631\U0001F631\U0001F631\U0001F631"`,
+		},
+		"decryption_disabled": {
+			inputTmpl:      "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: encrypt, // decryptionEnabled defaults to false
+			expectedResult: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+		},
+		"encrypt_and_decrypt": {
+			inputTmpl: "value: '{{ \"Raleigh\" | protect }}'\nvalue2: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey: key, DecryptionEnabled: true, EncryptionEnabled: true, InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==\nvalue2: Raleigh",
+		},
+		"protect_not_enabled": {
+			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
+			resolveOptions: ResolveOptions{EncryptionConfig: EncryptionConfig{AESKey: key, InitializationVector: iv}},
+			expectedErr:    ErrProtectNotEnabled,
+		},
+		"protect_not_enabled2": {
+			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
+			resolveOptions: decrypt,
+			expectedErr:    ErrProtectNotEnabled,
+		},
+		"encrypt_fails_illegalbase64": {
+			inputTmpl:      "value: $ocm_encrypted:==========",
+			resolveOptions: decrypt,
+			expectedErr:    ErrInvalidB64OfEncrypted,
+		},
+		"encrypt_fails_invalidpaddinglength": {
+			inputTmpl:      "value: $ocm_encrypted:mXIueuA3HvfBeobZZ0LdzA==",
+			resolveOptions: decrypt,
+			expectedErr:    ErrInvalidPKCS7Padding,
+		},
+		"encrypt_fails_invalidpaddingbytes": {
+			inputTmpl:      "value: $ocm_encrypted:/X3LA2SczM7eqOLhZKAZXg==",
+			resolveOptions: decrypt,
+			expectedErr:    ErrInvalidPKCS7Padding,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			doResolveTest(t, test)
+		})
+	}
+}
+
+// protectWithEmbeddedIV encrypts the value like the protect template function but in the embedded IV format.
+func protectWithEmbeddedIV(t *testing.T, key []byte, iv []byte, value string) string {
+	t.Helper()
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	paddedValue := pkcs7Pad([]byte(value), block.BlockSize())
+	encryptedValue := make([]byte, len(paddedValue))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encryptedValue, paddedValue)
+
+	payload := append([]byte{embeddedIVVersion}, iv...)
+	payload = append(payload, encryptedValue...)
+
+	return protectedPrefix + base64.StdEncoding.EncodeToString(payload)
+}
+
+func TestProcessEncryptedStrsEmbeddedIV(t *testing.T) {
+	t.Parallel()
+
+	keyBytesSize := 256 / 8
+	key := bytes.Repeat([]byte{byte('A')}, keyBytesSize)
+	otherKey := bytes.Repeat([]byte{byte('B')}, keyBytesSize)
+	iv := bytes.Repeat([]byte{byte('I')}, IVSize)
+	embeddedIV := bytes.Repeat([]byte{byte('E')}, IVSize)
+	otherEmbeddedIV := bytes.Repeat([]byte{byte('F')}, IVSize)
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	options := &ResolveOptions{
+		EncryptionConfig: EncryptionConfig{
+			AESKey: key, DecryptionEnabled: true, InitializationVector: iv,
+		},
+	}
+
+	legacy, err := resolver.protect(options, "Raleigh")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// Find a legacy value whose first byte is the embedded IV version to ensure it isn't mistaken for the new format
+	var (
+		legacyVersionByte          string
+		legacyVersionBytePlaintext string
+	)
+
+	for i := 0; legacyVersionByte == ""; i++ {
+		plaintext := fmt.Sprintf("value-%d", i)
+
+		encrypted, err := resolver.protect(options, plaintext)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, protectedPrefix))
+		if decoded[0] == embeddedIVVersion {
+			legacyVersionByte = encrypted
+			legacyVersionBytePlaintext = plaintext
+		}
+	}
+
+	// Values whose length is a multiple of 3 bytes have no base64 padding to delimit them, which are 16n+1 byte
+	// values with an embedded IV where n is 2 more than a multiple of 3 and 48 byte legacy values
+	unpaddedEmbedded := protectWithEmbeddedIV(t, key, embeddedIV, "Durham")
+
+	unpaddedLegacy, err := resolver.protect(options, "a legacy value of 32 characters!")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if strings.HasSuffix(unpaddedEmbedded, "=") || strings.HasSuffix(unpaddedLegacy, "=") {
+		t.Fatalf("expected %s and %s to not have base64 padding", unpaddedEmbedded, unpaddedLegacy)
+	}
+
+	testcases := map[string]struct {
+		input          string
+		aesKeyFallback []byte
+		expectedResult string
+		expectedErr    error
+	}{
+		"unpadded_embedded_followed_by_base64": {
+			input:          "https://" + unpaddedEmbedded + "/db",
+			expectedResult: "https://Durham/db",
+		},
+		"unpadded_legacy_followed_by_base64": {
+			input:          unpaddedLegacy + "/db+replica1",
+			expectedResult: "a legacy value of 32 characters!/db+replica1",
+		},
+		"unpadded_followed_by_padded": {
+			input:          unpaddedEmbedded + "/" + legacy,
+			expectedResult: "Durham/Raleigh",
+		},
+		"mixed": {
+			input: "legacy: " + legacy + "\n" +
+				"embedded: " + protectWithEmbeddedIV(t, key, embeddedIV, "Durham") + "\n" +
+				"other: " + protectWithEmbeddedIV(t, key, otherEmbeddedIV, "Cary") + "\n" +
+				"configured: " + protectWithEmbeddedIV(t, key, iv, "Apex") + "\n" +
+				"version-byte: " + legacyVersionByte,
+			expectedResult: "legacy: Raleigh\nembedded: Durham\nother: Cary\nconfigured: Apex\n" +
+				"version-byte: " + legacyVersionBytePlaintext,
+		},
+		"mixed_in_one_value": {
+			input:          legacy + "," + protectWithEmbeddedIV(t, key, embeddedIV, "a longer value than one block"),
+			expectedResult: "Raleigh,a longer value than one block",
+		},
+		"embedded_fallback_key": {
+			input:          protectWithEmbeddedIV(t, otherKey, embeddedIV, "Durham") + "," + legacy,
+			aesKeyFallback: otherKey,
+			expectedResult: "Durham,Raleigh",
+		},
+		"unsupported_version": {
+			input:       protectedPrefix + base64.StdEncoding.EncodeToString(append([]byte{2}, make([]byte, 32)...)),
+			expectedErr: ErrInvalidEncryptedFormat,
+		},
+		"too_short": {
+			input:       protectedPrefix + base64.StdEncoding.EncodeToString(append([]byte{1}, make([]byte, 16)...)),
+			expectedErr: ErrInvalidEncryptedFormat,
+		},
+		"invalid_length": {
+			input:       protectedPrefix + base64.StdEncoding.EncodeToString(make([]byte, 20)),
+			expectedErr: ErrInvalidEncryptedFormat,
+		},
+		"embedded_wrong_key": {
+			input:       protectWithEmbeddedIV(t, otherKey, embeddedIV, "Durham"),
+			expectedErr: ErrInvalidPKCS7Padding,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			testOptions := *options
+			testOptions.AESKeyFallback = test.aesKeyFallback
+
+			result, err := resolver.processEncryptedStrs(&testOptions, &TemplateResult{}, test.input)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if result != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateMaskEncryptedForDisplay(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{PreserveComments: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	options := &ResolveOptions{
+		EncryptionConfig: EncryptionConfig{
+			AESKey:               bytes.Repeat([]byte{byte('A')}, 256/8),
+			EncryptionEnabled:    true,
+			InitializationVector: bytes.Repeat([]byte{byte('I')}, IVSize),
+		},
+		InputIsYAML:             true,
+		MaskEncryptedForDisplay: true,
+	}
+
+	tmpl := "# A preview\nvalue: '{{ \"Raleigh\" | protect }}'\n" +
+		"embedded: 'password=$ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==;'\nplain: value\n" +
+		"unpadded: '" + protectWithEmbeddedIV(t, options.AESKey, options.InitializationVector, "Durham") + "/db'\n"
+
+	tmplResult, err := resolver.ResolveTemplate([]byte(tmpl), nil, options)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expectedJSON := `{"embedded":"password=$ocm_encrypted:\u003cmasked\u003e;","plain":"value",` +
+		`"unpadded":"$ocm_encrypted:\u003cmasked\u003e/db","value":"$ocm_encrypted:\u003cmasked\u003e"}`
+	if string(tmplResult.ResolvedJSON) != expectedJSON {
+		t.Fatalf("expected : %s , got : %s", expectedJSON, tmplResult.ResolvedJSON)
+	}
+
+	expectedYAML := "# A preview\nvalue: '$ocm_encrypted:<masked>'\nembedded: 'password=$ocm_encrypted:<masked>;'\n" +
+		"plain: value\nunpadded: '$ocm_encrypted:<masked>/db'\n"
+	if string(tmplResult.ResolvedYAML) != expectedYAML {
+		t.Fatalf("expected : %s , got : %s", expectedYAML, tmplResult.ResolvedYAML)
+	}
+
+	if !tmplResult.EncryptedValuesMasked {
+		t.Fatal("expected EncryptedValuesMasked to be true")
+	}
+
+	tmplResult, err = resolver.ResolveTemplate([]byte("plain: value\n"), nil, options)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if tmplResult.EncryptedValuesMasked {
+		t.Fatal("expected EncryptedValuesMasked to be false when there are no encrypted values")
+	}
+}
+
+func TestResolveTemplateCollectMetrics(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := []byte("{{/* the second lookup is cached */}}\n" +
+		"a: '{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}'\n" +
+		"b: '{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}'\n" +
+		"c: '{{ \"value\" | upper }}'\n")
+
+	tmplResult, err := resolver.ResolveTemplate(tmpl, nil, &ResolveOptions{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if tmplResult.Metrics != nil {
+		t.Fatalf("expected no metrics without CollectMetrics but got %+v", tmplResult.Metrics)
+	}
+
+	tmplResult, err = resolver.ResolveTemplate(tmpl, nil, &ResolveOptions{InputIsYAML: true, CollectMetrics: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := TemplateMetrics{
+		Actions:     4,
+		APIQueries:  2,
+		OutputBytes: len(`{"a":"3","b":"3","c":"VALUE"}`),
+		CachingUsed: false,
+	}
+
+	if tmplResult.Metrics == nil || *tmplResult.Metrics != expected {
+		t.Fatalf("expected metrics: %+v, got: %+v", expected, tmplResult.Metrics)
+	}
+}
+
+func TestResolveTemplateCollectFunctionStats(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := []byte("a: '{{ fromSecret \"app\" \"app-secret\" \"password\" }}'\n" +
+		"b: '{{ fromSecret \"app\" \"app-secret\" \"password\" | base64dec }}'\n" +
+		"c: '{{ list \"x\" \"y\" | join \",\" | upper }}'\n" +
+		"d: '{{ \"3\" | toInt }}'\n")
+
+	tmplResult, err := resolver.ResolveTemplate(tmpl, nil, &ResolveOptions{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if tmplResult.FunctionCalls != nil {
+		t.Fatalf("expected no function calls without CollectFunctionStats but got %v", tmplResult.FunctionCalls)
+	}
+
+	tmplResult, err = resolver.ResolveTemplate(
+		tmpl, nil, &ResolveOptions{InputIsYAML: true, CollectFunctionStats: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := map[string]int{"fromSecret": 2, "base64dec": 1, "list": 1, "join": 1, "upper": 1, "toInt": 1}
+	if !reflect.DeepEqual(tmplResult.FunctionCalls, expected) {
+		t.Fatalf("expected function calls: %v, got: %v", expected, tmplResult.FunctionCalls)
+	}
+
+	expectedJSON := `{"a":"cGFzc3dvcmQ=","b":"password","c":"X,Y","d":3}`
+	if string(tmplResult.ResolvedJSON) != expectedJSON {
+		t.Fatalf("expected : %s , got : %s", expectedJSON, tmplResult.ResolvedJSON)
+	}
+}
+
+func TestResolveTemplateMissingKeyPlaceholder(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: no-value
+  namespace: app
+data:
+  key: <no value>
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := []byte("present: '{{ .Labels.present }}'\nmissing: '{{ .Labels.missing }}'\n" +
+		"object: '{{ (lookup \"v1\" \"ConfigMap\" \"app\" \"not-found\").data.key }}'\n" +
+		"nested: '{{ if true }}{{ $value := .Labels.missing }}{{ $value }}{{ end }}'\n" +
+		"literal: <no value>\n" +
+		"data: '{{ fromConfigMap \"app\" \"no-value\" \"key\" }}'\n")
+	ctx := struct{ Labels map[string]string }{Labels: map[string]string{"present": "value"}}
+
+	testcases := map[string]struct {
+		placeholder string
+		expected    string
+	}{
+		"unset": {
+			expected: `{"data":"\u003cno value\u003e","literal":"\u003cno value\u003e",` +
+				`"missing":"\u003cno value\u003e","nested":"\u003cno value\u003e","object":"\u003cno value\u003e",` +
+				`"present":"value"}`,
+		},
+		"custom": {
+			placeholder: "UNSET",
+			expected: `{"data":"\u003cno value\u003e","literal":"\u003cno value\u003e","missing":"UNSET",` +
+				`"nested":"UNSET","object":"UNSET","present":"value"}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolverFromSnapshot(objects, Config{MissingKeyPlaceholder: test.placeholder})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			tmplResult, err := resolver.ResolveTemplate(tmpl, ctx, &ResolveOptions{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestMissingKeyPlaceholderInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, placeholder := range []string{"'", `"`, `\`, "# unset", "key: value", "un\nset"} {
+		_, err := NewResolverFromSnapshot(nil, Config{MissingKeyPlaceholder: placeholder})
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("expected an ErrInvalidInput error for %q, got : %v", placeholder, err)
+		}
+	}
+}
+
+func TestResolveTemplateWithOriginal(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(snapshotBundle))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl        string
+		inputIsYAML      bool
+		expectedOriginal string
+		expectedResolved string
+		expectedErr      string
+	}{
+		"json": {
+			inputTmpl:        `{"replicas": "{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}", "count": 1}`,
+			expectedOriginal: `{"count":1,"replicas":"{{ fromConfigMap \"app\" \"app-config\" \"replicas\" }}"}`,
+			expectedResolved: `{"count":1,"replicas":"3"}`,
+		},
+		"yaml": {
+			inputTmpl:        "# c\nreplicas: '{{ fromConfigMap \"app\" \"app-config\" \"replicas\" | toInt }}'\n",
+			inputIsYAML:      true,
+			expectedOriginal: `{"replicas":"{{ fromConfigMap \"app\" \"app-config\" \"replicas\" | toInt }}"}`,
+			expectedResolved: `{"replicas":3}`,
+		},
+		"yaml_invalid_before_resolution": {
+			inputTmpl:   "list:\n{{- range until 2 }}\n- item\n{{- end }}\n",
+			inputIsYAML: true,
+			expectedErr: "failed to convert the original template to JSON",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplateWithOriginal(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: test.inputIsYAML},
+			)
+			if err != nil {
+				if test.expectedErr == "" {
+					t.Fatalf(err.Error())
+				}
+
+				if !strings.HasPrefix(err.Error(), test.expectedErr) {
+					t.Fatalf("expected err: %s got err: %s", test.expectedErr, err)
+				}
+
+				return
+			} else if test.expectedErr != "" {
+				t.Fatalf("An error was expected but not returned %s", test.expectedErr)
+			}
+
+			if string(result.OriginalJSON) != test.expectedOriginal {
+				t.Fatalf("expected : %s , got : %s", test.expectedOriginal, result.OriginalJSON)
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResolved {
+				t.Fatalf("expected : %s , got : %s", test.expectedResolved, result.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateEnvFunction(t *testing.T) {
+	t.Setenv("GTU_TEST_ENV", "from-env")
+
+	tmpl := []byte(`value: '{{ env "GTU_TEST_ENV" }}'` + "\n" + `unset: '{{ env "GTU_TEST_ENV_UNSET" }}'`)
+
+	disabledResolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = disabledResolver.ResolveTemplate(tmpl, nil, &ResolveOptions{InputIsYAML: true})
+	if !errors.Is(err, ErrEnvNotEnabled) {
+		t.Fatalf("expected err: %s got err: %v", ErrEnvNotEnabled, err)
+	}
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{AllowEnvFunction: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplResult, err := resolver.ResolveTemplate(tmpl, nil, &ResolveOptions{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"unset":"","value":"from-env"}`
+	if string(tmplResult.ResolvedJSON) != expected {
+		t.Fatalf("expected : %s , got : %s", expected, tmplResult.ResolvedJSON)
+	}
+}
+
+func TestResolveTemplateWrapInList(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{PreserveComments: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl    string
+		expectedJSON string
+		expectedYAML string
+	}{
+		"array": {
+			inputTmpl: "# The ConfigMaps\n{{- range $i := until 2 }}\n- apiVersion: v1\n  kind: ConfigMap\n" +
+				"  metadata:\n    name: cm-{{ $i }}\n{{- end }}\n",
+			expectedJSON: `{"apiVersion":"v1","items":[` +
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm-0"}},` +
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm-1"}}],"kind":"List"}`,
+			expectedYAML: "apiVersion: v1\nkind: List\nitems:\n  # The ConfigMaps\n" +
+				"  - apiVersion: v1\n    kind: ConfigMap\n    metadata:\n      name: cm-0\n" +
+				"  - apiVersion: v1\n    kind: ConfigMap\n    metadata:\n      name: cm-1\n",
+		},
+		"empty": {
+			inputTmpl:    "{{- range $i := until 0 }}\n- name: cm-{{ $i }}\n{{- end }}\n",
+			expectedJSON: `{"apiVersion":"v1","items":[],"kind":"List"}`,
+			expectedYAML: "apiVersion: v1\nkind: List\nitems: []\n",
+		},
+		"object": {
+			inputTmpl:    "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: '{{ \"cm\" }}'\n",
+			expectedJSON: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm"}}`,
+			expectedYAML: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: 'cm'\n",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{InputIsYAML: true, WrapInList: true}
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedJSON {
+				t.Fatalf("expected : %s , got : %s", test.expectedJSON, tmplResult.ResolvedJSON)
+			}
+
+			if string(tmplResult.ResolvedYAML) != test.expectedYAML {
+				t.Fatalf("expected : %q , got : %q", test.expectedYAML, tmplResult.ResolvedYAML)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateRejectSecretInConfigMap(t *testing.T) {
+	t.Parallel()
+
+	secret := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "app-secret", "namespace": "app"},
+		"data":       map[string]interface{}{"password": "cGFzc3dvcmQ="},
+	}}
+
+	resolver, err := NewResolverFromSnapshot([]unstructured.Unstructured{secret}, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	configMapTmpl := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app-config\ndata:\n" +
+		"  password: '{{ fromSecret \"app\" \"app-secret\" \"password\" | base64dec }}'\n"
+
+	testcases := map[string]struct {
+		inputTmpl   string
+		reject      bool
+		expectedErr bool
+	}{
+		"configmap": {
+			inputTmpl:   configMapTmpl,
+			reject:      true,
+			expectedErr: true,
+		},
+		"configmap_objectDefinition": {
+			inputTmpl: "object-templates:\n- objectDefinition:\n    apiVersion: v1\n    kind: ConfigMap\n" +
+				"    data:\n      password: '{{ fromSecret \"app\" \"app-secret\" \"password\" }}'\n",
+			reject:      true,
+			expectedErr: true,
+		},
+		"configmap_not_rejected": {
+			inputTmpl: configMapTmpl,
+		},
+		"configmap_without_sensitive_data": {
+			inputTmpl: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app-config\ndata:\n" +
+				"  password: '{{ \"not-secret\" }}'\n",
+			reject: true,
+		},
+		"secret": {
+			inputTmpl: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: copy\ndata:\n" +
+				"  password: '{{ fromSecret \"app\" \"app-secret\" \"password\" }}'\n",
+			reject: true,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{InputIsYAML: true, RejectSecretInConfigMap: test.reject}
+
+			_, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if test.expectedErr {
+				if !errors.Is(err, ErrSensitiveDataInConfigMap) {
+					t.Fatalf("expected an ErrSensitiveDataInConfigMap error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
 		})
 	}
 }
 
-func TestResolveTemplateWithCrypto(t *testing.T) {
+func TestResolveTemplateValidateMetadata(t *testing.T) {
 	t.Parallel()
 
-	// Generate a 256 bit for AES-256. It can't be random so that the test results are deterministic.
-	keyBytesSize := 256 / 8
-	key := bytes.Repeat([]byte{byte('A')}, keyBytesSize)
-	otherKey := bytes.Repeat([]byte{byte('B')}, keyBytesSize)
-	iv := bytes.Repeat([]byte{byte('I')}, IVSize)
-
-	encrypt := ResolveOptions{
-		EncryptionConfig: EncryptionConfig{
-			AESKey:               key,
-			EncryptionEnabled:    true,
-			InitializationVector: iv,
-		},
-	}
-
-	decrypt := ResolveOptions{
-		EncryptionConfig: EncryptionConfig{
-			AESKey:               key,
-			DecryptionEnabled:    true,
-			InitializationVector: iv,
-		},
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
 	}
 
-	testcases := map[string]resolveTestCase{
-		"encrypt_protect": {
-			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
-			resolveOptions: encrypt,
-			expectedResult: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
-		},
-		"encrypt_protect_empty": {
-			inputTmpl:      `value: '{{ "" | protect }}'`,
-			resolveOptions: encrypt,
-			expectedResult: `value: ""`,
-		},
-		"encrypt_fromSecret": {
-			inputTmpl:      `data: '{{ fromSecret "testns" "testsecret" "secretkey1" }}'`,
-			resolveOptions: encrypt,
-			expectedResult: "data: $ocm_encrypted:c6PNhsEfbM9NRUqeJ+HbcECCyVdFnRbLdd+n8r1fS9M=",
-		},
-		"encrypt_copySecretData": {
-			inputTmpl:      `data: '{{ copySecretData "testns" "testsecret" }}'`,
-			resolveOptions: encrypt,
-			expectedResult: "data:\n  secretkey1: $ocm_encrypted:c6PNhsEfbM9NRUqeJ+HbcECCyVdFnRbLdd+n8r1fS9M=\n" +
-				"  secretkey2: $ocm_encrypted:VlXOhKuKGoHimHAYlQ2xz5EBw7mriqtt7fEP5ShP5cw=",
-		},
-		"decrypt": {
-			inputTmpl:      "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
-			resolveOptions: decrypt,
-			expectedResult: "value: Raleigh",
-		},
-		"decrypt_fallback_unused": {
-			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
-			resolveOptions: ResolveOptions{
-				EncryptionConfig: EncryptionConfig{
-					AESKey: key, AESKeyFallback: otherKey, DecryptionEnabled: true, InitializationVector: iv,
-				},
-			},
-			expectedResult: "value: Raleigh",
-		},
-		"decrypt_fallback": {
-			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
-			resolveOptions: ResolveOptions{
-				EncryptionConfig: EncryptionConfig{
-					AESKey: otherKey, AESKeyFallback: key, DecryptionEnabled: true, InitializationVector: iv,
-				},
-			},
-			expectedResult: "value: Raleigh",
-		},
-		"decryptionConcurrency": {
-			inputTmpl: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==\n" +
-				"value2: $ocm_encrypted:rBaGZbpT4WOXZzFI+XBrgg==\n" +
-				"value3: $ocm_encrypted:rcKUPnLe4rejwXzsm2/g/w==",
-			resolveOptions: ResolveOptions{
-				EncryptionConfig: EncryptionConfig{
-					AESKey: key, DecryptionConcurrency: 5, DecryptionEnabled: true, InitializationVector: iv,
-				},
-			},
-			expectedResult: "value: Raleigh\nvalue2: Raleigh2\nvalue3: Raleigh3",
-		},
-		"nothing_to_decrypt": {
-			inputTmpl:      "value: Raleigh",
-			resolveOptions: decrypt,
-			expectedResult: "value: Raleigh",
-		},
-		"decrypt_multiline": {
-			inputTmpl:      "value: $ocm_encrypted:x7Ix9DQueY+gf08PM6VSVA==",
-			resolveOptions: decrypt,
-			expectedResult: "value: Hello\\nRaleigh",
-		},
-		"decrypt_ignores_nonbase64": {
-			inputTmpl:      "value: $ocm_encrypted:😱😱😱😱",
-			resolveOptions: decrypt,
-			expectedResult: `value: "$ocm_encrypted:\U0001F631\U0001F631\U0001F631\U0001F631"`,
-		},
-		"decryption_disabled": {
-			inputTmpl:      "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
-			resolveOptions: encrypt, // decryptionEnabled defaults to false
-			expectedResult: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
-		},
-		"encrypt_and_decrypt": {
-			inputTmpl: "value: '{{ \"Raleigh\" | protect }}'\nvalue2: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
-			resolveOptions: ResolveOptions{
-				EncryptionConfig: EncryptionConfig{
-					AESKey: key, DecryptionEnabled: true, EncryptionEnabled: true, InitializationVector: iv,
-				},
-			},
-			expectedResult: "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==\nvalue2: Raleigh",
-		},
-		"protect_not_enabled": {
-			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
-			resolveOptions: ResolveOptions{EncryptionConfig: EncryptionConfig{AESKey: key, InitializationVector: iv}},
-			expectedErr:    ErrProtectNotEnabled,
+	testcases := map[string]struct {
+		inputTmpl   string
+		expectedErr string
+	}{
+		"valid": {
+			inputTmpl: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  labels:\n" +
+				"    app.kubernetes.io/name: '{{ \"my-app\" }}'\n  annotations:\n    note: '{{ \"a/b c\" }}'\n",
 		},
-		"protect_not_enabled2": {
-			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
-			resolveOptions: decrypt,
-			expectedErr:    ErrProtectNotEnabled,
+		"label_value_with_slash": {
+			inputTmpl: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  labels:\n" +
+				"    version: '{{ \"release/1.0\" }}'\n",
+			expectedErr: `the ConfigMap app: metadata.labels: Invalid value: "release/1.0"`,
 		},
-		"encrypt_fails_illegalbase64": {
-			inputTmpl:      "value: $ocm_encrypted:==========",
-			resolveOptions: decrypt,
-			expectedErr:    ErrInvalidB64OfEncrypted,
+		"label_value_too_long": {
+			inputTmpl: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  labels:\n" +
+				"    name: '{{ printf \"%064d\" 1 }}'\n",
+			expectedErr: "must be no more than 63 characters",
 		},
-		"encrypt_fails_invalidpaddinglength": {
-			inputTmpl:      "value: $ocm_encrypted:mXIueuA3HvfBeobZZ0LdzA==",
-			resolveOptions: decrypt,
-			expectedErr:    ErrInvalidPKCS7Padding,
+		"invalid_keys_objectDefinition": {
+			inputTmpl: "object-templates:\n- objectDefinition:\n    apiVersion: v1\n    kind: Pod\n" +
+				"    metadata:\n      name: web\n      labels:\n        '{{ \"bad key\" }}': value\n" +
+				"      annotations:\n        '{{ \"-bad\" }}': value\n",
+			expectedErr: `the Pod web: metadata.annotations: Invalid value: "-bad"`,
 		},
-		"encrypt_fails_invalidpaddingbytes": {
-			inputTmpl:      "value: $ocm_encrypted:/X3LA2SczM7eqOLhZKAZXg==",
-			resolveOptions: decrypt,
-			expectedErr:    ErrInvalidPKCS7Padding,
+		"not_strings": {
+			inputTmpl: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  labels:\n" +
+				"    replicas: '{{ \"3\" | toInt }}'\n",
+			expectedErr: "the ConfigMap app: metadata.labels: must be a map of strings",
 		},
 	}
 
@@ -1070,7 +3125,20 @@ func TestResolveTemplateWithCrypto(t *testing.T) {
 		t.Run(testName, func(t *testing.T) {
 			t.Parallel()
 
-			doResolveTest(t, test)
+			options := &ResolveOptions{InputIsYAML: true, ValidateMetadata: true}
+
+			_, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if test.expectedErr != "" {
+				if !errors.Is(err, ErrInvalidMetadata) || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an ErrInvalidMetadata error containing %q, got : %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
 		})
 	}
 }
@@ -1116,6 +3184,7 @@ func TestUsesEncryption(t *testing.T) {
 		{" I am a sample unencrypted template ", "", "", false},
 		{" I am a {{ sample }}  unencrypted template ", "{{", "}}", false},
 		{" I am a {{ fromSecret test-secret }}  encrypted template ", "{{", "}}", true},
+		{" I am a {{ fromSecretFirst test-ns (list test-secret) }}  encrypted template ", "{{", "}}", true},
 		{" I am a {{ test-secret | protect }}  encrypted template ", "{{", "}}", true},
 		{`{"msg: "I am a {{ sample }} unencrypted template"}`, "{{", "}}", false},
 		{`{"msg: "I am a {{ fromSecret test-secret }}  encrypted template"}`, "{{", "}}", true},
@@ -1214,6 +3283,31 @@ func TestProcessForDataTypes(t *testing.T) {
 			hubConfig,
 			`key : {{hub "1" | toBool hub}}`,
 		},
+		{
+			`key : '{{- "1" | toInt -}}'`,
+			config,
+			`key : {{ "1" | toInt }}`,
+		},
+		{
+			`key : '{{- "1" | toBool }}'`,
+			config,
+			`key : {{ "1" | toBool }}`,
+		},
+		{
+			`key : '{{ "[a, b]" | toLiteral -}}'`,
+			config,
+			`key : {{ "[a, b]" | toLiteral }}`,
+		},
+		{
+			`key : '{{hub- "1" | toInt -hub}}'`,
+			hubConfig,
+			`key : {{hub "1" | toInt hub}}`,
+		},
+		{
+			`key : '{{- if true }}{{- "1" | toInt -}}{{ end -}}'`,
+			config,
+			`key : {{ if true }}{{- "1" | toInt -}}{{ end }}`,
+		},
 		{
 			`key : '{{ if fromClusterClaim "something"}} 1 {{ else }} {{ 2 | toInt }} {{ end }} }}'`,
 			config,
@@ -1251,6 +3345,58 @@ func TestProcessForDataTypes(t *testing.T) {
 	}
 }
 
+func TestResolveTemplateChompMarkers(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+	}{
+		"toInt": {
+			inputTmpl:      "before: a\nkey: '{{- \"1\" | toInt -}}'\nafter: b\n",
+			expectedResult: `{"after":"b","before":"a","key":1}`,
+		},
+		"toBool": {
+			inputTmpl:      "before: a\nkey: '{{- \"true\" | toBool -}}'\nafter: b\n",
+			expectedResult: `{"after":"b","before":"a","key":true}`,
+		},
+		"toLiteral": {
+			inputTmpl:      "before: a\nkey: '{{- \"[x, y]\" | toLiteral -}}'\nafter: b\n",
+			expectedResult: `{"after":"b","before":"a","key":["x","y"]}`,
+		},
+		"autoindent": {
+			inputTmpl:      "before: a\nkey: |\n  {{- \"x\\ny\" | autoindent -}}\nafter: b\n",
+			expectedResult: `{"after":"b","before":"a","key":"x\ny\n"}`,
+		},
+		"autoindent_right_marker": {
+			inputTmpl:      "before: a\nkey: |\n  {{ \"x\\ny\" | autoindent -}}\nafter: b\n",
+			expectedResult: `{"after":"b","before":"a","key":"x\ny\n"}`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplResult, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(tmplResult.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, tmplResult.ResolvedJSON)
+			}
+		})
+	}
+}
+
 func TestGetNamespace(t *testing.T) {
 	t.Parallel()
 
@@ -1379,3 +3525,166 @@ spec:
 	// Templates rock!
 	// Y2x1c3RlcjAwMDE=
 }
+
+func TestResolveTemplateAllowedContextFields(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	type ClusterContext struct {
+		ClusterName string
+	}
+
+	ctx := struct {
+		ClusterContext
+		ObjectName     string
+		PolicyMetadata map[string]interface{}
+	}{
+		ClusterContext: ClusterContext{ClusterName: "cluster1"},
+		ObjectName:     "my-obj",
+		PolicyMetadata: map[string]interface{}{"name": "my-policy"},
+	}
+
+	testcases := map[string]struct {
+		inputTmpl     string
+		allowedFields []string
+		expected      string
+	}{
+		"not_set": {
+			inputTmpl: "value: '{{ .ObjectName }}-{{ .PolicyMetadata.name }}'",
+			expected:  "value: my-obj-my-policy\n",
+		},
+		"allowed": {
+			inputTmpl:     "value: '{{ .ObjectName }}-{{ .ClusterName }}'",
+			allowedFields: []string{"ObjectName", "ClusterName"},
+			expected:      "value: my-obj-cluster1\n",
+		},
+		"filtered": {
+			inputTmpl:     "value: '{{ .ObjectName }}-{{ .PolicyMetadata }}'",
+			allowedFields: []string{"ObjectName"},
+			expected:      "value: my-obj-<no value>\n",
+		},
+		"filtered_default": {
+			inputTmpl:     "value: '{{ .PolicyMetadata.name | default \"hidden\" }}'",
+			allowedFields: []string{"ObjectName"},
+			expected:      "value: hidden\n",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &ResolveOptions{InputIsYAML: true, AllowedContextFields: test.allowedFields}
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), ctx, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(val) != test.expected {
+				t.Fatalf("expected : %q , got : %q", test.expected, string(val))
+			}
+		})
+	}
+}
+
+func TestResolveTemplatePostResolve(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolverFromSnapshot(nil, Config{PreserveComments: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	addLabel := func(value string) func(map[string]interface{}) (map[string]interface{}, error) {
+		return func(resolved map[string]interface{}) (map[string]interface{}, error) {
+			err := unstructured.SetNestedField(resolved, value, "metadata", "labels", "managed-by")
+
+			return resolved, err
+		}
+	}
+
+	inputTmpl := "# A comment\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: '{{ \"app\" }}'\n"
+
+	testcases := map[string]struct {
+		inputTmpl    string
+		postResolve  func(map[string]interface{}) (map[string]interface{}, error)
+		options      ResolveOptions
+		expectedYAML string
+		expectedErr  error
+	}{
+		"add_label": {
+			inputTmpl:   inputTmpl,
+			postResolve: addLabel("policy-framework"),
+			expectedYAML: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  labels:\n    managed-by: policy-framework\n" +
+				"  name: app\n",
+		},
+		"validated_after": {
+			inputTmpl:   inputTmpl,
+			postResolve: addLabel("not/valid"),
+			options:     ResolveOptions{ValidateMetadata: true},
+			expectedErr: ErrInvalidMetadata,
+		},
+		"function_error": {
+			inputTmpl: inputTmpl,
+			postResolve: func(map[string]interface{}) (map[string]interface{}, error) {
+				return nil, errors.New("some error")
+			},
+			expectedErr: ErrPostResolveFailed,
+		},
+		"not_an_object": {
+			inputTmpl:   "- '{{ \"a\" }}'\n",
+			postResolve: addLabel("policy-framework"),
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := test.options
+			options.InputIsYAML = true
+			options.PostResolve = test.postResolve
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &options)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected an error wrapping %v, got : %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(val) != test.expectedYAML {
+				t.Fatalf("expected : %q , got : %q", test.expectedYAML, string(val))
+			}
+
+			if string(result.ResolvedYAML) != test.expectedYAML {
+				t.Fatalf("expected ResolvedYAML : %q , got : %q", test.expectedYAML, string(result.ResolvedYAML))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"time"
+)
+
+// resolveNow returns ResolveOptions.Now, or the current time if it's not set.
+func resolveNow(options *ResolveOptions) time.Time {
+	if options.Now.IsZero() {
+		return time.Now()
+	}
+
+	return options.Now
+}
+
+// parseTimestamp parses the input RFC 3339 timestamp, such as the metadata.creationTimestamp of an object.
+func parseTimestamp(timestamp string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: the timestamp %q is not in the RFC 3339 format: %w",
+			ErrInvalidInput, timestamp, err)
+	}
+
+	return parsed, nil
+}
+
+func ageSinceHelper(options *ResolveOptions) func(string) (string, error) {
+	now := resolveNow(options)
+
+	return func(timestamp string) (string, error) {
+		return ageSince(now, timestamp)
+	}
+}
+
+// ageSince returns the duration from the RFC 3339 timestamp until now, truncated to seconds, such as "72h0m0s". The
+// duration is negative if the timestamp is in the future.
+func ageSince(now time.Time, timestamp string) (string, error) {
+	parsed, err := parseTimestamp(timestamp)
+	if err != nil {
+		return "", err
+	}
+
+	return now.Sub(parsed).Truncate(time.Second).String(), nil
+}
+
+func olderThanHelper(options *ResolveOptions) func(string, string) (bool, error) {
+	now := resolveNow(options)
+
+	return func(timestamp string, duration string) (bool, error) {
+		return olderThan(now, timestamp, duration)
+	}
+}
+
+// olderThan returns true if more than the duration, such as "720h", has passed from the RFC 3339 timestamp until now.
+// The duration is in the format of time.ParseDuration.
+func olderThan(now time.Time, timestamp string, duration string) (bool, error) {
+	parsed, err := parseTimestamp(timestamp)
+	if err != nil {
+		return false, err
+	}
+
+	parsedDuration, err := time.ParseDuration(duration)
+	if err != nil {
+		return false, fmt.Errorf("%w: the duration %q is invalid: %w", ErrInvalidInput, duration, err)
+	}
+
+	return now.Sub(parsed) > parsedDuration, nil
+}
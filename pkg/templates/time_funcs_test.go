@@ -0,0 +1,130 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func TestAgeSince(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		timestamp   string
+		expected    string
+		expectedErr bool
+	}{
+		"days":              {"2024-03-07T12:00:00Z", "72h0m0s", false},
+		"truncated_seconds": {"2024-03-10T11:59:29.75Z", "30s", false},
+		"time_zone":         {"2024-03-10T12:00:00+02:00", "2h0m0s", false},
+		"future":            {"2024-03-10T13:30:00Z", "-1h30m0s", false},
+		"invalid":           {"2024-03-10", "", true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := ageSince(testNow, test.timestamp)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %s , got : %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestOlderThan(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		timestamp   string
+		duration    string
+		expected    bool
+		expectedErr bool
+	}{
+		"older":            {"2024-01-01T00:00:00Z", "720h", true, false},
+		"newer":            {"2024-03-01T00:00:00Z", "720h", false, false},
+		"exactly":          {"2024-03-09T12:00:00Z", "24h", false, false},
+		"future":           {"2024-03-11T00:00:00Z", "0s", false, false},
+		"invalid_time":     {"yesterday", "24h", false, true},
+		"invalid_duration": {"2024-03-09T12:00:00Z", "1d", false, true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := olderThan(testNow, test.timestamp, test.duration)
+			if test.expectedErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("expected an ErrInvalidInput error, got : %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %v , got : %v", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateTimeFunctions(t *testing.T) {
+	t.Parallel()
+
+	objects, err := ParseResourceBundle([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cert-info
+  namespace: default
+data:
+  issued: "2024-01-10T12:00:00Z"
+`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewResolverFromSnapshot(objects, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := "age: '{{ fromConfigMap \"default\" \"cert-info\" \"issued\" | ageSince }}'\n" +
+		"rotate: '{{ olderThan (fromConfigMap \"default\" \"cert-info\" \"issued\") \"1440h\" }}'\n"
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{InputIsYAML: true, Now: testNow})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"age":"1440h0m0s","rotate":"false"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("expected : %s , got : %s", expected, string(result.ResolvedJSON))
+	}
+}